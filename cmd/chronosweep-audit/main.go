@@ -4,15 +4,22 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"go.opentelemetry.io/otel"
+
 	"github.com/joshsymonds/chronosweep/internal/audit"
+	"github.com/joshsymonds/chronosweep/internal/cache"
 	"github.com/joshsymonds/chronosweep/internal/gmailctl"
 	"github.com/joshsymonds/chronosweep/internal/rate"
 	"github.com/joshsymonds/chronosweep/internal/runtime"
+	"github.com/joshsymonds/chronosweep/internal/snapshot"
+	"github.com/joshsymonds/chronosweep/internal/telemetry"
 )
 
 const hoursPerDay = 24
@@ -23,17 +30,42 @@ type auditConfig struct {
 	topN           int
 	jsonOut        string
 	pageSize       int
-	rps            int
+	workers        int
+	rps            float64
+	burst          int
 	gmailctlCfg    string
 	gmailctlBinary string
+	noCache        bool
+	cacheDir       string
+	cacheMaxAge    time.Duration
+	cacheLabels    string
+	snapshot       string
+	mbox           string
+	maildir        string
+	imap           runtime.IMAPConfig
+	auth           runtime.AuthConfig
+	log            runtime.LogConfig
+	trace          telemetry.Config
+	metadataOnly   bool
+	labels         string
+	snapshotOut    string
+	sensitivity    audit.SensitivityPolicy
+	rulesOut       string
+	rulesDiff      string
+	format         string
+	out            string
 }
 
 func main() {
 	cfg := parseFlags()
-	if err := run(cfg); err != nil {
-		runtime.DefaultLogger().Error("chronosweep-audit failed", "error", err)
-		os.Exit(1)
+	logger, err := cfg.log.Logger(os.Stderr, "chronosweep-audit")
+	if err == nil {
+		err = run(cfg, logger)
+	}
+	if err != nil {
+		logger.Error("chronosweep-audit failed", "error", err)
 	}
+	os.Exit(runtime.WriteStatus(os.Stderr, "chronosweep-audit", err))
 }
 
 func parseFlags() auditConfig {
@@ -42,9 +74,55 @@ func parseFlags() auditConfig {
 	topN := flag.Int("top", 30, "number of top senders/lists to display")
 	jsonOut := flag.String("json", "", "write JSON report to path")
 	pageSize := flag.Int("page-size", 500, "Gmail list page size (<=500)")
-	rps := flag.Int("rps", 4, "max requests per second")
+	workers := flag.Int("workers", 4, "concurrent metadata fetches sharing the -rps budget")
+	rps := flag.Float64("rps", 4, "max requests per second (fractional values allowed; 0 disables)")
+	burst := flag.Int("burst", 0, "max requests allowed in a burst (0 derives from -rps)")
 	gmailctlConfig := flag.String("gmailctl-config", "", "path to gmailctl config (optional)")
 	gmailctlBin := flag.String("gmailctl-binary", "gmailctl", "gmailctl binary to invoke")
+	noCache := flag.Bool("no-cache", false, "disable the on-disk metadata cache")
+	cacheDir := flag.String("cache-dir", cache.DefaultDir(), "directory holding the metadata cache")
+	cacheMaxAge := flag.Duration("cache-max-age", cache.DefaultMaxAge, "evict cache entries unused for this long")
+	cacheLabels := flag.String("cache-labels", cache.DefaultLabelMode, "label handling for cached messages (refresh or stale)")
+	snapshotIn := flag.String("snapshot", "", "serve Gmail reads from a metadata snapshot file (offline)")
+	snapshotOut := flag.String("snapshot-out", "", "write the collected metadata snapshot (JSONL) to path")
+	mbox := flag.String("mbox", "", "read messages from a local mbox export (e.g. Google Takeout) instead of Gmail")
+	maildir := flag.String("maildir", "", "read messages from a local Maildir instead of Gmail")
+	imapCfg := runtime.RegisterIMAPFlags(flag.CommandLine)
+	authCfg := runtime.RegisterAuthFlags(flag.CommandLine)
+	authCfg.RegisterScopeFlag(flag.CommandLine)
+	logCfg := runtime.RegisterLogFlags(flag.CommandLine)
+	traceCfg := telemetry.RegisterFlags(flag.CommandLine)
+	metadataOnly := flag.Bool(
+		"metadata-only",
+		false,
+		"list messages by label without search queries, so a gmail.metadata-scoped token suffices",
+	)
+	labels := flag.String(
+		"labels",
+		"",
+		"comma separated labels every -metadata-only message must carry (names or system IDs such as INBOX)",
+	)
+	sensitiveKeywords := flag.String("sensitive-keywords", "", "comma separated keywords marking a domain sensitive")
+	sensitiveDomains := flag.String("sensitive-domains", "", "comma separated domains always kept in the inbox")
+	sensitiveAllow := flag.String("sensitive-allow", "", "comma separated domains never treated as sensitive")
+	sensitiveLabel := flag.String("sensitive-label", "sensitive", "label applied by keep-in-inbox suggestions")
+	rulesOut := flag.String("rules-out", "", "write the suggested rules as a gmailctl Jsonnet library to path")
+	rulesDiff := flag.String(
+		"rules-diff",
+		"",
+		"print a unified diff adding the suggested rules library to this config.jsonnet instead of the report",
+	)
+	format := flag.String(
+		"format",
+		audit.FormatHuman,
+		"comma separated report formats: human, json, markdown, csv (one file per section) or html",
+	)
+	out := flag.String("out", "", "directory to write the -format reports to (stdout when empty and one file)")
+	sensitiveBuiltin := flag.Bool(
+		"sensitive-builtin",
+		true,
+		"treat built-in bank, payroll, government and legal domains as sensitive",
+	)
 	flag.Parse()
 
 	return auditConfig{
@@ -53,30 +131,119 @@ func parseFlags() auditConfig {
 		topN:           *topN,
 		jsonOut:        *jsonOut,
 		pageSize:       *pageSize,
+		workers:        *workers,
 		rps:            *rps,
+		burst:          *burst,
 		gmailctlCfg:    *gmailctlConfig,
 		gmailctlBinary: *gmailctlBin,
+		noCache:        *noCache,
+		cacheDir:       *cacheDir,
+		cacheMaxAge:    *cacheMaxAge,
+		cacheLabels:    *cacheLabels,
+		snapshot:       *snapshotIn,
+		mbox:           *mbox,
+		maildir:        *maildir,
+		imap:           *imapCfg,
+		auth:           *authCfg,
+		log:            *logCfg,
+		trace:          *traceCfg,
+		metadataOnly:   *metadataOnly,
+		labels:         *labels,
+		snapshotOut:    *snapshotOut,
+		rulesOut:       *rulesOut,
+		rulesDiff:      *rulesDiff,
+		format:         *format,
+		out:            *out,
+		sensitivity: audit.SensitivityPolicy{
+			Keywords:  splitLabels(*sensitiveKeywords),
+			Domains:   splitLabels(*sensitiveDomains),
+			Allow:     splitLabels(*sensitiveAllow),
+			Label:     *sensitiveLabel,
+			NoBuiltin: !*sensitiveBuiltin,
+		},
 	}
 }
 
-func run(cfg auditConfig) error {
+// offline reports whether the run reads from a local source, which needs no rate limiting.
+func (cfg auditConfig) offline() bool {
+	return countSet(cfg.snapshot, cfg.mbox, cfg.maildir) > 0
+}
+
+// account names the mailbox in log records; local sources have none.
+func (cfg auditConfig) account() string {
+	switch {
+	case cfg.offline():
+		return ""
+	case cfg.imap.Enabled():
+		return cfg.imap.Account()
+	}
+	return cfg.auth.Provider(cfg.cfgDir).Account()
+}
+
+// outputs resolves -format, refusing several files without -out before any mailbox work starts.
+func (cfg auditConfig) outputs() ([]audit.Output, error) {
+	outputs, err := audit.ParseFormats(cfg.format)
+	if err != nil {
+		return nil, runtime.InvalidConfigf("parse -format: %w", err)
+	}
+	if cfg.out == "" && len(outputs) > 1 {
+		return nil, runtime.InvalidConfigf(
+			"-format %s writes %d files; set -out to a directory",
+			cfg.format,
+			len(outputs),
+		)
+	}
+	return outputs, nil
+}
+
+func run(cfg auditConfig, logger *slog.Logger) (err error) {
+	outputs, err := cfg.outputs()
+	if err != nil {
+		return err
+	}
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	logger := runtime.DefaultLogger()
-	client, err := runtime.NewGmailClient(ctx, cfg.cfgDir, runtime.ScopeReadonly)
+	flushTraces, err := cfg.trace.Setup(ctx, "chronosweep-audit", os.Stderr)
 	if err != nil {
-		return fmt.Errorf("create gmail client: %w", err)
+		return err
 	}
+	defer func() {
+		if flushErr := flushTraces(); flushErr != nil {
+			logger.WarnContext(ctx, "flush traces", slog.String("error", flushErr.Error()))
+		}
+	}()
+	ctx, span := otel.Tracer(telemetry.TracerName).Start(ctx, "chronosweep-audit")
+	defer func() {
+		telemetry.RecordError(span, err)
+		span.End()
+	}()
 
-	var (
-		limiter rate.Limiter
-		bucket  *rate.TokenBucket
-	)
-	if cfg.rps > 0 {
-		bucket = rate.NewTokenBucket(cfg.rps)
+	if account := cfg.account(); account != "" {
+		logger = logger.With(slog.String("account", account))
+	}
+	client, clock, closeClient, err := runtime.OpenClient(ctx, cfg.openOptions(logger))
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+	startedAt := clock()
+	var recorder *snapshot.Recorder
+	if cfg.snapshotOut != "" {
+		recorder = snapshot.NewRecorder(client)
+		client = recorder
+	}
+
+	var limiter rate.Limiter
+	if cfg.rps > 0 && !cfg.offline() {
+		bucket, bucketErr := rate.NewTokenBucket(cfg.rps, cfg.burst)
+		if bucketErr != nil {
+			return runtime.InvalidConfigf("create rate limiter: %w", bucketErr)
+		}
 		limiter = bucket
-		defer bucket.Stop()
+		defer func() {
+			logger.InfoContext(ctx, "rate limiter stats", slog.Any("wait", bucket.Stats()))
+		}()
 	}
 
 	cfgPath := cfg.gmailctlCfg
@@ -89,14 +256,50 @@ func run(cfg auditConfig) error {
 	}
 
 	svc := audit.NewService(client, limiter, logger, loader)
+	svc.Clock = clock
 	window := time.Duration(cfg.days) * hoursPerDay * time.Hour
-	rep, err := svc.Run(ctx, audit.Options{Window: window, TopN: cfg.topN, PageSize: cfg.pageSize})
+	rep, err := svc.Run(ctx, audit.Options{
+		Window:       window,
+		TopN:         cfg.topN,
+		PageSize:     cfg.pageSize,
+		Workers:      cfg.workers,
+		MetadataOnly: cfg.metadataOnly,
+		Labels:       splitLabels(cfg.labels),
+		Sensitivity:  cfg.sensitivity,
+	})
 	if err != nil {
 		return fmt.Errorf("run audit: %w", err)
 	}
+	if recorder != nil {
+		if snapErr := snapshot.WriteFile(cfg.snapshotOut, recorder.Snapshot(startedAt)); snapErr != nil {
+			return fmt.Errorf("write snapshot: %w", snapErr)
+		}
+	}
 
-	if printErr := audit.PrintHuman(rep, os.Stdout); printErr != nil {
-		return fmt.Errorf("print report: %w", printErr)
+	return writeOutputs(cfg, outputs, rep)
+}
+
+// writeOutputs renders the -format reports, to stdout or into -out, and writes the requested files.
+// -rules-diff takes over stdout, so the reports are then only written when -out is set.
+func writeOutputs(cfg auditConfig, outputs []audit.Output, rep audit.Report) error {
+	if cfg.rulesDiff != "" {
+		patch, err := audit.RulesPatch(rep, cfg.rulesDiff)
+		if err != nil {
+			return fmt.Errorf("diff rules: %w", err)
+		}
+		if _, err := fmt.Fprint(os.Stdout, patch); err != nil {
+			return fmt.Errorf("print rules diff: %w", err)
+		}
+	}
+	if cfg.rulesDiff == "" || cfg.out != "" {
+		if err := audit.WriteReports(rep, outputs, cfg.out, os.Stdout); err != nil {
+			return fmt.Errorf("write report: %w", err)
+		}
+	}
+	if cfg.rulesOut != "" {
+		if err := audit.WriteRulesLibrary(rep, cfg.rulesOut); err != nil {
+			return fmt.Errorf("write rules: %w", err)
+		}
 	}
 	if cfg.jsonOut == "" {
 		return nil
@@ -106,3 +309,47 @@ func run(cfg auditConfig) error {
 	}
 	return nil
 }
+
+// openOptions describes the mailbox the run reads. Live clients are read-only.
+func (cfg auditConfig) openOptions(logger *slog.Logger) runtime.OpenOptions {
+	scope := runtime.ScopeReadonly
+	if cfg.metadataOnly {
+		scope = runtime.ScopeMetadata
+	}
+	opts := runtime.OpenOptions{
+		Snapshot:  cfg.snapshot,
+		Mbox:      cfg.mbox,
+		Maildir:   cfg.maildir,
+		IMAP:      cfg.imap,
+		ConfigDir: cfg.cfgDir,
+		Auth:      cfg.auth,
+		Scope:     scope,
+		Log:       cfg.log,
+		Trace:     cfg.trace,
+		Logger:    logger,
+	}
+	if !cfg.noCache {
+		opts.Cache = &runtime.CacheOptions{Dir: cfg.cacheDir, MaxAge: cfg.cacheMaxAge, Labels: cfg.cacheLabels}
+	}
+	return opts
+}
+
+func splitLabels(raw string) []string {
+	var labels []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			labels = append(labels, part)
+		}
+	}
+	return labels
+}
+
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,2 @@
+// Package main exposes the chronosweep-auth CLI entrypoint.
+package main
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/auth"
+	"github.com/joshsymonds/chronosweep/internal/runtime"
+)
+
+const usage = "usage: chronosweep-auth login|status|revoke [flags]"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		runtime.DefaultLogger().Error("chronosweep-auth failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	switch args[0] {
+	case "login":
+		return login(ctx, args[1:])
+	case "status":
+		return status(args[1:])
+	case "revoke":
+		return revoke(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q; %s", args[0], usage)
+	}
+}
+
+// login runs the loopback OAuth flow and stores the token under the account Gmail reports for it.
+func login(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	authCfg := runtime.RegisterAuthFlags(fs)
+	credentials := fs.String(
+		"credentials",
+		"",
+		"OAuth client credentials JSON (Desktop app) to copy into the token store; reuses the stored copy if empty",
+	)
+	scopeName := fs.String("scope", "modify", "scope to request: readonly, modify or metadata")
+	listen := fs.String("listen", auth.DefaultListenAddr, "loopback address that receives the OAuth redirect")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parse login flags: %w", err)
+	}
+	scope, err := runtime.ParseScope(*scopeName)
+	if err != nil {
+		return fmt.Errorf("parse scope: %w", err)
+	}
+	store := authCfg.Store()
+	if *credentials != "" {
+		raw, readErr := os.ReadFile(filepath.Clean(*credentials))
+		if readErr != nil {
+			return fmt.Errorf("read client credentials: %w", readErr)
+		}
+		if saveErr := store.SaveCredentials(raw); saveErr != nil {
+			return fmt.Errorf("store client credentials: %w", saveErr)
+		}
+	}
+	oauthCfg, err := store.Config(scope.URL())
+	if err != nil {
+		return fmt.Errorf("load client credentials (pass -credentials on first login): %w", err)
+	}
+
+	tok, err := auth.Login(ctx, oauthCfg, auth.LoginOptions{
+		Addr: *listen,
+		Prompt: func(authURL string) {
+			_, _ = fmt.Fprintf(os.Stderr, "Open this URL in a browser to authorize chronosweep:\n\n  %s\n\n", authURL)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("oauth login: %w", err)
+	}
+	if tok.RefreshToken == "" {
+		return errors.New("google returned no refresh token; revoke chronosweep's access in your account and retry")
+	}
+	email, err := runtime.AccountEmail(ctx, oauthCfg.TokenSource(ctx, tok))
+	if err != nil {
+		return fmt.Errorf("identify account: %w", err)
+	}
+	if authCfg.Account != "" && !strings.EqualFold(authCfg.Account, email) {
+		return fmt.Errorf("authorized as %s, but -account asked for %s", email, authCfg.Account)
+	}
+	if saveErr := store.Save(email, scope.String(), tok); saveErr != nil {
+		return fmt.Errorf("store token: %w", saveErr)
+	}
+	summary := fmt.Sprintf("stored %s token for %s in %s", scope, email, store.Dir())
+	if os.Getenv(runtime.TokenPassphraseEnv) != "" {
+		summary += " (encrypted)"
+	}
+	if _, writeErr := os.Stdout.WriteString(summary + "\n"); writeErr != nil {
+		return fmt.Errorf("write summary: %w", writeErr)
+	}
+	return nil
+}
+
+// status lists stored tokens with their expiry and whether they can be refreshed.
+func status(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	authCfg := runtime.RegisterAuthFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parse status flags: %w", err)
+	}
+	store := authCfg.Store()
+	entries, err := accountEntries(store, authCfg.Account, "")
+	if err != nil {
+		return err
+	}
+	var builder strings.Builder
+	if len(entries) == 0 {
+		fmt.Fprintf(&builder, "no stored tokens in %s\n", store.Dir())
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(&builder, "%-30s %-16s %s\n", entry.Account, entry.Scope, describeToken(store, entry))
+	}
+	if _, writeErr := os.Stdout.WriteString(builder.String()); writeErr != nil {
+		return fmt.Errorf("write status: %w", writeErr)
+	}
+	return nil
+}
+
+// revoke invalidates an account's tokens at Google and removes them from the store.
+func revoke(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("revoke", flag.ExitOnError)
+	authCfg := runtime.RegisterAuthFlags(fs)
+	scopeName := fs.String("scope", "", "only revoke this scope (readonly, modify or metadata); default all")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parse revoke flags: %w", err)
+	}
+	if authCfg.Account == "" {
+		return errors.New("revoke requires -account")
+	}
+	scopeFilter := ""
+	if *scopeName != "" {
+		scope, err := runtime.ParseScope(*scopeName)
+		if err != nil {
+			return fmt.Errorf("parse scope: %w", err)
+		}
+		scopeFilter = scope.String()
+	}
+	store := authCfg.Store()
+	entries, err := accountEntries(store, authCfg.Account, scopeFilter)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("%w for %s", auth.ErrNoToken, authCfg.Account)
+	}
+	for _, entry := range entries {
+		tok, loadErr := store.Load(entry.Account, entry.Scope)
+		if loadErr != nil {
+			return fmt.Errorf("load %s token: %w", entry.Scope, loadErr)
+		}
+		if revokeErr := auth.Revoke(ctx, http.DefaultClient, auth.RevokeURL, tok); revokeErr != nil {
+			return fmt.Errorf("revoke %s token: %w", entry.Scope, revokeErr)
+		}
+		if deleteErr := store.Delete(entry.Account, entry.Scope); deleteErr != nil {
+			return fmt.Errorf("delete %s token: %w", entry.Scope, deleteErr)
+		}
+		line := fmt.Sprintf("revoked %s token for %s\n", entry.Scope, entry.Account)
+		if _, writeErr := os.Stdout.WriteString(line); writeErr != nil {
+			return fmt.Errorf("write summary: %w", writeErr)
+		}
+	}
+	return nil
+}
+
+func accountEntries(store *auth.Store, account, scope string) ([]auth.Entry, error) {
+	all, err := store.List()
+	if err != nil {
+		return nil, fmt.Errorf("list stored tokens: %w", err)
+	}
+	var entries []auth.Entry
+	for _, entry := range all {
+		if account != "" && !strings.EqualFold(entry.Account, account) {
+			continue
+		}
+		if scope != "" && entry.Scope != scope {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func describeToken(store *auth.Store, entry auth.Entry) string {
+	tok, err := store.Load(entry.Account, entry.Scope)
+	if errors.Is(err, auth.ErrLocked) {
+		return "encrypted (set $" + runtime.TokenPassphraseEnv + " to inspect)"
+	}
+	if err != nil {
+		return "unreadable: " + err.Error()
+	}
+	parts := make([]string, 0, 3)
+	switch {
+	case tok.Expiry.IsZero():
+		parts = append(parts, "no expiry")
+	case tok.Expiry.Before(time.Now()):
+		parts = append(parts, "access token expired "+tok.Expiry.Format(time.RFC3339))
+	default:
+		parts = append(parts, "access token valid until "+tok.Expiry.Format(time.RFC3339))
+	}
+	if tok.RefreshToken == "" {
+		parts = append(parts, "no refresh token")
+	} else {
+		parts = append(parts, "refreshable")
+	}
+	if entry.Encrypted {
+		parts = append(parts, "encrypted")
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,2 @@
+// Package main exposes the chronosweep-doctor CLI entrypoint.
+package main
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/doctor"
+	"github.com/joshsymonds/chronosweep/internal/runtime"
+)
+
+type doctorConfig struct {
+	cfgDir  string
+	scope   string
+	labels  string
+	timeout time.Duration
+	auth    runtime.AuthConfig
+}
+
+func main() {
+	cfg := parseFlags()
+	err := run(cfg)
+	if err != nil {
+		runtime.DefaultLogger().Error("chronosweep-doctor failed", "error", err)
+	}
+	os.Exit(runtime.WriteStatus(os.Stderr, "chronosweep-doctor", err))
+}
+
+func parseFlags() doctorConfig {
+	cfgDir := flag.String("config", os.ExpandEnv("$HOME/.gmailctl"), "gmailctl auth directory")
+	scope := flag.String("scope", "modify", "scope the checked command needs: modify (sweep), readonly or metadata")
+	labels := flag.String(
+		"labels",
+		"",
+		"comma separated labels that must exist (e.g. the sweep's -label and -grace-map labels)",
+	)
+	timeout := flag.Duration("timeout", 30*time.Second, "give up on network checks after this long")
+	authCfg := runtime.RegisterAuthFlags(flag.CommandLine)
+	authCfg.RegisterScopeFlag(flag.CommandLine)
+	flag.Parse()
+
+	return doctorConfig{
+		cfgDir:  *cfgDir,
+		scope:   *scope,
+		labels:  *labels,
+		timeout: *timeout,
+		auth:    *authCfg,
+	}
+}
+
+func run(cfg doctorConfig) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, cfg.timeout)
+	defer cancelTimeout()
+
+	scope, err := runtime.ParseScope(cfg.scope)
+	if err != nil {
+		return runtime.InvalidConfigf("parse scope: %w", err)
+	}
+	rep := doctor.NewService().Run(ctx, doctor.Options{
+		Provider: cfg.auth.Provider(cfg.cfgDir),
+		Scope:    scope,
+		Labels:   splitLabels(cfg.labels),
+		// A read-only command run with -allow-broader-scope accepts a modify token.
+		AllowBroaderScope: cfg.auth.AllowBroaderScope,
+	})
+	if printErr := doctor.PrintHuman(rep, os.Stdout); printErr != nil {
+		return fmt.Errorf("print report: %w", printErr)
+	}
+	return rep.Err()
+}
+
+func splitLabels(raw string) []string {
+	var labels []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			labels = append(labels, part)
+		}
+	}
+	return labels
+}
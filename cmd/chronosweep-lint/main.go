@@ -4,15 +4,22 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"go.opentelemetry.io/otel"
+
 	"github.com/joshsymonds/chronosweep/internal/audit"
+	"github.com/joshsymonds/chronosweep/internal/cache"
+	"github.com/joshsymonds/chronosweep/internal/gmail"
 	"github.com/joshsymonds/chronosweep/internal/gmailctl"
 	"github.com/joshsymonds/chronosweep/internal/rate"
 	"github.com/joshsymonds/chronosweep/internal/runtime"
+	"github.com/joshsymonds/chronosweep/internal/telemetry"
 )
 
 const (
@@ -24,17 +31,36 @@ type lintConfig struct {
 	days           int
 	failOn         string
 	pageSize       int
-	rps            int
+	workers        int
+	rps            float64
+	burst          int
 	gmailctlCfg    string
 	gmailctlBinary string
+	noCache        bool
+	cacheDir       string
+	cacheMaxAge    time.Duration
+	cacheLabels    string
+	snapshot       string
+	mbox           string
+	maildir        string
+	imap           runtime.IMAPConfig
+	auth           runtime.AuthConfig
+	log            runtime.LogConfig
+	trace          telemetry.Config
+	metadataOnly   bool
+	labels         string
 }
 
 func main() {
 	cfg := parseLintFlags()
-	if err := run(cfg); err != nil {
-		runtime.DefaultLogger().Error("chronosweep-lint failed", "error", err)
-		os.Exit(1)
+	logger, err := cfg.log.Logger(os.Stderr, "chronosweep-lint")
+	if err == nil {
+		err = run(cfg, logger)
+	}
+	if err != nil {
+		logger.Error("chronosweep-lint failed", "error", err)
 	}
+	os.Exit(runtime.WriteStatus(os.Stderr, "chronosweep-lint", err))
 }
 
 func parseLintFlags() lintConfig {
@@ -42,9 +68,33 @@ func parseLintFlags() lintConfig {
 	days := flag.Int("days", 30, "lookback window in days")
 	failOn := flag.String("fail-on", "dead,conflict,missing-label", "comma separated lint failures")
 	pageSize := flag.Int("page-size", 500, "Gmail list page size (<=500)")
-	rps := flag.Int("rps", 4, "max requests per second")
+	workers := flag.Int("workers", 4, "concurrent metadata fetches sharing the -rps budget")
+	rps := flag.Float64("rps", 4, "max requests per second (fractional values allowed; 0 disables)")
+	burst := flag.Int("burst", 0, "max requests allowed in a burst (0 derives from -rps)")
 	gmailctlConfig := flag.String("gmailctl-config", "", "path to gmailctl config (optional)")
 	gmailctlBin := flag.String("gmailctl-binary", "gmailctl", "gmailctl binary to invoke")
+	noCache := flag.Bool("no-cache", false, "disable the on-disk metadata cache")
+	cacheDir := flag.String("cache-dir", cache.DefaultDir(), "directory holding the metadata cache")
+	cacheMaxAge := flag.Duration("cache-max-age", cache.DefaultMaxAge, "evict cache entries unused for this long")
+	cacheLabels := flag.String("cache-labels", cache.DefaultLabelMode, "label handling for cached messages (refresh or stale)")
+	snapshotIn := flag.String("snapshot", "", "serve Gmail reads from a metadata snapshot file (offline)")
+	mbox := flag.String("mbox", "", "read messages from a local mbox export (e.g. Google Takeout) instead of Gmail")
+	maildir := flag.String("maildir", "", "read messages from a local Maildir instead of Gmail")
+	imapCfg := runtime.RegisterIMAPFlags(flag.CommandLine)
+	authCfg := runtime.RegisterAuthFlags(flag.CommandLine)
+	authCfg.RegisterScopeFlag(flag.CommandLine)
+	logCfg := runtime.RegisterLogFlags(flag.CommandLine)
+	traceCfg := telemetry.RegisterFlags(flag.CommandLine)
+	metadataOnly := flag.Bool(
+		"metadata-only",
+		false,
+		"list messages by label without search queries, so a gmail.metadata-scoped token suffices",
+	)
+	labels := flag.String(
+		"labels",
+		"",
+		"comma separated labels every -metadata-only message must carry (names or system IDs such as INBOX)",
+	)
 	flag.Parse()
 
 	return lintConfig{
@@ -52,30 +102,81 @@ func parseLintFlags() lintConfig {
 		days:           *days,
 		failOn:         *failOn,
 		pageSize:       *pageSize,
+		workers:        *workers,
 		rps:            *rps,
+		burst:          *burst,
 		gmailctlCfg:    *gmailctlConfig,
 		gmailctlBinary: *gmailctlBin,
+		noCache:        *noCache,
+		cacheDir:       *cacheDir,
+		cacheMaxAge:    *cacheMaxAge,
+		cacheLabels:    *cacheLabels,
+		snapshot:       *snapshotIn,
+		mbox:           *mbox,
+		maildir:        *maildir,
+		imap:           *imapCfg,
+		auth:           *authCfg,
+		log:            *logCfg,
+		trace:          *traceCfg,
+		metadataOnly:   *metadataOnly,
+		labels:         *labels,
 	}
 }
 
-func run(cfg lintConfig) error {
+// offline reports whether the run reads from a local source, which needs no rate limiting.
+func (cfg lintConfig) offline() bool {
+	return countSet(cfg.snapshot, cfg.mbox, cfg.maildir) > 0
+}
+
+// account names the mailbox in log records; local sources have none.
+func (cfg lintConfig) account() string {
+	switch {
+	case cfg.offline():
+		return ""
+	case cfg.imap.Enabled():
+		return cfg.imap.Account()
+	}
+	return cfg.auth.Provider(cfg.cfgDir).Account()
+}
+
+func run(cfg lintConfig, logger *slog.Logger) (err error) {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	logger := runtime.DefaultLogger()
-	client, err := runtime.NewGmailClient(ctx, cfg.cfgDir, runtime.ScopeReadonly)
+	flushTraces, err := cfg.trace.Setup(ctx, "chronosweep-lint", os.Stderr)
 	if err != nil {
-		return fmt.Errorf("create gmail client: %w", err)
+		return err
 	}
+	defer func() {
+		if flushErr := flushTraces(); flushErr != nil {
+			logger.WarnContext(ctx, "flush traces", slog.String("error", flushErr.Error()))
+		}
+	}()
+	ctx, span := otel.Tracer(telemetry.TracerName).Start(ctx, "chronosweep-lint")
+	defer func() {
+		telemetry.RecordError(span, err)
+		span.End()
+	}()
 
-	var (
-		limiter rate.Limiter
-		bucket  *rate.TokenBucket
-	)
-	if cfg.rps > 0 {
-		bucket = rate.NewTokenBucket(cfg.rps)
+	if account := cfg.account(); account != "" {
+		logger = logger.With(slog.String("account", account))
+	}
+	client, clock, closeClient, err := runtime.OpenClient(ctx, cfg.openOptions(logger))
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	var limiter rate.Limiter
+	if cfg.rps > 0 && !cfg.offline() {
+		bucket, bucketErr := rate.NewTokenBucket(cfg.rps, cfg.burst)
+		if bucketErr != nil {
+			return runtime.InvalidConfigf("create rate limiter: %w", bucketErr)
+		}
 		limiter = bucket
-		defer bucket.Stop()
+		defer func() {
+			logger.InfoContext(ctx, "rate limiter stats", slog.Any("wait", bucket.Stats()))
+		}()
 	}
 
 	cfgPath := cfg.gmailctlCfg
@@ -88,8 +189,16 @@ func run(cfg lintConfig) error {
 	}
 
 	svc := audit.NewService(client, limiter, logger, loader)
+	svc.Clock = clock
 	window := time.Duration(cfg.days) * hoursPerDayLint * time.Hour
-	rep, err := svc.RunLint(ctx, audit.Options{Window: window, TopN: 0, PageSize: cfg.pageSize})
+	rep, err := svc.RunLint(ctx, audit.Options{
+		Window:       window,
+		TopN:         0,
+		PageSize:     cfg.pageSize,
+		Workers:      cfg.workers,
+		MetadataOnly: cfg.metadataOnly,
+		Labels:       splitLabels(cfg.labels),
+	})
 	if err != nil {
 		return fmt.Errorf("run lint: %w", err)
 	}
@@ -102,7 +211,51 @@ func run(cfg lintConfig) error {
 	}
 	failTokens := audit.ParseFailOn(cfg.failOn)
 	if rep.ShouldFail(failTokens) {
-		return fmt.Errorf("lint failures matched: %s", cfg.failOn)
+		return gmail.Classify(gmail.ClassPolicyViolation, fmt.Errorf("lint failures matched: %s", cfg.failOn))
 	}
 	return nil
 }
+
+// openOptions describes the mailbox the run reads. Live clients are read-only.
+func (cfg lintConfig) openOptions(logger *slog.Logger) runtime.OpenOptions {
+	scope := runtime.ScopeReadonly
+	if cfg.metadataOnly {
+		scope = runtime.ScopeMetadata
+	}
+	opts := runtime.OpenOptions{
+		Snapshot:  cfg.snapshot,
+		Mbox:      cfg.mbox,
+		Maildir:   cfg.maildir,
+		IMAP:      cfg.imap,
+		ConfigDir: cfg.cfgDir,
+		Auth:      cfg.auth,
+		Scope:     scope,
+		Log:       cfg.log,
+		Trace:     cfg.trace,
+		Logger:    logger,
+	}
+	if !cfg.noCache {
+		opts.Cache = &runtime.CacheOptions{Dir: cfg.cacheDir, MaxAge: cfg.cacheMaxAge, Labels: cfg.cacheLabels}
+	}
+	return opts
+}
+
+func splitLabels(raw string) []string {
+	var labels []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			labels = append(labels, part)
+		}
+	}
+	return labels
+}
+
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -4,15 +4,19 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 	"time"
 
+	"go.opentelemetry.io/otel"
+
 	"github.com/joshsymonds/chronosweep/internal/rate"
 	"github.com/joshsymonds/chronosweep/internal/runtime"
 	"github.com/joshsymonds/chronosweep/internal/sweep"
+	"github.com/joshsymonds/chronosweep/internal/telemetry"
 )
 
 type sweepConfig struct {
@@ -23,17 +27,30 @@ type sweepConfig struct {
 	exclude       string
 	expiredLabel  string
 	pageSize      int
-	rps           int
+	workers       int
+	rps           float64
+	burst         int
 	dryRun        bool
 	pauseWeekends bool
+	snapshot      string
+	allowMissing  string
+	imap          runtime.IMAPConfig
+	auth          runtime.AuthConfig
+	labels        runtime.LabelStyle
+	log           runtime.LogConfig
+	trace         telemetry.Config
 }
 
 func main() {
 	cfg := parseSweepFlags()
-	if err := run(cfg); err != nil {
-		runtime.DefaultLogger().Error("chronosweep-sweep failed", "error", err)
-		os.Exit(1)
+	logger, err := cfg.log.Logger(os.Stderr, "chronosweep-sweep")
+	if err == nil {
+		err = run(cfg, logger)
+	}
+	if err != nil {
+		logger.Error("chronosweep-sweep failed", "error", err)
 	}
+	os.Exit(runtime.WriteStatus(os.Stderr, "chronosweep-sweep", err))
 }
 
 func parseSweepFlags() sweepConfig {
@@ -44,9 +61,22 @@ func parseSweepFlags() sweepConfig {
 	excludeFlag := flag.String("exclude-labels", "", "comma separated labels to protect")
 	expiredLabel := flag.String("expired-label", "auto-archived/expired", "label applied to swept mail")
 	pageSize := flag.Int("page-size", 500, "Gmail list page size (<=500)")
-	rps := flag.Int("rps", 4, "max requests per second")
+	workers := flag.Int("workers", 4, "concurrent label re-reads before each batch, sharing the -rps budget")
+	rps := flag.Float64("rps", 4, "max requests per second (fractional values allowed; 0 disables)")
+	burst := flag.Int("burst", 0, "max requests allowed in a burst (0 derives from -rps)")
 	dryRun := flag.Bool("dry-run", false, "log only; skip modifications")
 	pauseWeekends := flag.Bool("pause-weekends", false, "skip runs on Saturday/Sunday")
+	snapshotIn := flag.String("snapshot", "", "dry-run against a metadata snapshot file instead of Gmail")
+	allowMissing := flag.String(
+		"allow-missing-labels",
+		"",
+		"comma separated labels that may not exist yet; any other unknown label aborts the sweep",
+	)
+	imapCfg := runtime.RegisterIMAPFlags(flag.CommandLine)
+	authCfg := runtime.RegisterAuthFlags(flag.CommandLine)
+	labelStyle := runtime.RegisterLabelFlags(flag.CommandLine)
+	logCfg := runtime.RegisterLogFlags(flag.CommandLine)
+	traceCfg := telemetry.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
 	return sweepConfig{
@@ -57,49 +87,102 @@ func parseSweepFlags() sweepConfig {
 		exclude:       *excludeFlag,
 		expiredLabel:  *expiredLabel,
 		pageSize:      *pageSize,
+		workers:       *workers,
 		rps:           *rps,
+		burst:         *burst,
 		dryRun:        *dryRun,
 		pauseWeekends: *pauseWeekends,
+		snapshot:      *snapshotIn,
+		allowMissing:  *allowMissing,
+		imap:          *imapCfg,
+		auth:          *authCfg,
+		labels:        *labelStyle,
+		log:           *logCfg,
+		trace:         *traceCfg,
+	}
+}
+
+// offline reports whether the run reads from a local source, which needs no rate limiting.
+func (cfg sweepConfig) offline() bool {
+	return cfg.snapshot != ""
+}
+
+// account names the mailbox in log records; snapshots have none.
+func (cfg sweepConfig) account() string {
+	switch {
+	case cfg.offline():
+		return ""
+	case cfg.imap.Enabled():
+		return cfg.imap.Account()
 	}
+	return cfg.auth.Provider(cfg.cfgDir).Account()
 }
 
-func run(cfg sweepConfig) error {
+func run(cfg sweepConfig, logger *slog.Logger) (err error) {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	flushTraces, err := cfg.trace.Setup(ctx, "chronosweep-sweep", os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if flushErr := flushTraces(); flushErr != nil {
+			logger.WarnContext(ctx, "flush traces", slog.String("error", flushErr.Error()))
+		}
+	}()
+	ctx, span := otel.Tracer(telemetry.TracerName).Start(ctx, "chronosweep-sweep")
+	defer func() {
+		telemetry.RecordError(span, err)
+		span.End()
+	}()
+
 	overrides, err := sweep.ParseGraceMap(cfg.graceMap)
 	if err != nil {
-		return fmt.Errorf("parse grace map: %w", err)
+		return runtime.InvalidConfigf("parse grace map: %w", err)
 	}
 	exclude := splitList(cfg.exclude)
+	allowMissing := splitList(cfg.allowMissing)
 
-	client, err := runtime.NewGmailClient(ctx, cfg.cfgDir, runtime.ScopeModify)
+	if account := cfg.account(); account != "" {
+		logger = logger.With(slog.String("account", account))
+	}
+	// Snapshots cannot be modified, so they only preview a sweep.
+	if cfg.snapshot != "" && !cfg.dryRun {
+		return runtime.InvalidConfigf("-snapshot requires -dry-run")
+	}
+	client, clock, closeClient, err := runtime.OpenClient(ctx, cfg.openOptions(logger))
 	if err != nil {
-		return fmt.Errorf("create gmail client: %w", err)
+		return err
 	}
+	defer closeClient()
 
-	var (
-		limiter rate.Limiter
-		bucket  *rate.TokenBucket
-	)
-	if cfg.rps > 0 {
-		bucket = rate.NewTokenBucket(cfg.rps)
+	var limiter rate.Limiter
+	if cfg.rps > 0 && !cfg.offline() {
+		bucket, bucketErr := rate.NewTokenBucket(cfg.rps, cfg.burst)
+		if bucketErr != nil {
+			return runtime.InvalidConfigf("create rate limiter: %w", bucketErr)
+		}
 		limiter = bucket
-		defer bucket.Stop()
+		defer func() {
+			logger.InfoContext(ctx, "rate limiter stats", slog.Any("wait", bucket.Stats()))
+		}()
 	}
 
-	svc := sweep.NewService(client, limiter, runtime.DefaultLogger())
-	svc.Clock = time.Now
+	svc := sweep.NewService(client, limiter, logger)
+	svc.Clock = clock
 
 	spec := sweep.Spec{
-		Label:          cfg.label,
-		Grace:          cfg.grace,
-		DryRun:         cfg.dryRun,
-		PauseWeekends:  cfg.pauseWeekends,
-		GraceOverrides: overrides,
-		ExcludeLabels:  exclude,
-		ExpiredLabel:   cfg.expiredLabel,
-		PageSize:       cfg.pageSize,
+		Label:              cfg.label,
+		Grace:              cfg.grace,
+		DryRun:             cfg.dryRun,
+		PauseWeekends:      cfg.pauseWeekends,
+		GraceOverrides:     overrides,
+		ExcludeLabels:      exclude,
+		ExpiredLabel:       cfg.expiredLabel,
+		PageSize:           cfg.pageSize,
+		Workers:            cfg.workers,
+		AllowMissingLabels: allowMissing,
 	}
 
 	if runErr := svc.Run(ctx, spec); runErr != nil {
@@ -112,14 +195,16 @@ func run(cfg sweepConfig) error {
 
 	for lbl, dur := range overrides {
 		overrideSpec := sweep.Spec{
-			Label:          lbl,
-			Grace:          dur,
-			DryRun:         cfg.dryRun,
-			PauseWeekends:  cfg.pauseWeekends,
-			GraceOverrides: overrides,
-			ExcludeLabels:  exclude,
-			ExpiredLabel:   cfg.expiredLabel,
-			PageSize:       cfg.pageSize,
+			Label:              lbl,
+			Grace:              dur,
+			DryRun:             cfg.dryRun,
+			PauseWeekends:      cfg.pauseWeekends,
+			GraceOverrides:     overrides,
+			ExcludeLabels:      exclude,
+			ExpiredLabel:       cfg.expiredLabel,
+			PageSize:           cfg.pageSize,
+			Workers:            cfg.workers,
+			AllowMissingLabels: allowMissing,
 		}
 		if runErr := svc.Run(ctx, overrideSpec); runErr != nil {
 			return fmt.Errorf("run sweep override for %s: %w", lbl, runErr)
@@ -128,6 +213,21 @@ func run(cfg sweepConfig) error {
 	return nil
 }
 
+// openOptions describes the mailbox the sweep modifies.
+func (cfg sweepConfig) openOptions(logger *slog.Logger) runtime.OpenOptions {
+	return runtime.OpenOptions{
+		Snapshot:  cfg.snapshot,
+		IMAP:      cfg.imap,
+		ConfigDir: cfg.cfgDir,
+		Auth:      cfg.auth,
+		Scope:     runtime.ScopeModify,
+		Labels:    cfg.labels,
+		Log:       cfg.log,
+		Trace:     cfg.trace,
+		Logger:    logger,
+	}
+}
+
 func splitList(input string) []string {
 	if strings.TrimSpace(input) == "" {
 		return nil
@@ -0,0 +1,2 @@
+// Package main exposes the chronosweep-unsubscribe CLI entrypoint.
+package main
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/joshsymonds/chronosweep/internal/rate"
+	"github.com/joshsymonds/chronosweep/internal/runtime"
+	"github.com/joshsymonds/chronosweep/internal/telemetry"
+	"github.com/joshsymonds/chronosweep/internal/unsubscribe"
+)
+
+const hoursPerDay = 24
+
+type unsubscribeConfig struct {
+	cfgDir      string
+	lists       string
+	senders     string
+	days        int
+	ledger      string
+	dryRun      bool
+	verify      bool
+	verifyGrace time.Duration
+	timeout     time.Duration
+	rps         float64
+	burst       int
+	auth        runtime.AuthConfig
+	log         runtime.LogConfig
+	trace       telemetry.Config
+}
+
+func main() {
+	cfg := parseFlags()
+	logger, err := cfg.log.Logger(os.Stderr, "chronosweep-unsubscribe")
+	if err == nil {
+		err = run(cfg, logger)
+	}
+	if err != nil {
+		logger.Error("chronosweep-unsubscribe failed", "error", err)
+	}
+	os.Exit(runtime.WriteStatus(os.Stderr, "chronosweep-unsubscribe", err))
+}
+
+func parseFlags() unsubscribeConfig {
+	cfgDir := flag.String("config", os.ExpandEnv("$HOME/.gmailctl"), "gmailctl auth directory")
+	lists := flag.String("lists", "", "comma separated List-Ids to unsubscribe from")
+	senders := flag.String("senders", "", "comma separated sender domains to unsubscribe from")
+	days := flag.Int("days", 30, "look this many days back for a message carrying List-Unsubscribe")
+	ledger := flag.String("ledger", unsubscribe.DefaultLedgerPath(), "file recording unsubscribe attempts")
+	dryRun := flag.Bool("dry-run", false, "report what would be done without posting, drafting or recording")
+	verify := flag.Bool("verify", false, "check recorded attempts for mail received after -verify-grace")
+	verifyGrace := flag.Duration(
+		"verify-grace",
+		72*time.Hour,
+		"how long senders get to honour an unsubscribe before new mail counts against them",
+	)
+	timeout := flag.Duration("timeout", unsubscribe.DefaultTimeout, "timeout for each one-click POST")
+	rps := flag.Float64("rps", 4, "max Gmail requests per second (fractional values allowed; 0 disables)")
+	burst := flag.Int("burst", 0, "max requests allowed in a burst (0 derives from -rps)")
+	authCfg := runtime.RegisterAuthFlags(flag.CommandLine)
+	authCfg.RegisterScopeFlag(flag.CommandLine)
+	logCfg := runtime.RegisterLogFlags(flag.CommandLine)
+	traceCfg := telemetry.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	return unsubscribeConfig{
+		cfgDir:      *cfgDir,
+		lists:       *lists,
+		senders:     *senders,
+		days:        *days,
+		ledger:      *ledger,
+		dryRun:      *dryRun,
+		verify:      *verify,
+		verifyGrace: *verifyGrace,
+		timeout:     *timeout,
+		rps:         *rps,
+		burst:       *burst,
+		auth:        *authCfg,
+		log:         *logCfg,
+		trace:       *traceCfg,
+	}
+}
+
+// targets returns the selected lists and senders; unsubscribing is opt-in, so nothing is chosen for the
+// user.
+func (cfg unsubscribeConfig) targets() []unsubscribe.Target {
+	var targets []unsubscribe.Target
+	for _, id := range splitList(cfg.lists) {
+		targets = append(targets, unsubscribe.Target{Kind: unsubscribe.KindList, Value: strings.ToLower(id)})
+	}
+	for _, domain := range splitList(cfg.senders) {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "@"))
+		targets = append(targets, unsubscribe.Target{Kind: unsubscribe.KindSender, Value: domain})
+	}
+	return targets
+}
+
+func (cfg unsubscribeConfig) validate() error {
+	targets := len(cfg.targets())
+	switch {
+	case cfg.verify && targets > 0:
+		return runtime.InvalidConfigf("-verify checks the ledger; it cannot be combined with -lists or -senders")
+	case !cfg.verify && targets == 0:
+		return runtime.InvalidConfigf("select what to unsubscribe from with -lists or -senders")
+	case cfg.days <= 0:
+		return runtime.InvalidConfigf("-days must be positive")
+	case cfg.verifyGrace < 0:
+		return runtime.InvalidConfigf("-verify-grace must not be negative")
+	case strings.TrimSpace(cfg.ledger) == "":
+		return runtime.InvalidConfigf("-ledger must not be empty")
+	}
+	return nil
+}
+
+func run(cfg unsubscribeConfig, logger *slog.Logger) (err error) {
+	if validateErr := cfg.validate(); validateErr != nil {
+		return validateErr
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	flushTraces, err := cfg.trace.Setup(ctx, "chronosweep-unsubscribe", os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if flushErr := flushTraces(); flushErr != nil {
+			logger.WarnContext(ctx, "flush traces", slog.String("error", flushErr.Error()))
+		}
+	}()
+	ctx, span := otel.Tracer(telemetry.TracerName).Start(ctx, "chronosweep-unsubscribe")
+	defer func() {
+		telemetry.RecordError(span, err)
+		span.End()
+	}()
+
+	account := cfg.auth.Provider(cfg.cfgDir).Account()
+	if account != "" {
+		logger = logger.With(slog.String("account", account))
+	}
+	ledger, err := unsubscribe.LoadLedger(cfg.ledger)
+	if err != nil {
+		return fmt.Errorf("load ledger: %w", err)
+	}
+
+	// Drafting mailto unsubscribes needs gmail.modify; dry runs and verification only read.
+	scope := runtime.ScopeModify
+	if cfg.dryRun || cfg.verify {
+		scope = runtime.ScopeReadonly
+	}
+	client, _, closeClient, err := runtime.OpenClient(ctx, runtime.OpenOptions{
+		ConfigDir: cfg.cfgDir,
+		Auth:      cfg.auth,
+		Scope:     scope,
+		Log:       cfg.log,
+		Trace:     cfg.trace,
+		Logger:    logger,
+	})
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	var limiter rate.Limiter
+	if cfg.rps > 0 {
+		bucket, bucketErr := rate.NewTokenBucket(cfg.rps, cfg.burst)
+		if bucketErr != nil {
+			return runtime.InvalidConfigf("create rate limiter: %w", bucketErr)
+		}
+		limiter = bucket
+	}
+	poster := unsubscribe.NewPoster(&http.Client{Timeout: cfg.timeout})
+	svc := unsubscribe.NewService(client, poster, limiter, logger)
+
+	if cfg.verify {
+		return verify(ctx, cfg, svc, ledger, account)
+	}
+	attempts, err := svc.Unsubscribe(ctx, cfg.targets(), unsubscribe.Options{
+		Window:  time.Duration(cfg.days) * hoursPerDay * time.Hour,
+		DryRun:  cfg.dryRun,
+		Account: account,
+	})
+	// Record whatever was attempted before a failure, since those requests already went out.
+	if !cfg.dryRun && len(attempts) > 0 {
+		ledger.Attempts = append(ledger.Attempts, attempts...)
+		if saveErr := ledger.Save(cfg.ledger); saveErr != nil {
+			return fmt.Errorf("save ledger: %w", saveErr)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unsubscribe: %w", err)
+	}
+	return printAttempts(os.Stdout, attempts)
+}
+
+func verify(
+	ctx context.Context,
+	cfg unsubscribeConfig,
+	svc *unsubscribe.Service,
+	ledger *unsubscribe.Ledger,
+	account string,
+) error {
+	if err := svc.Verify(ctx, ledger, account, cfg.verifyGrace); err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	if err := ledger.Save(cfg.ledger); err != nil {
+		return fmt.Errorf("save ledger: %w", err)
+	}
+	var checked []unsubscribe.Attempt
+	for _, attempt := range ledger.Attempts {
+		if attempt.Verified != nil && attempt.Account == account {
+			checked = append(checked, attempt)
+		}
+	}
+	return printAttempts(os.Stdout, checked)
+}
+
+// printAttempts writes one line per attempt: target, mechanism, status and, once verified, the outcome.
+func printAttempts(w io.Writer, attempts []unsubscribe.Attempt) error {
+	var b strings.Builder
+	for _, attempt := range attempts {
+		mechanism := string(attempt.Mechanism)
+		if mechanism == "" {
+			mechanism = "-"
+		}
+		fmt.Fprintf(&b, "%-6s %-32s %-9s %-11s", attempt.Target.Kind, attempt.Target.Value, mechanism, attempt.Status)
+		switch {
+		case attempt.Verified != nil:
+			fmt.Fprintf(&b, " %s", attempt.Verified.Outcome)
+		case attempt.Status == unsubscribe.StatusManual:
+			fmt.Fprintf(&b, " visit %s", attempt.URI)
+		case attempt.Status == unsubscribe.StatusDrafted:
+			b.WriteString(" review and send the draft")
+		case attempt.Error != "":
+			fmt.Fprintf(&b, " %s", attempt.Error)
+		}
+		b.WriteString("\n")
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("print attempts: %w", err)
+	}
+	return nil
+}
+
+func splitList(input string) []string {
+	var out []string
+	for _, part := range strings.Split(input, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,387 @@
+package audit
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/unsubscribe"
+)
+
+const (
+	// sketchMinCapacity is the smallest number of keys a top-K sketch tracks. Counts are exact while a
+	// window has no more distinct senders or lists than the sketch holds.
+	sketchMinCapacity = 1024
+	// sketchFactor sizes the sketch relative to the requested top N.
+	sketchFactor = 50
+	// maxEvidenceSamples caps the message IDs kept as evidence per rule, conflict or skip reason.
+	maxEvidenceSamples = 5
+)
+
+// aggregator folds a stream of message pages into everything the report needs while holding state
+// bounded by the number of distinct labels, the sketch capacity and the number of rules, never by the
+// number of messages. The one exception is the set of threads the user replied in, which grows with
+// the number of replies sent in the window. Pages must be added in listing order for the output to be
+// deterministic.
+type aggregator struct {
+	total      int
+	senders    *topK
+	lists      *topK
+	coverage   map[string]int
+	classes    map[MessageClass]int
+	labelsByID map[gmail.LabelID]string
+	rules      *ruleAggregator
+	replied    map[string]struct{}
+	skipped    evidence
+	skipSample []SkippedMessage
+	stale      int
+}
+
+func newAggregator(topN int, labelsByID map[gmail.LabelID]string, rules []compiledRule) *aggregator {
+	capacity := max(sketchMinCapacity, topN*sketchFactor)
+	return &aggregator{
+		senders:    newTopK(capacity),
+		lists:      newTopK(capacity),
+		coverage:   map[string]int{},
+		classes:    map[MessageClass]int{},
+		labelsByID: labelsByID,
+		rules:      newRuleAggregator(rules),
+		replied:    map[string]struct{}{},
+	}
+}
+
+// add folds one page of messages into every statistic. The user's own sent mail counts towards the
+// totals but not the sender and list rankings; instead it marks its thread as replied. Gmail lists
+// newest first, so a reply is seen before the messages it answers and those are credited with it.
+func (a *aggregator) add(metas []gmail.MessageMeta) {
+	for _, meta := range metas {
+		a.total++
+		if meta.LabelsStale {
+			a.stale++
+		}
+		class := ClassifyMessage(meta)
+		a.classes[class]++
+		if isSent(meta) {
+			if meta.ThreadID != "" {
+				a.replied[meta.ThreadID] = struct{}{}
+			}
+		} else {
+			a.rank(meta, class)
+		}
+		for _, lid := range meta.LabelIDs {
+			if name, ok := a.labelsByID[lid]; ok {
+				a.coverage[name]++
+			}
+		}
+		a.rules.add(meta)
+	}
+}
+
+// markReplies records the threads of the user's sent messages without counting them, for label listings
+// whose own pages never include sent mail.
+func (a *aggregator) markReplies(metas []gmail.MessageMeta) {
+	for _, meta := range metas {
+		if meta.ThreadID != "" {
+			a.replied[meta.ThreadID] = struct{}{}
+		}
+	}
+}
+
+// rank counts a received message towards its sender domain and list.
+func (a *aggregator) rank(meta gmail.MessageMeta, class MessageClass) {
+	_, replied := a.replied[meta.ThreadID]
+	obs := observe(meta, class, replied && meta.ThreadID != "")
+	if domain := domainOf(meta.Headers["From"]); domain != "" {
+		a.senders.add(domain, obs)
+	}
+	if lid := normalizeListID(meta.Headers["List-Id"]); lid != "" {
+		a.lists.add(lid, obs)
+	}
+}
+
+// skip records messages that could not be fetched, keeping only a sample.
+func (a *aggregator) skip(skipped []SkippedMessage) {
+	for _, msg := range skipped {
+		if a.skipped.add(msg.ID) {
+			a.skipSample = append(a.skipSample, msg)
+		}
+	}
+}
+
+func (a *aggregator) topSenders(n int) []SenderStat {
+	entries := a.senders.top(n)
+	out := make([]SenderStat, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, SenderStat{
+			Domain:         entry.key,
+			Count:          entry.count,
+			Overestimate:   entry.err,
+			HumanCount:     entry.human,
+			PreviewSubject: entry.preview,
+			Engagement:     engagementOf(entry),
+			Unsubscribe:    unsubscribeOf(entry),
+		})
+	}
+	return out
+}
+
+func (a *aggregator) topLists(n int) []ListStat {
+	entries := a.lists.top(n)
+	out := make([]ListStat, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, ListStat{
+			ListID:         entry.key,
+			Count:          entry.count,
+			Overestimate:   entry.err,
+			PreviewSubject: entry.preview,
+			Engagement:     engagementOf(entry),
+			Unsubscribe:    unsubscribeOf(entry),
+		})
+	}
+	return out
+}
+
+// evidence counts occurrences and keeps the first few message IDs as samples.
+type evidence struct {
+	count   int
+	samples []gmail.MessageID
+}
+
+// add counts id and reports whether it was kept as a sample.
+func (e *evidence) add(id gmail.MessageID) bool {
+	e.count++
+	if len(e.samples) >= maxEvidenceSamples {
+		return false
+	}
+	e.samples = append(e.samples, id)
+	return true
+}
+
+// ruleAggregator replays compiled rules against each message as it streams past, keeping a counter
+// and evidence per rule and per conflicting rule set instead of every match.
+type ruleAggregator struct {
+	rules     []compiledRule
+	matches   map[string]*evidence
+	conflicts map[string]*conflictEvidence
+}
+
+type conflictEvidence struct {
+	rules []string
+	evidence
+}
+
+func newRuleAggregator(rules []compiledRule) *ruleAggregator {
+	return &ruleAggregator{
+		rules:     rules,
+		matches:   map[string]*evidence{},
+		conflicts: map[string]*conflictEvidence{},
+	}
+}
+
+func (r *ruleAggregator) add(meta gmail.MessageMeta) {
+	var archiveRules, starRules []string
+	for _, rule := range r.rules {
+		if !rule.Evaluable || !rule.matches(meta) {
+			continue
+		}
+		ev := r.matches[rule.Name]
+		if ev == nil {
+			ev = &evidence{}
+			r.matches[rule.Name] = ev
+		}
+		ev.add(meta.ID)
+		if rule.Actions.Archive {
+			archiveRules = appendIfMissing(archiveRules, rule.Name)
+		}
+		if rule.Actions.Star {
+			starRules = appendIfMissing(starRules, rule.Name)
+		}
+	}
+	if len(archiveRules) == 0 || len(starRules) == 0 {
+		return
+	}
+	combined := mergeRuleSets(archiveRules, starRules)
+	key := strings.Join(combined, "|")
+	conflict := r.conflicts[key]
+	if conflict == nil {
+		conflict = &conflictEvidence{rules: combined}
+		r.conflicts[key] = conflict
+	}
+	conflict.add(meta.ID)
+}
+
+// matched reports how many messages rule matched.
+func (r *ruleAggregator) matched(rule string) int {
+	if ev := r.matches[rule]; ev != nil {
+		return ev.count
+	}
+	return 0
+}
+
+// conflictList returns the conflicts seen, ordered by their rule sets.
+func (r *ruleAggregator) conflictList() []Conflict {
+	keys := make([]string, 0, len(r.conflicts))
+	for key := range r.conflicts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	conflicts := make([]Conflict, 0, len(keys))
+	for _, key := range keys {
+		conflict := r.conflicts[key]
+		conflicts = append(conflicts, Conflict{
+			Rules:       conflict.rules,
+			Description: "archive and star rules overlap",
+			Count:       conflict.count,
+			Samples:     conflict.samples,
+		})
+	}
+	return conflicts
+}
+
+// topK is a Space-Saving sketch: it tracks at most capacity keys, and a new key arriving when full
+// replaces the least frequent one, inheriting its count. Reported counts are exact until the sketch
+// first evicts and can only overestimate afterwards; each entry keeps the count it inherited as err, the
+// most by which its own count may be too high. The heaviest keys are always retained. Ties are
+// broken by key so the result depends only on the input order. Each entry also counts messages
+// classified as human and the engagement signals; like the total they are inherited on eviction, so
+// they err towards "has human mail" and "is read".
+type topK struct {
+	capacity int
+	index    map[string]*topKEntry
+	heap     topKHeap
+}
+
+type topKEntry struct {
+	key     string
+	count   int
+	err     int
+	human   int
+	read    int
+	starred int
+	replied int
+	score   float64
+	preview string
+	unsub   unsubscribe.Methods
+	pos     int
+}
+
+func newTopK(capacity int) *topK {
+	return &topK{capacity: capacity, index: map[string]*topKEntry{}}
+}
+
+func (t *topK) add(key string, obs observation) {
+	if entry, ok := t.index[key]; ok {
+		entry.count++
+		entry.observe(obs)
+		if entry.preview == "" {
+			entry.preview = obs.subject
+		}
+		heap.Fix(&t.heap, entry.pos)
+		return
+	}
+	if len(t.heap) < t.capacity {
+		entry := &topKEntry{key: key, count: 1, preview: obs.subject}
+		entry.observe(obs)
+		t.index[key] = entry
+		heap.Push(&t.heap, entry)
+		return
+	}
+	evicted := t.heap[0]
+	delete(t.index, evicted.key)
+	evicted.key = key
+	evicted.err = evicted.count
+	evicted.count++
+	evicted.observe(obs)
+	evicted.preview = obs.subject
+	evicted.unsub = obs.unsubscribe
+	t.index[key] = evicted
+	heap.Fix(&t.heap, evicted.pos)
+}
+
+func (e *topKEntry) observe(obs observation) {
+	e.human += boolCount(obs.human)
+	e.read += boolCount(obs.read)
+	e.starred += boolCount(obs.starred)
+	e.replied += boolCount(obs.replied)
+	e.score += obs.score
+	if e.unsub.Empty() {
+		e.unsub = obs.unsubscribe
+	}
+}
+
+// unsubscribeOf returns the mechanisms the entry's mail advertised, or nil when none did.
+func unsubscribeOf(entry topKEntry) *unsubscribe.Methods {
+	if entry.unsub.Empty() {
+		return nil
+	}
+	methods := entry.unsub
+	return &methods
+}
+
+func boolCount(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// top returns the n noisiest keys: highest volume left after discounting engagement first, then by
+// count and key. The sketch still retains keys by volume, so a ranking only reorders heavy hitters.
+func (t *topK) top(n int) []topKEntry {
+	entries := make([]topKEntry, 0, len(t.heap))
+	for _, entry := range t.heap {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		noiseI, noiseJ := entries[i].noise(), entries[j].noise()
+		if noiseI != noiseJ {
+			return noiseI > noiseJ
+		}
+		if entries[i].count == entries[j].count {
+			return entries[i].key < entries[j].key
+		}
+		return entries[i].count > entries[j].count
+	})
+	if n < len(entries) {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// noise is the entry's volume discounted by its summed engagement scores.
+func (e topKEntry) noise() float64 {
+	return float64(e.count) - e.score
+}
+
+// topKHeap is a min-heap on (count, key descending), so the root is the entry to evict: the least
+// frequent, and among equals the one that would sort last in the output.
+type topKHeap []*topKEntry
+
+func (h topKHeap) Len() int { return len(h) }
+
+func (h topKHeap) Less(i, j int) bool {
+	if h[i].count == h[j].count {
+		return h[i].key > h[j].key
+	}
+	return h[i].count < h[j].count
+}
+
+func (h topKHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *topKHeap) Push(x any) {
+	entry, _ := x.(*topKEntry)
+	entry.pos = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *topKHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+func TestTopKExactBelowCapacity(t *testing.T) {
+	sketch := newTopK(8)
+	for _, key := range []string{"b", "a", "c", "a", "b", "a"} {
+		sketch.add(key, observation{subject: "subject " + key})
+	}
+	got := sketch.top(2)
+	if len(got) != 2 || got[0].key != "a" || got[0].count != 3 || got[1].key != "b" || got[1].count != 2 {
+		t.Fatalf("unexpected top entries %+v", got)
+	}
+	if got[0].preview != "subject a" {
+		t.Fatalf("expected first subject as preview, got %q", got[0].preview)
+	}
+}
+
+func TestTopKKeepsHeavyHittersWithinCapacity(t *testing.T) {
+	// Space-Saving retains every key seen more than total/capacity times: here 3500/8.
+	sketch := newTopK(8)
+	for i := range 2000 {
+		sketch.add(fmt.Sprintf("noise-%d", i), observation{})
+		if i%2 == 0 {
+			sketch.add("heavy.example.com", observation{})
+		}
+		if i%4 == 0 {
+			sketch.add("medium.example.com", observation{})
+		}
+	}
+	if len(sketch.heap) != 8 || len(sketch.index) != 8 {
+		t.Fatalf("sketch grew past its capacity: %d entries", len(sketch.heap))
+	}
+	got := sketch.top(2)
+	if got[0].key != "heavy.example.com" || got[1].key != "medium.example.com" {
+		t.Fatalf("expected heavy hitters to survive, got %+v", got)
+	}
+	if got[0].count < 1000 {
+		t.Fatalf("counts may only overestimate, got %d for 1000 occurrences", got[0].count)
+	}
+}
+
+func TestTopKTracksOverestimate(t *testing.T) {
+	sketch := newTopK(2)
+	for _, key := range []string{"a", "a", "a", "b", "b", "c", "c"} {
+		sketch.add(key, observation{})
+	}
+	// c evicted b after its two messages, so c's count of 4 includes up to 2 that were b's.
+	got := sketch.top(2)
+	if got[0].key != "c" || got[0].count != 4 || got[0].err != 2 {
+		t.Fatalf("expected c to carry b's count as its error, got %+v", got[0])
+	}
+	if got[1].key != "a" || got[1].count != 3 || got[1].err != 0 {
+		t.Fatalf("expected a to stay exact, got %+v", got[1])
+	}
+
+	agg := newAggregator(1, nil, nil)
+	agg.senders = sketch
+	var out strings.Builder
+	if err := PrintHuman(Report{TopSenders: agg.topSenders(1)}, &out); err != nil {
+		t.Fatalf("PrintHuman: %v", err)
+	}
+	if !strings.Contains(out.String(), "c                               2-4 ") {
+		t.Fatalf("expected the count range in the report:\n%s", out.String())
+	}
+}
+
+func TestTopKDeterministic(t *testing.T) {
+	run := func() []topKEntry {
+		sketch := newTopK(3)
+		for i := range 500 {
+			sketch.add(fmt.Sprintf("k%d", i%7), observation{human: i%5 == 0, read: i%3 == 0, score: 0.5})
+		}
+		return sketch.top(3)
+	}
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("run %d differs: %+v vs %+v", i, first[i], second[i])
+		}
+	}
+}
+
+func TestRuleAggregatorCapsEvidence(t *testing.T) {
+	rules := []compiledRule{
+		{
+			Name:      "archive",
+			Evaluable: true,
+			Matchers:  []matcher{{kind: matcherList, values: []string{"alerts.example.com"}}},
+			Actions:   ruleActions{Archive: true},
+		},
+		{
+			Name:      "star",
+			Evaluable: true,
+			Matchers:  []matcher{{kind: matcherList, values: []string{"alerts.example.com"}}},
+			Actions:   ruleActions{Star: true},
+		},
+	}
+	agg := newAggregator(5, nil, rules)
+	page := make([]gmail.MessageMeta, 0, 100)
+	for i := range 100 {
+		page = append(page, gmail.MessageMeta{
+			ID:      gmail.MessageID(fmt.Sprintf("m%03d", i)),
+			Headers: map[string]string{"List-Id": "<alerts.example.com>", "From": "a@example.com"},
+		})
+	}
+	agg.add(page)
+
+	if agg.rules.matched("archive") != 100 {
+		t.Fatalf("expected 100 matches, got %d", agg.rules.matched("archive"))
+	}
+	conflicts := agg.rules.conflictList()
+	if len(conflicts) != 1 || conflicts[0].Count != 100 {
+		t.Fatalf("unexpected conflicts %+v", conflicts)
+	}
+	if got := conflicts[0].Samples; len(got) != maxEvidenceSamples || got[0] != "m000" {
+		t.Fatalf("expected %d leading samples, got %v", maxEvidenceSamples, got)
+	}
+	if ev := agg.rules.matches["archive"]; len(ev.samples) != maxEvidenceSamples {
+		t.Fatalf("expected capped rule evidence, got %d samples", len(ev.samples))
+	}
+}
+
+func TestAggregatorReportsUnsubscribeMethods(t *testing.T) {
+	agg := newAggregator(5, nil, nil)
+	agg.add([]gmail.MessageMeta{
+		{ID: "1", Headers: map[string]string{"From": "news@news.example.com", "List-Id": "<weekly.news.example.com>"}},
+		{ID: "2", Headers: map[string]string{
+			"From":                  "news@news.example.com",
+			"List-Id":               "<weekly.news.example.com>",
+			"List-Unsubscribe":      "<https://news.example.com/u/2>, <mailto:leave@news.example.com>",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}},
+		{ID: "3", Headers: map[string]string{"From": "a@plain.example"}},
+	})
+	lists := agg.topLists(5)
+	if len(lists) != 1 || lists[0].Unsubscribe == nil || !lists[0].Unsubscribe.OneClick {
+		t.Fatalf("expected one-click list, got %+v", lists)
+	}
+	senders := agg.topSenders(5)
+	for _, sender := range senders {
+		if sender.Domain == "plain.example" && sender.Unsubscribe != nil {
+			t.Fatalf("sender without List-Unsubscribe reported %+v", sender.Unsubscribe)
+		}
+	}
+
+	var out strings.Builder
+	if err := PrintHuman(Report{TopLists: lists, TopSenders: senders}, &out); err != nil {
+		t.Fatalf("PrintHuman: %v", err)
+	}
+	if !strings.Contains(out.String(), "Unsubscribe:\n  list weekly.news.example.com") ||
+		!strings.Contains(out.String(), "one-click, mailto, https") {
+		t.Fatalf("missing unsubscribe section:\n%s", out.String())
+	}
+}
+
+func TestAggregatorFlagsStaleLabels(t *testing.T) {
+	agg := newAggregator(5, nil, nil)
+	agg.add([]gmail.MessageMeta{
+		{ID: "1", LabelsStale: true, Headers: map[string]string{"From": "a@example.com"}},
+		{ID: "2", Headers: map[string]string{"From": "a@example.com"}},
+	})
+	if agg.stale != 1 {
+		t.Fatalf("stale = %d, want 1", agg.stale)
+	}
+	var out strings.Builder
+	if err := PrintHuman(Report{StaleLabels: agg.stale}, &out); err != nil {
+		t.Fatalf("PrintHuman: %v", err)
+	}
+	if !strings.Contains(out.String(), "Labels of 1 messages came from the cache unrefreshed") {
+		t.Fatalf("missing stale labels note:\n%s", out.String())
+	}
+}
@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"strings"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// MessageClass describes who or what sent a message, as far as its headers tell.
+type MessageClass string
+
+// Message classes, from most to least automated.
+const (
+	ClassAutoGenerated MessageClass = "auto-generated"
+	ClassBulk          MessageClass = "bulk"
+	ClassList          MessageClass = "list"
+	ClassTransactional MessageClass = "transactional"
+	ClassHuman         MessageClass = "human"
+)
+
+// messageClasses lists every class in report order.
+func messageClasses() []MessageClass {
+	return []MessageClass{ClassHuman, ClassTransactional, ClassList, ClassBulk, ClassAutoGenerated}
+}
+
+// espMailers lists lower-cased X-Mailer fragments of email service providers.
+func espMailers() []string {
+	return []string{
+		"mailchimp", "mandrill", "sendgrid", "mailgun", "amazon ses", "postmark", "sparkpost",
+		"sendinblue", "brevo", "klaviyo", "hubspot", "marketo", "salesforce", "campaign monitor",
+		"constant contact", "customer.io", "braze", "iterable", "mailjet", "zendesk",
+	}
+}
+
+// ClassifyMessage assigns meta a class from its headers. The first matching signal wins:
+//
+//   - Auto-Submitted other than "no" means auto-generated (vacation replies, notifications);
+//   - Precedence bulk or junk means bulk;
+//   - a List-Id or Precedence list means list;
+//   - a List-Unsubscribe header means bulk;
+//   - a Feedback-ID or an email service provider's X-Mailer means transactional;
+//   - anything else is treated as human.
+func ClassifyMessage(meta gmail.MessageMeta) MessageClass {
+	if auto := strings.ToLower(header(meta, "Auto-Submitted")); auto != "" && auto != "no" {
+		return ClassAutoGenerated
+	}
+	switch strings.ToLower(header(meta, "Precedence")) {
+	case "bulk", "junk":
+		return ClassBulk
+	case "list":
+		return ClassList
+	}
+	if header(meta, "List-Id") != "" {
+		return ClassList
+	}
+	if header(meta, "List-Unsubscribe") != "" {
+		return ClassBulk
+	}
+	if header(meta, "Feedback-ID") != "" || isESPMailer(header(meta, "X-Mailer")) {
+		return ClassTransactional
+	}
+	return ClassHuman
+}
+
+func isESPMailer(mailer string) bool {
+	mailer = strings.ToLower(mailer)
+	if mailer == "" {
+		return false
+	}
+	for _, esp := range espMailers() {
+		if strings.Contains(mailer, esp) {
+			return true
+		}
+	}
+	return false
+}
+
+// header looks name up exactly, then case-insensitively, since senders and backends disagree on
+// capitalization ("List-ID", "Feedback-Id").
+func header(meta gmail.MessageMeta, name string) string {
+	if value, ok := meta.Headers[name]; ok {
+		return strings.TrimSpace(value)
+	}
+	for key, value := range meta.Headers {
+		if strings.EqualFold(key, name) {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
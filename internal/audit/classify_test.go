@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+func TestClassifyMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    MessageClass
+	}{
+		{name: "plain", headers: map[string]string{"From": "alice@example.com"}, want: ClassHuman},
+		{name: "auto submitted no", headers: map[string]string{"Auto-Submitted": "no"}, want: ClassHuman},
+		{name: "auto reply", headers: map[string]string{"Auto-Submitted": "auto-replied"}, want: ClassAutoGenerated},
+		{
+			name:    "auto beats list",
+			headers: map[string]string{"Auto-Submitted": "auto-generated", "List-Id": "<ops.example.com>"},
+			want:    ClassAutoGenerated,
+		},
+		{name: "precedence bulk", headers: map[string]string{"Precedence": "Bulk"}, want: ClassBulk},
+		{name: "precedence junk", headers: map[string]string{"Precedence": "junk"}, want: ClassBulk},
+		{name: "precedence list", headers: map[string]string{"Precedence": "list"}, want: ClassList},
+		{name: "list id", headers: map[string]string{"List-ID": "<dev.example.org>"}, want: ClassList},
+		{
+			name:    "unsubscribe",
+			headers: map[string]string{"List-Unsubscribe": "<mailto:unsub@example.com>"},
+			want:    ClassBulk,
+		},
+		{name: "feedback id", headers: map[string]string{"Feedback-Id": "1:receipt:ses"}, want: ClassTransactional},
+		{name: "esp mailer", headers: map[string]string{"X-Mailer": "SendGrid"}, want: ClassTransactional},
+		{name: "desktop mailer", headers: map[string]string{"X-Mailer": "Apple Mail (2.3731)"}, want: ClassHuman},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyMessage(gmail.MessageMeta{Headers: tt.headers}); got != tt.want {
+				t.Fatalf("got %s want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceRunSkipsArchiveForHumanDomains(t *testing.T) {
+	client := &fakeAuditClient{
+		pages: []gmail.ListPage{{IDs: []gmail.MessageID{"1", "2", "3"}}},
+		metas: map[gmail.MessageID]gmail.MessageMeta{
+			"1": {ID: "1", Headers: map[string]string{"From": "news@mixed.com", "Precedence": "bulk"}},
+			"2": {ID: "2", Headers: map[string]string{"From": "friend@mixed.com", "Subject": "Dinner?"}},
+			"3": {ID: "3", Headers: map[string]string{"From": "deals@shop.com", "List-Unsubscribe": "<x>"}},
+		},
+	}
+	svc := NewService(client, nil, slogDiscard(), nil)
+	svc.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+
+	rep, err := svc.Run(context.Background(), Options{Window: 24 * time.Hour, TopN: 5})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if rep.Classes[ClassBulk] != 2 || rep.Classes[ClassHuman] != 1 {
+		t.Fatalf("unexpected class counts %v", rep.Classes)
+	}
+	for _, snippet := range rep.Suggestions.ArchiveRules {
+		if strings.Contains(snippet, "mixed.com") {
+			t.Fatalf("suggested archiving a domain that sends personal mail: %s", snippet)
+		}
+	}
+	if len(rep.Suggestions.ArchiveRules) != 1 || !strings.Contains(rep.Suggestions.ArchiveRules[0], "shop.com") {
+		t.Fatalf("expected only shop.com to be suggested, got %v", rep.Suggestions.ArchiveRules)
+	}
+}
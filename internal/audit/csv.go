@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/joshsymonds/chronosweep/internal/unsubscribe"
+)
+
+// CSVSection selects which part of the report a CSVRenderer writes; CSV holds one table per file.
+type CSVSection string
+
+// CSV sections, one file each.
+const (
+	CSVSenders  CSVSection = "senders"
+	CSVLists    CSVSection = "lists"
+	CSVRules    CSVSection = "rules"
+	CSVFindings CSVSection = "findings"
+)
+
+func csvSections() []CSVSection {
+	return []CSVSection{CSVSenders, CSVLists, CSVRules, CSVFindings}
+}
+
+// CSVRenderer writes one section of the report as CSV with a header row, for spreadsheets.
+type CSVRenderer struct {
+	Section CSVSection
+}
+
+// Render writes the renderer's section of rep.
+func (r CSVRenderer) Render(rep Report, w io.Writer) error {
+	var records [][]string
+	switch r.Section {
+	case CSVSenders:
+		records = csvSenders(rep.TopSenders)
+	case CSVLists:
+		records = csvLists(rep.TopLists)
+	case CSVRules:
+		records = csvRules(rep.Suggestions)
+	case CSVFindings:
+		records = csvFindings(rep.Findings)
+	default:
+		return fmt.Errorf("%w: csv section %q", errUnknownFormat, r.Section)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(records); err != nil {
+		return fmt.Errorf("write %s csv: %w", r.Section, err)
+	}
+	return nil
+}
+
+func engagementHeader() []string {
+	return []string{"read_rate", "star_rate", "reply_rate", "engagement", "noise"}
+}
+
+func engagementFields(e Engagement) []string {
+	return []string{
+		formatFloat(e.ReadRate), formatFloat(e.StarRate), formatFloat(e.ReplyRate),
+		formatFloat(e.Score), formatFloat(e.Noise),
+	}
+}
+
+func csvSenders(senders []SenderStat) [][]string {
+	records := [][]string{slices.Concat(
+		[]string{"domain", "count", "human_count"},
+		engagementHeader(),
+		[]string{"unsubscribe", "preview_subject", "overestimate"},
+	)}
+	for _, s := range senders {
+		records = append(records, slices.Concat(
+			[]string{csvText(s.Domain), strconv.Itoa(s.Count), strconv.Itoa(s.HumanCount)},
+			engagementFields(s.Engagement),
+			[]string{csvMechanisms(s.Unsubscribe), csvText(s.PreviewSubject), strconv.Itoa(s.Overestimate)},
+		))
+	}
+	return records
+}
+
+func csvLists(lists []ListStat) [][]string {
+	records := [][]string{slices.Concat(
+		[]string{"list_id", "count"},
+		engagementHeader(),
+		[]string{"unsubscribe", "preview_subject", "overestimate"},
+	)}
+	for _, l := range lists {
+		records = append(records, slices.Concat(
+			[]string{csvText(l.ListID), strconv.Itoa(l.Count)},
+			engagementFields(l.Engagement),
+			[]string{csvMechanisms(l.Unsubscribe), csvText(l.PreviewSubject), strconv.Itoa(l.Overestimate)},
+		))
+	}
+	return records
+}
+
+// csvRules lists proposed rules followed by amendments to existing ones; an amendment's label column
+// holds what to add and its reason the rule to amend.
+func csvRules(s Suggestions) [][]string {
+	records := [][]string{{"action", "kind", "target", "label", "count", "noise", "reason"}}
+	for _, p := range s.Rules {
+		records = append(records, []string{
+			p.Action, p.Kind, csvText(p.Target), csvText(p.Label), strconv.Itoa(p.Count), formatFloat(p.Noise),
+			csvText(p.Reason),
+		})
+	}
+	for _, am := range s.Amendments {
+		records = append(records, []string{
+			"amend", am.Kind, csvText(am.Target), csvText(am.Add), "", "", "rule " + am.Rule,
+		})
+	}
+	return records
+}
+
+func csvFindings(f GmailctlFindings) [][]string {
+	records := [][]string{{"finding", "subject", "detail", "count"}}
+	for _, fr := range f.DeadRules {
+		records = append(records, []string{"dead-rule", csvText(fr.Name), csvText(fr.Reason), ""})
+	}
+	for _, lbl := range f.MissingLabels {
+		records = append(records, []string{"missing-label", csvText(lbl), "", ""})
+	}
+	for _, cf := range f.Conflicts {
+		records = append(records, []string{
+			"conflict", csvText(strings.Join(cf.Rules, ", ")), csvText(cf.Description), strconv.Itoa(cf.Count),
+		})
+	}
+	return records
+}
+
+// csvMechanisms joins the unsubscribe mechanisms with spaces so the cell stays a single token list.
+func csvMechanisms(methods *unsubscribe.Methods) string {
+	if methods == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(methods.Mechanisms()))
+	for _, mechanism := range methods.Mechanisms() {
+		parts = append(parts, string(mechanism))
+	}
+	return strings.Join(parts, " ")
+}
+
+// csvText defuses cells a spreadsheet would evaluate as a formula. Subjects, list IDs and domains come from
+// whoever sent the mail, so a leading =, +, -, @, tab or carriage return is prefixed with a quote.
+func csvText(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
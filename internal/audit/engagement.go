@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"math"
+	"slices"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/unsubscribe"
+)
+
+const (
+	labelUnread    gmail.LabelID = "UNREAD"
+	labelInbox     gmail.LabelID = "INBOX"
+	labelStarred   gmail.LabelID = "STARRED"
+	labelImportant gmail.LabelID = "IMPORTANT"
+	labelSent      gmail.LabelID = "SENT"
+
+	// readScore is what opening a message is worth; starring or replying is worth a full point.
+	readScore = 0.5
+	// importantScore is added when Gmail marked the message important on the user's behalf.
+	importantScore = 0.25
+	// engagedThreshold is the engagement above which a sender or list is never suggested for archiving.
+	// It equals readScore, so mail that is only ever read remains a candidate.
+	engagedThreshold = 0.5
+	// ratePrecision rounds reported rates to three decimals so reports stay stable and readable.
+	ratePrecision = 1000
+	// percent scales a rate for display.
+	percent = 100
+)
+
+// Engagement summarizes how the user handled a sender's or list's mail. Rates are fractions of the
+// messages counted; Score averages a per-message score (1 for a star or reply, 0.5 for a read, plus
+// 0.25 if Gmail marked it important, capped at 1) and Noise is the volume left after discounting it.
+type Engagement struct {
+	ReadRate  float64 `json:"read_rate"`
+	StarRate  float64 `json:"star_rate"`
+	ReplyRate float64 `json:"reply_rate"`
+	Score     float64 `json:"engagement"`
+	Noise     float64 `json:"noise"`
+}
+
+// observation is what the rankings keep about one message.
+type observation struct {
+	subject string
+	human   bool
+	read    bool
+	starred bool
+	replied bool
+	score   float64
+	// unsubscribe is what the message advertises in List-Unsubscribe, kept for the report.
+	unsubscribe unsubscribe.Methods
+}
+
+// observe derives meta's engagement signals. A message counts as read when it lacks UNREAD; replied
+// reports whether the user has sent mail in its thread.
+func observe(meta gmail.MessageMeta, class MessageClass, replied bool) observation {
+	obs := observation{
+		subject: meta.Headers["Subject"],
+		human:   class == ClassHuman,
+		read:    !slices.Contains(meta.LabelIDs, labelUnread),
+		starred: slices.Contains(meta.LabelIDs, labelStarred),
+		replied: replied,
+		unsubscribe: unsubscribe.Parse(
+			meta.Headers[unsubscribe.HeaderUnsubscribe],
+			meta.Headers[unsubscribe.HeaderUnsubscribePost],
+		),
+	}
+	switch {
+	case obs.starred || obs.replied:
+		obs.score = 1
+	case obs.read:
+		obs.score = readScore
+	}
+	if slices.Contains(meta.LabelIDs, labelImportant) {
+		obs.score = min(1, obs.score+importantScore)
+	}
+	return obs
+}
+
+// isSent reports whether meta is the user's own outgoing mail. Notes to self carry INBOX as well and
+// are treated as received.
+func isSent(meta gmail.MessageMeta) bool {
+	return slices.Contains(meta.LabelIDs, labelSent) && !slices.Contains(meta.LabelIDs, labelInbox)
+}
+
+// engagementOf turns an entry's counters into rates and a noise score.
+func engagementOf(entry topKEntry) Engagement {
+	if entry.count == 0 {
+		return Engagement{}
+	}
+	count := float64(entry.count)
+	score := entry.score / count
+	return Engagement{
+		ReadRate:  roundRate(float64(entry.read) / count),
+		StarRate:  roundRate(float64(entry.starred) / count),
+		ReplyRate: roundRate(float64(entry.replied) / count),
+		Score:     roundRate(score),
+		Noise:     roundRate(count * (1 - score)),
+	}
+}
+
+func roundRate(v float64) float64 {
+	return math.Round(v*ratePrecision) / ratePrecision
+}
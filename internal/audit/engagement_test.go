@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+func TestObserveScores(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  []gmail.LabelID
+		replied bool
+		want    float64
+	}{
+		{name: "unread", labels: []gmail.LabelID{"INBOX", "UNREAD"}, want: 0},
+		{name: "read", labels: []gmail.LabelID{"INBOX"}, want: readScore},
+		{name: "starred unread", labels: []gmail.LabelID{"UNREAD", "STARRED"}, want: 1},
+		{name: "replied", labels: []gmail.LabelID{"INBOX"}, replied: true, want: 1},
+		{name: "important unread", labels: []gmail.LabelID{"UNREAD", "IMPORTANT"}, want: importantScore},
+		{name: "important read", labels: []gmail.LabelID{"IMPORTANT"}, want: readScore + importantScore},
+		{name: "important starred", labels: []gmail.LabelID{"STARRED", "IMPORTANT"}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obs := observe(gmail.MessageMeta{LabelIDs: tt.labels}, ClassBulk, tt.replied)
+			if obs.score != tt.want {
+				t.Fatalf("got score %v want %v", obs.score, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceRunRanksByNoise(t *testing.T) {
+	received := func(id gmail.MessageID, thread, from string, labels ...gmail.LabelID) gmail.MessageMeta {
+		return gmail.MessageMeta{
+			ID:       id,
+			ThreadID: thread,
+			LabelIDs: labels,
+			Headers:  map[string]string{"From": from, "List-Unsubscribe": "<x>"},
+		}
+	}
+	// Gmail lists newest first, so the reply in thread t1 comes before the message it answers.
+	ordered := []gmail.MessageMeta{
+		{
+			ID:       "sent",
+			ThreadID: "t1",
+			LabelIDs: []gmail.LabelID{"SENT"},
+			Headers:  map[string]string{"From": "me@home.example"},
+		},
+		received("r1", "t1", "team@engaged.example", "INBOX"),
+		received("r2", "t2", "team@engaged.example", "INBOX"),
+		received("r3", "t3", "team@engaged.example"),
+		received("r4", "t4", "team@engaged.example"),
+		received("n1", "t5", "promo@noisy.example", "INBOX", "UNREAD"),
+		received("n2", "t6", "promo@noisy.example", "INBOX", "UNREAD"),
+		received("n3", "t7", "promo@noisy.example", "INBOX", "UNREAD"),
+	}
+	ids := make([]gmail.MessageID, 0, len(ordered))
+	metas := make(map[gmail.MessageID]gmail.MessageMeta, len(ordered))
+	for _, meta := range ordered {
+		ids = append(ids, meta.ID)
+		metas[meta.ID] = meta
+	}
+	client := &fakeAuditClient{pages: []gmail.ListPage{{IDs: ids}}, metas: metas}
+	svc := NewService(client, nil, slogDiscard(), nil)
+	svc.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+
+	rep, err := svc.Run(context.Background(), Options{Window: 24 * time.Hour, TopN: 5})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if len(rep.TopSenders) != 2 {
+		t.Fatalf("expected sent mail to stay out of the rankings, got %+v", rep.TopSenders)
+	}
+	noisy, engaged := rep.TopSenders[0], rep.TopSenders[1]
+	if noisy.Domain != "noisy.example" || engaged.Domain != "engaged.example" {
+		t.Fatalf("expected the unread sender to outrank the larger read one, got %+v", rep.TopSenders)
+	}
+	if noisy.Noise != 3 || noisy.ReadRate != 0 {
+		t.Fatalf("unexpected noisy engagement %+v", noisy.Engagement)
+	}
+	if engaged.ReadRate != 1 || engaged.ReplyRate != 0.25 || engaged.Score != 0.625 || engaged.Noise != 1.5 {
+		t.Fatalf("unexpected engaged engagement %+v", engaged.Engagement)
+	}
+	for _, snippet := range rep.Suggestions.ArchiveRules {
+		if strings.Contains(snippet, "engaged.example") {
+			t.Fatalf("suggested archiving a sender the user engages with: %s", snippet)
+		}
+	}
+	var out strings.Builder
+	if err := PrintHuman(rep, &out); err != nil {
+		t.Fatalf("print: %v", err)
+	}
+	if !strings.Contains(out.String(), "reply  25%") {
+		t.Fatalf("expected engagement in the human report:\n%s", out.String())
+	}
+}
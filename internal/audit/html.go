@@ -0,0 +1,285 @@
+package audit
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/unsubscribe"
+)
+
+// HTMLRenderer writes a self-contained HTML page: summary, sortable ranking tables with inline bar
+// charts, and the suggested rules. Styles and the sorting script are inlined, so the file can be
+// opened or mailed on its own.
+type HTMLRenderer struct{}
+
+// htmlPage is the template's view of a report.
+type htmlPage struct {
+	Summary     string
+	Classes     []htmlBar
+	Tables      []htmlTable
+	Rules       []string
+	Amendments  []string
+	Escalations []string
+	Findings    []string
+}
+
+// htmlBar is one row of the traffic chart; Width is a percentage of the largest class.
+type htmlBar struct {
+	Label string
+	Value int
+	Width string
+}
+
+type htmlTable struct {
+	Title   string
+	Columns []htmlColumn
+	Rows    [][]htmlCell
+}
+
+type htmlColumn struct {
+	Name    string
+	Numeric bool
+}
+
+// htmlCell is a table cell; Sort is the key the table sorts by and Bar, when set, the width of the
+// inline bar drawn behind the value.
+type htmlCell struct {
+	Text  string
+	Sort  string
+	Bar   string
+	Class string
+}
+
+// Render writes rep as a standalone HTML page.
+func (HTMLRenderer) Render(rep Report, w io.Writer) error {
+	tmpl, err := template.New("report").Parse(htmlTemplate)
+	if err != nil {
+		return fmt.Errorf("parse html template: %w", err)
+	}
+	if err := tmpl.Execute(w, htmlView(rep)); err != nil {
+		return fmt.Errorf("write html report: %w", err)
+	}
+	return nil
+}
+
+func htmlView(rep Report) htmlPage {
+	page := htmlPage{Summary: fmt.Sprintf("Window %s, %d messages", rep.Window, rep.Total)}
+	if !rep.GeneratedAt.IsZero() {
+		page.Summary += ", generated " + rep.GeneratedAt.UTC().Format(time.RFC3339)
+	}
+	if rep.StaleLabels > 0 {
+		page.Summary += fmt.Sprintf("; labels of %d messages came from the cache unrefreshed", rep.StaleLabels)
+	}
+	largest := 0
+	for _, count := range rep.Classes {
+		largest = max(largest, count)
+	}
+	for _, class := range messageClasses() {
+		if count := rep.Classes[class]; count > 0 {
+			page.Classes = append(page.Classes, htmlBar{
+				Label: string(class), Value: count, Width: barWidth(count, largest),
+			})
+		}
+	}
+	if len(rep.TopSenders) > 0 {
+		page.Tables = append(page.Tables, senderTable(rep.TopSenders))
+	}
+	if len(rep.TopLists) > 0 {
+		page.Tables = append(page.Tables, listTable(rep.TopLists))
+	}
+	s := rep.Suggestions
+	page.Rules = s.ArchiveRules
+	for _, am := range s.Amendments {
+		page.Amendments = append(page.Amendments,
+			fmt.Sprintf("%s matches %s %s: add %s", am.Rule, am.Kind, am.Target, am.Add))
+	}
+	for _, esc := range s.Escalations {
+		page.Escalations = append(page.Escalations,
+			fmt.Sprintf("%s %s: %s\n%s", esc.Kind, esc.Target, esc.Reason, esc.Rule))
+	}
+	for _, fr := range rep.Findings.DeadRules {
+		page.Findings = append(page.Findings, fmt.Sprintf("dead rule %s: %s", fr.Name, fr.Reason))
+	}
+	for _, lbl := range rep.Findings.MissingLabels {
+		page.Findings = append(page.Findings, "missing label "+lbl)
+	}
+	for _, cf := range rep.Findings.Conflicts {
+		page.Findings = append(page.Findings,
+			fmt.Sprintf("conflict between %s: %s", strings.Join(cf.Rules, ", "), cf.Description))
+	}
+	return page
+}
+
+func rankingColumns(first string, extra ...string) []htmlColumn {
+	columns := []htmlColumn{{Name: first}, {Name: "Count", Numeric: true}}
+	for _, name := range extra {
+		columns = append(columns, htmlColumn{Name: name, Numeric: true})
+	}
+	for _, name := range []string{"Read", "Star", "Reply", "Noise"} {
+		columns = append(columns, htmlColumn{Name: name, Numeric: true})
+	}
+	return append(columns, htmlColumn{Name: "Unsubscribe"}, htmlColumn{Name: "Preview"})
+}
+
+func senderTable(senders []SenderStat) htmlTable {
+	table := htmlTable{Title: "Top senders", Columns: rankingColumns("Domain", "Human")}
+	largest, noisiest := 0, 0.0
+	for _, s := range senders {
+		largest, noisiest = max(largest, s.Count), max(noisiest, s.Noise)
+	}
+	for _, s := range senders {
+		row := []htmlCell{
+			textCell(s.Domain), sketchCountCell(s.Count, s.Overestimate, largest), countCell(s.HumanCount, 0),
+		}
+		row = append(row, engagementCells(s.Engagement, noisiest)...)
+		table.Rows = append(table.Rows, append(row, mechanismCell(s.Unsubscribe), textCell(s.PreviewSubject)))
+	}
+	return table
+}
+
+func listTable(lists []ListStat) htmlTable {
+	table := htmlTable{Title: "Top lists", Columns: rankingColumns("List-Id")}
+	largest, noisiest := 0, 0.0
+	for _, l := range lists {
+		largest, noisiest = max(largest, l.Count), max(noisiest, l.Noise)
+	}
+	for _, l := range lists {
+		row := []htmlCell{textCell(l.ListID), sketchCountCell(l.Count, l.Overestimate, largest)}
+		row = append(row, engagementCells(l.Engagement, noisiest)...)
+		table.Rows = append(table.Rows, append(row, mechanismCell(l.Unsubscribe), textCell(l.PreviewSubject)))
+	}
+	return table
+}
+
+func textCell(s string) htmlCell {
+	return htmlCell{Text: s, Sort: strings.ToLower(s)}
+}
+
+// countCell draws a bar relative to largest; a zero largest draws none.
+func countCell(n, largest int) htmlCell {
+	cell := htmlCell{Text: strconv.Itoa(n), Sort: strconv.Itoa(n), Class: "num"}
+	if largest > 0 {
+		cell.Bar, cell.Class = barWidth(n, largest), "num bar"
+	}
+	return cell
+}
+
+// sketchCountCell is a countCell showing the range the true count lies in when it may be overestimated.
+func sketchCountCell(n, overestimate, largest int) htmlCell {
+	cell := countCell(n, largest)
+	cell.Text = formatCount(n, overestimate)
+	return cell
+}
+
+func engagementCells(e Engagement, noisiest float64) []htmlCell {
+	rate := func(v float64) htmlCell {
+		return htmlCell{Text: fmt.Sprintf("%.0f%%", v*percent), Sort: formatFloat(v), Class: "num"}
+	}
+	noise := htmlCell{Text: fmt.Sprintf("%.1f", e.Noise), Sort: formatFloat(e.Noise), Class: "num"}
+	if noisiest > 0 {
+		noise.Bar, noise.Class = fmt.Sprintf("%.1f", e.Noise/noisiest*percent), "num bar"
+	}
+	return []htmlCell{rate(e.ReadRate), rate(e.StarRate), rate(e.ReplyRate), noise}
+}
+
+func mechanismCell(methods *unsubscribe.Methods) htmlCell {
+	return textCell(csvMechanisms(methods))
+}
+
+func barWidth(n, largest int) string {
+	return fmt.Sprintf("%.1f", float64(n)/float64(largest)*percent)
+}
+
+// htmlTemplate is the page layout. Tables sort on a header click by each cell's data-sort key.
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>chronosweep audit</title>
+<style>
+body { font: 14px/1.4 system-ui, sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+table { border-collapse: collapse; margin: 1em 0 2em; }
+th, td { padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; text-align: left; white-space: nowrap; }
+th { cursor: pointer; user-select: none; background: #f4f4f4; }
+th.num, td.num { text-align: right; }
+th[aria-sort=ascending]::after { content: " \25B2"; }
+th[aria-sort=descending]::after { content: " \25BC"; }
+td.bar { position: relative; min-width: 6em; }
+td.bar span { position: absolute; left: 0; top: 15%; height: 70%; background: #cfe3f7; z-index: -1; }
+.chart div { display: flex; align-items: center; margin: 0.2em 0; }
+.chart label { width: 9em; }
+.chart span { display: inline-block; height: 1em; background: #7aa9d6; margin-right: 0.5em; }
+pre { background: #f7f7f7; padding: 0.8em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>chronosweep audit</h1>
+<p>{{.Summary}}</p>
+{{- if .Classes}}
+<h2>Traffic</h2>
+<div class="chart">
+{{- range .Classes}}
+<div><label>{{.Label}}</label><span style="width: {{.Width}}%"></span>{{.Value}}</div>
+{{- end}}
+</div>
+{{- end}}
+{{- range .Tables}}
+<h2>{{.Title}}</h2>
+<table class="sortable">
+<thead><tr>{{range .Columns}}<th{{if .Numeric}} class="num" data-numeric{{end}}>{{.Name}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr>{{range .}}<td data-sort="{{.Sort}}"{{with .Class}} class="{{.}}"{{end}}>
+{{- if .Bar}}<span style="width: {{.Bar}}%"></span>{{end}}{{.Text}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+{{- if .Rules}}
+<h2>Suggested gmailctl rules</h2>
+{{- range .Rules}}
+<pre>{{.}}</pre>
+{{- end}}
+{{- end}}
+{{- if .Amendments}}
+<h2>Amend existing gmailctl rules</h2>
+<ul>{{range .Amendments}}<li>{{.}}</li>{{end}}</ul>
+{{- end}}
+{{- if .Escalations}}
+<h2>Keep in inbox (sensitive)</h2>
+{{- range .Escalations}}
+<pre>{{.}}</pre>
+{{- end}}
+{{- end}}
+{{- if .Findings}}
+<h2>Lint findings</h2>
+<ul>{{range .Findings}}<li>{{.}}</li>{{end}}</ul>
+{{- end}}
+<script>
+document.querySelectorAll("table.sortable th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var table = th.closest("table");
+    var index = Array.prototype.indexOf.call(th.parentNode.children, th);
+    var numeric = th.hasAttribute("data-numeric");
+    var ascending = th.getAttribute("aria-sort") !== "ascending";
+    th.parentNode.querySelectorAll("th").forEach(function (other) { other.removeAttribute("aria-sort"); });
+    th.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+    var body = table.tBodies[0];
+    var rows = Array.prototype.slice.call(body.rows);
+    rows.sort(function (a, b) {
+      var x = a.cells[index].dataset.sort, y = b.cells[index].dataset.sort;
+      var order = numeric ? parseFloat(x) - parseFloat(y) : x.localeCompare(y);
+      return ascending ? order : -order;
+    });
+    rows.forEach(function (row) { body.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`
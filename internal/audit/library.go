@@ -0,0 +1,283 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/textdiff"
+)
+
+// LibraryName is the file the generated rules library is written to next to config.jsonnet.
+const LibraryName = "chronosweep.libsonnet"
+
+// day is the unit the library header reports the audit window in.
+const day = 24 * time.Hour
+
+// libraryImport is the line that binds the library in config.jsonnet.
+const libraryImport = "local chronosweep = import '" + LibraryName + "';"
+
+var (
+	libraryImportRe = regexp.MustCompile(`import\s+['"]` + regexp.QuoteMeta(LibraryName) + `['"]`)
+	labelNameRe     = regexp.MustCompile(`\bname:\s*'((?:[^'\\]|\\.)*)'|\bname:\s*"((?:[^"\\]|\\.)*)"`)
+	errNoRulesField = errors.New("no top-level rules: field to extend")
+	errNoConfigPath = errors.New("gmailctl config path must not be empty")
+)
+
+// ruleGroup is one generated rule: every proposal sharing an action and label becomes an or: filter.
+type ruleGroup struct {
+	action    string
+	label     string
+	proposals []RuleProposal
+}
+
+// RulesLibrary renders the report's proposals as a formatted gmailctl Jsonnet library exposing labels
+// and rules. Proposals are grouped by action and label into one or: filter each, with each target's
+// evidence as a comment; amendments to existing rules are listed as comments since they edit rules the
+// library does not own.
+func RulesLibrary(rep Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s, generated by chronosweep-audit at %s from %d messages over %d days.\n",
+		LibraryName, rep.GeneratedAt.UTC().Format(time.RFC3339), rep.Total, int(rep.Window/day))
+	b.WriteString("// Regenerate rather than edit. Use it from config.jsonnet:\n//\n")
+	fmt.Fprintf(&b, "//   %s\n", libraryImport)
+	b.WriteString("//   { labels: chronosweep.labelsExcept([...]) + [...], rules: chronosweep.rules + [...] }\n//\n")
+	b.WriteString("// passing labelsExcept the names config.jsonnet already declares.\n")
+	b.WriteString("{\n")
+	groups := groupProposals(rep.Suggestions.Rules)
+	b.WriteString("  labels: [\n")
+	for _, group := range groups {
+		fmt.Fprintf(&b, "    { name: %s },\n", jsonnetString(group.label))
+	}
+	b.WriteString("  ],\n")
+	b.WriteString("  // labelsExcept drops labels config.jsonnet already declares so the two lists can be joined.\n")
+	b.WriteString("  labelsExcept(names):: [l for l in self.labels if !std.member(names, l.name)],\n")
+	b.WriteString("  rules: [\n")
+	for _, group := range groups {
+		writeGroup(&b, group)
+	}
+	b.WriteString("  ],\n")
+	if len(rep.Suggestions.Amendments) > 0 {
+		b.WriteString("  // Existing rules to amend rather than duplicate:\n")
+		for _, am := range rep.Suggestions.Amendments {
+			fmt.Fprintf(&b, "  //   %s matches %s %s: add %s\n", am.Rule, am.Kind, am.Target, am.Add)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// groupProposals buckets proposals by action and label, keeping the order each bucket first appears.
+func groupProposals(proposals []RuleProposal) []ruleGroup {
+	var groups []ruleGroup
+	for _, proposal := range proposals {
+		idx := -1
+		for i, group := range groups {
+			if group.action == proposal.Action && group.label == proposal.Label {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			groups = append(groups, ruleGroup{action: proposal.Action, label: proposal.Label})
+			idx = len(groups) - 1
+		}
+		groups[idx].proposals = append(groups[idx].proposals, proposal)
+	}
+	return groups
+}
+
+func writeGroup(b *strings.Builder, group ruleGroup) {
+	total := 0
+	for _, proposal := range group.proposals {
+		total += proposal.Count
+	}
+	actions := "archive: true, markRead: true"
+	summary := "Archive and mark read"
+	if group.action == ActionKeep {
+		actions = "markImportant: true"
+		summary = "Keep in inbox (sensitive)"
+	}
+	fmt.Fprintf(b, "    // %s (%d messages).\n", summary, total)
+	b.WriteString("    {\n      filter: {\n        or: [\n")
+	for _, proposal := range group.proposals {
+		key, value := "list", proposal.Target
+		if proposal.Kind == TargetSender {
+			key, value = "from", "*@"+proposal.Target
+		}
+		evidence := fmt.Sprintf("%d messages, noise %.1f", proposal.Count, proposal.Noise)
+		if proposal.Reason != "" {
+			evidence += "; " + proposal.Reason
+		}
+		fmt.Fprintf(b, "          { %s: %s },  // %s\n", key, jsonnetString(value), evidence)
+	}
+	b.WriteString("        ],\n      },\n")
+	fmt.Fprintf(b, "      actions: { %s, labels: [%s] },\n    },\n", actions, jsonnetString(group.label))
+}
+
+// jsonnetString quotes s as a single-quoted Jsonnet string.
+func jsonnetString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`).Replace(s) + "'"
+}
+
+// RulesPatch returns a unified diff, relative to configPath's directory (apply it there with git apply
+// or patch -p1), that writes the report's rules library next to config.jsonnet and wires it in by
+// importing it, prepending chronosweep.rules to the top-level rules: field and, when there is a top-level
+// labels: field, prepending the library's labels that config.jsonnet does not declare yet. A config that already
+// imports the library is left alone, so the diff then only refreshes the library.
+func RulesPatch(rep Report, configPath string) (string, error) {
+	if strings.TrimSpace(configPath) == "" {
+		return "", errNoConfigPath
+	}
+	clean := filepath.Clean(configPath)
+	config, err := os.ReadFile(clean) // #nosec G304 -- the config the user named
+	if err != nil {
+		return "", fmt.Errorf("read gmailctl config %s: %w", clean, err)
+	}
+	libPath := filepath.Join(filepath.Dir(clean), LibraryName)
+	current, err := os.ReadFile(libPath) // #nosec G304 -- sibling of the config the user named
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("read rules library %s: %w", libPath, err)
+	}
+	wired, err := wireLibrary(string(config))
+	if err != nil {
+		return "", fmt.Errorf("wire %s into %s: %w", LibraryName, clean, err)
+	}
+	configName := filepath.Base(clean)
+	oldLib := ""
+	if current != nil {
+		oldLib = "a/" + LibraryName
+	}
+	patch := textdiff.Unified("a/"+configName, "b/"+configName, string(config), wired)
+	patch += textdiff.Unified(oldLib, "b/"+LibraryName, string(current), RulesLibrary(rep))
+	return patch, nil
+}
+
+// wireLibrary imports the library after config's leading comments, prepends its rules to the top-level
+// rules: field and its labels to the top-level labels: field, if any. Labels config already names are
+// filtered out of the library's so gmailctl does not see them declared twice.
+func wireLibrary(config string) (string, error) {
+	if libraryImportRe.MatchString(config) {
+		return config, nil
+	}
+	rulesAt := topLevelField(config, "rules")
+	if rulesAt < 0 {
+		return "", errNoRulesField
+	}
+	labelsAt := topLevelField(config, "labels")
+	config = config[:rulesAt] + "chronosweep.rules + " + config[rulesAt:]
+	if labelsAt >= 0 {
+		if labelsAt > rulesAt {
+			labelsAt += len("chronosweep.rules + ")
+		}
+		config = config[:labelsAt] + libraryLabels(declaredLabels(config)) + " + " + config[labelsAt:]
+	}
+	insert := 0
+	for insert < len(config) {
+		end := strings.IndexByte(config[insert:], '\n')
+		if end < 0 {
+			break
+		}
+		line := strings.TrimSpace(config[insert : insert+end])
+		if line != "" && !strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "#") {
+			break
+		}
+		insert += end + 1
+	}
+	return config[:insert] + libraryImport + "\n" + config[insert:], nil
+}
+
+// libraryLabels is the expression for the library's labels minus those config already declares.
+func libraryLabels(declared []string) string {
+	if len(declared) == 0 {
+		return "chronosweep.labels"
+	}
+	quoted := make([]string, 0, len(declared))
+	for _, name := range declared {
+		quoted = append(quoted, jsonnetString(name))
+	}
+	return "chronosweep.labelsExcept([" + strings.Join(quoted, ", ") + "])"
+}
+
+// declaredLabels collects the label names config spells out as name: fields, in order and without
+// repeats. Only labels field entries carry a name in gmailctl configs.
+func declaredLabels(config string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, match := range labelNameRe.FindAllStringSubmatch(config, -1) {
+		name := match[1] + match[2]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// topLevelField returns the offset just past "field:" and any following blanks in config's outermost
+// object, or -1. Strings and comments are skipped so nested action labels: and quoted text do not match.
+func topLevelField(config, field string) int {
+	depth := 0
+	for i := 0; i < len(config); i++ {
+		switch c := config[i]; {
+		case c == '\'' || c == '"':
+			i = skipString(config, i)
+		case c == '#' || strings.HasPrefix(config[i:], "//"):
+			if end := strings.IndexByte(config[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(config)
+			}
+		case strings.HasPrefix(config[i:], "/*"):
+			if end := strings.Index(config[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(config)
+			}
+		case c == '{' || c == '[' || c == '(':
+			depth++
+		case c == '}' || c == ']' || c == ')':
+			depth--
+		case depth == 1 && strings.HasPrefix(config[i:], field) && (i == 0 || !isIdentByte(config[i-1])):
+			rest := strings.TrimLeft(config[i+len(field):], " \t")
+			if strings.HasPrefix(rest, ":") && !strings.HasPrefix(rest, "::") {
+				after := rest[1:]
+				return len(config) - len(strings.TrimLeft(after, " \t"))
+			}
+		}
+	}
+	return -1
+}
+
+// skipString returns the offset of the quote closing the string that opens at start.
+func skipString(config string, start int) int {
+	quote := config[start]
+	for i := start + 1; i < len(config); i++ {
+		switch config[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return len(config)
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// WriteRulesLibrary writes RulesLibrary(rep) to path.
+func WriteRulesLibrary(rep Report, path string) error {
+	clean := filepath.Clean(path)
+	if err := os.WriteFile(clean, []byte(RulesLibrary(rep)), 0o600); err != nil {
+		return fmt.Errorf("write rules library %s: %w", clean, err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func libraryReport() Report {
+	rep := Report{GeneratedAt: time.Unix(1700000000, 0), Window: 48 * time.Hour, Total: 9}
+	rep.Suggestions.Rules = []RuleProposal{
+		{Action: ActionArchive, Kind: TargetList, Target: "alerts.example.com", Label: "bulk", Count: 5, Noise: 4.5},
+		{
+			Action: ActionKeep,
+			Kind:   TargetSender,
+			Target: "chase.com",
+			Label:  "sensitive",
+			Count:  2,
+			Reason: "built-in bank domain chase.com",
+		},
+		{Action: ActionArchive, Kind: TargetSender, Target: "shop.com", Label: "bulk", Count: 2, Noise: 2},
+	}
+	rep.Suggestions.Amendments = []Amendment{
+		{Rule: "LabelOps", Kind: TargetList, Target: "ops.example.com", Add: archiveActions},
+	}
+	return rep
+}
+
+func TestRulesLibraryGroupsByAction(t *testing.T) {
+	got := RulesLibrary(libraryReport())
+	want := `{
+  labels: [
+    { name: 'bulk' },
+    { name: 'sensitive' },
+  ],
+  // labelsExcept drops labels config.jsonnet already declares so the two lists can be joined.
+  labelsExcept(names):: [l for l in self.labels if !std.member(names, l.name)],
+  rules: [
+    // Archive and mark read (7 messages).
+    {
+      filter: {
+        or: [
+          { list: 'alerts.example.com' },  // 5 messages, noise 4.5
+          { from: '*@shop.com' },  // 2 messages, noise 2.0
+        ],
+      },
+      actions: { archive: true, markRead: true, labels: ['bulk'] },
+    },
+    // Keep in inbox (sensitive) (2 messages).
+    {
+      filter: {
+        or: [
+          { from: '*@chase.com' },  // 2 messages, noise 0.0; built-in bank domain chase.com
+        ],
+      },
+      actions: { markImportant: true, labels: ['sensitive'] },
+    },
+  ],
+  // Existing rules to amend rather than duplicate:
+  //   LabelOps matches list ops.example.com: add { archive: true, markRead: true }
+}
+`
+	if !strings.HasSuffix(got, want) {
+		t.Fatalf("unexpected library:\n%s", got)
+	}
+	if !strings.Contains(got, "from 9 messages over 2 days") {
+		t.Fatalf("expected evidence in the header:\n%s", got)
+	}
+}
+
+func TestJSONNetString(t *testing.T) {
+	if got := jsonnetString(`it's a \ test`); got != `'it\'s a \\ test'` {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestWireLibrary(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "after leading comments",
+			config: "// mine\n\n{\n  rules: [\n  ],\n}\n",
+			want:   "// mine\n\n" + libraryImport + "\n{\n  rules: chronosweep.rules + [\n  ],\n}\n",
+		},
+		{
+			name:   "rules bound to a local",
+			config: "local rules = [];\n{ version: 'v1alpha3',\n  rules: rules,\n}\n",
+			want: libraryImport + "\nlocal rules = [];\n" +
+				"{ version: 'v1alpha3',\n  rules: chronosweep.rules + rules,\n}\n",
+		},
+		{
+			name:   "already imported",
+			config: "local chronosweep = import \"chronosweep.libsonnet\";\n{ rules: [] }\n",
+			want:   "local chronosweep = import \"chronosweep.libsonnet\";\n{ rules: [] }\n",
+		},
+		{
+			name:   "labels field",
+			config: "{\n  labels: [\n    { name: 'bulk' },\n    { name: \"it's\" },\n  ],\n  rules: [],\n}\n",
+			want: libraryImport + "\n{\n  labels: chronosweep.labelsExcept(['bulk', 'it\\'s']) + [\n" +
+				"    { name: 'bulk' },\n    { name: \"it's\" },\n  ],\n  rules: chronosweep.rules + [],\n}\n",
+		},
+		{
+			name: "labels after rules with nested action labels",
+			config: "{\n  rules: [\n    { filter: { from: 'a' }, actions: {\n      labels: ['x'] } },\n  ],\n" +
+				"  // labels: in a comment\n  labels: local,\n}\n",
+			want: libraryImport + "\n{\n  rules: chronosweep.rules + [\n" +
+				"    { filter: { from: 'a' }, actions: {\n      labels: ['x'] } },\n  ],\n" +
+				"  // labels: in a comment\n  labels: chronosweep.labels + local,\n}\n",
+		},
+		{
+			name:   "nested rules before the top-level field",
+			config: "{\n  tests: [{\n    rules: 'mine',\n  }],\n  rules: [],\n}\n",
+			want: libraryImport + "\n{\n  tests: [{\n    rules: 'mine',\n  }],\n" +
+				"  rules: chronosweep.rules + [],\n}\n",
+		},
+		{name: "only nested rules", config: "{ tests: [{ rules: [] }] }\n", wantErr: true},
+		{name: "no rules field", config: "{ version: 'v1alpha3' }\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := wireLibrary(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("wire: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesPatch(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.jsonnet")
+	original := "{\n  labels: [{ name: 'bulk' }],\n  rules: [],\n}\n"
+	if err := os.WriteFile(config, []byte(original), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	patch, err := RulesPatch(libraryReport(), config)
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	for _, want := range []string{
+		"--- a/config.jsonnet\n+++ b/config.jsonnet\n",
+		"+" + libraryImport + "\n",
+		"+  labels: chronosweep.labelsExcept(['bulk']) + [{ name: 'bulk' }],\n",
+		"+  rules: chronosweep.rules + [],\n",
+		"--- /dev/null\n+++ b/chronosweep.libsonnet\n",
+		"+          { list: 'alerts.example.com' },",
+	} {
+		if !strings.Contains(patch, want) {
+			t.Fatalf("patch lacks %q:\n%s", want, patch)
+		}
+	}
+
+	// Once applied, a regenerated patch only touches the library.
+	wired := libraryImport + "\n{\n  rules: chronosweep.rules + [],\n}\n"
+	if err := os.WriteFile(config, []byte(wired), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := WriteRulesLibrary(libraryReport(), filepath.Join(dir, LibraryName)); err != nil {
+		t.Fatalf("write library: %v", err)
+	}
+	rep := libraryReport()
+	rep.Total = 10
+	patch, err = RulesPatch(rep, config)
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if strings.Contains(patch, "a/config.jsonnet") || !strings.HasPrefix(patch, "--- a/chronosweep.libsonnet\n") {
+		t.Fatalf("expected only a library update:\n%s", patch)
+	}
+}
@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// listing builds the message listing for opts. Searches carry the window in the query itself; label
+// listings return a cutoff for fetchMetadata to apply client-side instead.
+func (s *Service) listing(opts Options, labelsByName map[string]gmail.LabelID) (gmail.Query, time.Time, error) {
+	if !opts.MetadataOnly {
+		if len(opts.Labels) > 0 {
+			return gmail.Query{}, time.Time{}, gmail.Classify(
+				gmail.ClassInvalidConfig,
+				errors.New("labels require metadata-only listing"),
+			)
+		}
+		return gmail.Search(gmail.NewerThan(opts.Window)), time.Time{}, nil
+	}
+	query := gmail.Query{}
+	for _, name := range opts.Labels {
+		id, err := resolveLabel(name, labelsByName)
+		if err != nil {
+			return gmail.Query{}, time.Time{}, err
+		}
+		query.LabelIDs = append(query.LabelIDs, id)
+	}
+	return query, s.Clock().Add(-opts.Window), nil
+}
+
+// resolveLabel maps a user label name to its ID. Names not found are accepted only when they look like
+// a system label ID (INBOX, CATEGORY_UPDATES, ...), which some backends omit from ListLabels.
+func resolveLabel(name string, labelsByName map[string]gmail.LabelID) (gmail.LabelID, error) {
+	name = strings.TrimSpace(name)
+	if id, ok := labelsByName[name]; ok {
+		return id, nil
+	}
+	isSystem := name != ""
+	for _, r := range name {
+		if (r < 'A' || r > 'Z') && r != '_' {
+			isSystem = false
+			break
+		}
+	}
+	if !isSystem {
+		return "", gmail.Classify(gmail.ClassInvalidConfig, fmt.Errorf("unknown label %q", name))
+	}
+	return gmail.LabelID(name), nil
+}
+
+// withinWindow keeps the messages dated at or after cutoff and reports whether any fell before it.
+// A zero cutoff keeps everything.
+func withinWindow(metas []gmail.MessageMeta, cutoff time.Time) ([]gmail.MessageMeta, bool) {
+	if cutoff.IsZero() {
+		return metas, false
+	}
+	kept := metas[:0]
+	exhausted := false
+	for _, meta := range metas {
+		if meta.Date.Before(cutoff) {
+			exhausted = true
+			continue
+		}
+		kept = append(kept, meta)
+	}
+	return kept, exhausted
+}
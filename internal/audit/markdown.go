@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/joshsymonds/chronosweep/internal/unsubscribe"
+)
+
+// MarkdownRenderer writes the report as GitHub-flavoured Markdown for pasting into PRs and wikis.
+type MarkdownRenderer struct{}
+
+// Render writes rep as Markdown: a summary, ranking tables, suggested rules in jsonnet blocks and the
+// lint findings.
+func (MarkdownRenderer) Render(rep Report, w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# chronosweep audit\n\nWindow %s, %d messages", rep.Window, rep.Total)
+	if !rep.GeneratedAt.IsZero() {
+		fmt.Fprintf(&b, ", generated %s", rep.GeneratedAt.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	b.WriteString(".\n")
+	if len(rep.Classes) > 0 {
+		b.WriteString("\n| Class | Messages |\n|---|---:|\n")
+		for _, class := range messageClasses() {
+			if count := rep.Classes[class]; count > 0 {
+				fmt.Fprintf(&b, "| %s | %d |\n", class, count)
+			}
+		}
+	}
+	if len(rep.TopSenders) > 0 {
+		b.WriteString("\n## Top senders\n\n")
+		b.WriteString("| Domain | Count | Human | Read | Star | Reply | Noise | Unsubscribe | Preview |\n")
+		b.WriteString("|---|---:|---:|---:|---:|---:|---:|---|---|\n")
+		for _, s := range rep.TopSenders {
+			fmt.Fprintf(&b, "| %s | %s | %d | %s | %s |\n", markdownCell(s.Domain),
+				formatCount(s.Count, s.Overestimate), s.HumanCount,
+				markdownEngagement(s.Engagement), markdownUnsubscribe(s.Unsubscribe, s.PreviewSubject))
+		}
+	}
+	if len(rep.TopLists) > 0 {
+		b.WriteString("\n## Top lists\n\n")
+		b.WriteString("| List-Id | Count | Read | Star | Reply | Noise | Unsubscribe | Preview |\n")
+		b.WriteString("|---|---:|---:|---:|---:|---:|---|---|\n")
+		for _, l := range rep.TopLists {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", markdownCell(l.ListID), formatCount(l.Count, l.Overestimate),
+				markdownEngagement(l.Engagement), markdownUnsubscribe(l.Unsubscribe, l.PreviewSubject))
+		}
+	}
+	writeMarkdownSuggestions(&b, rep.Suggestions)
+	writeMarkdownFindings(&b, rep)
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("write markdown report: %w", err)
+	}
+	return nil
+}
+
+func writeMarkdownSuggestions(b *strings.Builder, s Suggestions) {
+	if len(s.ArchiveRules) > 0 {
+		b.WriteString("\n## Suggested gmailctl rules\n")
+		for _, snip := range s.ArchiveRules {
+			fmt.Fprintf(b, "\n```jsonnet\n%s\n```\n", snip)
+		}
+	}
+	if len(s.Amendments) > 0 {
+		b.WriteString("\n## Amend existing gmailctl rules\n\n")
+		for _, am := range s.Amendments {
+			fmt.Fprintf(b, "- `%s` matches %s `%s`: add `%s`\n", am.Rule, am.Kind, am.Target, am.Add)
+		}
+	}
+	if len(s.Escalations) > 0 {
+		b.WriteString("\n## Keep in inbox (sensitive)\n")
+		for _, esc := range s.Escalations {
+			fmt.Fprintf(b, "\n%s `%s`: %s\n\n```jsonnet\n%s\n```\n", esc.Kind, esc.Target, esc.Reason, esc.Rule)
+		}
+	}
+}
+
+func writeMarkdownFindings(b *strings.Builder, rep Report) {
+	f := rep.Findings
+	if len(f.DeadRules) > 0 || len(f.MissingLabels) > 0 || len(f.Conflicts) > 0 {
+		b.WriteString("\n## Lint findings\n\n")
+		for _, fr := range f.DeadRules {
+			fmt.Fprintf(b, "- dead rule `%s`: %s\n", fr.Name, fr.Reason)
+		}
+		for _, lbl := range f.MissingLabels {
+			fmt.Fprintf(b, "- missing label `%s`\n", lbl)
+		}
+		for _, cf := range f.Conflicts {
+			fmt.Fprintf(b, "- conflict between `%s`: %s\n", strings.Join(cf.Rules, "`, `"), cf.Description)
+		}
+	}
+	if rep.StaleLabels > 0 {
+		fmt.Fprintf(b, "\nLabels of %d messages came from the cache unrefreshed; engagement may be out of date.\n",
+			rep.StaleLabels)
+	}
+	if rep.SkippedTotal > 0 {
+		fmt.Fprintf(b, "\nSkipped %d messages that could not be fetched.\n", rep.SkippedTotal)
+	}
+	if rep.UnparsedTotal > 0 {
+		fmt.Fprintf(b, "\nLeft out %d imported messages whose headers could not be parsed.\n", rep.UnparsedTotal)
+	}
+}
+
+func markdownEngagement(e Engagement) string {
+	return fmt.Sprintf("%.0f%% | %.0f%% | %.0f%% | %.1f",
+		e.ReadRate*percent, e.StarRate*percent, e.ReplyRate*percent, e.Noise)
+}
+
+func markdownUnsubscribe(methods *unsubscribe.Methods, preview string) string {
+	mechanisms := ""
+	if methods != nil {
+		mechanisms = methods.String()
+	}
+	return markdownCell(mechanisms) + " | " + markdownCell(truncate(preview, previewSubjectDisplayLimit))
+}
+
+// markdownCell escapes s for a table cell: pipes would end the cell and newlines the row.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,323 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Renderer writes a Report in one output format.
+type Renderer interface {
+	Render(rep Report, w io.Writer) error
+}
+
+// Output formats accepted by ParseFormats.
+const (
+	FormatHuman    = "human"
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+	FormatHTML     = "html"
+)
+
+// reportBase names the files WriteReports creates, e.g. audit.md or audit-senders.csv.
+const reportBase = "audit"
+
+var (
+	errUnknownFormat = errors.New("unknown report format")
+	errNoFormat      = errors.New("no report format selected")
+	errNeedOutDir    = errors.New("several report files need an output directory")
+)
+
+// Output pairs a renderer with the file name it is written to inside an output directory.
+type Output struct {
+	Name     string
+	Renderer Renderer
+}
+
+// ParseFormats turns a comma separated format list into outputs, in order and without duplicates.
+// "md" is accepted for markdown and "text" for human; csv expands to one file per CSVSection.
+func ParseFormats(raw string) ([]Output, error) {
+	var outputs []Output
+	seen := map[string]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		format := strings.ToLower(strings.TrimSpace(part))
+		switch format {
+		case "":
+			continue
+		case "md":
+			format = FormatMarkdown
+		case "text":
+			format = FormatHuman
+		}
+		if seen[format] {
+			continue
+		}
+		seen[format] = true
+		switch format {
+		case FormatHuman:
+			outputs = append(outputs, Output{Name: reportBase + ".txt", Renderer: HumanRenderer{}})
+		case FormatJSON:
+			outputs = append(outputs, Output{Name: reportBase + ".json", Renderer: JSONRenderer{}})
+		case FormatMarkdown:
+			outputs = append(outputs, Output{Name: reportBase + ".md", Renderer: MarkdownRenderer{}})
+		case FormatHTML:
+			outputs = append(outputs, Output{Name: reportBase + ".html", Renderer: HTMLRenderer{}})
+		case FormatCSV:
+			for _, section := range csvSections() {
+				outputs = append(outputs, Output{
+					Name:     fmt.Sprintf("%s-%s.csv", reportBase, section),
+					Renderer: CSVRenderer{Section: section},
+				})
+			}
+		default:
+			return nil, fmt.Errorf("%w %q (want human, json, markdown, csv or html)", errUnknownFormat, part)
+		}
+	}
+	if len(outputs) == 0 {
+		return nil, errNoFormat
+	}
+	return outputs, nil
+}
+
+// WriteReports renders rep once per output. With an empty dir a single output goes to stdout; otherwise
+// dir is created if needed and each output is written to its file there.
+func WriteReports(rep Report, outputs []Output, dir string, stdout io.Writer) error {
+	if strings.TrimSpace(dir) == "" {
+		if len(outputs) != 1 {
+			return fmt.Errorf("%w: %d files", errNeedOutDir, len(outputs))
+		}
+		return outputs[0].Renderer.Render(rep, stdout)
+	}
+	clean := filepath.Clean(dir)
+	if err := os.MkdirAll(clean, 0o700); err != nil {
+		return fmt.Errorf("create report dir %s: %w", clean, err)
+	}
+	for _, out := range outputs {
+		if err := writeReport(rep, out, filepath.Join(clean, out.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeReport(rep Report, out Output, path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) // #nosec G304 -- under -out
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	if renderErr := out.Renderer.Render(rep, f); renderErr != nil {
+		_ = f.Close()
+		return fmt.Errorf("render %s: %w", path, renderErr)
+	}
+	if closeErr := f.Close(); closeErr != nil {
+		return fmt.Errorf("close %s: %w", path, closeErr)
+	}
+	return nil
+}
+
+// HumanRenderer is the plain-text report.
+type HumanRenderer struct{}
+
+// JSONRenderer is the indented JSON report chronosweep-lint reads.
+type JSONRenderer struct{}
+
+// PrintHuman writes a readable report to the provided writer.
+func PrintHuman(rep Report, w io.Writer) error {
+	if w == nil {
+		w = os.Stdout
+	}
+	return HumanRenderer{}.Render(rep, w)
+}
+
+// Render writes the plain-text report chronosweep-audit prints by default.
+func (HumanRenderer) Render(rep Report, w io.Writer) error {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "chronosweep audit — window %s (%d messages)\n", rep.Window, rep.Total)
+	if len(rep.Classes) > 0 {
+		parts := make([]string, 0, len(rep.Classes))
+		for _, class := range messageClasses() {
+			if count := rep.Classes[class]; count > 0 {
+				parts = append(parts, fmt.Sprintf("%s %d", class, count))
+			}
+		}
+		fmt.Fprintf(&builder, "Traffic: %s\n", strings.Join(parts, ", "))
+	}
+	if len(rep.TopSenders) > 0 {
+		builder.WriteString("\nTop senders:\n")
+		for _, s := range rep.TopSenders {
+			fmt.Fprintf(
+				&builder,
+				"  %-30s %4s %s %s\n",
+				s.Domain,
+				formatCount(s.Count, s.Overestimate),
+				formatEngagement(s.Engagement),
+				truncate(s.PreviewSubject, previewSubjectDisplayLimit),
+			)
+		}
+	}
+	if len(rep.TopLists) > 0 {
+		builder.WriteString("\nTop lists:\n")
+		for _, l := range rep.TopLists {
+			fmt.Fprintf(
+				&builder,
+				"  %-30s %4s %s %s\n",
+				l.ListID,
+				formatCount(l.Count, l.Overestimate),
+				formatEngagement(l.Engagement),
+				truncate(l.PreviewSubject, previewSubjectDisplayLimit),
+			)
+		}
+	}
+	writeUnsubscribe(&builder, rep)
+	if len(rep.Suggestions.ArchiveRules) > 0 {
+		builder.WriteString("\nSuggested gmailctl snippets:\n")
+		for _, snip := range rep.Suggestions.ArchiveRules {
+			fmt.Fprintf(&builder, "%s\n\n", snip)
+		}
+	}
+	if len(rep.Suggestions.Amendments) > 0 {
+		builder.WriteString("\nAmend existing gmailctl rules:\n")
+		for _, am := range rep.Suggestions.Amendments {
+			fmt.Fprintf(&builder, "  %s (matches %s %s): add %s\n", am.Rule, am.Kind, am.Target, am.Add)
+		}
+	}
+	if len(rep.Suggestions.Escalations) > 0 {
+		builder.WriteString("\nKeep in inbox (sensitive):\n")
+		for _, esc := range rep.Suggestions.Escalations {
+			fmt.Fprintf(&builder, "  %s %s — %s\n%s\n\n", esc.Kind, esc.Target, esc.Reason, esc.Rule)
+		}
+	}
+	if len(rep.Findings.DeadRules) > 0 || len(rep.Findings.MissingLabels) > 0 ||
+		len(rep.Findings.Conflicts) > 0 {
+		builder.WriteString("\nLint findings:\n")
+		for _, fr := range rep.Findings.DeadRules {
+			fmt.Fprintf(&builder, "  dead rule: %s — %s\n", fr.Name, fr.Reason)
+		}
+		for _, lbl := range rep.Findings.MissingLabels {
+			fmt.Fprintf(&builder, "  missing label: %s\n", lbl)
+		}
+		for _, cf := range rep.Findings.Conflicts {
+			fmt.Fprintf(
+				&builder,
+				"  conflict: %s (%s)\n",
+				strings.Join(cf.Rules, ", "),
+				cf.Description,
+			)
+		}
+	}
+	if rep.StaleLabels > 0 {
+		fmt.Fprintf(
+			&builder,
+			"\nLabels of %d messages came from the cache unrefreshed; engagement may be out of date.\n",
+			rep.StaleLabels,
+		)
+	}
+	if rep.SkippedTotal > 0 {
+		fmt.Fprintf(&builder, "\nSkipped %d messages that could not be fetched.\n", rep.SkippedTotal)
+	}
+	if rep.UnparsedTotal > 0 {
+		fmt.Fprintf(&builder, "\nLeft out %d imported messages whose headers could not be parsed.\n", rep.UnparsedTotal)
+	}
+	if _, err := io.WriteString(w, builder.String()); err != nil {
+		return fmt.Errorf("write human report: %w", err)
+	}
+	return nil
+}
+
+// writeUnsubscribe lists how each ranked sender and list can be unsubscribed from.
+func writeUnsubscribe(builder *strings.Builder, rep Report) {
+	var lines []string
+	for _, l := range rep.TopLists {
+		if l.Unsubscribe != nil {
+			lines = append(lines, fmt.Sprintf("  %s %-30s %s\n", TargetList, l.ListID, l.Unsubscribe))
+		}
+	}
+	for _, s := range rep.TopSenders {
+		if s.Unsubscribe != nil {
+			lines = append(lines, fmt.Sprintf("  %s %-30s %s\n", TargetSender, s.Domain, s.Unsubscribe))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	builder.WriteString("\nUnsubscribe:\n")
+	for _, line := range lines {
+		builder.WriteString(line)
+	}
+}
+
+// formatEngagement renders rates as whole percentages for the human report.
+func formatEngagement(e Engagement) string {
+	return fmt.Sprintf(
+		"read %3.0f%% star %3.0f%% reply %3.0f%% noise %6.1f",
+		e.ReadRate*percent,
+		e.StarRate*percent,
+		e.ReplyRate*percent,
+		e.Noise,
+	)
+}
+
+// formatCount renders a sketch count, as the range the true count lies in when it may be overestimated.
+func formatCount(count, overestimate int) string {
+	if overestimate == 0 {
+		return strconv.Itoa(count)
+	}
+	return fmt.Sprintf("%d-%d", count-overestimate, count)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-1] + "…"
+}
+
+// Render writes rep as indented JSON.
+func (JSONRenderer) Render(rep Report, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON serializes the report to disk.
+func WriteJSON(rep Report, path string) error {
+	clean := strings.TrimSpace(path)
+	if clean == "" {
+		return fmt.Errorf("path must not be empty")
+	}
+	clean = filepath.Clean(clean)
+	if filepath.IsAbs(clean) {
+		return fmt.Errorf("output path must be relative, got %s", clean)
+	}
+	if strings.HasPrefix(clean, "..") {
+		return fmt.Errorf("output path %s escapes working directory", clean)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("determine working directory: %w", err)
+	}
+	abs := filepath.Join(wd, clean)
+	f, err := os.OpenFile(abs, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("create %s: %w", abs, err)
+	}
+	defer func() { _ = f.Close() }()
+	return JSONRenderer{}.Render(rep, f)
+}
+
+var (
+	_ Renderer = HumanRenderer{}
+	_ Renderer = JSONRenderer{}
+	_ Renderer = MarkdownRenderer{}
+	_ Renderer = CSVRenderer{}
+	_ Renderer = HTMLRenderer{}
+)
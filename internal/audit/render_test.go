@@ -0,0 +1,275 @@
+package audit
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/unsubscribe"
+)
+
+func renderFixture() Report {
+	return Report{
+		GeneratedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Window:      30 * 24 * time.Hour,
+		Total:       60,
+		Classes:     map[MessageClass]int{ClassBulk: 40, ClassHuman: 20},
+		TopSenders: []SenderStat{
+			{
+				Domain: "shop.example", Count: 40, PreviewSubject: "Sale | 50% <b>off</b>",
+				Engagement:  Engagement{ReadRate: 0.25, Noise: 35},
+				Unsubscribe: &unsubscribe.Methods{OneClick: true, HTTPS: "https://shop.example/u"},
+			},
+			{Domain: "friend.example", Count: 20, HumanCount: 20, Engagement: Engagement{ReadRate: 1, Noise: 10}},
+		},
+		TopLists: []ListStat{
+			{ListID: "news.example.com", Count: 12, Overestimate: 5, Engagement: Engagement{Noise: 12}},
+		},
+		Suggestions: Suggestions{
+			ArchiveRules: []string{"{ filter: { from: '*@shop.example' } }"},
+			Rules: []RuleProposal{{
+				Action: ActionArchive, Kind: TargetSender, Target: "shop.example", Label: "bulk", Count: 40, Noise: 35,
+			}},
+			Amendments: []Amendment{
+				{Rule: "promos", Kind: TargetList, Target: "news.example.com", Add: "archive: true"},
+			},
+		},
+		Findings: GmailctlFindings{DeadRules: []RuleFinding{{Name: "old", Reason: "no matches"}}},
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "default", raw: "human", want: []string{"audit.txt"}},
+		{name: "aliases dedupe", raw: "md, markdown,text,human", want: []string{"audit.md", "audit.txt"}},
+		{
+			name: "csv expands per section",
+			raw:  "csv,html",
+			want: []string{
+				"audit-senders.csv", "audit-lists.csv", "audit-rules.csv", "audit-findings.csv", "audit.html",
+			},
+		},
+		{name: "unknown", raw: "json,pdf", wantErr: true},
+		{name: "empty", raw: " , ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputs, err := ParseFormats(tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", outputs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFormats: %v", err)
+			}
+			names := make([]string, 0, len(outputs))
+			for _, out := range outputs {
+				names = append(names, out.Name)
+			}
+			if strings.Join(names, " ") != strings.Join(tt.want, " ") {
+				t.Fatalf("outputs = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownRenderer(t *testing.T) {
+	var out strings.Builder
+	if err := (MarkdownRenderer{}).Render(renderFixture(), &out); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"| bulk | 40 |",
+		"| shop.example | 40 | 0 | 25% | 0% | 0% | 35.0 | one-click, https | Sale \\| 50% <b>off</b> |",
+		"| news.example.com | 7-12 | 0% | 0% | 0% | 12.0 |  |  |",
+		"```jsonnet\n{ filter: { from: '*@shop.example' } }\n```",
+		"- `promos` matches list `news.example.com`: add `archive: true`",
+		"- dead rule `old`: no matches",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("markdown missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestCSVRenderer(t *testing.T) {
+	tests := []struct {
+		section CSVSection
+		want    [][]string
+	}{
+		{
+			section: CSVSenders,
+			want: [][]string{
+				{
+					"domain", "count", "human_count", "read_rate", "star_rate", "reply_rate", "engagement", "noise",
+					"unsubscribe", "preview_subject", "overestimate",
+				},
+				{
+					"shop.example", "40", "0", "0.25", "0", "0", "0", "35", "one-click https", "Sale | 50% <b>off</b>",
+					"0",
+				},
+				{"friend.example", "20", "20", "1", "0", "0", "0", "10", "", "", "0"},
+			},
+		},
+		{
+			section: CSVRules,
+			want: [][]string{
+				{"action", "kind", "target", "label", "count", "noise", "reason"},
+				{"archive", "sender", "shop.example", "bulk", "40", "35", ""},
+				{"amend", "list", "news.example.com", "archive: true", "", "", "rule promos"},
+			},
+		},
+		{
+			section: CSVFindings,
+			want:    [][]string{{"finding", "subject", "detail", "count"}, {"dead-rule", "old", "no matches", ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.section), func(t *testing.T) {
+			var out strings.Builder
+			if err := (CSVRenderer{Section: tt.section}).Render(renderFixture(), &out); err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			records, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+			if err != nil {
+				t.Fatalf("read back csv: %v", err)
+			}
+			if len(records) != len(tt.want) {
+				t.Fatalf("got %d records, want %d:\n%s", len(records), len(tt.want), out.String())
+			}
+			for i := range tt.want {
+				if strings.Join(records[i], "\x00") != strings.Join(tt.want[i], "\x00") {
+					t.Fatalf("record %d = %q, want %q", i, records[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCSVRendererDefusesFormulas(t *testing.T) {
+	rep := Report{
+		TopSenders: []SenderStat{
+			{Domain: "@evil.example", Count: 1, PreviewSubject: `=HYPERLINK("https://evil.example","win")`},
+			{Domain: "ok.example", Count: 1, PreviewSubject: "-50% today"},
+			{Domain: "tab.example", Count: 1, PreviewSubject: "\tcmd"},
+			{Domain: "plain.example", Count: 1, PreviewSubject: "a=b"},
+		},
+		TopLists: []ListStat{{ListID: "+1.example.com", Count: 1, PreviewSubject: "\r=1+1"}},
+		Suggestions: Suggestions{
+			Rules: []RuleProposal{
+				{Action: ActionArchive, Kind: TargetSender, Target: "x", Reason: "=cmd|' /C calc'!A0"},
+			},
+		},
+	}
+	tests := []struct {
+		section CSVSection
+		row     int
+		col     int
+		want    string
+	}{
+		{section: CSVSenders, row: 1, col: 0, want: "'@evil.example"},
+		{section: CSVSenders, row: 1, col: 9, want: `'=HYPERLINK("https://evil.example","win")`},
+		{section: CSVSenders, row: 2, col: 9, want: "'-50% today"},
+		{section: CSVSenders, row: 3, col: 9, want: "'\tcmd"},
+		{section: CSVSenders, row: 4, col: 9, want: "a=b"},
+		{section: CSVLists, row: 1, col: 0, want: "'+1.example.com"},
+		{section: CSVLists, row: 1, col: 8, want: "'\r=1+1"},
+		{section: CSVRules, row: 1, col: 6, want: "'=cmd|' /C calc'!A0"},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		if err := (CSVRenderer{Section: tt.section}).Render(rep, &out); err != nil {
+			t.Fatalf("Render %s: %v", tt.section, err)
+		}
+		records, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+		if err != nil {
+			t.Fatalf("read back %s csv: %v", tt.section, err)
+		}
+		if got := records[tt.row][tt.col]; got != tt.want {
+			t.Fatalf("%s[%d][%d] = %q, want %q", tt.section, tt.row, tt.col, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLRenderer(t *testing.T) {
+	var out strings.Builder
+	if err := (HTMLRenderer{}).Render(renderFixture(), &out); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		`<table class="sortable">`,
+		`<th class="num" data-numeric>Count</th>`,
+		// The busiest sender's bar spans the column; the other is scaled to it.
+		`<td data-sort="40" class="num bar"><span style="width: 100.0%"></span>40</td>`,
+		`<td data-sort="20" class="num bar"><span style="width: 50.0%"></span>20</td>`,
+		`<label>bulk</label><span style="width: 100.0%"></span>40`,
+		"Sale | 50% &lt;b&gt;off&lt;/b&gt;",
+		"promos matches list news.example.com: add archive: true",
+		"<script>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("html missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "<b>off</b>") {
+		t.Fatalf("subject was not escaped")
+	}
+}
+
+func TestWriteReports(t *testing.T) {
+	rep := renderFixture()
+	outputs, err := ParseFormats("json,csv")
+	if err != nil {
+		t.Fatalf("ParseFormats: %v", err)
+	}
+	var stdout strings.Builder
+	if err := WriteReports(rep, outputs, "", &stdout); !errors.Is(err, errNeedOutDir) {
+		t.Fatalf("expected errNeedOutDir without a directory, got %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "reports")
+	if err := WriteReports(rep, outputs, dir, &stdout); err != nil {
+		t.Fatalf("WriteReports: %v", err)
+	}
+	for _, out := range outputs {
+		info, statErr := os.Stat(filepath.Join(dir, out.Name))
+		if statErr != nil {
+			t.Fatalf("missing %s: %v", out.Name, statErr)
+		}
+		if info.Size() == 0 {
+			t.Fatalf("%s is empty", out.Name)
+		}
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("nothing should reach stdout with a directory, got %q", stdout.String())
+	}
+
+	human, err := ParseFormats("human")
+	if err != nil {
+		t.Fatalf("ParseFormats human: %v", err)
+	}
+	var want strings.Builder
+	if err := PrintHuman(rep, &want); err != nil {
+		t.Fatalf("PrintHuman: %v", err)
+	}
+	if err := WriteReports(rep, human, "", &stdout); err != nil {
+		t.Fatalf("WriteReports stdout: %v", err)
+	}
+	if stdout.String() != want.String() {
+		t.Fatalf("human output differs from PrintHuman")
+	}
+}
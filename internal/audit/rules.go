@@ -271,21 +271,6 @@ func describeCriteria(c gmailctl.FilterCriteria) string {
 	return "gmailctl-rule"
 }
 
-func evaluateRules(rules []compiledRule, metas []gmail.MessageMeta) map[string][]gmail.MessageID {
-	matches := make(map[string][]gmail.MessageID, len(rules))
-	for _, rule := range rules {
-		if !rule.Evaluable {
-			continue
-		}
-		for _, meta := range metas {
-			if rule.matches(meta) {
-				matches[rule.Name] = append(matches[rule.Name], meta.ID)
-			}
-		}
-	}
-	return matches
-}
-
 func (r compiledRule) matches(meta gmail.MessageMeta) bool {
 	for _, m := range r.Matchers {
 		if !m.matches(meta) {
@@ -295,69 +280,6 @@ func (r compiledRule) matches(meta gmail.MessageMeta) bool {
 	return true
 }
 
-type ruleSummary struct {
-	Name    string
-	Actions ruleActions
-}
-
-func detectConflicts(rules []compiledRule, matches map[string][]gmail.MessageID) []Conflict {
-	byMessage := collectRuleSummaries(rules, matches)
-	seen := map[string]struct{}{}
-	conflicts := make([]Conflict, 0, len(byMessage))
-	for _, summaries := range byMessage {
-		archiveRules, starRules := classifySummaries(summaries)
-		if len(archiveRules) == 0 || len(starRules) == 0 {
-			continue
-		}
-		combined := mergeRuleSets(archiveRules, starRules)
-		key := strings.Join(combined, "|")
-		if _, ok := seen[key]; ok {
-			continue
-		}
-		seen[key] = struct{}{}
-		conflicts = append(conflicts, Conflict{
-			Rules:       combined,
-			Description: "archive and star rules overlap",
-		})
-	}
-	sort.Slice(conflicts, func(i, j int) bool {
-		return strings.Join(conflicts[i].Rules, "|") < strings.Join(conflicts[j].Rules, "|")
-	})
-	return conflicts
-}
-
-func collectRuleSummaries(
-	rules []compiledRule,
-	matches map[string][]gmail.MessageID,
-) map[gmail.MessageID][]ruleSummary {
-	byMessage := make(map[gmail.MessageID][]ruleSummary)
-	for _, rule := range rules {
-		ids := matches[rule.Name]
-		if len(ids) == 0 {
-			continue
-		}
-		summary := ruleSummary{Name: rule.Name, Actions: rule.Actions}
-		for _, id := range ids {
-			byMessage[id] = append(byMessage[id], summary)
-		}
-	}
-	return byMessage
-}
-
-func classifySummaries(summaries []ruleSummary) ([]string, []string) {
-	archiveRules := make([]string, 0, len(summaries))
-	starRules := make([]string, 0, len(summaries))
-	for _, summary := range summaries {
-		if summary.Actions.Archive {
-			archiveRules = appendIfMissing(archiveRules, summary.Name)
-		}
-		if summary.Actions.Star {
-			starRules = appendIfMissing(starRules, summary.Name)
-		}
-	}
-	return archiveRules, starRules
-}
-
 func mergeRuleSets(a, b []string) []string {
 	combined := append([]string{}, a...)
 	for _, name := range b {
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultSensitiveLabel is applied by escalated suggestions when the policy names no label.
+const defaultSensitiveLabel = "sensitive"
+
+// SensitivityPolicy decides which senders and lists are too important to archive. Domains and list IDs
+// are matched against the built-in set (banks, payroll, government and legal services) unless
+// NoBuiltin is set, then against Domains (the domain or any subdomain) and Keywords (a substring of
+// it). Allow exempts a domain and its subdomains from every check.
+type SensitivityPolicy struct {
+	Keywords  []string
+	Domains   []string
+	Allow     []string
+	Label     string
+	NoBuiltin bool
+}
+
+// Escalation records a sender or list that would have been archived but is kept in the inbox instead,
+// with the reason and the gmailctl rule proposed in place of the archive rule.
+type Escalation struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+	Rule   string `json:"rule"`
+}
+
+// sensitiveCategory is one group of the built-in set.
+type sensitiveCategory struct {
+	name     string
+	domains  []string
+	suffixes []string
+	keywords []string
+}
+
+// builtinSensitive lists the built-in sensitive domains by category. Keywords are kept specific
+// because they match anywhere in a domain.
+func builtinSensitive() []sensitiveCategory {
+	return []sensitiveCategory{
+		{
+			name: "bank",
+			domains: []string{
+				"chase.com", "bankofamerica.com", "wellsfargo.com", "citi.com", "capitalone.com",
+				"americanexpress.com", "discover.com", "usbank.com", "schwab.com", "fidelity.com",
+				"vanguard.com", "paypal.com", "hsbc.com", "barclays.co.uk", "ally.com",
+			},
+			keywords: []string{"bank", "brokerage", "creditunion"},
+		},
+		{
+			name: "payroll",
+			domains: []string{
+				"adp.com", "gusto.com", "paychex.com", "workday.com", "rippling.com", "justworks.com",
+				"trinet.com",
+			},
+			keywords: []string{"payroll"},
+		},
+		{
+			name:     "government",
+			domains:  []string{"irs.gov", "ssa.gov", "hmrc.gov.uk", "cra-arc.gc.ca"},
+			suffixes: []string{".gov", ".mil", ".gov.uk", ".gc.ca", ".gov.au", ".europa.eu"},
+		},
+		{
+			name:     "legal",
+			domains:  []string{"docusign.net", "docusign.com", "uscourts.gov", "legalzoom.com"},
+			keywords: []string{"attorney", "lawfirm", "lawyer", "legal", "court"},
+		},
+	}
+}
+
+// label returns the label escalated rules apply.
+func (p SensitivityPolicy) label() string {
+	if label := strings.TrimSpace(p.Label); label != "" {
+		return label
+	}
+	return defaultSensitiveLabel
+}
+
+// match reports why target, a sender domain or list ID, is sensitive, or "" when it is not.
+func (p SensitivityPolicy) match(target string) string {
+	target = strings.ToLower(strings.TrimSpace(target))
+	if target == "" || matchesDomain(target, p.Allow) != "" {
+		return ""
+	}
+	if domain := matchesDomain(target, p.Domains); domain != "" {
+		return fmt.Sprintf("configured sensitive domain %s", domain)
+	}
+	if keyword := containsKeyword(target, p.Keywords); keyword != "" {
+		return fmt.Sprintf("contains configured keyword %q", keyword)
+	}
+	if p.NoBuiltin {
+		return ""
+	}
+	for _, category := range builtinSensitive() {
+		if domain := matchesDomain(target, category.domains); domain != "" {
+			return fmt.Sprintf("built-in %s domain %s", category.name, domain)
+		}
+		for _, suffix := range category.suffixes {
+			if strings.HasSuffix(target, suffix) {
+				return fmt.Sprintf("built-in %s suffix %s", category.name, suffix)
+			}
+		}
+		if keyword := containsKeyword(target, category.keywords); keyword != "" {
+			return fmt.Sprintf("contains %s keyword %q", category.name, keyword)
+		}
+	}
+	return ""
+}
+
+// matchesDomain returns the entry of domains that target equals or is a subdomain of.
+func matchesDomain(target string, domains []string) string {
+	for _, domain := range domains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain == "" {
+			continue
+		}
+		if target == domain || strings.HasSuffix(target, "."+domain) {
+			return domain
+		}
+	}
+	return ""
+}
+
+// containsKeyword returns the first keyword found in target.
+func containsKeyword(target string, keywords []string) string {
+	for _, keyword := range keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword != "" && strings.Contains(target, keyword) {
+			return keyword
+		}
+	}
+	return ""
+}
+
+// escalate builds the keep-in-inbox suggestion for target: label it and mark it important instead of
+// archiving it.
+func (p SensitivityPolicy) escalate(kind, target, reason string) Escalation {
+	return Escalation{
+		Kind:   kind,
+		Target: target,
+		Reason: reason,
+		Rule: fmt.Sprintf(`{
+  filter: { %s },
+  actions: { labels: [%q], markImportant: true },
+}`, ruleFilter(kind, target), p.label()),
+	}
+}
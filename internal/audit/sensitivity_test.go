@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+func TestSensitivityPolicyMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy SensitivityPolicy
+		target string
+		want   string
+	}{
+		{name: "ordinary", target: "shop.example", want: ""},
+		{name: "builtin bank", target: "alerts.chase.com", want: "built-in bank domain chase.com"},
+		{name: "builtin payroll", target: "adp.com", want: "built-in payroll domain adp.com"},
+		{name: "government suffix", target: "dmv.ca.gov", want: "built-in government suffix .gov"},
+		{name: "legal keyword", target: "smithlegal.example", want: `contains legal keyword "legal"`},
+		{name: "builtin disabled", policy: SensitivityPolicy{NoBuiltin: true}, target: "chase.com", want: ""},
+		{
+			name:   "configured domain",
+			policy: SensitivityPolicy{Domains: []string{"Landlord.example"}},
+			target: "mail.landlord.example",
+			want:   "configured sensitive domain landlord.example",
+		},
+		{
+			name:   "configured keyword",
+			policy: SensitivityPolicy{Keywords: []string{"clinic"}},
+			target: "dentalclinic.example",
+			want:   `contains configured keyword "clinic"`,
+		},
+		{
+			name:   "allow beats builtin",
+			policy: SensitivityPolicy{Allow: []string{"bankrate.com"}},
+			target: "news.bankrate.com",
+			want:   "",
+		},
+		{name: "not a suffix of a longer label", target: "notchase.com", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.match(tt.target); got != tt.want {
+				t.Fatalf("got %q want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceRunEscalatesSensitiveSenders(t *testing.T) {
+	client := &fakeAuditClient{
+		pages: []gmail.ListPage{{IDs: []gmail.MessageID{"1", "2", "3"}}},
+		metas: map[gmail.MessageID]gmail.MessageMeta{
+			"1": {ID: "1", Headers: map[string]string{"From": "alerts@chase.com", "Precedence": "bulk"}},
+			"2": {ID: "2", Headers: map[string]string{"From": "deals@shop.com", "List-Unsubscribe": "<x>"}},
+			"3": {ID: "3", Headers: map[string]string{"From": "billing@clinic.example", "Precedence": "bulk"}},
+		},
+	}
+	svc := NewService(client, nil, slogDiscard(), nil)
+	svc.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+
+	rep, err := svc.Run(context.Background(), Options{
+		Window:      24 * time.Hour,
+		TopN:        5,
+		Sensitivity: SensitivityPolicy{Domains: []string{"clinic.example"}, Label: "keep"},
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if len(rep.Suggestions.ArchiveRules) != 1 || !strings.Contains(rep.Suggestions.ArchiveRules[0], "shop.com") {
+		t.Fatalf("expected only shop.com to be archived, got %v", rep.Suggestions.ArchiveRules)
+	}
+	escalated := map[string]Escalation{}
+	for _, esc := range rep.Suggestions.Escalations {
+		escalated[esc.Target] = esc
+	}
+	bank, ok := escalated["chase.com"]
+	if !ok || bank.Kind != TargetSender || bank.Reason != "built-in bank domain chase.com" {
+		t.Fatalf("expected chase.com escalation, got %+v", rep.Suggestions.Escalations)
+	}
+	if !strings.Contains(bank.Rule, `labels: ["keep"], markImportant: true`) || strings.Contains(bank.Rule, "archive") {
+		t.Fatalf("unexpected escalated rule:\n%s", bank.Rule)
+	}
+	if _, ok := escalated["clinic.example"]; !ok {
+		t.Fatalf("expected configured domain to be escalated, got %+v", rep.Suggestions.Escalations)
+	}
+	var out strings.Builder
+	if err := PrintHuman(rep, &out); err != nil {
+		t.Fatalf("print: %v", err)
+	}
+	if !strings.Contains(out.String(), "sender chase.com — built-in bank domain chase.com") {
+		t.Fatalf("expected escalation in the human report:\n%s", out.String())
+	}
+}
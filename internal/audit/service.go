@@ -2,33 +2,45 @@ package audit
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"os"
-	"path/filepath"
-	"sort"
-	"strings"
+	"slices"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/joshsymonds/chronosweep/internal/gmail"
 	"github.com/joshsymonds/chronosweep/internal/gmailctl"
 	"github.com/joshsymonds/chronosweep/internal/rate"
+	"github.com/joshsymonds/chronosweep/internal/telemetry"
+	"github.com/joshsymonds/chronosweep/internal/unsubscribe"
 )
 
 const previewSubjectDisplayLimit = 60
 
 func defaultHeaders() []string {
-	return []string{"From", "To", "Subject", "List-Id", "Auto-Submitted", "Precedence"}
+	return []string{
+		"From", "To", "Subject", "List-Id", "Auto-Submitted", "Precedence",
+		"List-Unsubscribe", "List-Unsubscribe-Post", "X-Mailer", "Feedback-ID",
+	}
 }
 
 // Options controls the behavior of the audit analyzer.
+// MetadataOnly lists messages by label instead of searching, which is all the gmail.metadata scope
+// allows; Labels (names or system label IDs) then restricts the listing to messages carrying each label.
+// Workers bounds how many GetMetadata calls run at once; values below one mean one.
 type Options struct {
-	Window   time.Duration
-	TopN     int
-	PageSize int
-	Headers  []string
+	Window       time.Duration
+	TopN         int
+	PageSize     int
+	Headers      []string
+	MetadataOnly bool
+	Labels       []string
+	Workers      int
+	Sensitivity  SensitivityPolicy
 }
 
 // GmailctlLoader loads compiled gmailctl filters for replay.
@@ -36,6 +48,12 @@ type GmailctlLoader interface {
 	ExportFilters(ctx context.Context) (gmailctl.Export, error)
 }
 
+// UnparsedReporter is implemented by offline clients, such as mailbox imports, that left out messages
+// whose headers could not be parsed.
+type UnparsedReporter interface {
+	Unparsed() int
+}
+
 // Service executes audit analyses against Gmail metadata.
 type Service struct {
 	Client  gmail.Client
@@ -43,6 +61,7 @@ type Service struct {
 	Logger  *slog.Logger
 	Clock   func() time.Time
 	Loader  GmailctlLoader
+	Tracer  trace.Tracer
 }
 
 // NewService constructs a Service with sane defaults.
@@ -61,40 +80,67 @@ func NewService(
 		Logger:  logger,
 		Clock:   time.Now,
 		Loader:  loader,
+		Tracer:  otel.Tracer(telemetry.TracerName),
 	}
 }
 
 // Report summarizes recent inbox activity and suggestions.
 type Report struct {
-	GeneratedAt time.Time        `json:"generated_at"`
-	Window      time.Duration    `json:"window"`
-	Total       int              `json:"total"`
-	TopSenders  []SenderStat     `json:"top_senders"`
-	TopLists    []ListStat       `json:"top_lists"`
-	Coverage    map[string]int   `json:"coverage"`
-	Suggestions Suggestions      `json:"suggestions"`
-	Findings    GmailctlFindings `json:"findings"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Window      time.Duration  `json:"window"`
+	Total       int            `json:"total"`
+	TopSenders  []SenderStat   `json:"top_senders"`
+	TopLists    []ListStat     `json:"top_lists"`
+	Coverage    map[string]int `json:"coverage"`
+	// Classes counts messages per MessageClass.
+	Classes     map[MessageClass]int `json:"classes"`
+	Suggestions Suggestions          `json:"suggestions"`
+	Findings    GmailctlFindings     `json:"findings"`
+	// StaleLabels counts messages whose labels came from the cache without a refresh
+	// (-cache-labels stale), so engagement and coverage may lag what the mailbox shows now.
+	StaleLabels int `json:"stale_labels,omitempty"`
+	// SkippedTotal counts messages that could not be fetched; Skipped holds the first few of them.
+	SkippedTotal int              `json:"skipped_total,omitempty"`
+	Skipped      []SkippedMessage `json:"skipped,omitempty"`
+	// UnparsedTotal counts messages a local mbox or Maildir import left out because their headers could
+	// not be parsed.
+	UnparsedTotal int `json:"unparsed_total,omitempty"`
 }
 
-// SenderStat ranks noisy sender domains.
+// SenderStat ranks noisy sender domains. HumanCount is how many of the domain's messages looked
+// person-to-person; such domains never get archive suggestions, and neither do ones the user engages with.
+// Count is exact unless Overestimate is set: once a window has more distinct domains than the sketch
+// tracks, a domain may inherit an evicted one's count, and its true count lies between
+// Count-Overestimate and Count.
 type SenderStat struct {
 	Domain         string `json:"domain"`
 	Count          int    `json:"count"`
+	Overestimate   int    `json:"overestimate,omitempty"`
+	HumanCount     int    `json:"human_count"`
 	PreviewSubject string `json:"preview_subject"`
+	Engagement
+	Unsubscribe *unsubscribe.Methods `json:"unsubscribe,omitempty"`
 }
 
-// ListStat ranks noisy List-Id sources.
+// ListStat ranks noisy List-Id sources by the same engagement-discounted volume as senders, with Count
+// bounded by Overestimate the same way.
 type ListStat struct {
 	ListID         string `json:"list_id"`
 	Count          int    `json:"count"`
+	Overestimate   int    `json:"overestimate,omitempty"`
 	PreviewSubject string `json:"preview_subject"`
+	Engagement
+	Unsubscribe *unsubscribe.Methods `json:"unsubscribe,omitempty"`
 }
 
 // Suggestions includes proposed gmailctl snippets and clean-ups.
 type Suggestions struct {
-	ArchiveRules []string      `json:"archive_rules"`
-	RemoveRules  []RuleFinding `json:"remove_rules"`
-	Smells       []Conflict    `json:"smells"`
+	ArchiveRules []string       `json:"archive_rules"`
+	Escalations  []Escalation   `json:"escalations,omitempty"`
+	Amendments   []Amendment    `json:"amendments,omitempty"`
+	Rules        []RuleProposal `json:"rules,omitempty"`
+	RemoveRules  []RuleFinding  `json:"remove_rules"`
+	Smells       []Conflict     `json:"smells"`
 }
 
 // GmailctlFindings feeds chronosweep-lint.
@@ -110,14 +156,32 @@ type RuleFinding struct {
 	Reason string `json:"reason"`
 }
 
-// Conflict represents conflicting actions between rules for the same messages.
+// Conflict represents conflicting actions between rules for the same messages. Count is how many
+// messages hit the conflict and Samples holds the first few of them.
 type Conflict struct {
-	Rules       []string `json:"rules"`
-	Description string   `json:"description"`
+	Rules       []string          `json:"rules"`
+	Description string            `json:"description"`
+	Count       int               `json:"count,omitempty"`
+	Samples     []gmail.MessageID `json:"samples,omitempty"`
 }
 
 // Run produces a full audit report.
 func (s *Service) Run(ctx context.Context, opts Options) (Report, error) {
+	ctx, span := s.Tracer.Start(ctx, "audit.run", trace.WithAttributes(
+		attribute.String("window", opts.Window.String()),
+		attribute.Bool("metadata_only", opts.MetadataOnly),
+	))
+	defer span.End()
+	rep, err := s.run(ctx, opts)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return Report{}, err
+	}
+	span.SetAttributes(attribute.Int("messages", rep.Total))
+	return rep, nil
+}
+
+func (s *Service) run(ctx context.Context, opts Options) (Report, error) {
 	if opts.Window <= 0 {
 		return Report{}, fmt.Errorf("window must be positive")
 	}
@@ -141,321 +205,171 @@ func (s *Service) Run(ctx context.Context, opts Options) (Report, error) {
 	if err != nil {
 		return Report{}, fmt.Errorf("list labels: %w", err)
 	}
-	existingLabels := make(map[string]struct{}, len(labelsByName))
-	for name := range labelsByName {
-		existingLabels[name] = struct{}{}
+	query, cutoff, err := s.listing(opts, labelsByName)
+	if err != nil {
+		return Report{}, err
 	}
-
-	metas, err := s.fetchMetadata(ctx, opts.Window, headers, pageSize)
+	rules, err := s.loadRules(ctx, labelsByID)
 	if err != nil {
 		return Report{}, err
 	}
 
-	rep := Report{
-		GeneratedAt: s.Clock(),
-		Window:      opts.Window,
-		Total:       len(metas),
-		Coverage:    map[string]int{},
+	agg := newAggregator(topN, labelsByID, rules)
+	plan := fetchPlan{query: query, cutoff: cutoff, headers: headers, pageSize: pageSize, workers: opts.Workers}
+	if sent, ok := plan.sentPlan(); ok {
+		if fetchErr := s.fetchMetadata(ctx, sent, agg); fetchErr != nil {
+			return Report{}, fmt.Errorf("list sent mail for replies: %w", fetchErr)
+		}
+	}
+	if fetchErr := s.fetchMetadata(ctx, plan, agg); fetchErr != nil {
+		return Report{}, fetchErr
+	}
+	if agg.stale > 0 {
+		logger.WarnContext(ctx, "engagement and coverage use cached labels that were not refreshed",
+			slog.Int("count", agg.stale))
+	}
+	if agg.skipped.count > 0 {
+		logger.WarnContext(ctx, "skipped messages that could not be fetched", slog.Int("count", agg.skipped.count))
+	}
+	unparsed := 0
+	if reporter, ok := s.Client.(UnparsedReporter); ok {
+		unparsed = reporter.Unparsed()
+	}
+	if unparsed > 0 {
+		logger.WarnContext(ctx, "import skipped messages with unparseable headers", slog.Int("count", unparsed))
 	}
 
-	if len(metas) == 0 {
+	rep := Report{
+		GeneratedAt:   s.Clock(),
+		Window:        opts.Window,
+		Total:         agg.total,
+		Coverage:      agg.coverage,
+		Classes:       agg.classes,
+		StaleLabels:   agg.stale,
+		SkippedTotal:  agg.skipped.count,
+		Skipped:       agg.skipSample,
+		UnparsedTotal: unparsed,
+	}
+	if agg.total == 0 {
 		return rep, nil
 	}
 
-	rep.TopSenders, rep.TopLists = buildRankings(metas, topN)
-	rep.Suggestions.ArchiveRules = buildArchiveRules(rep.TopLists, rep.TopSenders)
-	rep.Coverage = buildCoverage(metas, labelsByID)
-
-	findings, err := s.analyseGmailctl(ctx, metas, labelsByID, existingLabels)
-	if err != nil {
-		return Report{}, err
-	}
-	rep.Findings = findings
-	rep.Suggestions.RemoveRules = findings.DeadRules
-	rep.Suggestions.Smells = findings.Conflicts
+	rep.TopSenders = agg.topSenders(topN)
+	rep.TopLists = agg.topLists(topN)
+	rep.Suggestions = suggest(rep.TopLists, rep.TopSenders, opts.Sensitivity, rules)
+	rep.Findings = gmailctlFindings(rules, agg.rules, labelsByName)
+	rep.Suggestions.RemoveRules = rep.Findings.DeadRules
+	rep.Suggestions.Smells = rep.Findings.Conflicts
 
 	return rep, nil
 }
 
-func (s *Service) fetchMetadata(
-	ctx context.Context,
-	window time.Duration,
-	headers []string,
-	pageSize int,
-) ([]gmail.MessageMeta, error) {
-	days := daysFromDuration(window)
-	query := gmail.Query{Raw: fmt.Sprintf("newer_than:%dd", days)}
-	var (
-		metas []gmail.MessageMeta
-		token string
-	)
-	for {
-		page, err := s.listMessages(ctx, query, token, pageSize)
-		if err != nil {
-			return nil, err
-		}
-		if len(page.IDs) == 0 {
-			if page.NextPageToken == "" {
-				break
-			}
-			token = page.NextPageToken
-			continue
-		}
+// fetchPlan describes one metadata listing: what to list, how far back, and how to fetch it. A
+// repliesOnly listing only marks the threads of the messages it finds as replied.
+type fetchPlan struct {
+	query       gmail.Query
+	cutoff      time.Time
+	headers     []string
+	pageSize    int
+	workers     int
+	repliesOnly bool
+}
 
-		chunk, err := s.messageMetadata(ctx, page.IDs, headers)
+// sentPlan returns the listing of the user's sent mail in the same window, for label listings that would
+// otherwise never see it: label IDs must all match, so listing INBOX leaves out every reply and the reply
+// rate would always be zero. Searches already include sent mail.
+func (p fetchPlan) sentPlan() (fetchPlan, bool) {
+	if len(p.query.LabelIDs) == 0 || slices.Contains(p.query.LabelIDs, labelSent) {
+		return fetchPlan{}, false
+	}
+	sent := p
+	sent.query = gmail.Query{LabelIDs: []gmail.LabelID{labelSent}}
+	sent.headers = []string{"From"}
+	sent.repliesOnly = true
+	return sent, true
+}
+
+// fetchMetadata pages through the plan's query, handing each page to agg as soon as it is fetched so
+// no more than one page of metadata is held at a time. A non-zero cutoff drops messages dated before it
+// and stops paging after the first page that reaches past it, since Gmail lists newest first.
+func (s *Service) fetchMetadata(ctx context.Context, plan fetchPlan, agg *aggregator) error {
+	var token string
+	for number := 1; ; number++ {
+		page, chunk, skipped, err := s.fetchPage(ctx, plan, token, number)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		metas = append(metas, chunk...)
-
-		if page.NextPageToken == "" {
-			break
+		kept, exhausted := withinWindow(chunk, plan.cutoff)
+		if plan.repliesOnly {
+			agg.markReplies(kept)
+		} else {
+			agg.add(kept)
+			agg.skip(skipped)
+		}
+		if exhausted || page.NextPageToken == "" {
+			return nil
 		}
 		token = page.NextPageToken
 	}
-	return metas, nil
 }
 
-func (s *Service) analyseGmailctl(
+// fetchPage lists one page and fetches its metadata inside an "audit.page" span.
+func (s *Service) fetchPage(
 	ctx context.Context,
-	metas []gmail.MessageMeta,
-	labelsByID map[gmail.LabelID]string,
-	existingLabels map[string]struct{},
-) (GmailctlFindings, error) {
+	plan fetchPlan,
+	token string,
+	number int,
+) (gmail.ListPage, []gmail.MessageMeta, []SkippedMessage, error) {
+	ctx, span := s.Tracer.Start(ctx, "audit.page", trace.WithAttributes(attribute.Int("page", number)))
+	defer span.End()
+	page, err := s.listMessages(ctx, plan.query, token, plan.pageSize)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return gmail.ListPage{}, nil, nil, err
+	}
+	span.SetAttributes(attribute.Int("messages", len(page.IDs)))
+	chunk, skipped, err := s.messageMetadata(ctx, page.IDs, plan.headers, plan.workers)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return gmail.ListPage{}, nil, nil, err
+	}
+	span.SetAttributes(attribute.Int("skipped", len(skipped)))
+	return page, chunk, skipped, nil
+}
+
+// loadRules compiles the gmailctl filters for replay, or returns none when no loader is configured.
+func (s *Service) loadRules(ctx context.Context, labelsByID map[gmail.LabelID]string) ([]compiledRule, error) {
 	if s.Loader == nil {
-		return GmailctlFindings{}, nil
+		return nil, nil
 	}
 	export, err := s.Loader.ExportFilters(ctx)
 	if err != nil {
-		return GmailctlFindings{}, fmt.Errorf("load gmailctl filters: %w", err)
-	}
-	compiled := compileRules(export, labelsByID)
-	if len(compiled) == 0 {
-		return GmailctlFindings{}, nil
+		return nil, fmt.Errorf("load gmailctl filters: %w", err)
 	}
-	matches := evaluateRules(compiled, metas)
-	findings := GmailctlFindings{}
+	return compileRules(export, labelsByID), nil
+}
 
-	for _, rule := range compiled {
-		if len(matches[rule.Name]) == 0 && rule.Evaluable {
+func gmailctlFindings(
+	rules []compiledRule,
+	replay *ruleAggregator,
+	labelsByName map[string]gmail.LabelID,
+) GmailctlFindings {
+	findings := GmailctlFindings{}
+	for _, rule := range rules {
+		if rule.Evaluable && replay.matched(rule.Name) == 0 {
 			findings.DeadRules = append(
 				findings.DeadRules,
 				RuleFinding{Name: rule.Name, Reason: "no messages matched in lookback"},
 			)
 		}
 		for _, lbl := range rule.Labels {
-			if _, ok := existingLabels[lbl]; !ok {
+			if _, ok := labelsByName[lbl]; !ok {
 				findings.MissingLabels = appendIfMissing(findings.MissingLabels, lbl)
 			}
 		}
 	}
-
-	findings.Conflicts = detectConflicts(compiled, matches)
-	return findings, nil
-}
-
-// PrintHuman writes a readable report to the provided writer.
-func PrintHuman(rep Report, w io.Writer) error {
-	if w == nil {
-		w = os.Stdout
-	}
-	var builder strings.Builder
-	fmt.Fprintf(&builder, "chronosweep audit — window %s (%d messages)\n", rep.Window, rep.Total)
-	if len(rep.TopSenders) > 0 {
-		builder.WriteString("\nTop senders:\n")
-		for _, s := range rep.TopSenders {
-			fmt.Fprintf(
-				&builder,
-				"  %-30s %4d %s\n",
-				s.Domain,
-				s.Count,
-				truncate(s.PreviewSubject, previewSubjectDisplayLimit),
-			)
-		}
-	}
-	if len(rep.TopLists) > 0 {
-		builder.WriteString("\nTop lists:\n")
-		for _, l := range rep.TopLists {
-			fmt.Fprintf(
-				&builder,
-				"  %-30s %4d %s\n",
-				l.ListID,
-				l.Count,
-				truncate(l.PreviewSubject, previewSubjectDisplayLimit),
-			)
-		}
-	}
-	if len(rep.Suggestions.ArchiveRules) > 0 {
-		builder.WriteString("\nSuggested gmailctl snippets:\n")
-		for _, snip := range rep.Suggestions.ArchiveRules {
-			fmt.Fprintf(&builder, "%s\n\n", snip)
-		}
-	}
-	if len(rep.Findings.DeadRules) > 0 || len(rep.Findings.MissingLabels) > 0 ||
-		len(rep.Findings.Conflicts) > 0 {
-		builder.WriteString("\nLint findings:\n")
-		for _, fr := range rep.Findings.DeadRules {
-			fmt.Fprintf(&builder, "  dead rule: %s — %s\n", fr.Name, fr.Reason)
-		}
-		for _, lbl := range rep.Findings.MissingLabels {
-			fmt.Fprintf(&builder, "  missing label: %s\n", lbl)
-		}
-		for _, cf := range rep.Findings.Conflicts {
-			fmt.Fprintf(
-				&builder,
-				"  conflict: %s (%s)\n",
-				strings.Join(cf.Rules, ", "),
-				cf.Description,
-			)
-		}
-	}
-	if _, err := io.WriteString(w, builder.String()); err != nil {
-		return fmt.Errorf("write human report: %w", err)
-	}
-	return nil
-}
-
-// WriteJSON serializes the report to disk.
-func WriteJSON(rep Report, path string) error {
-	clean := strings.TrimSpace(path)
-	if clean == "" {
-		return fmt.Errorf("path must not be empty")
-	}
-	clean = filepath.Clean(clean)
-	if filepath.IsAbs(clean) {
-		return fmt.Errorf("output path must be relative, got %s", clean)
-	}
-	if strings.HasPrefix(clean, "..") {
-		return fmt.Errorf("output path %s escapes working directory", clean)
-	}
-	wd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("determine working directory: %w", err)
-	}
-	abs := filepath.Join(wd, clean)
-	f, err := os.OpenFile(abs, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) // #nosec G304
-	if err != nil {
-		return fmt.Errorf("create %s: %w", abs, err)
-	}
-	defer func() { _ = f.Close() }()
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	if encodeErr := enc.Encode(rep); encodeErr != nil {
-		return fmt.Errorf("encode report: %w", encodeErr)
-	}
-	return nil
-}
-
-func buildRankings(metas []gmail.MessageMeta, topN int) ([]SenderStat, []ListStat) {
-	senders := map[string]*SenderStat{}
-	lists := map[string]*ListStat{}
-	for _, meta := range metas {
-		from := meta.Headers["From"]
-		if domain := domainOf(from); domain != "" {
-			st := senders[domain]
-			if st == nil {
-				st = &SenderStat{Domain: domain}
-				senders[domain] = st
-			}
-			st.Count++
-			if st.PreviewSubject == "" {
-				st.PreviewSubject = meta.Headers["Subject"]
-			}
-		}
-		if lid := normalizeListID(meta.Headers["List-Id"]); lid != "" {
-			ls := lists[lid]
-			if ls == nil {
-				ls = &ListStat{ListID: lid}
-				lists[lid] = ls
-			}
-			ls.Count++
-			if ls.PreviewSubject == "" {
-				ls.PreviewSubject = meta.Headers["Subject"]
-			}
-		}
-	}
-	return rankSenders(senders, topN), rankLists(lists, topN)
-}
-
-func buildCoverage(metas []gmail.MessageMeta, labelsByID map[gmail.LabelID]string) map[string]int {
-	coverage := make(map[string]int)
-	for _, meta := range metas {
-		for _, lid := range meta.LabelIDs {
-			if name, ok := labelsByID[lid]; ok {
-				coverage[name]++
-			}
-		}
-	}
-	return coverage
-}
-
-func rankSenders(m map[string]*SenderStat, topN int) []SenderStat {
-	slice := make([]SenderStat, 0, len(m))
-	for _, st := range m {
-		slice = append(slice, *st)
-	}
-	sort.Slice(slice, func(i, j int) bool {
-		if slice[i].Count == slice[j].Count {
-			return slice[i].Domain < slice[j].Domain
-		}
-		return slice[i].Count > slice[j].Count
-	})
-	if topN < len(slice) {
-		slice = slice[:topN]
-	}
-	return slice
-}
-
-func rankLists(m map[string]*ListStat, topN int) []ListStat {
-	slice := make([]ListStat, 0, len(m))
-	for _, st := range m {
-		slice = append(slice, *st)
-	}
-	sort.Slice(slice, func(i, j int) bool {
-		if slice[i].Count == slice[j].Count {
-			return slice[i].ListID < slice[j].ListID
-		}
-		return slice[i].Count > slice[j].Count
-	})
-	if topN < len(slice) {
-		slice = slice[:topN]
-	}
-	return slice
-}
-
-func buildArchiveRules(lists []ListStat, senders []SenderStat) []string {
-	const maxRules = 10
-	estimate := len(lists) + len(senders)
-	if estimate > maxRules {
-		estimate = maxRules
-	}
-	snippets := make([]string, 0, estimate)
-	for _, ls := range lists {
-		snippets = append(snippets, fmt.Sprintf(`{
-  filter: { list: "%s" },
-  actions: { archive: true, markRead: true },
-}`, ls.ListID))
-		if len(snippets) >= maxRules {
-			return snippets
-		}
-	}
-	for _, sd := range senders {
-		snippets = append(snippets, fmt.Sprintf(`{
-  filter: { from: "*@%s" },
-  actions: { archive: true, markRead: true },
-}`, sd.Domain))
-		if len(snippets) >= maxRules {
-			break
-		}
-	}
-	return snippets
-}
-
-func truncate(s string, n int) string {
-	if len(s) <= n {
-		return s
-	}
-	return s[:n-1] + "…"
+	findings.Conflicts = replay.conflictList()
+	return findings
 }
 
 func appendIfMissing(slice []string, val string) []string {
@@ -483,49 +397,14 @@ func (s *Service) listMessages(
 	return page, nil
 }
 
-func (s *Service) messageMetadata(
-	ctx context.Context,
-	ids []gmail.MessageID,
-	headers []string,
-) ([]gmail.MessageMeta, error) {
-	if len(ids) == 0 {
-		return nil, nil
-	}
-	metas := make([]gmail.MessageMeta, 0, len(ids))
-	for _, id := range ids {
-		if err := s.wait(ctx, "rate limit metadata"); err != nil {
-			return nil, err
-		}
-		meta, err := s.Client.GetMetadata(ctx, id, headers)
-		if err != nil {
-			return nil, fmt.Errorf("get metadata %s: %w", id, err)
-		}
-		metas = append(metas, meta)
-	}
-	return metas, nil
-}
-
 func (s *Service) wait(ctx context.Context, operation string) error {
 	if s.Limiter == nil {
 		return nil
 	}
+	start := time.Now()
 	if err := s.Limiter.Wait(ctx); err != nil {
 		return fmt.Errorf("%s: %w", operation, err)
 	}
+	telemetry.RecordWait(ctx, operation, time.Since(start))
 	return nil
 }
-
-func daysFromDuration(window time.Duration) int {
-	const day = 24 * time.Hour
-	if window <= 0 {
-		return 1
-	}
-	days := int(window / day)
-	if window%day != 0 {
-		days++
-	}
-	if days <= 0 {
-		days = 1
-	}
-	return days
-}
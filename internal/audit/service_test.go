@@ -4,6 +4,8 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,9 +15,11 @@ import (
 
 type fakeAuditClient struct {
 	pages        []gmail.ListPage
+	sent         []gmail.ListPage
 	metas        map[gmail.MessageID]gmail.MessageMeta
 	labelsByName map[string]gmail.LabelID
 	labelsByID   map[gmail.LabelID]string
+	queries      []gmail.Query
 }
 
 func (f *fakeAuditClient) List(
@@ -25,9 +29,17 @@ func (f *fakeAuditClient) List(
 	pageSize int,
 ) (gmail.ListPage, error) {
 	_ = ctx
-	_ = q
 	_ = pageToken
 	_ = pageSize
+	f.queries = append(f.queries, q)
+	if slices.Equal(q.LabelIDs, []gmail.LabelID{labelSent}) {
+		if len(f.sent) == 0 {
+			return gmail.ListPage{}, nil
+		}
+		page := f.sent[0]
+		f.sent = f.sent[1:]
+		return page, nil
+	}
 	if len(f.pages) == 0 {
 		return gmail.ListPage{}, nil
 	}
@@ -214,6 +226,115 @@ func TestServiceRunGmailctlFindings(t *testing.T) {
 	}
 }
 
+func TestServiceRunMetadataOnly(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	recent, stale := now.Add(-time.Hour), now.Add(-72*time.Hour)
+	meta := func(id gmail.MessageID, date time.Time) gmail.MessageMeta {
+		return gmail.MessageMeta{
+			ID:       id,
+			ThreadID: "t" + string(id),
+			Date:     date,
+			Headers:  map[string]string{"From": "alerts@example.com", "Subject": "Alert " + string(id)},
+			LabelIDs: []gmail.LabelID{"INBOX", "Label_bulk"},
+		}
+	}
+	client := &fakeAuditClient{
+		pages: []gmail.ListPage{
+			{IDs: []gmail.MessageID{"1", "2"}, NextPageToken: "p2"},
+			{IDs: []gmail.MessageID{"3", "4"}, NextPageToken: "p3"},
+			{IDs: []gmail.MessageID{"5"}},
+		},
+		sent: []gmail.ListPage{{IDs: []gmail.MessageID{"r1"}}},
+		metas: map[gmail.MessageID]gmail.MessageMeta{
+			"1": meta("1", recent),
+			"2": meta("2", recent),
+			"3": meta("3", recent),
+			"4": meta("4", stale),
+			"5": meta("5", stale),
+			// The user's reply in thread t1, which an INBOX listing never returns.
+			"r1": {ID: "r1", ThreadID: "t1", Date: recent, LabelIDs: []gmail.LabelID{"SENT"}},
+		},
+		labelsByName: map[string]gmail.LabelID{"bulk": "Label_bulk"},
+		labelsByID:   map[gmail.LabelID]string{"Label_bulk": "bulk"},
+	}
+
+	svc := NewService(client, nil, slogDiscard(), nil)
+	svc.Clock = func() time.Time { return now }
+
+	rep, err := svc.Run(context.Background(), Options{
+		Window:       48 * time.Hour,
+		PageSize:     2,
+		MetadataOnly: true,
+		Labels:       []string{"bulk", "INBOX"},
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if rep.Total != 3 {
+		t.Fatalf("expected the 3 in-window messages, got %d", rep.Total)
+	}
+	if len(rep.TopSenders) != 1 || rep.TopSenders[0].ReplyRate != 0.333 {
+		t.Fatalf("expected the listed reply to count, got %+v", rep.TopSenders)
+	}
+	if len(client.queries) != 3 {
+		t.Fatalf("expected one sent page and paging to stop after the window, listed %d pages", len(client.queries))
+	}
+	if !slices.Equal(client.queries[0].LabelIDs, []gmail.LabelID{labelSent}) {
+		t.Fatalf("expected sent mail to be listed first, got %v", client.queries[0].LabelIDs)
+	}
+	q := client.queries[1]
+	if q.Raw != "" {
+		t.Fatalf("metadata-only listing must not search, got q=%q", q.Raw)
+	}
+	if len(q.LabelIDs) != 2 || q.LabelIDs[0] != "Label_bulk" || q.LabelIDs[1] != "INBOX" {
+		t.Fatalf("unexpected label filter: %v", q.LabelIDs)
+	}
+
+	_, err = svc.Run(context.Background(), Options{Window: time.Hour, MetadataOnly: true, Labels: []string{"nope"}})
+	if err == nil {
+		t.Fatalf("expected unknown label error")
+	}
+}
+
+// importedClient stands in for a mailbox import that left messages out.
+type importedClient struct {
+	*fakeAuditClient
+	unparsed int
+}
+
+func (c importedClient) Unparsed() int {
+	return c.unparsed
+}
+
+func TestServiceRunReportsUnparsedImports(t *testing.T) {
+	client := importedClient{
+		fakeAuditClient: &fakeAuditClient{
+			pages: []gmail.ListPage{{IDs: []gmail.MessageID{"1"}}},
+			metas: map[gmail.MessageID]gmail.MessageMeta{
+				"1": {ID: "1", Headers: map[string]string{"From": "a@example.com", "Subject": "Hi"}},
+			},
+		},
+		unparsed: 2,
+	}
+	svc := NewService(client, nil, slogDiscard(), nil)
+	svc.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+
+	rep, err := svc.Run(context.Background(), Options{Window: 48 * time.Hour})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if rep.Total != 1 || rep.UnparsedTotal != 2 {
+		t.Fatalf("got total %d and unparsed %d, want 1 and 2", rep.Total, rep.UnparsedTotal)
+	}
+	var out strings.Builder
+	if renderErr := (HumanRenderer{}).Render(rep, &out); renderErr != nil {
+		t.Fatalf("render: %v", renderErr)
+	}
+	if !strings.Contains(out.String(), "Left out 2 imported messages") {
+		t.Fatalf("human report does not mention the unparsed messages:\n%s", out.String())
+	}
+}
+
 func TestParseFailOn(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"fmt"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// Target kinds name what a suggested rule filters on: a sender domain or a List-Id.
+const (
+	TargetSender = "sender"
+	TargetList   = "list"
+)
+
+const (
+	// maxSuggestions caps archive rules, amendments and escalations together.
+	maxSuggestions = 10
+	// archiveActions is what every archive suggestion, new or amended, asks for.
+	archiveActions = "{ archive: true, markRead: true }"
+)
+
+// Proposal actions say what a proposed rule does with its mail.
+const (
+	ActionArchive = "archive"
+	ActionKeep    = "keep"
+)
+
+// archiveLabel is the label proposed archive rules apply, so archived mail stays findable.
+const archiveLabel = "bulk"
+
+// RuleProposal is one new rule in structured form: what it filters on, what it does, and the evidence
+// behind it. ArchiveRules and Escalations render the same proposals as standalone snippets.
+type RuleProposal struct {
+	Action string  `json:"action"`
+	Kind   string  `json:"kind"`
+	Target string  `json:"target"`
+	Label  string  `json:"label"`
+	Count  int     `json:"count"`
+	Noise  float64 `json:"noise"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// Amendment proposes extending an existing gmailctl rule that already matches a noisy sender or list
+// but does not archive it, instead of adding a second filter for the same mail.
+type Amendment struct {
+	Rule   string `json:"rule"`
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Add    string `json:"add"`
+}
+
+// candidate is a list or sender domain considered for an archive suggestion.
+type candidate struct {
+	kind    string
+	target  string
+	count   int
+	noise   float64
+	engaged bool
+}
+
+// suggest proposes archive rules for the noisiest lists and senders, up to maxSuggestions in total.
+// Candidates the policy deems sensitive are escalated whatever their engagement. The rest are replayed
+// against rules: one already archived by a rule is skipped, and one a rule matches without archiving
+// gets an amendment naming that rule rather than a duplicate filter.
+func suggest(lists []ListStat, senders []SenderStat, policy SensitivityPolicy, rules []compiledRule) Suggestions {
+	candidates := make([]candidate, 0, len(lists)+len(senders))
+	for _, ls := range lists {
+		candidates = append(candidates, candidate{
+			kind:    TargetList,
+			target:  ls.ListID,
+			count:   ls.Count,
+			noise:   ls.Noise,
+			engaged: ls.Score > engagedThreshold,
+		})
+	}
+	for _, sd := range senders {
+		// A domain that also sends personal mail, or that the user reads, must not be archived wholesale.
+		candidates = append(candidates, candidate{
+			kind:    TargetSender,
+			target:  sd.Domain,
+			count:   sd.Count,
+			noise:   sd.Noise,
+			engaged: sd.HumanCount > 0 || sd.Score > engagedThreshold,
+		})
+	}
+
+	var out Suggestions
+	for _, cand := range candidates {
+		if len(out.ArchiveRules)+len(out.Amendments)+len(out.Escalations) >= maxSuggestions {
+			break
+		}
+		if reason := policy.match(cand.target); reason != "" {
+			out.Escalations = append(out.Escalations, policy.escalate(cand.kind, cand.target, reason))
+			out.Rules = append(out.Rules, cand.proposal(ActionKeep, policy.label(), reason))
+			continue
+		}
+		if cand.engaged {
+			continue
+		}
+		rule, covered := coveringRule(cand, rules)
+		switch {
+		case covered:
+		case rule != "":
+			out.Amendments = append(out.Amendments, Amendment{
+				Rule:   rule,
+				Kind:   cand.kind,
+				Target: cand.target,
+				Add:    archiveActions,
+			})
+		default:
+			out.ArchiveRules = append(out.ArchiveRules, archiveRule(cand))
+			out.Rules = append(out.Rules, cand.proposal(ActionArchive, archiveLabel, ""))
+		}
+	}
+	return out
+}
+
+// coveringRule replays rules against a message standing in for every message from cand. covered
+// reports that a matching rule already archives it, or stars it, which an archive would contradict;
+// otherwise rule names the first matching rule to amend, or is empty when none matches.
+func coveringRule(cand candidate, rules []compiledRule) (string, bool) {
+	meta := gmail.MessageMeta{Headers: map[string]string{"List-Id": "<" + cand.target + ">"}}
+	if cand.kind == TargetSender {
+		meta = gmail.MessageMeta{Headers: map[string]string{"From": "*@" + cand.target}}
+	}
+	var amend string
+	for _, rule := range rules {
+		if !rule.Evaluable || !rule.matches(meta) {
+			continue
+		}
+		if rule.Actions.Archive || rule.Actions.Star {
+			return rule.Name, true
+		}
+		if amend == "" {
+			amend = rule.Name
+		}
+	}
+	return amend, false
+}
+
+func (c candidate) proposal(action, label, reason string) RuleProposal {
+	return RuleProposal{
+		Action: action,
+		Kind:   c.kind,
+		Target: c.target,
+		Label:  label,
+		Count:  c.count,
+		Noise:  c.noise,
+		Reason: reason,
+	}
+}
+
+func archiveRule(cand candidate) string {
+	return fmt.Sprintf("{\n  filter: { %s },\n  actions: %s,\n}", ruleFilter(cand.kind, cand.target), archiveActions)
+}
+
+// ruleFilter renders the gmailctl filter selecting a list or every address at a sender domain.
+func ruleFilter(kind, target string) string {
+	if kind == TargetSender {
+		return fmt.Sprintf(`from: "*@%s"`, target)
+	}
+	return fmt.Sprintf(`list: "%s"`, target)
+}
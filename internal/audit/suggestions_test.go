@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/gmailctl"
+)
+
+func TestSuggestAgainstExistingRules(t *testing.T) {
+	listRule := func(name, list string, actions ruleActions) compiledRule {
+		return compiledRule{
+			Name:      name,
+			Evaluable: true,
+			Matchers:  []matcher{{kind: matcherList, values: []string{list}}},
+			Actions:   actions,
+		}
+	}
+	fromRule := func(name, from string, actions ruleActions) compiledRule {
+		return compiledRule{
+			Name:      name,
+			Evaluable: true,
+			Matchers:  []matcher{{kind: matcherFrom, values: splitCandidates(from)}},
+			Actions:   actions,
+		}
+	}
+	tests := []struct {
+		name       string
+		lists      []ListStat
+		senders    []SenderStat
+		rules      []compiledRule
+		wantRules  int
+		wantAmend  string
+		wantTarget string
+	}{
+		{name: "no rules", lists: []ListStat{{ListID: "alerts.example.com"}}, wantRules: 1},
+		{
+			name:  "archived list",
+			lists: []ListStat{{ListID: "alerts.example.com"}},
+			rules: []compiledRule{listRule("alerts", "alerts.example.com", ruleActions{Archive: true})},
+		},
+		{
+			name:  "starred list",
+			lists: []ListStat{{ListID: "alerts.example.com"}},
+			rules: []compiledRule{listRule("alerts", "alerts.example.com", ruleActions{Star: true})},
+		},
+		{
+			name:       "labelled list",
+			lists:      []ListStat{{ListID: "alerts.example.com"}},
+			rules:      []compiledRule{listRule("alerts", "alerts.example.com", ruleActions{Labels: []string{"ops"}})},
+			wantAmend:  "alerts",
+			wantTarget: "alerts.example.com",
+		},
+		{
+			name:    "archived domain",
+			senders: []SenderStat{{Domain: "shop.example"}},
+			rules:   []compiledRule{fromRule("shop", "*@shop.example", ruleActions{Archive: true})},
+		},
+		{
+			name:       "labelled domain",
+			senders:    []SenderStat{{Domain: "shop.example"}},
+			rules:      []compiledRule{fromRule("shop", "shop.example", ruleActions{Labels: []string{"deals"}})},
+			wantAmend:  "shop",
+			wantTarget: "shop.example",
+		},
+		{
+			name:      "narrower sender rule",
+			senders:   []SenderStat{{Domain: "shop.example"}},
+			rules:     []compiledRule{fromRule("receipts", "receipts@shop.example", ruleActions{Archive: true})},
+			wantRules: 1,
+		},
+		{
+			name:  "unevaluable rule",
+			lists: []ListStat{{ListID: "alerts.example.com"}},
+			rules: []compiledRule{
+				{Name: "query", Matchers: []matcher{{kind: matcherList, values: []string{"alerts.example.com"}}}},
+			},
+			wantRules: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := suggest(tt.lists, tt.senders, SensitivityPolicy{}, tt.rules)
+			if len(got.ArchiveRules) != tt.wantRules {
+				t.Fatalf("got %d archive rules, want %d: %v", len(got.ArchiveRules), tt.wantRules, got.ArchiveRules)
+			}
+			if len(got.Rules) != tt.wantRules {
+				t.Fatalf("expected one proposal per new archive rule, got %+v", got.Rules)
+			}
+			if tt.wantAmend == "" {
+				if len(got.Amendments) != 0 {
+					t.Fatalf("unexpected amendments %+v", got.Amendments)
+				}
+				return
+			}
+			if len(got.Amendments) != 1 || got.Amendments[0].Rule != tt.wantAmend ||
+				got.Amendments[0].Target != tt.wantTarget || got.Amendments[0].Add != archiveActions {
+				t.Fatalf("unexpected amendments %+v", got.Amendments)
+			}
+		})
+	}
+}
+
+func TestServiceRunAmendsLabellingRule(t *testing.T) {
+	client := &fakeAuditClient{
+		pages: []gmail.ListPage{{IDs: []gmail.MessageID{"1", "2"}}},
+		metas: map[gmail.MessageID]gmail.MessageMeta{
+			"1": {ID: "1", Headers: map[string]string{"From": "a@alerts.example", "List-Id": "<alerts.example.com>"}},
+			"2": {ID: "2", Headers: map[string]string{"From": "b@alerts.example", "List-Id": "<alerts.example.com>"}},
+		},
+		labelsByName: map[string]gmail.LabelID{"ops": "Label_ops"},
+		labelsByID:   map[gmail.LabelID]string{"Label_ops": "ops"},
+	}
+	export := gmailctl.Export{Filters: []gmailctl.Filter{{
+		Name:     "LabelAlerts",
+		Criteria: gmailctl.FilterCriteria{List: "alerts.example.com"},
+		Action:   gmailctl.FilterAction{AddLabelIDs: []string{"Label_ops"}},
+	}}}
+	svc := NewService(client, nil, slogDiscard(), stubLoader{export: export})
+	svc.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+
+	rep, err := svc.Run(context.Background(), Options{Window: 24 * time.Hour, TopN: 5})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	for _, snippet := range rep.Suggestions.ArchiveRules {
+		if strings.Contains(snippet, "alerts.example.com") {
+			t.Fatalf("duplicated an existing rule: %s", snippet)
+		}
+	}
+	if len(rep.Suggestions.Amendments) != 1 || rep.Suggestions.Amendments[0].Rule != "LabelAlerts" {
+		t.Fatalf("expected an amendment to LabelAlerts, got %+v", rep.Suggestions.Amendments)
+	}
+	var out strings.Builder
+	if err := PrintHuman(rep, &out); err != nil {
+		t.Fatalf("print: %v", err)
+	}
+	if !strings.Contains(out.String(), "LabelAlerts (matches list alerts.example.com): add { archive: true") {
+		t.Fatalf("expected the amendment in the human report:\n%s", out.String())
+	}
+}
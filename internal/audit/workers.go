@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// SkippedMessage records a listed message whose metadata could not be fetched, typically because it
+// was deleted between List and GetMetadata. Skipped messages are left out of every statistic.
+type SkippedMessage struct {
+	ID     gmail.MessageID `json:"id"`
+	Reason string          `json:"reason"`
+}
+
+type metaResult struct {
+	meta    gmail.MessageMeta
+	skipped error
+}
+
+// messageMetadata fetches headers for ids with up to workers concurrent GetMetadata calls, all sharing
+// the limiter. Results keep the order of ids. Messages that no longer exist are skipped and reported;
+// any other failure cancels the remaining fetches and is returned.
+func (s *Service) messageMetadata(
+	ctx context.Context,
+	ids []gmail.MessageID,
+	headers []string,
+	workers int,
+) ([]gmail.MessageMeta, []SkippedMessage, error) {
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+	results := make([]metaResult, len(ids))
+	pending := make([]int, 0, len(ids))
+	peeker, canPeek := s.Client.(gmail.MetadataPeeker)
+	for i, id := range ids {
+		if canPeek {
+			if meta, ok := peeker.PeekMetadata(id, headers); ok {
+				results[i].meta = meta
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+	if err := s.fetchAll(ctx, ids, pending, headers, workers, results); err != nil {
+		return nil, nil, err
+	}
+
+	metas := make([]gmail.MessageMeta, 0, len(ids))
+	var skipped []SkippedMessage
+	for i, result := range results {
+		if result.skipped != nil {
+			skipped = append(skipped, SkippedMessage{ID: ids[i], Reason: result.skipped.Error()})
+			continue
+		}
+		metas = append(metas, result.meta)
+	}
+	return metas, skipped, nil
+}
+
+// fetchAll fills results at the pending indexes. Each worker writes only the slots it was handed, so
+// results needs no locking.
+func (s *Service) fetchAll(
+	ctx context.Context,
+	ids []gmail.MessageID,
+	pending []int,
+	headers []string,
+	workers int,
+	results []metaResult,
+) error {
+	workers = max(1, min(workers, len(pending)))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	jobs := make(chan int)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				meta, err := s.fetchOne(ctx, ids[i], headers)
+				switch {
+				case err == nil:
+					results[i].meta = meta
+				case errors.Is(err, gmail.ErrMessageNotFound):
+					results[i].skipped = err
+				default:
+					fail(err)
+				}
+			}
+		}()
+	}
+feed:
+	for _, i := range pending {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("fetch metadata: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) fetchOne(ctx context.Context, id gmail.MessageID, headers []string) (gmail.MessageMeta, error) {
+	if err := ctx.Err(); err != nil {
+		return gmail.MessageMeta{}, fmt.Errorf("get metadata %s: %w", id, err)
+	}
+	if err := s.wait(ctx, "rate limit metadata"); err != nil {
+		return gmail.MessageMeta{}, err
+	}
+	meta, err := s.Client.GetMetadata(ctx, id, headers)
+	if err != nil {
+		return gmail.MessageMeta{}, fmt.Errorf("get metadata %s: %w", id, err)
+	}
+	return meta, nil
+}
@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+var errAuthExpired = errors.New("token expired")
+
+type concurrentClient struct {
+	fakeAuditClient
+	failures map[gmail.MessageID]error
+	calls    atomic.Int32
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (c *concurrentClient) GetMetadata(
+	ctx context.Context,
+	id gmail.MessageID,
+	headers []string,
+) (gmail.MessageMeta, error) {
+	_ = headers
+	c.calls.Add(1)
+	current := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if current <= peak || c.peak.CompareAndSwap(peak, current) {
+			break
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return gmail.MessageMeta{}, ctx.Err()
+	case <-time.After(time.Millisecond):
+	}
+	if err := c.failures[id]; err != nil {
+		return gmail.MessageMeta{}, err
+	}
+	return gmail.MessageMeta{ID: id, Headers: map[string]string{"From": "a@example.com"}}, nil
+}
+
+func newConcurrentClient(count int, failures map[gmail.MessageID]error) (*concurrentClient, []gmail.MessageID) {
+	ids := make([]gmail.MessageID, count)
+	for i := range ids {
+		ids[i] = gmail.MessageID(fmt.Sprintf("m%03d", i))
+	}
+	return &concurrentClient{failures: failures}, ids
+}
+
+func TestMessageMetadataKeepsOrderAndSkipsMissing(t *testing.T) {
+	client, ids := newConcurrentClient(40, map[gmail.MessageID]error{
+		"m007": fmt.Errorf("get m007: %w", gmail.ErrMessageNotFound),
+	})
+	svc := NewService(client, nil, slogDiscard(), nil)
+
+	metas, skipped, err := svc.messageMetadata(context.Background(), ids, nil, 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(skipped) != 1 || skipped[0].ID != "m007" {
+		t.Fatalf("expected m007 to be skipped, got %+v", skipped)
+	}
+	if len(metas) != len(ids)-1 {
+		t.Fatalf("expected %d messages, got %d", len(ids)-1, len(metas))
+	}
+	want := 0
+	for _, meta := range metas {
+		if ids[want] == "m007" {
+			want++
+		}
+		if meta.ID != ids[want] {
+			t.Fatalf("position %d: got %s want %s", want, meta.ID, ids[want])
+		}
+		want++
+	}
+	if peak := client.peak.Load(); peak < 2 || peak > 8 {
+		t.Fatalf("expected bounded parallelism between 2 and 8, got %d", peak)
+	}
+}
+
+func TestMessageMetadataStopsOnFatalError(t *testing.T) {
+	client, ids := newConcurrentClient(200, map[gmail.MessageID]error{"m003": errAuthExpired})
+	svc := NewService(client, nil, slogDiscard(), nil)
+
+	_, _, err := svc.messageMetadata(context.Background(), ids, nil, 4)
+	if !errors.Is(err, errAuthExpired) {
+		t.Fatalf("expected the fatal error, got %v", err)
+	}
+	if calls := client.calls.Load(); calls >= int32(len(ids)) {
+		t.Fatalf("expected remaining fetches to be cancelled, got %d calls", calls)
+	}
+}
+
+func TestMessageMetadataSingleWorker(t *testing.T) {
+	client, ids := newConcurrentClient(5, nil)
+	svc := NewService(client, nil, slogDiscard(), nil)
+
+	metas, _, err := svc.messageMetadata(context.Background(), ids, nil, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(metas) != len(ids) || client.peak.Load() != 1 {
+		t.Fatalf("expected sequential fetches, got %d messages with peak %d", len(metas), client.peak.Load())
+	}
+}
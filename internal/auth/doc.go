@@ -0,0 +1,2 @@
+// Package auth runs chronosweep's own OAuth login and keeps the resulting tokens in a per-account store.
+package auth
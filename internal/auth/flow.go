@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// RevokeURL is Google's OAuth token revocation endpoint.
+	RevokeURL = "https://oauth2.googleapis.com/revoke"
+	// DefaultListenAddr lets the OS pick a free loopback port for the redirect.
+	DefaultListenAddr = "127.0.0.1:0"
+	stateBytes        = 16
+	shutdownTimeout   = 5 * time.Second
+	maxRevokeBytes    = 4 << 10
+)
+
+// LoginOptions configures the loopback login flow.
+type LoginOptions struct {
+	// Addr is the loopback address the redirect listener binds; empty uses DefaultListenAddr.
+	Addr string
+	// Prompt receives the consent URL the user has to open in a browser.
+	Prompt func(authURL string)
+}
+
+// Login runs the OAuth authorization-code flow with PKCE against a loopback redirect, as Google
+// recommends for desktop clients. It blocks until the browser is redirected back or ctx ends.
+func Login(ctx context.Context, cfg *oauth2.Config, opts LoginOptions) (*oauth2.Token, error) {
+	addr := opts.Addr
+	if addr == "" {
+		addr = DefaultListenAddr
+	}
+	ln, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen for oauth redirect: %w", err)
+	}
+	state, err := randomState()
+	if err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	flow := *cfg
+	flow.RedirectURL = "http://" + ln.Addr().String() + "/"
+	verifier := oauth2.GenerateVerifier()
+
+	codes := make(chan callback, 1)
+	srv := &http.Server{Handler: redirectHandler(state, codes), ReadHeaderTimeout: shutdownTimeout}
+	go func() { _ = srv.Serve(ln) }()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	if opts.Prompt != nil {
+		opts.Prompt(flow.AuthCodeURL(
+			state,
+			oauth2.AccessTypeOffline,
+			oauth2.S256ChallengeOption(verifier),
+			// Forcing consent makes Google issue a refresh token even when the app was approved before.
+			oauth2.SetAuthURLParam("prompt", "consent"),
+		))
+	}
+
+	var result callback
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("wait for oauth redirect: %w", ctx.Err())
+	case result = <-codes:
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+	tok, err := flow.Exchange(ctx, result.code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange authorization code: %w", err)
+	}
+	return tok, nil
+}
+
+// Revoke invalidates tok at Google. Revoking the refresh token also revokes its access tokens; a token
+// Google no longer recognizes counts as revoked.
+func Revoke(ctx context.Context, client *http.Client, endpoint string, tok *oauth2.Token) error {
+	value := tok.RefreshToken
+	if value == "" {
+		value = tok.AccessToken
+	}
+	if value == "" {
+		return errors.New("token has nothing to revoke")
+	}
+	form := url.Values{"token": {value}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("build revoke request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("revoke token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxRevokeBytes))
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "invalid_token") {
+		return nil
+	}
+	return fmt.Errorf("revoke token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// PersistingSource writes every refreshed token back to the store, keeping the stored expiry current
+// and picking up rotated refresh tokens.
+type PersistingSource struct {
+	mu      sync.Mutex
+	base    oauth2.TokenSource
+	store   *Store
+	account string
+	scope   string
+	last    string
+}
+
+// NewPersistingSource wraps base, which was created from current, the token stored for account and scope.
+func NewPersistingSource(
+	base oauth2.TokenSource,
+	store *Store,
+	account, scope string,
+	current *oauth2.Token,
+) *PersistingSource {
+	return &PersistingSource{base: base, store: store, account: account, scope: scope, last: current.AccessToken}
+}
+
+// Token returns a valid token from base, saving it when it differs from the last one seen.
+func (p *PersistingSource) Token() (*oauth2.Token, error) {
+	tok, err := p.base.Token()
+	if err != nil {
+		return nil, fmt.Errorf("refresh token: %w", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if tok.AccessToken == p.last {
+		return tok, nil
+	}
+	if saveErr := p.store.Save(p.account, p.scope, tok); saveErr != nil {
+		return nil, fmt.Errorf("persist refreshed token: %w", saveErr)
+	}
+	p.last = tok.AccessToken
+	return tok, nil
+}
+
+var _ oauth2.TokenSource = (*PersistingSource)(nil)
+
+type callback struct {
+	code string
+	err  error
+}
+
+// redirectHandler accepts the first redirect carrying a matching state and hands its outcome to codes.
+func redirectHandler(state string, codes chan<- callback) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "state mismatch; restart the login", http.StatusBadRequest)
+			return
+		}
+		result := callback{code: query.Get("code")}
+		switch {
+		case query.Get("error") != "":
+			result.err = fmt.Errorf("authorization denied: %s", query.Get("error"))
+		case result.code == "":
+			result.err = errors.New("authorization redirect carried no code")
+		}
+		select {
+		case codes <- result:
+		default:
+		}
+		if result.err != nil {
+			http.Error(w, result.err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = io.WriteString(w, "chronosweep is authorized; you can close this tab.\n")
+	})
+}
+
+func randomState() (string, error) {
+	buf := make([]byte, stateBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate oauth state: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
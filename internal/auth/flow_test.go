@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestLoginLoopback(t *testing.T) {
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("code") != "the-code" || r.PostForm.Get("code_verifier") == "" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(
+			[]byte(`{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":3600}`),
+		)
+	}))
+	defer tokenSrv.Close()
+
+	cfg := &oauth2.Config{
+		ClientID: "client-id",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://accounts.example/auth", TokenURL: tokenSrv.URL},
+		Scopes:   []string{"https://www.googleapis.com/auth/gmail.metadata"},
+	}
+	redirected := make(chan int, 1)
+	prompt := func(authURL string) {
+		parsed, err := url.Parse(authURL)
+		if err != nil {
+			t.Errorf("parse auth url: %v", err)
+			return
+		}
+		q := parsed.Query()
+		if q.Get("access_type") != "offline" || q.Get("code_challenge_method") != "S256" {
+			t.Errorf("auth url lacks offline access or PKCE: %s", authURL)
+		}
+		go func() {
+			back := q.Get("redirect_uri") + "?" + url.Values{"code": {"the-code"}, "state": {q.Get("state")}}.Encode()
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, back, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("follow redirect: %v", err)
+				redirected <- 0
+				return
+			}
+			_ = resp.Body.Close()
+			redirected <- resp.StatusCode
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tok, err := Login(ctx, cfg, LoginOptions{Prompt: prompt})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if tok.RefreshToken != "refresh" {
+		t.Fatalf("unexpected token: %+v", tok)
+	}
+	if status := <-redirected; status != http.StatusOK {
+		t.Fatalf("redirect page returned %d", status)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{name: "revoked", status: http.StatusOK},
+		{name: "already revoked", status: http.StatusBadRequest, body: `{"error":"invalid_token"}`},
+		{name: "server error", status: http.StatusInternalServerError, body: "boom", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = r.ParseForm()
+				sent = r.PostForm.Get("token")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			tok := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}
+			err := Revoke(context.Background(), srv.Client(), srv.URL, tok)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sent != "refresh" {
+				t.Fatalf("expected the refresh token to be revoked, sent %q", sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt parameters follow the package's interactive-login recommendation.
+const (
+	scryptN   = 1 << 15
+	scryptR   = 8
+	scryptP   = 1
+	keyLength = 32
+	saltSize  = 16
+)
+
+// errBadPassphrase hides whether the passphrase or the file was wrong; GCM cannot tell them apart.
+var errBadPassphrase = errors.New("wrong passphrase or corrupted token")
+
+// sealed is a token encrypted with AES-256-GCM under a key derived from the passphrase with scrypt.
+type sealed struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func seal(passphrase, plain []byte) (*sealed, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, randErr := rand.Read(nonce); randErr != nil {
+		return nil, fmt.Errorf("generate nonce: %w", randErr)
+	}
+	return &sealed{Salt: salt, Nonce: nonce, Ciphertext: aead.Seal(nil, nonce, plain, nil)}, nil
+}
+
+func (s *sealed) open(passphrase []byte) ([]byte, error) {
+	aead, err := newAEAD(passphrase, s.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != aead.NonceSize() {
+		return nil, errBadPassphrase
+	}
+	plain, err := aead.Open(nil, s.Nonce, s.Ciphertext, nil)
+	if err != nil {
+		return nil, errBadPassphrase
+	}
+	return plain, nil
+}
+
+func newAEAD(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create gcm: %w", err)
+	}
+	return gcm, nil
+}
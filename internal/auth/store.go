@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	fileMode        = 0o600
+	dirMode         = 0o700
+	credentialsFile = "credentials.json"
+	tokensDir       = "tokens"
+	tokenExt        = ".json"
+)
+
+var (
+	// ErrNoToken is returned when the store holds no token for the requested account and scope.
+	ErrNoToken = errors.New("no stored token")
+	// ErrNoCredentials is returned when no OAuth client credentials have been saved to the store.
+	ErrNoCredentials = errors.New("no OAuth client credentials in token store")
+	// ErrLocked is returned when a token is encrypted and the store has no passphrase.
+	ErrLocked = errors.New("stored token is encrypted; a passphrase is required")
+)
+
+// Store keeps OAuth client credentials and one token per account and scope under a directory:
+//
+//	<dir>/credentials.json
+//	<dir>/tokens/<account>/<scope>.json
+//
+// Everything is written owner-only. When the store has a passphrase, tokens are sealed with it.
+type Store struct {
+	dir        string
+	passphrase []byte
+}
+
+// Entry describes one stored token.
+type Entry struct {
+	Account   string
+	Scope     string
+	Path      string
+	Encrypted bool
+}
+
+// record is the on-disk form of a token; exactly one of Token and Sealed is set.
+type record struct {
+	Account string        `json:"account"`
+	Scope   string        `json:"scope"`
+	Token   *oauth2.Token `json:"token,omitempty"`
+	Sealed  *sealed       `json:"sealed,omitempty"`
+}
+
+// DefaultDir returns the OS-appropriate configuration directory for chronosweep's token store.
+func DefaultDir() string {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "chronosweep")
+	}
+	return filepath.Join(base, "chronosweep")
+}
+
+// NewStore opens the store rooted at dir. A non-empty passphrase encrypts tokens on save and is required
+// to load encrypted ones.
+func NewStore(dir string, passphrase []byte) *Store {
+	return &Store{dir: filepath.Clean(dir), passphrase: passphrase}
+}
+
+// Dir returns the store's root directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// CredentialsPath returns the file holding the OAuth client credentials.
+func (s *Store) CredentialsPath() string {
+	return filepath.Join(s.dir, credentialsFile)
+}
+
+// SaveCredentials validates an OAuth client credentials file (as downloaded from the Google Cloud
+// console) and copies it into the store.
+func (s *Store) SaveCredentials(raw []byte) error {
+	if _, err := google.ConfigFromJSON(raw); err != nil {
+		return fmt.Errorf("parse client credentials: %w", err)
+	}
+	return writeFile(s.CredentialsPath(), raw)
+}
+
+// Config builds the OAuth client configuration from the stored credentials, requesting scopes.
+func (s *Store) Config(scopes ...string) (*oauth2.Config, error) {
+	raw, err := os.ReadFile(s.CredentialsPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w (%s)", ErrNoCredentials, s.dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read client credentials: %w", err)
+	}
+	cfg, err := google.ConfigFromJSON(raw, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("parse client credentials: %w", err)
+	}
+	return cfg, nil
+}
+
+// Load returns the token stored for account and scope.
+func (s *Store) Load(account, scope string) (*oauth2.Token, error) {
+	path, err := s.TokenPath(account, scope)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := readRecord(path)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Sealed == nil {
+		if rec.Token == nil {
+			return nil, fmt.Errorf("token file %s holds no token", path)
+		}
+		return rec.Token, nil
+	}
+	if len(s.passphrase) == 0 {
+		return nil, fmt.Errorf("%s: %w", path, ErrLocked)
+	}
+	plain, err := rec.Sealed.open(s.passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s: %w", path, err)
+	}
+	tok := &oauth2.Token{}
+	if unmarshalErr := json.Unmarshal(plain, tok); unmarshalErr != nil {
+		return nil, fmt.Errorf("parse decrypted token %s: %w", path, unmarshalErr)
+	}
+	return tok, nil
+}
+
+// Save writes tok for account and scope, sealing it when the store has a passphrase.
+func (s *Store) Save(account, scope string, tok *oauth2.Token) error {
+	path, err := s.TokenPath(account, scope)
+	if err != nil {
+		return err
+	}
+	rec := record{Account: account, Scope: scope}
+	if len(s.passphrase) == 0 {
+		rec.Token = tok
+	} else {
+		plain, marshalErr := json.Marshal(tok)
+		if marshalErr != nil {
+			return fmt.Errorf("encode token: %w", marshalErr)
+		}
+		rec.Sealed, err = seal(s.passphrase, plain)
+		if err != nil {
+			return fmt.Errorf("encrypt token: %w", err)
+		}
+	}
+	raw, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode token record: %w", err)
+	}
+	return writeFile(path, raw)
+}
+
+// Delete removes the token for account and scope. Deleting a missing token returns ErrNoToken.
+func (s *Store) Delete(account, scope string) error {
+	path, err := s.TokenPath(account, scope)
+	if err != nil {
+		return err
+	}
+	if removeErr := os.Remove(path); removeErr != nil {
+		if errors.Is(removeErr, fs.ErrNotExist) {
+			return fmt.Errorf("%s %s: %w", account, scope, ErrNoToken)
+		}
+		return fmt.Errorf("remove token: %w", removeErr)
+	}
+	// Drop the account directory once its last token is gone; failure just leaves it empty.
+	_ = os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// List returns every stored token, sorted by account then scope.
+func (s *Store) List() ([]Entry, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, tokensDir, "*", "*"+tokenExt))
+	if err != nil {
+		return nil, fmt.Errorf("list tokens: %w", err)
+	}
+	entries := make([]Entry, 0, len(paths))
+	for _, path := range paths {
+		rec, readErr := readRecord(path)
+		if readErr != nil {
+			return nil, readErr
+		}
+		entries = append(entries, Entry{
+			Account:   rec.Account,
+			Scope:     rec.Scope,
+			Path:      path,
+			Encrypted: rec.Sealed != nil,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Account != entries[j].Account {
+			return entries[i].Account < entries[j].Account
+		}
+		return entries[i].Scope < entries[j].Scope
+	})
+	return entries, nil
+}
+
+// TokenPath returns the file that holds the token for account and scope.
+func (s *Store) TokenPath(account, scope string) (string, error) {
+	for _, part := range []string{account, scope} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid account or scope %q", part)
+		}
+	}
+	return filepath.Join(s.dir, tokensDir, account, scope+tokenExt), nil
+}
+
+func readRecord(path string) (record, error) {
+	raw, err := os.ReadFile(path) // #nosec G304 -- paths are built by TokenPath or globbed inside the store
+	if errors.Is(err, fs.ErrNotExist) {
+		return record{}, fmt.Errorf("%s: %w", path, ErrNoToken)
+	}
+	if err != nil {
+		return record{}, fmt.Errorf("read token: %w", err)
+	}
+	var rec record
+	if unmarshalErr := json.Unmarshal(raw, &rec); unmarshalErr != nil {
+		return record{}, fmt.Errorf("parse token file %s: %w", path, unmarshalErr)
+	}
+	return rec, nil
+}
+
+// writeFile replaces path atomically with an owner-only file.
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return fmt.Errorf("create token store dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if chmodErr := tmp.Chmod(fileMode); chmodErr != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("chmod %s: %w", path, chmodErr)
+	}
+	if _, writeErr := tmp.Write(data); writeErr != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write %s: %w", path, writeErr)
+	}
+	if closeErr := tmp.Close(); closeErr != nil {
+		return fmt.Errorf("close %s: %w", path, closeErr)
+	}
+	if renameErr := os.Rename(tmp.Name(), path); renameErr != nil {
+		return fmt.Errorf("replace %s: %w", path, renameErr)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	tok := &oauth2.Token{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		TokenType:    "Bearer",
+		Expiry:       time.Unix(1700000000, 0).UTC(),
+	}
+	tests := []struct {
+		name       string
+		passphrase string
+		encrypted  bool
+	}{
+		{name: "plaintext"},
+		{name: "encrypted", passphrase: "correct horse", encrypted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := NewStore(dir, []byte(tt.passphrase))
+			if err := store.Save("me@example.com", "gmail.modify", tok); err != nil {
+				t.Fatalf("save: %v", err)
+			}
+			got, err := store.Load("me@example.com", "gmail.modify")
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if got.RefreshToken != tok.RefreshToken || !got.Expiry.Equal(tok.Expiry) {
+				t.Fatalf("token mismatch: %+v", got)
+			}
+
+			entries, err := store.List()
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("list: %v %+v", err, entries)
+			}
+			if entries[0].Account != "me@example.com" || entries[0].Scope != "gmail.modify" ||
+				entries[0].Encrypted != tt.encrypted {
+				t.Fatalf("unexpected entry: %+v", entries[0])
+			}
+			info, err := os.Stat(entries[0].Path)
+			if err != nil {
+				t.Fatalf("stat: %v", err)
+			}
+			if perm := info.Mode().Perm(); perm != fileMode {
+				t.Fatalf("token file mode %o, want %o", perm, fileMode)
+			}
+			raw, err := os.ReadFile(entries[0].Path)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if tt.encrypted && strings.Contains(string(raw), "refresh") {
+				t.Fatalf("encrypted token file leaks the refresh token: %s", raw)
+			}
+
+			if err := store.Delete("me@example.com", "gmail.modify"); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if _, err := store.Load("me@example.com", "gmail.modify"); !errors.Is(err, ErrNoToken) {
+				t.Fatalf("expected ErrNoToken after delete, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreEncryptedNeedsPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	sealedStore := NewStore(dir, []byte("secret"))
+	if err := sealedStore.Save("me", "gmail.readonly", &oauth2.Token{AccessToken: "a"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := NewStore(dir, nil).Load("me", "gmail.readonly"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if _, err := NewStore(dir, []byte("wrong")).Load("me", "gmail.readonly"); err == nil {
+		t.Fatalf("expected wrong passphrase to fail")
+	}
+}
+
+func TestStoreRejectsPathEscapes(t *testing.T) {
+	store := NewStore(t.TempDir(), nil)
+	for _, account := range []string{"", "..", "a/b", `a\b`} {
+		if err := store.Save(account, "gmail.modify", &oauth2.Token{}); err == nil {
+			t.Fatalf("expected account %q to be rejected", account)
+		}
+	}
+}
+
+func TestStoreCredentials(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir, nil)
+	if _, err := store.Config("scope"); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("expected ErrNoCredentials, got %v", err)
+	}
+	if err := store.SaveCredentials([]byte(`{"bogus":true}`)); err == nil {
+		t.Fatalf("expected invalid credentials to be rejected")
+	}
+	if err := store.SaveCredentials([]byte(testCredentials)); err != nil {
+		t.Fatalf("save credentials: %v", err)
+	}
+	cfg, err := store.Config("https://www.googleapis.com/auth/gmail.modify")
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	if cfg.ClientID != "client-id" || len(cfg.Scopes) != 1 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	info, err := os.Stat(filepath.Join(dir, credentialsFile))
+	if err != nil || info.Mode().Perm() != fileMode {
+		t.Fatalf("credentials file not owner-only: %v %v", err, info)
+	}
+}
+
+const testCredentials = `{"installed":{"client_id":"client-id","client_secret":"secret",` +
+	`"auth_uri":"https://accounts.google.com/o/oauth2/auth","token_uri":"https://oauth2.googleapis.com/token",` +
+	`"redirect_uris":["http://localhost"]}}`
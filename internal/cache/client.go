@@ -0,0 +1,592 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// LabelMode controls how label IDs are handled for cache hits.
+type LabelMode int
+
+const (
+	// LabelsRefresh keeps cached label IDs current. When the wrapped client implements gmail.HistoryReader,
+	// the label changes recorded since the previous run are replayed onto every cached entry, which takes a
+	// few history.list calls however many messages are cached. Entries the history cannot vouch for (the
+	// first run, a history position Gmail has expired, or a backend without history) re-read their labels
+	// through gmail.LabelReader on each hit instead: one call per message, as costly as the metadata fetch.
+	LabelsRefresh LabelMode = iota
+	// LabelsStale serves cached label IDs and flags them as stale.
+	LabelsStale
+)
+
+// DefaultLabelMode is the -cache-labels default shared by every command that opens the cache.
+const DefaultLabelMode = "refresh"
+
+const (
+	// DefaultMaxAge evicts entries that have not been used for this long.
+	DefaultMaxAge = 90 * 24 * time.Hour
+	fileMode      = 0o600
+	dirMode       = 0o700
+	keyHashLength = 16
+	// entryVersion is stamped on every entry written. Entries from older versions are dropped on load:
+	// version 1 (no stamp) predates thread IDs, so serving it would hide replies from the audit.
+	entryVersion = 2
+)
+
+// ParseLabelMode converts CLI input into a LabelMode.
+func ParseLabelMode(input string) (LabelMode, error) {
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "", "refresh":
+		return LabelsRefresh, nil
+	case "stale":
+		return LabelsStale, nil
+	default:
+		return LabelsRefresh, fmt.Errorf("unknown cache label mode %q (want refresh or stale)", input)
+	}
+}
+
+// Options configures the metadata cache.
+type Options struct {
+	Path   string
+	MaxAge time.Duration
+	Labels LabelMode
+	Clock  func() time.Time
+}
+
+// Stats counts cache activity for a run. Replayed counts the label history changes applied to cached
+// entries and Reread the hits whose labels had to be fetched one message at a time.
+type Stats struct {
+	Hits     int
+	Misses   int
+	Evicted  int
+	Entries  int
+	Replayed int
+	Reread   int
+}
+
+// LogValue renders the counters as a structured slog group.
+func (s Stats) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("hits", s.Hits),
+		slog.Int("misses", s.Misses),
+		slog.Int("evicted", s.Evicted),
+		slog.Int("entries", s.Entries),
+		slog.Int("replayed", s.Replayed),
+		slog.Int("reread", s.Reread),
+	)
+}
+
+// entry is one cached message. LabelsAt is the history position its labels are known to be current as
+// of, zero when unknown.
+type entry struct {
+	Version  int               `json:"v,omitempty"`
+	LabelsAt uint64            `json:"labels_at,omitempty"`
+	ID       gmail.MessageID   `json:"id"`
+	ThreadID string            `json:"thread_id,omitempty"`
+	Headers  map[string]string `json:"headers"`
+	Fetched  []string          `json:"fetched"`
+	LabelIDs []gmail.LabelID   `json:"label_ids"`
+	Date     time.Time         `json:"date"`
+	LastUsed time.Time         `json:"last_used"`
+}
+
+// state is the cache file's first line: the history position the next run replays label changes from.
+type state struct {
+	Version   int    `json:"v"`
+	HistoryID uint64 `json:"history_id"`
+}
+
+// Client caches message headers and internal dates on disk, keyed by message ID.
+// Headers never change after delivery, so only label IDs need refreshing.
+type Client struct {
+	inner   gmail.Client
+	path    string
+	maxAge  time.Duration
+	labels  LabelMode
+	clock   func() time.Time
+	mu      sync.Mutex
+	entries map[gmail.MessageID]*entry
+	dirty   bool
+	stats   Stats
+	// history is the position loaded from disk; current is the one captured this run, before any fetch,
+	// and is what labels read during the run are current as of.
+	history uint64
+	current uint64
+	synced  bool
+}
+
+// PathFor returns the cache file for an account inside dir.
+// The account (usually the gmailctl config directory) is hashed so accounts never share a file.
+func PathFor(dir, account string) string {
+	if abs, err := filepath.Abs(account); err == nil {
+		account = abs
+	}
+	sum := sha256.Sum256([]byte(account))
+	name := "metadata-" + hex.EncodeToString(sum[:])[:keyHashLength] + ".jsonl"
+	return filepath.Join(dir, name)
+}
+
+// DefaultDir returns the per-user cache directory for chronosweep.
+func DefaultDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "chronosweep")
+	}
+	return filepath.Join(base, "chronosweep")
+}
+
+// Open loads the cache file (if any) and wraps inner.
+func Open(inner gmail.Client, opts Options) (*Client, error) {
+	if inner == nil {
+		return nil, errors.New("cache requires a gmail client")
+	}
+	if strings.TrimSpace(opts.Path) == "" {
+		return nil, errors.New("cache path must not be empty")
+	}
+	maxAge := opts.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	clock := opts.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+	c := &Client{
+		inner:   inner,
+		path:    filepath.Clean(opts.Path),
+		maxAge:  maxAge,
+		labels:  opts.Labels,
+		clock:   clock,
+		entries: map[gmail.MessageID]*entry{},
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// List delegates to the wrapped client; listings are never cached.
+func (c *Client) List(
+	ctx context.Context,
+	q gmail.Query,
+	pageToken string,
+	pageSize int,
+) (gmail.ListPage, error) {
+	if err := c.syncLabels(ctx); err != nil {
+		return gmail.ListPage{}, err
+	}
+	page, err := c.inner.List(ctx, q, pageToken, pageSize)
+	if err != nil {
+		return gmail.ListPage{}, fmt.Errorf("cache list: %w", err)
+	}
+	return page, nil
+}
+
+// GetMetadata serves headers from disk when every requested header was fetched before.
+func (c *Client) GetMetadata(
+	ctx context.Context,
+	id gmail.MessageID,
+	headers []string,
+) (gmail.MessageMeta, error) {
+	if err := c.syncLabels(ctx); err != nil {
+		return gmail.MessageMeta{}, err
+	}
+	if meta, ok := c.lookup(id, headers, false); ok {
+		reader, canRefresh := c.inner.(gmail.LabelReader)
+		if !meta.LabelsStale || c.labels == LabelsStale || !canRefresh {
+			return meta, nil
+		}
+		labels, err := reader.GetLabels(ctx, id)
+		if err != nil {
+			return gmail.MessageMeta{}, fmt.Errorf("refresh labels: %w", err)
+		}
+		meta.LabelIDs = labels
+		meta.LabelsStale = false
+		c.updateLabels(id, labels)
+		return meta, nil
+	}
+	meta, err := c.inner.GetMetadata(ctx, id, headers)
+	if err != nil {
+		return gmail.MessageMeta{}, fmt.Errorf("fetch uncached metadata: %w", err)
+	}
+	c.store(meta, headers)
+	return meta, nil
+}
+
+// PeekMetadata answers a request without any API call when the cached labels are current or will not be
+// refreshed anyway.
+func (c *Client) PeekMetadata(id gmail.MessageID, headers []string) (gmail.MessageMeta, bool) {
+	_, canRefresh := c.inner.(gmail.LabelReader)
+	return c.lookup(id, headers, c.labels != LabelsStale && canRefresh)
+}
+
+// syncLabels runs once per run in refresh mode, before anything is fetched. It replays the label history
+// recorded since the previous run onto every entry that was current as of that run, and captures the
+// position the next run replays from. Without a usable history the position is still captured, so hits
+// re-read their labels this run and the next run can replay.
+func (c *Client) syncLabels(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.synced || c.labels == LabelsStale {
+		return nil
+	}
+	history, ok := c.inner.(gmail.HistoryReader)
+	if !ok {
+		c.synced = true
+		return nil
+	}
+	if c.history != 0 {
+		changes, latest, err := history.LabelChanges(ctx, c.history)
+		switch {
+		case err == nil:
+			c.replay(changes, latest)
+			c.current, c.synced = latest, true
+			return nil
+		case errors.Is(err, gmail.ErrHistoryUnsupported):
+			c.synced = true
+			return nil
+		case !errors.Is(err, gmail.ErrHistoryExpired):
+			return fmt.Errorf("replay label history: %w", err)
+		}
+	}
+	current, err := history.HistoryID(ctx)
+	if errors.Is(err, gmail.ErrHistoryUnsupported) {
+		c.synced = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read history position: %w", err)
+	}
+	c.current, c.synced = current, true
+	c.dirty = true
+	return nil
+}
+
+// replay applies changes, oldest first, to the entries whose labels were current as of the position they
+// start from, and marks those entries current as of latest. Adding or removing a label states its final
+// membership, so replaying a change an entry already reflects is harmless.
+func (c *Client) replay(changes []gmail.LabelChange, latest uint64) {
+	for _, change := range changes {
+		e, ok := c.entries[change.ID]
+		if !ok || e.LabelsAt < c.history {
+			continue
+		}
+		c.stats.Replayed++
+		if change.Deleted {
+			delete(c.entries, change.ID)
+			continue
+		}
+		e.LabelIDs = applyOps(e.LabelIDs, gmail.ModifyOps{AddLabels: change.Added, RemoveLabels: change.Removed})
+	}
+	for _, e := range c.entries {
+		if e.LabelsAt != 0 && e.LabelsAt >= c.history {
+			e.LabelsAt = latest
+		}
+	}
+	c.dirty = true
+}
+
+// BatchModify delegates to the wrapped client and applies the same label changes to cached entries.
+func (c *Client) BatchModify(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	if err := c.inner.BatchModify(ctx, ids, ops); err != nil {
+		return fmt.Errorf("cache batch modify: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		if e, ok := c.entries[id]; ok {
+			e.LabelIDs = applyOps(e.LabelIDs, ops)
+			c.dirty = true
+		}
+	}
+	return nil
+}
+
+// ListLabels delegates to the wrapped client.
+func (c *Client) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	byName, byID, err := c.inner.ListLabels(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cache list labels: %w", err)
+	}
+	return byName, byID, nil
+}
+
+// EnsureLabel delegates to the wrapped client.
+func (c *Client) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
+	id, err := c.inner.EnsureLabel(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("cache ensure label: %w", err)
+	}
+	return id, nil
+}
+
+// Stats returns counters for the current run.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.stats
+	st.Entries = len(c.entries)
+	return st
+}
+
+// Close evicts expired entries and writes the cache back to disk.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict()
+	if !c.dirty {
+		return nil
+	}
+	if err := c.save(); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// lookup serves id from the cache when every header was fetched before, flagging labels that are not
+// known to be current as stale. With requireCurrent such entries are reported as misses instead.
+func (c *Client) lookup(id gmail.MessageID, headers []string, requireCurrent bool) (gmail.MessageMeta, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok || !covers(e.Fetched, headers) {
+		return gmail.MessageMeta{}, false
+	}
+	current := c.current != 0 && e.LabelsAt >= c.current
+	if requireCurrent && !current {
+		return gmail.MessageMeta{}, false
+	}
+	c.stats.Hits++
+	e.LastUsed = c.clock()
+	c.dirty = true
+	hdrs := make(map[string]string, len(e.Headers))
+	for k, v := range e.Headers {
+		hdrs[k] = v
+	}
+	return gmail.MessageMeta{
+		ID:          e.ID,
+		ThreadID:    e.ThreadID,
+		LabelIDs:    append([]gmail.LabelID(nil), e.LabelIDs...),
+		Headers:     hdrs,
+		Date:        e.Date,
+		LabelsStale: !current,
+	}, true
+}
+
+func (c *Client) store(meta gmail.MessageMeta, headers []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Misses++
+	fetched := canonicalHeaders(headers)
+	hdrs := make(map[string]string, len(meta.Headers))
+	// Fetched grows across runs asking for different headers, so the values must grow with it.
+	if prev, ok := c.entries[meta.ID]; ok {
+		fetched = canonicalHeaders(append(fetched, prev.Fetched...))
+		for k, v := range prev.Headers {
+			hdrs[k] = v
+		}
+	}
+	for k, v := range meta.Headers {
+		hdrs[k] = v
+	}
+	c.entries[meta.ID] = &entry{
+		Version:  entryVersion,
+		ID:       meta.ID,
+		ThreadID: meta.ThreadID,
+		Headers:  hdrs,
+		Fetched:  fetched,
+		LabelsAt: c.current,
+		LabelIDs: append([]gmail.LabelID(nil), meta.LabelIDs...),
+		Date:     meta.Date,
+		LastUsed: c.clock(),
+	}
+	c.dirty = true
+}
+
+func (c *Client) updateLabels(id gmail.MessageID, labels []gmail.LabelID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Reread++
+	if e, ok := c.entries[id]; ok {
+		e.LabelIDs = append([]gmail.LabelID(nil), labels...)
+		e.LabelsAt = c.current
+		c.dirty = true
+	}
+}
+
+func (c *Client) evict() {
+	cutoff := c.clock().Add(-c.maxAge)
+	for id, e := range c.entries {
+		if e.LastUsed.Before(cutoff) {
+			delete(c.entries, id)
+			c.stats.Evicted++
+			c.dirty = true
+		}
+	}
+}
+
+func (c *Client) load() error {
+	f, err := os.Open(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open cache %s: %w", c.path, err)
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var e entry
+		if decodeErr := json.Unmarshal(scanner.Bytes(), &e); decodeErr != nil {
+			return fmt.Errorf("decode cache %s line %d: %w", c.path, line, decodeErr)
+		}
+		if e.ID == "" {
+			var st state
+			if json.Unmarshal(scanner.Bytes(), &st) == nil && st.Version >= entryVersion {
+				c.history = max(c.history, st.HistoryID)
+			}
+			continue
+		}
+		if e.Version < entryVersion {
+			c.stats.Evicted++
+			c.dirty = true
+			continue
+		}
+		c.entries[e.ID] = &e
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return fmt.Errorf("read cache %s: %w", c.path, scanErr)
+	}
+	return nil
+}
+
+func (c *Client) save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), dirMode); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return fmt.Errorf("create cache file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if chmodErr := tmp.Chmod(fileMode); chmodErr != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("chmod cache file: %w", chmodErr)
+	}
+	ids := make([]gmail.MessageID, 0, len(c.entries))
+	for id := range c.entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	// Without a new position from this run, keep replaying from the old one: stale runs read no history.
+	if position := max(c.current, c.history); position != 0 {
+		if encodeErr := enc.Encode(state{Version: entryVersion, HistoryID: position}); encodeErr != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("encode cache state: %w", encodeErr)
+		}
+	}
+	for _, id := range ids {
+		if encodeErr := enc.Encode(c.entries[id]); encodeErr != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("encode cache entry %s: %w", id, encodeErr)
+		}
+	}
+	if flushErr := w.Flush(); flushErr != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("flush cache: %w", flushErr)
+	}
+	if closeErr := tmp.Close(); closeErr != nil {
+		return fmt.Errorf("close cache: %w", closeErr)
+	}
+	if renameErr := os.Rename(tmp.Name(), c.path); renameErr != nil {
+		return fmt.Errorf("replace cache %s: %w", c.path, renameErr)
+	}
+	return nil
+}
+
+func covers(fetched, requested []string) bool {
+	have := make(map[string]struct{}, len(fetched))
+	for _, h := range fetched {
+		have[h] = struct{}{}
+	}
+	for _, h := range canonicalHeaders(requested) {
+		if _, ok := have[h]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func canonicalHeaders(headers []string) []string {
+	seen := make(map[string]struct{}, len(headers))
+	out := make([]string, 0, len(headers))
+	for _, h := range headers {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		seen[h] = struct{}{}
+		out = append(out, h)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func applyOps(labels []gmail.LabelID, ops gmail.ModifyOps) []gmail.LabelID {
+	remove := make(map[gmail.LabelID]struct{}, len(ops.RemoveLabels)+2)
+	for _, id := range ops.RemoveLabels {
+		remove[id] = struct{}{}
+	}
+	if ops.MarkRead {
+		remove["UNREAD"] = struct{}{}
+	}
+	if ops.Archive {
+		remove["INBOX"] = struct{}{}
+	}
+	out := make([]gmail.LabelID, 0, len(labels)+len(ops.AddLabels))
+	present := make(map[gmail.LabelID]struct{}, len(labels))
+	for _, id := range labels {
+		if _, drop := remove[id]; drop {
+			continue
+		}
+		present[id] = struct{}{}
+		out = append(out, id)
+	}
+	for _, id := range ops.AddLabels {
+		if _, ok := present[id]; ok {
+			continue
+		}
+		present[id] = struct{}{}
+		out = append(out, id)
+	}
+	return out
+}
+
+var (
+	_ gmail.Client         = (*Client)(nil)
+	_ gmail.MetadataPeeker = (*Client)(nil)
+)
@@ -0,0 +1,402 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+type fakeClient struct {
+	metas        map[gmail.MessageID]gmail.MessageMeta
+	labels       map[gmail.MessageID][]gmail.LabelID
+	metaCalls    int
+	labelCalls   int
+	batchBatches [][]gmail.MessageID
+}
+
+func (f *fakeClient) List(ctx context.Context, q gmail.Query, pageToken string, pageSize int) (gmail.ListPage, error) {
+	_ = ctx
+	_ = q
+	_ = pageToken
+	_ = pageSize
+	return gmail.ListPage{}, nil
+}
+
+func (f *fakeClient) GetMetadata(ctx context.Context, id gmail.MessageID, headers []string) (gmail.MessageMeta, error) {
+	_ = ctx
+	f.metaCalls++
+	meta := f.metas[id]
+	// Like Gmail, answer only the requested headers.
+	only := make(map[string]string, len(headers))
+	for _, h := range headers {
+		if v, ok := meta.Headers[h]; ok {
+			only[h] = v
+		}
+	}
+	meta.Headers = only
+	return meta, nil
+}
+
+func (f *fakeClient) BatchModify(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	_ = ctx
+	_ = ops
+	f.batchBatches = append(f.batchBatches, append([]gmail.MessageID(nil), ids...))
+	return nil
+}
+
+func (f *fakeClient) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	_ = ctx
+	return map[string]gmail.LabelID{}, map[gmail.LabelID]string{}, nil
+}
+
+func (f *fakeClient) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
+	_ = ctx
+	_ = name
+	return "", nil
+}
+
+func (f *fakeClient) GetLabels(ctx context.Context, id gmail.MessageID) ([]gmail.LabelID, error) {
+	_ = ctx
+	f.labelCalls++
+	return f.labels[id], nil
+}
+
+// historyFake adds a mailbox history to fakeClient.
+type historyFake struct {
+	*fakeClient
+	position   uint64
+	changes    []gmail.LabelChange
+	expired    bool
+	replayedAt []uint64
+}
+
+func (h *historyFake) HistoryID(ctx context.Context) (uint64, error) {
+	_ = ctx
+	return h.position, nil
+}
+
+func (h *historyFake) LabelChanges(ctx context.Context, start uint64) ([]gmail.LabelChange, uint64, error) {
+	_ = ctx
+	h.replayedAt = append(h.replayedAt, start)
+	if h.expired {
+		return nil, 0, gmail.ErrHistoryExpired
+	}
+	return h.changes, h.position, nil
+}
+
+func newFake() *fakeClient {
+	return &fakeClient{
+		metas: map[gmail.MessageID]gmail.MessageMeta{
+			"m1": {
+				ID:       "m1",
+				LabelIDs: []gmail.LabelID{"INBOX", "UNREAD"},
+				Headers:  map[string]string{"From": "a@example.com", "Subject": "hello"},
+				Date:     time.Unix(1700000000, 0).UTC(),
+			},
+		},
+		labels: map[gmail.MessageID][]gmail.LabelID{"m1": {"Label_new"}},
+	}
+}
+
+func TestClientPersistsAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.jsonl")
+	headers := []string{"From", "Subject"}
+
+	first := newFake()
+	c, err := Open(first, Options{Path: path, Labels: LabelsStale})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, getErr := c.GetMetadata(context.Background(), "m1", headers); getErr != nil {
+		t.Fatalf("get: %v", getErr)
+	}
+	if closeErr := c.Close(); closeErr != nil {
+		t.Fatalf("close: %v", closeErr)
+	}
+
+	second := newFake()
+	c, err = Open(second, Options{Path: path, Labels: LabelsStale})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	meta, ok := c.PeekMetadata("m1", []string{"subject"})
+	if !ok {
+		t.Fatalf("expected cached entry after reopen")
+	}
+	if meta.Headers["Subject"] != "hello" || !meta.LabelsStale || len(meta.LabelIDs) != 2 {
+		t.Fatalf("unexpected cached meta: %+v", meta)
+	}
+	if _, ok = c.PeekMetadata("m1", []string{"List-Id"}); ok {
+		t.Fatalf("expected miss for header that was never fetched")
+	}
+	if second.metaCalls != 0 {
+		t.Fatalf("expected no upstream calls, got %d", second.metaCalls)
+	}
+}
+
+func TestClientDropsEntriesWithoutThreadIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.jsonl")
+	old := `{"id":"m1","headers":{"From":"a@example.com"},"fetched":["from"],"label_ids":["INBOX"],` +
+		`"date":"2023-11-14T22:13:20Z","last_used":"2023-11-14T22:13:20Z"}` + "\n"
+	if err := os.WriteFile(path, []byte(old), 0o600); err != nil {
+		t.Fatalf("write old cache: %v", err)
+	}
+	fake := newFake()
+	fake.metas["m1"] = gmail.MessageMeta{ID: "m1", ThreadID: "t1", Headers: map[string]string{"From": "a@example.com"}}
+	c, err := Open(fake, Options{Path: path, Labels: LabelsStale})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, ok := c.PeekMetadata("m1", []string{"From"}); ok {
+		t.Fatalf("expected an entry from before thread IDs to be a miss")
+	}
+	meta, err := c.GetMetadata(context.Background(), "m1", []string{"From"})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if meta.ThreadID != "t1" || fake.metaCalls != 1 {
+		t.Fatalf("expected a refetch carrying the thread ID, got %+v after %d calls", meta, fake.metaCalls)
+	}
+	if cached, ok := c.PeekMetadata("m1", []string{"From"}); !ok || cached.ThreadID != "t1" {
+		t.Fatalf("expected the refetched entry to be cached with its thread ID, got %+v", cached)
+	}
+}
+
+func TestClientMergesDisjointHeaderSets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.jsonl")
+	fake := newFake()
+	c, err := Open(fake, Options{Path: path, Labels: LabelsStale})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := c.GetMetadata(ctx, "m1", []string{"From"}); err != nil {
+		t.Fatalf("fetch from: %v", err)
+	}
+	if _, err := c.GetMetadata(ctx, "m1", []string{"Subject"}); err != nil {
+		t.Fatalf("fetch subject: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reopened, err := Open(fake, Options{Path: path, Labels: LabelsStale})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	meta, err := reopened.GetMetadata(ctx, "m1", []string{"From", "Subject"})
+	if err != nil {
+		t.Fatalf("fetch both: %v", err)
+	}
+	if fake.metaCalls != 2 {
+		t.Fatalf("expected both header sets to be served from the cache, got %d fetches", fake.metaCalls)
+	}
+	if meta.Headers["From"] != "a@example.com" || meta.Headers["Subject"] != "hello" {
+		t.Fatalf("cached entry lost header values: %+v", meta.Headers)
+	}
+}
+
+func TestClientRefreshesLabels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.jsonl")
+	fake := newFake()
+	c, err := Open(fake, Options{Path: path, Labels: LabelsRefresh})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	headers := []string{"From"}
+	if _, getErr := c.GetMetadata(context.Background(), "m1", headers); getErr != nil {
+		t.Fatalf("get: %v", getErr)
+	}
+	if _, ok := c.PeekMetadata("m1", headers); ok {
+		t.Fatalf("refresh mode must not serve peeks")
+	}
+	meta, err := c.GetMetadata(context.Background(), "m1", headers)
+	if err != nil {
+		t.Fatalf("second get: %v", err)
+	}
+	if fake.metaCalls != 1 || fake.labelCalls != 1 {
+		t.Fatalf("unexpected upstream calls: meta=%d labels=%d", fake.metaCalls, fake.labelCalls)
+	}
+	if meta.LabelsStale || len(meta.LabelIDs) != 1 || meta.LabelIDs[0] != "Label_new" {
+		t.Fatalf("labels not refreshed: %+v", meta)
+	}
+	if st := c.Stats(); st.Hits != 1 || st.Misses != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestClientBatchModifyUpdatesLabels(t *testing.T) {
+	fake := newFake()
+	c, err := Open(fake, Options{Path: filepath.Join(t.TempDir(), "cache.jsonl"), Labels: LabelsStale})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, getErr := c.GetMetadata(context.Background(), "m1", []string{"From"}); getErr != nil {
+		t.Fatalf("get: %v", getErr)
+	}
+	ops := gmail.ModifyOps{AddLabels: []gmail.LabelID{"Label_expired"}, MarkRead: true, Archive: true}
+	if modErr := c.BatchModify(context.Background(), []gmail.MessageID{"m1"}, ops); modErr != nil {
+		t.Fatalf("batch modify: %v", modErr)
+	}
+	meta, ok := c.PeekMetadata("m1", []string{"From"})
+	if !ok {
+		t.Fatalf("expected cached entry")
+	}
+	if len(meta.LabelIDs) != 1 || meta.LabelIDs[0] != "Label_expired" {
+		t.Fatalf("labels not updated: %+v", meta.LabelIDs)
+	}
+}
+
+func TestClientEvictsByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.jsonl")
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+	c, err := Open(newFake(), Options{Path: path, Labels: LabelsStale, MaxAge: time.Hour, Clock: clock})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, getErr := c.GetMetadata(context.Background(), "m1", []string{"From"}); getErr != nil {
+		t.Fatalf("get: %v", getErr)
+	}
+	now = now.Add(2 * time.Hour)
+	if closeErr := c.Close(); closeErr != nil {
+		t.Fatalf("close: %v", closeErr)
+	}
+	if st := c.Stats(); st.Evicted != 1 || st.Entries != 0 {
+		t.Fatalf("expected eviction, got %+v", st)
+	}
+}
+
+func TestClientReplaysLabelHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.jsonl")
+	ctx := context.Background()
+	headers := []string{"From"}
+
+	first := &historyFake{fakeClient: newFake(), position: 100}
+	c, err := Open(first, Options{Path: path, Labels: LabelsRefresh})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, getErr := c.GetMetadata(ctx, "m1", headers); getErr != nil {
+		t.Fatalf("get: %v", getErr)
+	}
+	if closeErr := c.Close(); closeErr != nil {
+		t.Fatalf("close: %v", closeErr)
+	}
+	if len(first.replayedAt) != 0 {
+		t.Fatalf("a cache without a stored position must not replay, got %v", first.replayedAt)
+	}
+
+	second := &historyFake{fakeClient: newFake(), position: 120, changes: []gmail.LabelChange{
+		{ID: "m1", Removed: []gmail.LabelID{"UNREAD"}},
+		{ID: "m1", Added: []gmail.LabelID{"STARRED"}},
+		{ID: "gone", Deleted: true},
+	}}
+	c, err = Open(second, Options{Path: path, Labels: LabelsRefresh})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if _, listErr := c.List(ctx, gmail.Query{}, "", 10); listErr != nil {
+		t.Fatalf("list: %v", listErr)
+	}
+	meta, ok := c.PeekMetadata("m1", headers)
+	if !ok || meta.LabelsStale {
+		t.Fatalf("expected replayed labels to be served as current, got %+v (hit %v)", meta, ok)
+	}
+	if len(meta.LabelIDs) != 2 || meta.LabelIDs[0] != "INBOX" || meta.LabelIDs[1] != "STARRED" {
+		t.Fatalf("history not applied: %v", meta.LabelIDs)
+	}
+	if second.metaCalls != 0 || second.labelCalls != 0 || len(second.replayedAt) != 1 || second.replayedAt[0] != 100 {
+		t.Fatalf("expected one replay from 100 and no message calls, got replays %v meta=%d labels=%d",
+			second.replayedAt, second.metaCalls, second.labelCalls)
+	}
+	if st := c.Stats(); st.Replayed != 2 || st.Reread != 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if closeErr := c.Close(); closeErr != nil {
+		t.Fatalf("close: %v", closeErr)
+	}
+
+	third := &historyFake{fakeClient: newFake(), position: 130}
+	c, err = Open(third, Options{Path: path, Labels: LabelsRefresh})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if _, getErr := c.GetMetadata(ctx, "m1", headers); getErr != nil {
+		t.Fatalf("get: %v", getErr)
+	}
+	if len(third.replayedAt) != 1 || third.replayedAt[0] != 120 {
+		t.Fatalf("expected the next run to replay from 120, got %v", third.replayedAt)
+	}
+}
+
+func TestClientRereadsLabelsWhenHistoryExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.jsonl")
+	ctx := context.Background()
+	headers := []string{"From"}
+	first := &historyFake{fakeClient: newFake(), position: 100}
+	c, err := Open(first, Options{Path: path, Labels: LabelsRefresh})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, getErr := c.GetMetadata(ctx, "m1", headers); getErr != nil {
+		t.Fatalf("get: %v", getErr)
+	}
+	if closeErr := c.Close(); closeErr != nil {
+		t.Fatalf("close: %v", closeErr)
+	}
+
+	second := &historyFake{fakeClient: newFake(), position: 500, expired: true}
+	c, err = Open(second, Options{Path: path, Labels: LabelsRefresh})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if _, listErr := c.List(ctx, gmail.Query{}, "", 10); listErr != nil {
+		t.Fatalf("list: %v", listErr)
+	}
+	if _, ok := c.PeekMetadata("m1", headers); ok {
+		t.Fatalf("labels the history cannot vouch for must not be peeked")
+	}
+	meta, err := c.GetMetadata(ctx, "m1", headers)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if meta.LabelsStale || second.labelCalls != 1 || meta.LabelIDs[0] != "Label_new" {
+		t.Fatalf("expected a per-message reread, got %+v after %d label calls", meta, second.labelCalls)
+	}
+	if meta, ok := c.PeekMetadata("m1", headers); !ok || meta.LabelsStale {
+		t.Fatalf("expected the reread entry to be current, got %+v (hit %v)", meta, ok)
+	}
+}
+
+func TestClientHistoryErrorsFailTheRun(t *testing.T) {
+	errDown := errors.New("backend down")
+	fake := &failingHistory{historyFake: &historyFake{fakeClient: newFake()}, err: errDown}
+	path := filepath.Join(t.TempDir(), "cache.jsonl")
+	if err := os.WriteFile(path, []byte(`{"v":2,"history_id":7}`+"\n"), 0o600); err != nil {
+		t.Fatalf("write cache: %v", err)
+	}
+	c, err := Open(fake, Options{Path: path, Labels: LabelsRefresh})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := c.GetMetadata(context.Background(), "m1", []string{"From"}); !errors.Is(err, errDown) {
+		t.Fatalf("expected the history error, got %v", err)
+	}
+}
+
+type failingHistory struct {
+	*historyFake
+	err error
+}
+
+func (f *failingHistory) LabelChanges(ctx context.Context, start uint64) ([]gmail.LabelChange, uint64, error) {
+	_ = ctx
+	_ = start
+	return nil, 0, f.err
+}
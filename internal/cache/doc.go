@@ -0,0 +1,2 @@
+// Package cache provides an on-disk metadata cache that decorates a gmail.Client.
+package cache
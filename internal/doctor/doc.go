@@ -0,0 +1,2 @@
+// Package doctor diagnoses credentials, scopes, and Gmail connectivity before a sweep starts failing.
+package doctor
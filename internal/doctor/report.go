@@ -0,0 +1,110 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/runtime"
+)
+
+// Status grades a single finding.
+type Status int
+
+const (
+	// StatusOK means the check passed.
+	StatusOK Status = iota
+	// StatusWarn flags something that works today but is fragile or unsafe.
+	StatusWarn
+	// StatusFail means chronosweep cannot run until the finding is fixed.
+	StatusFail
+	// StatusSkip marks a check that could not run because an earlier one failed.
+	StatusSkip
+)
+
+// Finding is the outcome of one check, with a remediation hint when it did not pass.
+type Finding struct {
+	Check  string `json:"check"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"`
+	// class is the shared error class a failed finding exits with.
+	class gmail.ErrorClass
+}
+
+// Report collects findings in the order the checks ran.
+type Report struct {
+	Findings []Finding `json:"findings"`
+}
+
+// String renders the status for humans.
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "ok"
+	case StatusWarn:
+		return "warn"
+	case StatusFail:
+		return "FAIL"
+	default:
+		return "skip"
+	}
+}
+
+// Err summarizes the report for runtime.WriteStatus: the first failed check with its error class, a
+// policy violation when only warnings remain, and nil when every check passed.
+func (r Report) Err() error {
+	warnings := 0
+	for _, f := range r.Findings {
+		switch f.Status {
+		case StatusFail:
+			return gmail.Classify(f.class, fmt.Errorf("%s: %s", f.Check, f.Detail))
+		case StatusWarn:
+			warnings++
+		case StatusOK, StatusSkip:
+		}
+	}
+	if warnings > 0 {
+		return gmail.Classify(gmail.ClassPolicyViolation, fmt.Errorf("%d checks passed with warnings", warnings))
+	}
+	return nil
+}
+
+// PrintHuman writes one line per finding, followed by its hint.
+func PrintHuman(rep Report, w io.Writer) error {
+	var builder strings.Builder
+	for _, f := range rep.Findings {
+		fmt.Fprintf(&builder, "[%-4s] %-18s %s\n", f.Status, f.Check, f.Detail)
+		if f.Hint != "" && f.Status != StatusOK {
+			fmt.Fprintf(&builder, "       %-18s → %s\n", "", f.Hint)
+		}
+	}
+	if _, err := io.WriteString(w, builder.String()); err != nil {
+		return fmt.Errorf("write doctor report: %w", err)
+	}
+	return nil
+}
+
+func (r *Report) add(check string, status Status, detail, hint string) {
+	r.Findings = append(r.Findings, Finding{Check: check, Status: status, Detail: detail, Hint: hint})
+}
+
+// fail records a failed check that exits with class.
+func (r *Report) fail(check string, class gmail.ErrorClass, detail, hint string) {
+	r.Findings = append(r.Findings, Finding{Check: check, Status: StatusFail, Detail: detail, Hint: hint, class: class})
+}
+
+// classOf classifies a check's error, falling back when the chain carries no recognizable class. A missing
+// file takes the fallback too: its *fs.PathError would otherwise pass for a network timeout.
+func classOf(err error, fallback gmail.ErrorClass) gmail.ErrorClass {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fallback
+	}
+	if class := runtime.ClassifyError(err); class != gmail.ClassUnknown {
+		return class
+	}
+	return fallback
+}
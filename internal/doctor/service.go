@@ -0,0 +1,280 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/runtime"
+)
+
+const (
+	// privateBits are the group and other permission bits that secrets must not carry.
+	privateBits = 0o077
+	// writableBits let other users replace files in the credential directory.
+	writableBits = 0o022
+)
+
+// Gmail is the slice of the Gmail API the doctor exercises.
+type Gmail interface {
+	Profile(ctx context.Context) (runtime.Profile, error)
+	ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error)
+}
+
+// Options selects what to diagnose.
+type Options struct {
+	Provider runtime.TokenProvider
+	Scope    runtime.Scope
+	// Labels must exist in the mailbox, e.g. the labels a sweep is configured with.
+	Labels []string
+	// AllowBroaderScope downgrades a write-capable token for a read-only Scope from a failure to a warning.
+	AllowBroaderScope bool
+}
+
+// Service runs the checks. Scopes and Dial reach Google; tests replace them.
+type Service struct {
+	Scopes func(ctx context.Context, ts oauth2.TokenSource) ([]string, error)
+	Dial   func(ctx context.Context, ts oauth2.TokenSource) (Gmail, error)
+	Clock  func() time.Time
+}
+
+// NewService returns a Service wired to the live Google endpoints.
+func NewService() *Service {
+	return &Service{
+		Scopes: runtime.GrantedScopes,
+		Dial: func(ctx context.Context, ts oauth2.TokenSource) (Gmail, error) {
+			return runtime.NewTokenClient(ctx, ts)
+		},
+		Clock: time.Now,
+	}
+}
+
+// Run checks the credential files, refreshes the token, compares granted and required scopes, makes one
+// getProfile call, and looks up the configured labels. Checks that depend on a failed one are skipped.
+func (s *Service) Run(ctx context.Context, opts Options) Report {
+	var rep Report
+	hint := "log in again"
+	if loc, ok := opts.Provider.(runtime.CredentialLocator); ok {
+		hint = loc.LoginHint(opts.Scope)
+		s.checkFiles(&rep, loc, opts.Scope)
+	}
+
+	ts, err := opts.Provider.TokenSource(ctx, opts.Scope)
+	if err == nil {
+		_, err = ts.Token()
+	}
+	if err != nil {
+		rep.fail("token refresh", classOf(err, gmail.ClassAuthExpired), err.Error(), hint)
+		skip(&rep, "a usable token", "granted scopes", "gmail profile", "labels")
+		return rep
+	}
+	rep.add("token refresh", StatusOK, "obtained a valid access token", "")
+
+	s.checkScopes(ctx, &rep, ts, opts, hint)
+
+	client, err := s.Dial(ctx, ts)
+	if err != nil {
+		rep.fail(
+			"gmail profile",
+			classOf(err, gmail.ClassUnknown),
+			err.Error(),
+			"check network access to gmail.googleapis.com",
+		)
+		skip(&rep, "a Gmail connection", "labels")
+		return rep
+	}
+	profile, err := client.Profile(ctx)
+	if err != nil {
+		hint := "check network access, quota, and that the Gmail API is enabled"
+		rep.fail("gmail profile", classOf(err, gmail.ClassUnknown), err.Error(), hint)
+		skip(&rep, "a Gmail connection", "labels")
+		return rep
+	}
+	rep.add(
+		"gmail profile",
+		StatusOK,
+		fmt.Sprintf("%s (%d messages)", profile.Email, profile.MessagesTotal),
+		"",
+	)
+	checkLabels(ctx, &rep, client, opts.Labels)
+	return rep
+}
+
+func (s *Service) checkFiles(rep *Report, loc runtime.CredentialLocator, scope runtime.Scope) {
+	hint := loc.LoginHint(scope)
+	dir := loc.CredentialDir()
+	info, err := os.Stat(dir)
+	switch {
+	case err != nil:
+		rep.fail("credential dir", gmail.ClassInvalidConfig, err.Error(), hint)
+	case !info.IsDir():
+		rep.fail(
+			"credential dir",
+			gmail.ClassInvalidConfig,
+			dir+" is not a directory",
+			"point -config or -token-dir at a directory",
+		)
+	case info.Mode().Perm()&writableBits != 0:
+		detail := fmt.Sprintf("%s is %o; other users can replace its files", dir, info.Mode().Perm())
+		rep.add("credential dir", StatusWarn, detail, "chmod 700 "+dir)
+	default:
+		rep.add("credential dir", StatusOK, dir, "")
+	}
+
+	creds := loc.CredentialsPath()
+	if raw, readErr := readSecret(rep, "client credentials", creds, gmail.ClassInvalidConfig, hint); readErr == nil {
+		if _, parseErr := google.ConfigFromJSON(raw); parseErr != nil {
+			rep.fail(
+				"client credentials",
+				gmail.ClassInvalidConfig,
+				fmt.Sprintf("%s: %v", creds, parseErr),
+				"download the Desktop app OAuth client JSON from the Google Cloud console",
+			)
+		} else {
+			rep.add("client credentials", StatusOK, creds, "")
+		}
+	}
+
+	tok, path, err := loc.StoredToken(scope)
+	if err != nil {
+		rep.fail("stored token", classOf(err, gmail.ClassAuthExpired), err.Error(), hint)
+		return
+	}
+	if _, readErr := readSecret(rep, "stored token", path, gmail.ClassAuthExpired, hint); readErr != nil {
+		return
+	}
+	rep.Findings = append(rep.Findings, tokenFinding(tok, path, s.Clock(), hint))
+}
+
+func (s *Service) checkScopes(
+	ctx context.Context,
+	rep *Report,
+	ts oauth2.TokenSource,
+	opts Options,
+	hint string,
+) {
+	scope := opts.Scope
+	granted, err := s.Scopes(ctx, ts)
+	if err != nil {
+		rep.fail(
+			"granted scopes",
+			classOf(err, gmail.ClassUnknown),
+			err.Error(),
+			"check network access to oauth2.googleapis.com",
+		)
+		return
+	}
+	detail := fmt.Sprintf("need %s, token grants [%s]", scope, strings.Join(granted, " "))
+	if !scope.SatisfiedBy(granted) {
+		rep.fail("granted scopes", gmail.ClassScopeMissing, detail, hint)
+		return
+	}
+	if broader := scope.BroaderGranted(granted); len(broader) > 0 {
+		detail += fmt.Sprintf("; %s also allows modifying mail", strings.Join(broader, " "))
+		if opts.AllowBroaderScope {
+			rep.add("granted scopes", StatusWarn, detail, hint)
+		} else {
+			rep.fail("granted scopes", gmail.ClassScopeMissing, detail, hint+", or pass -allow-broader-scope")
+		}
+		return
+	}
+	rep.add("granted scopes", StatusOK, detail, "")
+}
+
+// readSecret reads a credential file, warning when its permissions expose it. Only failures are recorded: a
+// missing file fails with the missing class, an unreadable one as invalid configuration.
+func readSecret(rep *Report, check, path string, missing gmail.ErrorClass, hint string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			rep.fail(check, missing, path+" is missing", hint)
+		} else {
+			rep.fail(check, gmail.ClassInvalidConfig, err.Error(), hint)
+		}
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+	raw, err := os.ReadFile(path) // #nosec G304 -- paths come from the configured credential directory
+	if err != nil {
+		rep.fail(
+			check,
+			gmail.ClassInvalidConfig,
+			err.Error(),
+			"make "+path+" readable by the user running chronosweep",
+		)
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if perm := info.Mode().Perm(); perm&privateBits != 0 {
+		rep.add(check, StatusWarn, fmt.Sprintf("%s is %o; other users can read it", path, perm), "chmod 600 "+path)
+	}
+	return raw, nil
+}
+
+// tokenFinding grades a stored token by whether it can outlive its access token.
+func tokenFinding(tok *oauth2.Token, path string, now time.Time, hint string) Finding {
+	f := Finding{Check: "stored token", Hint: hint}
+	expired := !tok.Expiry.IsZero() && tok.Expiry.Before(now)
+	expiry := tok.Expiry.Format(time.RFC3339)
+	switch {
+	case tok.RefreshToken == "" && expired:
+		f.Status = StatusFail
+		f.class = gmail.ClassAuthExpired
+		f.Detail = fmt.Sprintf("%s expired %s and has no refresh token", path, expiry)
+	case tok.RefreshToken == "":
+		f.Status = StatusWarn
+		f.Detail = fmt.Sprintf("%s has no refresh token; it stops working %s", path, expiry)
+	case expired:
+		f.Status = StatusOK
+		f.Detail = fmt.Sprintf("%s: access token expired %s, refresh token present", path, expiry)
+	default:
+		f.Status = StatusOK
+		f.Detail = fmt.Sprintf("%s: refresh token present", path)
+	}
+	return f
+}
+
+func checkLabels(ctx context.Context, rep *Report, client Gmail, want []string) {
+	if len(want) == 0 {
+		rep.add("labels", StatusSkip, "no labels configured (pass -labels)", "")
+		return
+	}
+	byName, _, err := client.ListLabels(ctx)
+	if err != nil {
+		rep.fail(
+			"labels",
+			classOf(err, gmail.ClassUnknown),
+			err.Error(),
+			"check the token's scopes and network access",
+		)
+		return
+	}
+	var missing []string
+	for _, name := range want {
+		if _, ok := byName[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		rep.fail(
+			"labels",
+			gmail.ClassInvalidConfig,
+			"missing: "+strings.Join(missing, ", "),
+			"create the labels in Gmail (or gmailctl) or fix the names passed to chronosweep-sweep",
+		)
+		return
+	}
+	rep.add("labels", StatusOK, fmt.Sprintf("all %d configured labels exist", len(want)), "")
+}
+
+func skip(rep *Report, needs string, checks ...string) {
+	for _, check := range checks {
+		rep.add(check, StatusSkip, "needs "+needs, "")
+	}
+}
@@ -0,0 +1,198 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/runtime"
+)
+
+const testCredentials = `{"installed":{"client_id":"id","client_secret":"secret",` +
+	`"auth_uri":"https://accounts.google.com/o/oauth2/auth","token_uri":"https://oauth2.googleapis.com/token",` +
+	`"redirect_uris":["http://localhost"]}}`
+
+type fakeGmail struct {
+	labels map[string]gmail.LabelID
+}
+
+func (f fakeGmail) Profile(ctx context.Context) (runtime.Profile, error) {
+	_ = ctx
+	return runtime.Profile{Email: "me@example.com", MessagesTotal: 42}, nil
+}
+
+func (f fakeGmail) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	_ = ctx
+	return f.labels, nil, nil
+}
+
+func TestRunGmailctlDirectory(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	valid := &oauth2.Token{AccessToken: "a", RefreshToken: "r", Expiry: time.Now().Add(time.Hour)}
+	tests := []struct {
+		name      string
+		token     *oauth2.Token
+		credsMode os.FileMode
+		granted   []string
+		labels    []string
+		wantCode  int
+		want      map[string]Status
+	}{
+		{
+			name:      "healthy",
+			token:     valid,
+			credsMode: 0o600,
+			granted:   []string{runtime.ScopeModify.URL()},
+			labels:    []string{"auto-archived/expired"},
+			wantCode:  runtime.ExitOK,
+			want: map[string]Status{
+				"credential dir":     StatusOK,
+				"client credentials": StatusOK,
+				"stored token":       StatusOK,
+				"token refresh":      StatusOK,
+				"granted scopes":     StatusOK,
+				"gmail profile":      StatusOK,
+				"labels":             StatusOK,
+			},
+		},
+		{
+			name:      "readable credentials",
+			token:     valid,
+			credsMode: 0o644,
+			granted:   []string{runtime.ScopeModify.URL()},
+			wantCode:  runtime.ExitPolicyViolation,
+			want:      map[string]Status{"client credentials": StatusWarn, "labels": StatusSkip},
+		},
+		{
+			name:      "narrow scope and missing label",
+			token:     valid,
+			credsMode: 0o600,
+			granted:   []string{runtime.ScopeReadonly.URL()},
+			labels:    []string{"auto-archived/expired", "Alerts"},
+			wantCode:  runtime.ExitScopeMissing,
+			want:      map[string]Status{"granted scopes": StatusFail, "labels": StatusFail},
+		},
+		{
+			name:      "missing token",
+			credsMode: 0o600,
+			wantCode:  runtime.ExitAuthExpired,
+			want: map[string]Status{
+				"stored token":  StatusFail,
+				"token refresh": StatusFail,
+				"gmail profile": StatusSkip,
+				"labels":        StatusSkip,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "credentials.json"), []byte(testCredentials), tt.credsMode)
+			if tt.token != nil {
+				raw, err := json.Marshal(tt.token)
+				if err != nil {
+					t.Fatalf("marshal token: %v", err)
+				}
+				writeFile(t, filepath.Join(dir, "token.json"), raw, 0o600)
+			}
+
+			dialed := false
+			svc := &Service{
+				Scopes: func(ctx context.Context, ts oauth2.TokenSource) ([]string, error) {
+					_, _ = ctx, ts
+					return tt.granted, nil
+				},
+				Dial: func(ctx context.Context, ts oauth2.TokenSource) (Gmail, error) {
+					_, _ = ctx, ts
+					dialed = true
+					return fakeGmail{labels: map[string]gmail.LabelID{"auto-archived/expired": "Label_1"}}, nil
+				},
+				Clock: func() time.Time { return now },
+			}
+			rep := svc.Run(context.Background(), Options{
+				Provider: runtime.GmailctlProvider{Dir: dir},
+				Scope:    runtime.ScopeModify,
+				Labels:   tt.labels,
+			})
+
+			if got := runtime.StatusFor("chronosweep-doctor", rep.Err()).ExitCode; got != tt.wantCode {
+				t.Fatalf("exit code %d, want %d: %+v", got, tt.wantCode, rep.Findings)
+			}
+			got := map[string]Status{}
+			for _, f := range rep.Findings {
+				if prev, seen := got[f.Check]; !seen || (f.Status != StatusSkip && f.Status > prev) {
+					got[f.Check] = f.Status
+				}
+			}
+			for check, status := range tt.want {
+				if got[check] != status {
+					t.Fatalf("%s: got %s want %s (%+v)", check, got[check], status, rep.Findings)
+				}
+			}
+			if tt.token == nil && dialed {
+				t.Fatalf("gmail should not be contacted without a token")
+			}
+		})
+	}
+}
+
+func TestRunBroaderScopeForReadOnly(t *testing.T) {
+	valid := &oauth2.Token{AccessToken: "a", RefreshToken: "r", Expiry: time.Now().Add(time.Hour)}
+	raw, err := json.Marshal(valid)
+	if err != nil {
+		t.Fatalf("marshal token: %v", err)
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "credentials.json"), []byte(testCredentials), 0o600)
+	writeFile(t, filepath.Join(dir, "token.json"), raw, 0o600)
+	svc := &Service{
+		Scopes: func(ctx context.Context, ts oauth2.TokenSource) ([]string, error) {
+			_, _ = ctx, ts
+			return []string{runtime.ScopeModify.URL()}, nil
+		},
+		Dial: func(ctx context.Context, ts oauth2.TokenSource) (Gmail, error) {
+			_, _ = ctx, ts
+			return fakeGmail{}, nil
+		},
+		Clock: time.Now,
+	}
+
+	tests := []struct {
+		name  string
+		allow bool
+		want  Status
+	}{
+		{name: "refused by default", want: StatusFail},
+		{name: "allowed with a warning", allow: true, want: StatusWarn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := svc.Run(context.Background(), Options{
+				Provider:          runtime.GmailctlProvider{Dir: dir},
+				Scope:             runtime.ScopeReadonly,
+				AllowBroaderScope: tt.allow,
+			})
+			for _, f := range rep.Findings {
+				if f.Check == "granted scopes" && f.Status != tt.want {
+					t.Fatalf("granted scopes: got %s want %s (%s)", f.Status, tt.want, f.Detail)
+				}
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, data, mode); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatalf("chmod %s: %v", path, err)
+	}
+}
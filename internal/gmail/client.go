@@ -10,3 +10,30 @@ type Client interface {
 	ListLabels(ctx context.Context) (map[string]LabelID, map[LabelID]string, error)
 	EnsureLabel(ctx context.Context, name string) (LabelID, error)
 }
+
+// LabelReader is implemented by clients that can fetch a message's current labels without its headers.
+type LabelReader interface {
+	GetLabels(ctx context.Context, id MessageID) ([]LabelID, error)
+}
+
+// MetadataPeeker is implemented by clients that can answer some metadata requests without a Gmail API call.
+// Callers use it to skip rate limiting for requests served locally.
+type MetadataPeeker interface {
+	PeekMetadata(id MessageID, headers []string) (MessageMeta, bool)
+}
+
+// HistoryReader is implemented by clients that can report how labels changed since an earlier point in
+// the mailbox's history, so a cache can bring every cached message's labels up to date in a few calls.
+type HistoryReader interface {
+	// HistoryID returns the mailbox's current history position.
+	HistoryID(ctx context.Context) (uint64, error)
+	// LabelChanges returns the label changes and deletions recorded after start, oldest first, and the
+	// history position they run up to. It fails with ErrHistoryExpired when start is too old to replay.
+	LabelChanges(ctx context.Context, start uint64) ([]LabelChange, uint64, error)
+}
+
+// DraftCreator is implemented by clients that can save a message as a draft for the user to review and
+// send. raw is an RFC 5322 message; the returned string identifies the draft.
+type DraftCreator interface {
+	CreateDraft(ctx context.Context, raw []byte) (string, error)
+}
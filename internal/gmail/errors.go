@@ -0,0 +1,94 @@
+package gmail
+
+import "errors"
+
+// ErrMessageNotFound is returned when a listed message no longer exists, typically because it was
+// deleted between List and GetMetadata. Callers may skip the message and carry on.
+var ErrMessageNotFound = errors.New("message not found")
+
+// ErrDraftsUnsupported is returned when a draft is requested from a backend that cannot save one.
+var ErrDraftsUnsupported = errors.New("mailbox backend cannot create drafts")
+
+// ErrHistoryUnsupported is returned when label history is requested from a backend that keeps none.
+var ErrHistoryUnsupported = errors.New("mailbox backend has no label history")
+
+// ErrHistoryExpired is returned when a history position is older than the mailbox still remembers;
+// callers must fall back to reading labels per message and start again from a fresh position.
+var ErrHistoryExpired = errors.New("mailbox history position expired")
+
+// ErrorClass groups failures by what the operator should do about them: retry later, fix the
+// configuration, re-authenticate, or look at what the run refused to do.
+type ErrorClass int
+
+// Error classes. Each maps to a stable exit code in runtime.ExitCode.
+const (
+	ClassUnknown ErrorClass = iota
+	ClassAuthExpired
+	ClassScopeMissing
+	ClassQuotaExhausted
+	ClassTransient
+	ClassInvalidConfig
+	ClassSafetyAbort
+	ClassPolicyViolation
+)
+
+// Error attaches an ErrorClass to an underlying error.
+type Error struct {
+	Class ErrorClass
+	Err   error
+}
+
+// Classify wraps err with class. A nil err stays nil.
+func Classify(class ErrorClass, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Class: class, Err: err}
+}
+
+// ClassOf returns the class of the outermost classified error in err's chain, or ClassUnknown.
+func ClassOf(err error) ErrorClass {
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+	return ClassUnknown
+}
+
+// Error returns the underlying message; the class is reported separately.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap exposes the underlying error to errors.Is and errors.As.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// String names the class in the snake_case form used by status lines.
+func (c ErrorClass) String() string {
+	switch c {
+	case ClassUnknown:
+		return "error"
+	case ClassAuthExpired:
+		return "auth_expired"
+	case ClassScopeMissing:
+		return "scope_missing"
+	case ClassQuotaExhausted:
+		return "quota_exhausted"
+	case ClassTransient:
+		return "transient"
+	case ClassInvalidConfig:
+		return "invalid_config"
+	case ClassSafetyAbort:
+		return "safety_abort"
+	case ClassPolicyViolation:
+		return "policy_violation"
+	}
+	return "error"
+}
+
+// Retryable reports whether the same run may succeed later without any change on the operator's side.
+func (c ErrorClass) Retryable() bool {
+	return c == ClassQuotaExhausted || c == ClassTransient
+}
@@ -0,0 +1,126 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnly is returned when a mutating call reaches a client that must not change the mailbox.
+var ErrReadOnly = errors.New("gmail client is read-only")
+
+// ReadOnly wraps a Client and rejects BatchModify, EnsureLabel and CreateDraft before they reach the
+// inner client.
+// Read calls, including the optional LabelReader, MetadataPeeker and HistoryReader interfaces, pass through.
+type ReadOnly struct {
+	inner Client
+}
+
+// NewReadOnly guards inner against mutation.
+func NewReadOnly(inner Client) *ReadOnly {
+	return &ReadOnly{inner: inner}
+}
+
+// List delegates to the inner client.
+func (r *ReadOnly) List(ctx context.Context, q Query, pageToken string, pageSize int) (ListPage, error) {
+	page, err := r.inner.List(ctx, q, pageToken, pageSize)
+	if err != nil {
+		return ListPage{}, fmt.Errorf("read-only list: %w", err)
+	}
+	return page, nil
+}
+
+// GetMetadata delegates to the inner client.
+func (r *ReadOnly) GetMetadata(ctx context.Context, id MessageID, headers []string) (MessageMeta, error) {
+	meta, err := r.inner.GetMetadata(ctx, id, headers)
+	if err != nil {
+		return MessageMeta{}, fmt.Errorf("read-only get metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// GetLabels delegates to the inner client, falling back to GetMetadata when it has no cheaper call.
+func (r *ReadOnly) GetLabels(ctx context.Context, id MessageID) ([]LabelID, error) {
+	if reader, ok := r.inner.(LabelReader); ok {
+		labels, err := reader.GetLabels(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("read-only get labels: %w", err)
+		}
+		return labels, nil
+	}
+	meta, err := r.GetMetadata(ctx, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	return meta.LabelIDs, nil
+}
+
+// PeekMetadata delegates to the inner client when it can answer locally.
+func (r *ReadOnly) PeekMetadata(id MessageID, headers []string) (MessageMeta, bool) {
+	if peeker, ok := r.inner.(MetadataPeeker); ok {
+		return peeker.PeekMetadata(id, headers)
+	}
+	return MessageMeta{}, false
+}
+
+// HistoryID delegates to the inner client, failing with ErrHistoryUnsupported when it keeps no history.
+func (r *ReadOnly) HistoryID(ctx context.Context) (uint64, error) {
+	history, ok := r.inner.(HistoryReader)
+	if !ok {
+		return 0, ErrHistoryUnsupported
+	}
+	id, err := history.HistoryID(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("read-only history id: %w", err)
+	}
+	return id, nil
+}
+
+// LabelChanges delegates to the inner client, failing with ErrHistoryUnsupported when it keeps no history.
+func (r *ReadOnly) LabelChanges(ctx context.Context, start uint64) ([]LabelChange, uint64, error) {
+	history, ok := r.inner.(HistoryReader)
+	if !ok {
+		return nil, 0, ErrHistoryUnsupported
+	}
+	changes, latest, err := history.LabelChanges(ctx, start)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read-only label changes: %w", err)
+	}
+	return changes, latest, nil
+}
+
+// ListLabels delegates to the inner client.
+func (r *ReadOnly) ListLabels(ctx context.Context) (map[string]LabelID, map[LabelID]string, error) {
+	byName, byID, err := r.inner.ListLabels(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read-only list labels: %w", err)
+	}
+	return byName, byID, nil
+}
+
+// BatchModify always fails with ErrReadOnly.
+func (r *ReadOnly) BatchModify(ctx context.Context, ids []MessageID, ops ModifyOps) error {
+	_ = ctx
+	_ = ops
+	return fmt.Errorf("batch modify %d messages: %w", len(ids), ErrReadOnly)
+}
+
+// EnsureLabel always fails with ErrReadOnly.
+func (r *ReadOnly) EnsureLabel(ctx context.Context, name string) (LabelID, error) {
+	_ = ctx
+	return "", fmt.Errorf("ensure label %q: %w", name, ErrReadOnly)
+}
+
+// CreateDraft always fails with ErrReadOnly.
+func (r *ReadOnly) CreateDraft(ctx context.Context, raw []byte) (string, error) {
+	_ = ctx
+	return "", fmt.Errorf("create draft of %d bytes: %w", len(raw), ErrReadOnly)
+}
+
+var (
+	_ Client         = (*ReadOnly)(nil)
+	_ DraftCreator   = (*ReadOnly)(nil)
+	_ HistoryReader  = (*ReadOnly)(nil)
+	_ LabelReader    = (*ReadOnly)(nil)
+	_ MetadataPeeker = (*ReadOnly)(nil)
+)
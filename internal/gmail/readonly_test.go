@@ -0,0 +1,73 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type recordingClient struct {
+	modified bool
+	created  bool
+}
+
+func (r *recordingClient) List(ctx context.Context, q Query, pageToken string, pageSize int) (ListPage, error) {
+	_ = ctx
+	_ = q
+	_ = pageToken
+	_ = pageSize
+	return ListPage{IDs: []MessageID{"a"}}, nil
+}
+
+func (r *recordingClient) GetMetadata(ctx context.Context, id MessageID, headers []string) (MessageMeta, error) {
+	_ = ctx
+	_ = headers
+	return MessageMeta{ID: id, LabelIDs: []LabelID{"INBOX"}}, nil
+}
+
+func (r *recordingClient) BatchModify(ctx context.Context, ids []MessageID, ops ModifyOps) error {
+	_ = ctx
+	_ = ids
+	_ = ops
+	r.modified = true
+	return nil
+}
+
+func (r *recordingClient) ListLabels(ctx context.Context) (map[string]LabelID, map[LabelID]string, error) {
+	_ = ctx
+	return map[string]LabelID{}, map[LabelID]string{}, nil
+}
+
+func (r *recordingClient) EnsureLabel(ctx context.Context, name string) (LabelID, error) {
+	_ = ctx
+	r.created = true
+	return LabelID(name), nil
+}
+
+func TestReadOnlyRejectsMutations(t *testing.T) {
+	inner := &recordingClient{}
+	client := NewReadOnly(inner)
+	ctx := context.Background()
+
+	if err := client.BatchModify(ctx, []MessageID{"a"}, ModifyOps{Archive: true}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly from BatchModify, got %v", err)
+	}
+	if _, err := client.EnsureLabel(ctx, "x"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly from EnsureLabel, got %v", err)
+	}
+	if _, err := client.CreateDraft(ctx, []byte("To: a@example.com\r\n\r\n")); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly from CreateDraft, got %v", err)
+	}
+	if inner.modified || inner.created {
+		t.Fatalf("mutation reached the inner client")
+	}
+
+	page, err := client.List(ctx, Query{}, "", 10)
+	if err != nil || len(page.IDs) != 1 {
+		t.Fatalf("list should pass through: %v %v", page, err)
+	}
+	labels, err := client.GetLabels(ctx, "a")
+	if err != nil || len(labels) != 1 || labels[0] != "INBOX" {
+		t.Fatalf("get labels should fall back to metadata: %v %v", labels, err)
+	}
+}
@@ -0,0 +1,240 @@
+package gmail
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const hoursPerDay = 24
+
+// Term is one node of a Gmail search expression. Build terms with the constructors in this file and
+// render them with Search so every value is normalized and escaped the same way.
+type Term interface {
+	String() string
+	isTerm()
+}
+
+type fieldTerm struct {
+	op    string
+	value string
+}
+
+type notTerm struct {
+	inner Term
+}
+
+type groupTerm struct {
+	sep   string
+	terms []Term
+}
+
+// Label matches messages carrying the user label name, rendered through NormalizeLabel. Prefer
+// Query.LabelIDs with an ID from ListLabels when a label only needs to be required.
+func Label(name string) Term {
+	return fieldTerm{op: "label", value: NormalizeLabel(name)}
+}
+
+// In matches a mailbox location such as inbox, sent, drafts, trash, spam or anywhere.
+func In(location string) Term {
+	return fieldTerm{op: "in", value: strings.ToLower(strings.TrimSpace(location))}
+}
+
+// Is matches a message state such as unread, starred or important.
+func Is(state string) Term {
+	return fieldTerm{op: "is", value: strings.ToLower(strings.TrimSpace(state))}
+}
+
+// Category matches one of Gmail's inbox categories (primary, social, promotions, updates, forums).
+func Category(name string) Term {
+	return fieldTerm{op: "category", value: strings.ToLower(strings.TrimSpace(name))}
+}
+
+// Before matches messages received before t, rendered as epoch seconds so the boundary is exact.
+func Before(t time.Time) Term {
+	return fieldTerm{op: "before", value: strconv.FormatInt(t.Unix(), 10)}
+}
+
+// After matches messages received after t, rendered as epoch seconds like Before.
+func After(t time.Time) Term {
+	return fieldTerm{op: "after", value: strconv.FormatInt(t.Unix(), 10)}
+}
+
+// From matches messages whose sender contains value; "@example.com" selects a whole domain.
+func From(value string) Term {
+	return fieldTerm{op: "from", value: strings.ToLower(strings.TrimSpace(value))}
+}
+
+// To matches messages addressed to value.
+func To(value string) Term {
+	return fieldTerm{op: "to", value: strings.ToLower(strings.TrimSpace(value))}
+}
+
+// List matches messages whose List-Id contains id.
+func List(id string) Term {
+	return fieldTerm{op: "list", value: strings.ToLower(strings.TrimSpace(id))}
+}
+
+// NewerThan matches messages received within d, rounded up to whole days with a minimum of one.
+func NewerThan(d time.Duration) Term {
+	day := hoursPerDay * time.Hour
+	days := int64((d + day - 1) / day)
+	if days < 1 {
+		days = 1
+	}
+	return fieldTerm{op: "newer_than", value: strconv.FormatInt(days, 10) + "d"}
+}
+
+// Not negates t. Negating a negation yields the original term.
+func Not(t Term) Term {
+	if inner, ok := t.(notTerm); ok {
+		return inner.inner
+	}
+	return notTerm{inner: t}
+}
+
+// Or matches messages satisfying any of terms. Empty terms are dropped.
+func Or(terms ...Term) Term {
+	return groupTerm{sep: " OR ", terms: compact(terms)}
+}
+
+// And matches messages satisfying all of terms; it is only needed inside Or or Not groups.
+func And(terms ...Term) Term {
+	return groupTerm{sep: " ", terms: compact(terms)}
+}
+
+// locations maps the mailbox locations the in: operator accepts to the system labels they select.
+func locations() map[string]LabelID {
+	return map[string]LabelID{
+		"inbox":  "INBOX",
+		"sent":   "SENT",
+		"drafts": "DRAFT",
+		"chats":  "CHAT",
+		"trash":  "TRASH",
+		"spam":   "SPAM",
+	}
+}
+
+// LocationLabel returns the system label an in: location selects, such as DRAFT for in:drafts. It
+// reports false for "anywhere", which selects no label, and for unknown locations.
+func LocationLabel(location string) (LabelID, bool) {
+	id, ok := locations()[strings.ToLower(strings.TrimSpace(location))]
+	return id, ok
+}
+
+// SystemLabelTerm returns the search term selecting messages that carry the system label id, through the
+// in:, is: or category: operator Gmail provides for it. User label IDs such as Label_42 are opaque and
+// have no term: resolve them to names through ListLabels and use Label, or list by Query.LabelIDs.
+func SystemLabelTerm(id LabelID) (Term, bool) {
+	switch id {
+	case "UNREAD", "STARRED", "IMPORTANT":
+		return Is(string(id)), true
+	}
+	for location, label := range locations() {
+		if label == id {
+			return In(location), true
+		}
+	}
+	if category, ok := strings.CutPrefix(string(id), "CATEGORY_"); ok && category != "" {
+		return Category(category), true
+	}
+	return nil, false
+}
+
+// Search renders terms as a space-separated (implicitly AND-ed) query. Empty terms are skipped.
+func Search(terms ...Term) Query {
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if s := render(t); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return Query{Raw: strings.Join(parts, " ")}
+}
+
+// NormalizeLabel folds a label name for the label: operator: lower-cased, with every character other than
+// letters, digits, '-' and '_' replaced by a dash ("Ops/On Call" -> "ops-on-call"). Gmail's own search box
+// folds spaces and the nested-label '/' this way; that other punctuation ('&', parentheses, symbols) folds
+// the same is an assumption, made so such characters can never change the query's structure. A label:
+// term may therefore miss mail in Gmail, and callers that rely on an exclusion must not trust it alone:
+// sweep's verifier re-reading each message's label IDs is the real guard.
+func NormalizeLabel(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, strings.TrimSpace(name))
+}
+
+func (f fieldTerm) String() string {
+	if f.value == "" {
+		return ""
+	}
+	return f.op + ":" + quote(f.value)
+}
+
+func (n notTerm) String() string {
+	s := render(n.inner)
+	if s == "" {
+		return ""
+	}
+	return "-" + s
+}
+
+func (g groupTerm) String() string {
+	parts := make([]string, 0, len(g.terms))
+	for _, t := range g.terms {
+		if s := render(t); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	switch len(parts) {
+	case 0:
+		return ""
+	case 1:
+		return parts[0]
+	}
+	return "(" + strings.Join(parts, g.sep) + ")"
+}
+
+func (fieldTerm) isTerm() {}
+func (notTerm) isTerm()   {}
+func (groupTerm) isTerm() {}
+
+func render(t Term) string {
+	if t == nil {
+		return ""
+	}
+	return t.String()
+}
+
+func compact(terms []Term) []Term {
+	out := make([]Term, 0, len(terms))
+	for _, t := range terms {
+		if t != nil {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// quote wraps value in double quotes, escaping backslashes and quotes, when it contains whitespace or a
+// character Gmail's parser treats as syntax. Plain values are returned unchanged.
+func quote(value string) string {
+	if !strings.ContainsFunc(value, needsQuote) {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func needsQuote(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`"()[]{}:\`, r)
+}
+
+var (
+	_ Term = fieldTerm{}
+	_ Term = notTerm{}
+	_ Term = groupTerm{}
+)
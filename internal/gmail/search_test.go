@@ -0,0 +1,111 @@
+package gmail
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchRendersTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms []Term
+		want  string
+	}{
+		{name: "label normalized", terms: []Term{Label("Work/On Call")}, want: "label:work-on-call"},
+		{name: "label with quotes", terms: []Term{Label(`Ops "Pager"`)}, want: "label:ops--pager-"},
+		{name: "label with parens", terms: []Term{Not(Label("Finance (2024)"))}, want: "-label:finance--2024-"},
+		{name: "empty label dropped", terms: []Term{Label(" "), In("Inbox")}, want: "in:inbox"},
+		{
+			name:  "sweep shape",
+			terms: []Term{Is("unread"), Before(time.Unix(1700000000, 0)), Not(Is("starred"))},
+			want:  "is:unread before:1700000000 -is:starred",
+		},
+		{name: "newer than rounds up", terms: []Term{NewerThan(25 * time.Hour)}, want: "newer_than:2d"},
+		{name: "newer than minimum", terms: []Term{NewerThan(0)}, want: "newer_than:1d"},
+		{
+			name:  "negated or group",
+			terms: []Term{Not(Or(Category("Social"), Category("promotions")))},
+			want:  "-(category:social OR category:promotions)",
+		},
+		{name: "single member group", terms: []Term{Or(Label("news"))}, want: "label:news"},
+		{name: "double negation", terms: []Term{Not(Not(Is("important")))}, want: "is:important"},
+		{
+			name:  "and inside or",
+			terms: []Term{Or(And(In("inbox"), Is("unread")), Is("starred"))},
+			want:  "((in:inbox is:unread) OR is:starred)",
+		},
+		{name: "syntax quoted", terms: []Term{In(`a"b c`)}, want: `in:"a\"b c"`},
+		{
+			name:  "unsubscribe verification",
+			terms: []Term{Or(List("Alerts.Example.com"), From("@Shop.example")), After(time.Unix(1700000000, 0))},
+			want:  "(list:alerts.example.com OR from:@shop.example) after:1700000000",
+		},
+		{
+			name:  "sent unsubscribe draft",
+			terms: []Term{In("sent"), To("Unsub@Shop.example"), After(time.Unix(1700000000, 0))},
+			want:  "in:sent to:unsub@shop.example after:1700000000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Search(tt.terms...).Raw; got != tt.want {
+				t.Fatalf("got %q want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemLabelTerm(t *testing.T) {
+	tests := map[LabelID]string{
+		"INBOX":            "in:inbox",
+		"DRAFT":            "in:drafts",
+		"CHAT":             "in:chats",
+		"UNREAD":           "is:unread",
+		"CATEGORY_UPDATES": "category:updates",
+		"Label_42":         "",
+		"CATEGORY_":        "",
+	}
+	for id, want := range tests {
+		term, ok := SystemLabelTerm(id)
+		if ok != (want != "") || (ok && term.String() != want) {
+			t.Fatalf("%s: got %v (%v) want %q", id, term, ok, want)
+		}
+	}
+}
+
+func TestLocationLabel(t *testing.T) {
+	tests := map[string]LabelID{"Inbox": "INBOX", "drafts": "DRAFT", "chats": "CHAT", "anywhere": "", "work": ""}
+	for location, want := range tests {
+		if got, ok := LocationLabel(location); got != want || ok != (want != "") {
+			t.Fatalf("%s: got %q (%v) want %q", location, got, ok, want)
+		}
+	}
+}
+
+func TestNormalizeLabel(t *testing.T) {
+	// Gmail's search box folds spaces and the nested-label '/' to dashes; the '&' and symbol cases pin
+	// chronosweep's own folding, which Gmail is not known to share (see NormalizeLabel).
+	tests := []struct {
+		name  string
+		label string
+		want  string
+	}{
+		{name: "case folded", label: "Receipts", want: "receipts"},
+		{name: "space", label: "On Call", want: "on-call"},
+		{name: "nested label", label: "Work/Projects", want: "work-projects"},
+		{name: "nested with spaces", label: "Ops/On Call", want: "ops-on-call"},
+		{name: "ampersand", label: "Bills & Receipts", want: "bills---receipts"},
+		{name: "dash and underscore kept", label: "auto-archived_2024", want: "auto-archived_2024"},
+		{name: "surrounding space trimmed", label: "  news  ", want: "news"},
+		{name: "accented letters kept", label: "Café Reçus", want: "café-reçus"},
+		{name: "non-latin letters kept", label: "Ünïcode/日本", want: "ünïcode-日本"},
+		{name: "symbols folded", label: "★ VIP", want: "--vip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeLabel(tt.label); got != tt.want {
+				t.Fatalf("NormalizeLabel(%q) = %q, want %q", tt.label, got, tt.want)
+			}
+		})
+	}
+}
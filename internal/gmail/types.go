@@ -8,17 +8,23 @@ type MessageID string
 // LabelID identifies a Gmail label.
 type LabelID string
 
-// Query represents a raw Gmail search query string.
+// Query represents a Gmail message listing: a raw search query string and/or label IDs that every
+// listed message must carry. Tokens limited to the gmail.metadata scope may only filter by label.
 type Query struct {
-	Raw string
+	Raw      string
+	LabelIDs []LabelID
 }
 
 // MessageMeta captures metadata for a Gmail message that is safe to fetch quickly.
+// LabelsStale is set when LabelIDs were served from a cache without being refreshed. ThreadID is empty
+// when the backend does not know the conversation a message belongs to.
 type MessageMeta struct {
-	ID       MessageID
-	LabelIDs []LabelID
-	Headers  map[string]string
-	Date     time.Time
+	ID          MessageID
+	ThreadID    string
+	LabelIDs    []LabelID
+	Headers     map[string]string
+	Date        time.Time
+	LabelsStale bool
 }
 
 // ModifyOps describes the label mutations to apply to a set of messages.
@@ -29,6 +35,15 @@ type ModifyOps struct {
 	Archive      bool
 }
 
+// LabelChange is one entry of the mailbox history: labels added to or removed from a message, or the
+// message's deletion.
+type LabelChange struct {
+	ID      MessageID
+	Added   []LabelID
+	Removed []LabelID
+	Deleted bool
+}
+
 // ListPage contains a set of message IDs and a pagination token for the next page.
 type ListPage struct {
 	IDs           []MessageID
@@ -0,0 +1,495 @@
+package imap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// logoutTimeout bounds the courtesy LOGOUT sent by Close.
+const logoutTimeout = 5 * time.Second
+
+// ErrUnsupported is returned for queries and label operations that folder-based servers cannot express.
+var ErrUnsupported = errors.New("not supported by this IMAP server")
+
+// Options configures an IMAP connection.
+type Options struct {
+	// Addr is the server's host:port, e.g. imap.gmail.com:993.
+	Addr     string
+	Username string
+	Password string
+	// Plaintext disables TLS; only meant for servers on a trusted local link.
+	Plaintext bool
+	TLSConfig *tls.Config
+	// Clock supplies "now" for relative query terms on folder-based servers. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+// Client implements gmail.Client over IMAP. Servers advertising X-GM-EXT-1 are driven through Gmail's
+// extensions and return real Gmail message IDs; other servers are treated as folders, where a message's
+// folder is its only user label.
+type Client struct {
+	mu       sync.Mutex
+	conn     *conn
+	gmailExt bool
+	delim    string
+	folders  []folder
+	clock    func() time.Time
+
+	selected string
+	validity uint32
+
+	// allMail is Gmail's \All mailbox; uids maps Gmail message IDs found by List to its UIDs.
+	allMail string
+	uids    map[gmail.MessageID]uint32
+
+	// lastQuery and lastIDs keep the most recent search so later pages are stable.
+	lastQuery string
+	lastIDs   []gmail.MessageID
+}
+
+type folder struct {
+	name  string
+	attrs map[string]bool
+}
+
+// Dial connects, logs in, and discovers the server's mailboxes.
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	if opts.Addr == "" {
+		return nil, errors.New("imap address is required")
+	}
+	c, err := dial(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{conn: c, clock: opts.Clock, uids: map[gmail.MessageID]uint32{}}
+	if client.clock == nil {
+		client.clock = time.Now
+	}
+	if err = client.login(ctx, opts); err != nil {
+		_ = c.close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// Close logs out and closes the connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn.broken == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), logoutTimeout)
+		_, _ = c.conn.execute(ctx, "LOGOUT")
+		cancel()
+	}
+	return c.conn.close()
+}
+
+// GmailExtensions reports whether the server speaks Gmail's X-GM-EXT-1 extensions.
+func (c *Client) GmailExtensions() bool {
+	return c.gmailExt
+}
+
+// List searches with X-GM-RAW on Gmail, or translates the query into IMAP SEARCH on other servers.
+// Label ID filters are folded into the query text. Results are newest first; page tokens are offsets
+// into the search result.
+func (c *Client) List(
+	ctx context.Context,
+	q gmail.Query,
+	pageToken string,
+	pageSize int,
+) (gmail.ListPage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	offset := 0
+	if pageToken != "" {
+		parsed, err := strconv.Atoi(pageToken)
+		if err != nil || parsed < 0 {
+			return gmail.ListPage{}, fmt.Errorf("invalid page token %q", pageToken)
+		}
+		offset = parsed
+	}
+	raw := searchText(q)
+	if pageToken == "" || raw != c.lastQuery || c.lastIDs == nil {
+		var (
+			ids []gmail.MessageID
+			err error
+		)
+		if c.gmailExt {
+			ids, err = c.searchGmail(ctx, raw)
+		} else {
+			ids, err = c.searchFolders(ctx, raw)
+		}
+		if err != nil {
+			return gmail.ListPage{}, fmt.Errorf("imap list %q: %w", raw, err)
+		}
+		c.lastQuery, c.lastIDs = raw, ids
+	}
+	return pageOf(c.lastIDs, offset, pageSize), nil
+}
+
+// GetMetadata fetches flags, labels, the internal date, and only the requested headers.
+func (c *Client) GetMetadata(
+	ctx context.Context,
+	id gmail.MessageID,
+	headers []string,
+) (gmail.MessageMeta, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, err := c.fetchMeta(ctx, id, headers, true)
+	if err != nil {
+		return gmail.MessageMeta{}, fmt.Errorf("imap get metadata %s: %w", id, err)
+	}
+	return meta, nil
+}
+
+// GetLabels fetches only a message's current labels.
+func (c *Client) GetLabels(ctx context.Context, id gmail.MessageID) ([]gmail.LabelID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, err := c.fetchMeta(ctx, id, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("imap get labels %s: %w", id, err)
+	}
+	return meta.LabelIDs, nil
+}
+
+// ListLabels returns system labels the backend can express plus one user label per mailbox.
+// User label IDs are the label names, with the server's hierarchy delimiter rendered as "/".
+func (c *Client) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.refreshFolders(ctx); err != nil {
+		return nil, nil, err
+	}
+	byName, byID := c.labelTable()
+	return byName, byID, nil
+}
+
+// EnsureLabel returns the label with the given name, creating its mailbox when missing.
+func (c *Client) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.refreshFolders(ctx); err != nil {
+		return "", err
+	}
+	byName, _ := c.labelTable()
+	for existing, id := range byName {
+		if strings.EqualFold(existing, name) {
+			return id, nil
+		}
+	}
+	target, ok := c.folderForLabel(gmail.LabelID(name))
+	if !ok {
+		return "", fmt.Errorf("ensure label %q: %w", name, ErrUnsupported)
+	}
+	if _, err := c.conn.execute(ctx, "CREATE", astring(encodeMailbox(target))); err != nil {
+		return "", fmt.Errorf("imap create %q: %w", target, err)
+	}
+	if err := c.refreshFolders(ctx); err != nil {
+		return "", err
+	}
+	return c.labelForFolder(target), nil
+}
+
+// BatchModify stores X-GM-LABELS and flags on Gmail; elsewhere it sets flags and moves messages between
+// folders. Messages are only ever moved, never deleted outright.
+func (c *Client) BatchModify(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastQuery, c.lastIDs = "", nil
+	var err error
+	if c.gmailExt {
+		err = c.modifyGmail(ctx, ids, ops)
+	} else {
+		err = c.modifyFolders(ctx, ids, ops)
+	}
+	if err != nil {
+		return fmt.Errorf("imap batch modify %d messages: %w", len(ids), err)
+	}
+	return nil
+}
+
+func (c *Client) login(ctx context.Context, opts Options) error {
+	if err := c.conn.refreshCapabilities(ctx); err != nil {
+		return fmt.Errorf("imap capability: %w", err)
+	}
+	if c.conn.has("LOGINDISABLED") {
+		return errors.New("imap server disallows LOGIN on this connection; enable TLS")
+	}
+	if _, err := c.conn.execute(ctx, "LOGIN", astring(opts.Username), astring(opts.Password)); err != nil {
+		return fmt.Errorf("imap login as %s: %w", opts.Username, err)
+	}
+	if err := c.conn.refreshCapabilities(ctx); err != nil {
+		return fmt.Errorf("imap capability: %w", err)
+	}
+	c.gmailExt = c.conn.has("X-GM-EXT-1")
+	if err := c.refreshFolders(ctx); err != nil {
+		return err
+	}
+	if !c.gmailExt {
+		return nil
+	}
+	for _, f := range c.folders {
+		if f.attrs[`\ALL`] {
+			c.allMail = f.name
+		}
+	}
+	if c.allMail == "" {
+		return errors.New("gmail imap server has no \\All mailbox; enable it under Gmail's IMAP settings")
+	}
+	return nil
+}
+
+func pageOf(ids []gmail.MessageID, offset, pageSize int) gmail.ListPage {
+	if offset > len(ids) {
+		offset = len(ids)
+	}
+	end := len(ids)
+	if pageSize > 0 && offset+pageSize < end {
+		end = offset + pageSize
+	}
+	page := gmail.ListPage{IDs: append([]gmail.MessageID(nil), ids[offset:end]...)}
+	if end < len(ids) {
+		page.NextPageToken = strconv.Itoa(end)
+	}
+	return page
+}
+
+// searchText renders q as a single Gmail-style query, turning each label ID filter into the search term
+// that selects it.
+func searchText(q gmail.Query) string {
+	terms := make([]gmail.Term, 0, len(q.LabelIDs))
+	for _, id := range q.LabelIDs {
+		terms = append(terms, labelIDTerm(id))
+	}
+	labels := gmail.Search(terms...).Raw
+	if q.Raw == "" || labels == "" {
+		return q.Raw + labels
+	}
+	return q.Raw + " " + labels
+}
+
+// labelIDTerm returns the search term selecting id. System labels use Gmail's operators; any other ID
+// here is a mailbox name, since this backend's label IDs are the names themselves.
+func labelIDTerm(id gmail.LabelID) gmail.Term {
+	if term, ok := gmail.SystemLabelTerm(id); ok {
+		return term
+	}
+	return gmail.Label(string(id))
+}
+
+func (c *Client) fetchMeta(
+	ctx context.Context,
+	id gmail.MessageID,
+	headers []string,
+	withHeaders bool,
+) (gmail.MessageMeta, error) {
+	var (
+		uid uint32
+		err error
+	)
+	folderLabel := gmail.LabelID("")
+	if c.gmailExt {
+		uid, err = c.gmailUID(ctx, id)
+	} else {
+		var ref messageRef
+		ref, err = parseMessageRef(id)
+		if err == nil {
+			uid = ref.uid
+			folderLabel = c.labelForFolder(ref.folder)
+			err = c.selectChecked(ctx, ref)
+		}
+	}
+	if err != nil {
+		return gmail.MessageMeta{}, err
+	}
+	item, err := c.fetchOne(ctx, uid, headers, withHeaders)
+	if err != nil {
+		return gmail.MessageMeta{}, err
+	}
+	meta := item.meta(id, headers, withHeaders)
+	if folderLabel != "" {
+		meta.LabelIDs = append([]gmail.LabelID{folderLabel}, meta.LabelIDs...)
+	}
+	return meta, nil
+}
+
+func (c *Client) labelTable() (map[string]gmail.LabelID, map[gmail.LabelID]string) {
+	system := []gmail.LabelID{"INBOX", "UNREAD", "STARRED"}
+	if c.gmailExt {
+		system = append(system, "IMPORTANT")
+	}
+	byName := map[string]gmail.LabelID{}
+	byID := map[gmail.LabelID]string{}
+	for _, id := range system {
+		byName[string(id)] = id
+		byID[id] = string(id)
+	}
+	for _, f := range c.folders {
+		id := c.labelForFolder(f.name)
+		if id == "" {
+			continue
+		}
+		byName[string(id)] = id
+		byID[id] = string(id)
+	}
+	return byName, byID
+}
+
+// labelForFolder maps a mailbox to its label: INBOX, a system label for special-use mailboxes, or a user
+// label. Mailboxes with no label equivalent (Gmail's All Mail, \Noselect parents) map to "".
+func (c *Client) labelForFolder(name string) gmail.LabelID {
+	if strings.EqualFold(name, "INBOX") {
+		return "INBOX"
+	}
+	for _, f := range c.folders {
+		if f.name != name {
+			continue
+		}
+		if f.attrs[`\NOSELECT`] || f.attrs[`\NONEXISTENT`] || f.attrs[`\ALL`] || f.attrs[`\FLAGGED`] {
+			return ""
+		}
+		for attr, id := range specialUseLabels() {
+			if f.attrs[attr] {
+				return id
+			}
+		}
+	}
+	if c.delim != "" && c.delim != "/" {
+		name = strings.ReplaceAll(name, c.delim, "/")
+	}
+	return gmail.LabelID(name)
+}
+
+// folderForLabel is the inverse of labelForFolder.
+func (c *Client) folderForLabel(id gmail.LabelID) (string, bool) {
+	if id == "INBOX" {
+		return "INBOX", true
+	}
+	for _, f := range c.folders {
+		if c.labelForFolder(f.name) == id {
+			return f.name, true
+		}
+	}
+	if isSystemLabel(id) {
+		return "", false
+	}
+	name := string(id)
+	if c.delim != "" && c.delim != "/" {
+		name = strings.ReplaceAll(name, "/", c.delim)
+	}
+	return name, true
+}
+
+func (c *Client) refreshFolders(ctx context.Context) error {
+	lines, err := c.conn.execute(ctx, "LIST", astring(""), astring("*"))
+	if err != nil {
+		return fmt.Errorf("imap list mailboxes: %w", err)
+	}
+	folders := make([]folder, 0, len(lines))
+	for _, line := range lines {
+		rest, ok := cutPrefixFold(line, "LIST ")
+		if !ok {
+			continue
+		}
+		vals, parseErr := parseValues(rest)
+		if parseErr != nil || len(vals) < 3 || !vals[0].isList {
+			return fmt.Errorf("imap: malformed LIST response %q", line)
+		}
+		attrs := map[string]bool{}
+		for _, attr := range vals[0].list {
+			attrs[strings.ToUpper(attr.text)] = true
+		}
+		if !vals[1].isNil {
+			c.delim = vals[1].text
+		}
+		folders = append(folders, folder{name: decodeMailbox(vals[2].text), attrs: attrs})
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].name < folders[j].name })
+	c.folders = folders
+	return nil
+}
+
+// selectMailbox selects name unless it is already selected and records its UIDVALIDITY.
+func (c *Client) selectMailbox(ctx context.Context, name string) error {
+	if c.selected == name {
+		return nil
+	}
+	c.selected = ""
+	lines, err := c.conn.execute(ctx, "SELECT", astring(encodeMailbox(name)))
+	if err != nil {
+		return fmt.Errorf("imap select %q: %w", name, err)
+	}
+	c.validity = 0
+	for _, line := range lines {
+		if _, rest, ok := strings.Cut(line, "[UIDVALIDITY "); ok {
+			digits, _, _ := strings.Cut(rest, "]")
+			parsed, parseErr := strconv.ParseUint(digits, 10, 32)
+			if parseErr == nil {
+				c.validity = uint32(parsed)
+			}
+		}
+	}
+	c.selected = name
+	return nil
+}
+
+// uidSet renders UIDs as a comma separated sequence set.
+func uidSet(uids []uint32) string {
+	parts := make([]string, len(uids))
+	for i, uid := range uids {
+		parts[i] = strconv.FormatUint(uint64(uid), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// chunkUIDs splits uids so command lines stay well under common server limits.
+func chunkUIDs(uids []uint32) [][]uint32 {
+	const size = 500
+	var chunks [][]uint32
+	for start := 0; start < len(uids); start += size {
+		chunks = append(chunks, uids[start:min(start+size, len(uids))])
+	}
+	return chunks
+}
+
+// searchUIDs runs UID SEARCH in the selected mailbox.
+func (c *Client) searchUIDs(ctx context.Context, criteria ...any) ([]uint32, error) {
+	lines, err := c.conn.execute(ctx, append([]any{"UID", "SEARCH"}, criteria...)...)
+	if err != nil {
+		return nil, fmt.Errorf("imap search: %w", err)
+	}
+	var uids []uint32
+	for _, line := range lines {
+		rest, ok := cutPrefixFold(line, "SEARCH")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(rest) {
+			uid, parseErr := strconv.ParseUint(field, 10, 32)
+			if parseErr != nil {
+				return nil, fmt.Errorf("imap: malformed SEARCH response %q", line)
+			}
+			uids = append(uids, uint32(uid))
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	return uids, nil
+}
+
+var (
+	_ gmail.Client      = (*Client)(nil)
+	_ gmail.LabelReader = (*Client)(nil)
+)
@@ -0,0 +1,359 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/audit"
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/sweep"
+)
+
+func testNow() time.Time {
+	return time.Date(2024, time.March, 20, 12, 0, 0, 0, time.UTC)
+}
+
+func gmailStandIn(t *testing.T) *standIn {
+	t.Helper()
+	srv := newStandIn(t, "X-GM-EXT-1 UIDPLUS MOVE")
+	srv.addMailbox("INBOX", `\HasNoChildren`, 1)
+	srv.addMailbox("Newsletters", `\HasNoChildren`, 2)
+	srv.addMailbox("[Gmail]", `\HasChildren \Noselect`, 3)
+	srv.addMailbox("[Gmail]/All Mail", `\HasNoChildren \All`, 4)
+	srv.addMailbox("[Gmail]/Sent Mail", `\HasNoChildren \Sent`, 5)
+	all := srv.mailboxes["[Gmail]/All Mail"]
+	all.add(&testMessage{
+		uid:    10,
+		msgID:  0x18b3d491b4320001,
+		labels: []string{`\Inbox`, "Newsletters"},
+		date:   testNow().Add(-10 * day),
+		header: "From: =?UTF-8?Q?Caf=C3=A9?= <news@cafe.example>\r\nSubject: Weekly\r\n\r\n",
+	})
+	all.add(&testMessage{
+		uid:    11,
+		msgID:  0x18b3d491b4320002,
+		labels: []string{`\Inbox`, `\Important`},
+		flags:  map[string]bool{`\Seen`: true},
+		date:   testNow().Add(-time.Hour),
+		header: "From: boss@work.example\r\nSubject: Today\r\n\r\n",
+	})
+	srv.raw = func(query string) []uint32 {
+		if strings.Contains(query, "label:newsletters") {
+			return []uint32{10}
+		}
+		return []uint32{10, 11}
+	}
+	return srv
+}
+
+func dialStandIn(t *testing.T, srv *standIn) *Client {
+	t.Helper()
+	client, err := Dial(context.Background(), Options{
+		Addr:      srv.start(),
+		Username:  srv.user,
+		Password:  srv.password,
+		Plaintext: true,
+		Clock:     testNow,
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestGmailListAndMetadata(t *testing.T) {
+	srv := gmailStandIn(t)
+	client := dialStandIn(t, srv)
+	ctx := context.Background()
+	if !client.GmailExtensions() {
+		t.Fatalf("expected gmail extensions")
+	}
+
+	page, err := client.List(ctx, gmail.Query{Raw: "newer_than:30d"}, "", 1)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(page.IDs) != 1 || page.IDs[0] != "18b3d491b4320002" || page.NextPageToken == "" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+	next, err := client.List(ctx, gmail.Query{Raw: "newer_than:30d"}, page.NextPageToken, 1)
+	if err != nil {
+		t.Fatalf("list next: %v", err)
+	}
+	if len(next.IDs) != 1 || next.IDs[0] != "18b3d491b4320001" || next.NextPageToken != "" {
+		t.Fatalf("unexpected second page: %+v", next)
+	}
+
+	meta, err := client.GetMetadata(ctx, "18b3d491b4320001", []string{"From", "Subject"})
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if meta.Headers["From"] != "Café <news@cafe.example>" || meta.Headers["Subject"] != "Weekly" {
+		t.Fatalf("unexpected headers: %+v", meta.Headers)
+	}
+	for _, want := range []gmail.LabelID{"INBOX", "Newsletters", "UNREAD"} {
+		if !hasLabel(meta.LabelIDs, want) {
+			t.Fatalf("missing label %s in %v", want, meta.LabelIDs)
+		}
+	}
+	if !meta.Date.Equal(testNow().Add(-10 * day)) {
+		t.Fatalf("unexpected date %v", meta.Date)
+	}
+	if !sawCommand(srv, `X-GM-RAW "newer_than:30d"`) || !sawCommand(srv, "BODY.PEEK[HEADER.FIELDS (FROM SUBJECT)]") {
+		t.Fatalf("expected X-GM-RAW search and header-only fetch, got %q", srv.log())
+	}
+
+	byName, _, err := client.ListLabels(ctx)
+	if err != nil {
+		t.Fatalf("list labels: %v", err)
+	}
+	if byName["Newsletters"] != "Newsletters" || byName["SENT"] != "SENT" {
+		t.Fatalf("unexpected labels: %v", byName)
+	}
+	if _, ok := byName["[Gmail]/All Mail"]; ok {
+		t.Fatalf("all mail should not be a label: %v", byName)
+	}
+}
+
+func TestGmailSweepUsesLabelStore(t *testing.T) {
+	srv := gmailStandIn(t)
+	client := dialStandIn(t, srv)
+	svc := sweep.NewService(client, nil, slogDiscard())
+	svc.Clock = testNow
+
+	err := svc.Run(context.Background(), sweep.Spec{Label: "Newsletters", Grace: 7 * day})
+	if err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	swept, err := srv.message("[Gmail]/All Mail", "Weekly")
+	if err != nil {
+		t.Fatalf("find message: %v", err)
+	}
+	if containsFold(swept.labels, `\Inbox`) || !containsFold(swept.labels, "auto-archived/expired") {
+		t.Fatalf("unexpected labels after sweep: %v", swept.labels)
+	}
+	if !swept.flags[`\Seen`] {
+		t.Fatalf("expected swept message to be read")
+	}
+	kept, err := srv.message("[Gmail]/All Mail", "Today")
+	if err != nil {
+		t.Fatalf("find message: %v", err)
+	}
+	if !containsFold(kept.labels, `\Inbox`) {
+		t.Fatalf("untouched message lost INBOX: %v", kept.labels)
+	}
+	if !sawCommand(srv, `CREATE "auto-archived/expired"`) {
+		t.Fatalf("expected expired label to be created, got %q", srv.log())
+	}
+}
+
+func folderStandIn(t *testing.T) *standIn {
+	t.Helper()
+	srv := newStandIn(t, "MOVE")
+	srv.password = "pässwörd"
+	srv.addMailbox("INBOX", `\HasNoChildren`, 7)
+	srv.addMailbox("Lists", `\HasNoChildren`, 8)
+	srv.addMailbox("Trash", `\HasNoChildren \Trash`, 9)
+	inbox := srv.mailboxes["INBOX"]
+	inbox.add(&testMessage{
+		date:   testNow().Add(-20 * day),
+		header: "From: promo@shop.example\r\nSubject: Stale\r\n\r\n",
+	})
+	inbox.add(&testMessage{
+		flags:  map[string]bool{`\Flagged`: true},
+		date:   testNow().Add(-20 * day),
+		header: "From: friend@home.example\r\nSubject: Keep\r\n\r\n",
+	})
+	inbox.add(&testMessage{
+		date:   testNow().Add(-time.Hour),
+		header: "From: promo@shop.example\r\nSubject: Fresh\r\n\r\n",
+	})
+	srv.mailboxes["Lists"].add(&testMessage{
+		flags:  map[string]bool{`\Seen`: true},
+		date:   testNow().Add(-2 * day),
+		header: "From: list@lists.example\r\nSubject: Digest\r\nList-Id: <digest.lists.example>\r\n\r\n",
+	})
+	srv.mailboxes["Lists"].add(&testMessage{
+		date:   testNow().Add(-90 * day),
+		header: "From: list@lists.example\r\nSubject: Ancient\r\n\r\n",
+	})
+	srv.mailboxes["Trash"].add(&testMessage{
+		date:   testNow().Add(-time.Hour),
+		header: "From: promo@shop.example\r\nSubject: Binned\r\n\r\n",
+	})
+	return srv
+}
+
+func TestFolderAuditSearchesAllMailboxes(t *testing.T) {
+	srv := folderStandIn(t)
+	client := dialStandIn(t, srv)
+	if client.GmailExtensions() {
+		t.Fatalf("expected folder semantics")
+	}
+	svc := audit.NewService(client, nil, slogDiscard(), nil)
+	svc.Clock = testNow
+
+	rep, err := svc.Run(context.Background(), audit.Options{Window: 30 * day, TopN: 5})
+	if err != nil {
+		t.Fatalf("audit: %v", err)
+	}
+	// Stale, Keep, Fresh and Digest fall in the window; Ancient is too old and Binned is in the trash.
+	if rep.Total != 4 {
+		t.Fatalf("expected 4 messages, got %d", rep.Total)
+	}
+	if len(rep.TopSenders) == 0 || rep.TopSenders[0].Domain != "shop.example" || rep.TopSenders[0].Count != 2 {
+		t.Fatalf("unexpected top senders: %+v", rep.TopSenders)
+	}
+	if len(rep.TopLists) != 1 || rep.TopLists[0].ListID != "digest.lists.example" {
+		t.Fatalf("unexpected top lists: %+v", rep.TopLists)
+	}
+}
+
+func TestFolderSweepMovesIntoExpiredMailbox(t *testing.T) {
+	srv := folderStandIn(t)
+	client := dialStandIn(t, srv)
+	svc := sweep.NewService(client, nil, slogDiscard())
+	svc.Clock = testNow
+
+	if err := svc.Run(context.Background(), sweep.Spec{Grace: 7 * day}); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	moved, err := srv.message("auto-archived/expired", "Stale")
+	if err != nil {
+		t.Fatalf("expected stale message to be moved: %v", err)
+	}
+	if !moved.flags[`\Seen`] {
+		t.Fatalf("expected moved message to be marked read")
+	}
+	for _, subject := range []string{"Keep", "Fresh"} {
+		if _, findErr := srv.message("INBOX", subject); findErr != nil {
+			t.Fatalf("expected %s to stay in INBOX: %v", subject, findErr)
+		}
+	}
+	if _, err = srv.message("INBOX", "Stale"); err == nil {
+		t.Fatalf("stale message should have left INBOX")
+	}
+}
+
+func TestTranslateQuery(t *testing.T) {
+	client := &Client{
+		clock: testNow,
+		delim: ".",
+		folders: []folder{
+			{name: "INBOX", attrs: map[string]bool{}},
+			{name: "Work.Reports", attrs: map[string]bool{}},
+		},
+	}
+	tests := []struct {
+		raw      string
+		folders  []gmail.LabelID
+		criteria string
+		none     bool
+		err      error
+	}{
+		{raw: `label:"Work/Reports" in:inbox is:unread -is:starred`, folders: []gmail.LabelID{"Work/Reports"},
+			criteria: "UNSEEN UNFLAGGED"},
+		{raw: `from:"a b" -subject:x`, criteria: "FROM a b NOT SUBJECT x"},
+		{raw: "in:inbox is:important", folders: []gmail.LabelID{"INBOX"}, criteria: "ALL", none: true},
+		{raw: "label:missing", criteria: "ALL", none: true},
+		{raw: "label:work-reports in:inbox -label:other", folders: []gmail.LabelID{"Work/Reports"}, criteria: "ALL"},
+		{raw: "a OR b", err: ErrUnsupported},
+		{raw: "has:attachment", err: ErrUnsupported},
+		{raw: "(is:unread)", err: ErrUnsupported},
+		{raw: "-older_than:1d", err: ErrUnsupported},
+	}
+	for _, tt := range tests {
+		q, err := client.translateQuery(tt.raw)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("%q: expected %v, got %v", tt.raw, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tt.raw, err)
+		}
+		if q.none != tt.none || strings.Join(labelStrings(q.folders), ",") != strings.Join(labelStrings(tt.folders), ",") {
+			t.Fatalf("%q: unexpected folders %v none=%v", tt.raw, q.folders, q.none)
+		}
+		if got := criteriaString(q.searchCriteria()); got != tt.criteria {
+			t.Fatalf("%q: criteria %q want %q", tt.raw, got, tt.criteria)
+		}
+	}
+}
+
+func TestSearchTextFoldsLabelIDs(t *testing.T) {
+	q := gmail.Query{
+		Raw:      "from:alerts",
+		LabelIDs: []gmail.LabelID{"INBOX", "UNREAD", "DRAFT", "CATEGORY_UPDATES", `Ops/"Pager"`},
+	}
+	want := "from:alerts in:inbox is:unread in:drafts category:updates label:ops--pager-"
+	if got := searchText(q); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestMailboxNameEncoding(t *testing.T) {
+	tests := map[string]string{
+		"INBOX":            "INBOX",
+		"Reçus & Factures": "Re&AOc-us &- Factures",
+		"日本語":              "&ZeVnLIqe-",
+	}
+	for name, encoded := range tests {
+		if got := encodeMailbox(name); got != encoded {
+			t.Fatalf("encode %q: got %q want %q", name, got, encoded)
+		}
+		if got := decodeMailbox(encoded); got != name {
+			t.Fatalf("decode %q: got %q want %q", encoded, got, name)
+		}
+	}
+}
+
+func criteriaString(criteria []any) string {
+	parts := make([]string, 0, len(criteria))
+	for _, c := range criteria {
+		switch v := c.(type) {
+		case string:
+			parts = append(parts, v)
+		case astring:
+			parts = append(parts, string(v))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func labelStrings(ids []gmail.LabelID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = string(id)
+	}
+	return out
+}
+
+func hasLabel(ids []gmail.LabelID, want gmail.LabelID) bool {
+	for _, id := range ids {
+		if id == want {
+			return true
+		}
+	}
+	return false
+}
+
+func sawCommand(srv *standIn, fragment string) bool {
+	for _, cmd := range srv.log() {
+		if strings.Contains(cmd, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func slogDiscard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
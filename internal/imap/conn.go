@@ -0,0 +1,259 @@
+package imap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLiteral bounds a single server literal; chronosweep only ever fetches header blocks.
+const maxLiteral = 16 << 20
+
+// astring is a command argument sent as a quoted string, or as a literal when quoting cannot represent it.
+type astring string
+
+// conn is a single IMAP connection issuing one tagged command at a time. Callers serialize access.
+type conn struct {
+	nc     net.Conn
+	r      *bufio.Reader
+	tag    int
+	caps   map[string]bool
+	broken error
+}
+
+func dial(ctx context.Context, opts Options) (*conn, error) {
+	dialer := &net.Dialer{}
+	var (
+		nc  net.Conn
+		err error
+	)
+	if opts.Plaintext {
+		nc, err = dialer.DialContext(ctx, "tcp", opts.Addr)
+	} else {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: opts.TLSConfig}
+		nc, err = tlsDialer.DialContext(ctx, "tcp", opts.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", opts.Addr, err)
+	}
+	c := &conn{nc: nc, r: bufio.NewReader(nc), caps: map[string]bool{}}
+	greeting, err := c.readResponse()
+	if err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		_ = nc.Close()
+		return nil, fmt.Errorf("unexpected greeting from %s: %q", opts.Addr, greeting)
+	}
+	return c, nil
+}
+
+// refreshCapabilities asks the server what it supports; the answer may change after LOGIN.
+func (c *conn) refreshCapabilities(ctx context.Context) error {
+	lines, err := c.execute(ctx, "CAPABILITY")
+	if err != nil {
+		return err
+	}
+	c.caps = map[string]bool{}
+	for _, line := range lines {
+		rest, ok := cutPrefixFold(line, "CAPABILITY ")
+		if !ok {
+			continue
+		}
+		for _, capability := range strings.Fields(rest) {
+			c.caps[strings.ToUpper(capability)] = true
+		}
+	}
+	return nil
+}
+
+func (c *conn) has(capability string) bool {
+	return c.caps[capability]
+}
+
+// execute sends one tagged command built from parts (string atoms or astring values) and returns the
+// untagged responses with their "* " prefix removed. A NO or BAD completion is returned as an error.
+func (c *conn) execute(ctx context.Context, parts ...any) ([]string, error) {
+	if c.broken != nil {
+		return nil, c.broken
+	}
+	stop := context.AfterFunc(ctx, func() { _ = c.nc.SetDeadline(time.Now()) })
+	defer stop()
+	lines, err := c.roundTrip(parts)
+	var status *statusError
+	if err != nil && !errors.As(err, &status) {
+		// The connection is mid-command; nothing further can be trusted on it.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = fmt.Errorf("imap %s: %w", commandName(parts), ctxErr)
+		}
+		c.broken = err
+	}
+	return lines, err
+}
+
+func (c *conn) roundTrip(parts []any) ([]string, error) {
+	c.tag++
+	tag := "C" + strconv.Itoa(c.tag)
+	var (
+		buf      strings.Builder
+		untagged []string
+	)
+	buf.WriteString(tag)
+	for _, part := range parts {
+		buf.WriteByte(' ')
+		switch v := part.(type) {
+		case string:
+			buf.WriteString(v)
+		case astring:
+			s := string(v)
+			if !needsLiteral(s) {
+				buf.WriteString(quote(s))
+				continue
+			}
+			if c.has("LITERAL+") {
+				fmt.Fprintf(&buf, "{%d+}\r\n%s", len(s), s)
+				continue
+			}
+			fmt.Fprintf(&buf, "{%d}\r\n", len(s))
+			if err := c.send(buf.String()); err != nil {
+				return nil, err
+			}
+			buf.Reset()
+			more, err := c.awaitContinuation(tag)
+			untagged = append(untagged, more...)
+			if err != nil {
+				return untagged, err
+			}
+			buf.WriteString(s)
+		default:
+			return nil, fmt.Errorf("imap: unsupported command argument %T", part)
+		}
+	}
+	buf.WriteString("\r\n")
+	if err := c.send(buf.String()); err != nil {
+		return nil, err
+	}
+	rest, err := c.readUntilTagged(tag, false)
+	return append(untagged, rest...), err
+}
+
+func (c *conn) send(raw string) error {
+	if _, err := c.nc.Write([]byte(raw)); err != nil {
+		return fmt.Errorf("imap write: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) awaitContinuation(tag string) ([]string, error) {
+	return c.readUntilTagged(tag, true)
+}
+
+// readUntilTagged collects untagged responses until the command completes, or, when continuation is set,
+// until the server asks for the rest of a synchronizing literal.
+func (c *conn) readUntilTagged(tag string, continuation bool) ([]string, error) {
+	var untagged []string
+	for {
+		line, err := c.readResponse()
+		if err != nil {
+			return untagged, err
+		}
+		switch {
+		case strings.HasPrefix(line, "* "):
+			untagged = append(untagged, line[2:])
+		case strings.HasPrefix(line, "+"):
+			if continuation {
+				return untagged, nil
+			}
+		case strings.HasPrefix(line, tag+" "):
+			status := line[len(tag)+1:]
+			if _, ok := cutPrefixFold(status, "OK"); ok {
+				if continuation {
+					return untagged, errors.New("imap: server completed command before literal was sent")
+				}
+				return untagged, nil
+			}
+			return untagged, &statusError{status: status}
+		}
+	}
+}
+
+// readResponse reads one logical response line, inlining any literals it announces.
+func (c *conn) readResponse() (string, error) {
+	var out strings.Builder
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("imap read: %w", err)
+		}
+		size, ok := trailingLiteral(line)
+		if !ok {
+			out.WriteString(strings.TrimRight(line, "\r\n"))
+			return out.String(), nil
+		}
+		if size > maxLiteral {
+			return "", fmt.Errorf("imap: literal of %d bytes exceeds limit", size)
+		}
+		out.WriteString(line)
+		literal := make([]byte, size)
+		if _, err = io.ReadFull(c.r, literal); err != nil {
+			return "", fmt.Errorf("imap read literal: %w", err)
+		}
+		out.Write(literal)
+	}
+}
+
+// trailingLiteral reports the size of a literal announced at the end of line ("{123}\r\n").
+func trailingLiteral(line string) (int, bool) {
+	trimmed := strings.TrimRight(line, "\r\n")
+	if !strings.HasSuffix(trimmed, "}") {
+		return 0, false
+	}
+	open := strings.LastIndexByte(trimmed, '{')
+	if open < 0 {
+		return 0, false
+	}
+	size, err := strconv.Atoi(strings.TrimSuffix(trimmed[open+1:len(trimmed)-1], "+"))
+	if err != nil || size < 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+func (c *conn) close() error {
+	if err := c.nc.Close(); err != nil {
+		return fmt.Errorf("imap close: %w", err)
+	}
+	return nil
+}
+
+// statusError is a NO or BAD completion; the connection remains usable.
+type statusError struct {
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "imap: " + e.status
+}
+
+func commandName(parts []any) string {
+	if len(parts) == 0 {
+		return ""
+	}
+	name, _ := parts[0].(string)
+	return name
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
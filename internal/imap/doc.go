@@ -0,0 +1,3 @@
+// Package imap implements gmail.Client over IMAP, using Gmail's X-GM extensions when available
+// and degrading to folder semantics on other servers.
+package imap
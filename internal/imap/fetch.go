@@ -0,0 +1,210 @@
+package imap
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"mime"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+const internalDateLayout = "_2-Jan-2006 15:04:05 -0700"
+
+// fetchItem is the parsed data of one FETCH response.
+type fetchItem struct {
+	uid      uint32
+	msgID    uint64
+	threadID uint64
+	flags    []string
+	labels   []string
+	date     time.Time
+	header   string
+	hasMsgID bool
+}
+
+// fetchOne fetches flags, Gmail labels, and (optionally) the requested header fields of one message.
+func (c *Client) fetchOne(
+	ctx context.Context,
+	uid uint32,
+	headers []string,
+	withHeaders bool,
+) (fetchItem, error) {
+	items := []string{"UID", "FLAGS", "INTERNALDATE"}
+	if c.gmailExt {
+		items = append(items, "X-GM-LABELS", "X-GM-THRID")
+	}
+	if withHeaders {
+		items = append(items, headerSection(headers))
+	}
+	fetched, err := c.fetch(ctx, []uint32{uid}, "("+strings.Join(items, " ")+")")
+	if err != nil {
+		return fetchItem{}, err
+	}
+	for _, item := range fetched {
+		if item.uid == uid {
+			return item, nil
+		}
+	}
+	return fetchItem{}, fmt.Errorf("message uid %d in %q: %w", uid, c.selected, gmail.ErrMessageNotFound)
+}
+
+func headerSection(headers []string) string {
+	if len(headers) == 0 {
+		return "BODY.PEEK[HEADER]"
+	}
+	names := make([]string, len(headers))
+	for i, h := range headers {
+		names[i] = strings.ToUpper(strings.TrimSpace(h))
+	}
+	return "BODY.PEEK[HEADER.FIELDS (" + strings.Join(names, " ") + ")]"
+}
+
+// fetch runs UID FETCH for uids in the selected mailbox and parses each response.
+func (c *Client) fetch(ctx context.Context, uids []uint32, items string) ([]fetchItem, error) {
+	var out []fetchItem
+	for _, chunk := range chunkUIDs(uids) {
+		lines, err := c.conn.execute(ctx, "UID", "FETCH", uidSet(chunk), items)
+		if err != nil {
+			return nil, fmt.Errorf("imap fetch: %w", err)
+		}
+		for _, line := range lines {
+			_, rest, ok := strings.Cut(line, " ")
+			if !ok {
+				continue
+			}
+			data, isFetch := cutPrefixFold(rest, "FETCH ")
+			if !isFetch {
+				continue
+			}
+			item, parseErr := parseFetch(data)
+			if parseErr != nil {
+				return nil, parseErr
+			}
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+func parseFetch(data string) (fetchItem, error) {
+	vals, err := parseValues(data)
+	if err != nil || len(vals) != 1 || !vals[0].isList {
+		return fetchItem{}, fmt.Errorf("imap: malformed FETCH response %q", data)
+	}
+	var item fetchItem
+	pairs := vals[0].list
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key, val := strings.ToUpper(pairs[i].text), pairs[i+1]
+		switch {
+		case key == "UID":
+			parsed, parseErr := strconv.ParseUint(val.text, 10, 32)
+			if parseErr != nil {
+				return fetchItem{}, fmt.Errorf("imap: malformed UID %q", val.text)
+			}
+			item.uid = uint32(parsed)
+		case key == "X-GM-MSGID":
+			parsed, parseErr := strconv.ParseUint(val.text, 10, 64)
+			if parseErr != nil {
+				return fetchItem{}, fmt.Errorf("imap: malformed X-GM-MSGID %q", val.text)
+			}
+			item.msgID, item.hasMsgID = parsed, true
+		case key == "X-GM-THRID":
+			parsed, parseErr := strconv.ParseUint(val.text, 10, 64)
+			if parseErr != nil {
+				return fetchItem{}, fmt.Errorf("imap: malformed X-GM-THRID %q", val.text)
+			}
+			item.threadID = parsed
+		case key == "FLAGS":
+			item.flags = texts(val.list)
+		case key == "X-GM-LABELS":
+			item.labels = texts(val.list)
+		case key == "INTERNALDATE":
+			parsed, parseErr := time.Parse(internalDateLayout, val.text)
+			if parseErr != nil {
+				return fetchItem{}, fmt.Errorf("imap: malformed INTERNALDATE %q", val.text)
+			}
+			item.date = parsed
+		case strings.HasPrefix(key, "BODY["):
+			item.header = val.text
+		}
+	}
+	return item, nil
+}
+
+func texts(vals []value) []string {
+	out := make([]string, 0, len(vals))
+	for _, v := range vals {
+		out = append(out, v.text)
+	}
+	return out
+}
+
+// meta converts a fetched item to gmail metadata. Folder labels are added by the caller.
+func (item fetchItem) meta(id gmail.MessageID, headers []string, withHeaders bool) gmail.MessageMeta {
+	meta := gmail.MessageMeta{ID: id, Date: item.date, LabelIDs: item.labelIDs()}
+	if item.threadID != 0 {
+		meta.ThreadID = strconv.FormatUint(item.threadID, 16)
+	}
+	if withHeaders {
+		meta.Headers = parseHeaders(item.header, headers)
+	}
+	return meta
+}
+
+func (item fetchItem) labelIDs() []gmail.LabelID {
+	var ids []gmail.LabelID
+	for _, raw := range item.labels {
+		ids = append(ids, gmailLabelID(raw))
+	}
+	seen, starred := false, false
+	for _, flag := range item.flags {
+		switch strings.ToUpper(flag) {
+		case `\SEEN`:
+			seen = true
+		case `\FLAGGED`:
+			starred = true
+		}
+	}
+	if !seen {
+		ids = append(ids, "UNREAD")
+	}
+	if starred {
+		ids = append(ids, "STARRED")
+	}
+	return ids
+}
+
+// parseHeaders decodes a header block, keeping the requested names as the map keys callers look up.
+func parseHeaders(block string, wanted []string) map[string]string {
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(block + "\r\n\r\n")))
+	mimeHeader, err := reader.ReadMIMEHeader()
+	if err != nil && len(mimeHeader) == 0 {
+		return map[string]string{}
+	}
+	decoder := new(mime.WordDecoder)
+	decode := func(raw string) string {
+		decoded, decodeErr := decoder.DecodeHeader(raw)
+		if decodeErr != nil {
+			return raw
+		}
+		return decoded
+	}
+	out := map[string]string{}
+	if len(wanted) == 0 {
+		for name, values := range mimeHeader {
+			out[name] = decode(values[0])
+		}
+		return out
+	}
+	for _, name := range wanted {
+		if raw := mimeHeader.Get(name); raw != "" {
+			out[name] = decode(raw)
+		}
+	}
+	return out
+}
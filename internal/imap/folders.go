@@ -0,0 +1,612 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+const (
+	day   = 24 * time.Hour
+	month = 30 * day
+	year  = 365 * day
+	// searchSlack widens IMAP's day-granular SINCE/BEFORE so server time zones cannot drop matches;
+	// results are then filtered exactly on INTERNALDATE.
+	searchSlack = 2 * day
+	// searchDateLayout is the date format IMAP SEARCH expects.
+	searchDateLayout = "2-Jan-2006"
+	archiveFolder    = "Archive"
+)
+
+// errUIDValidity signals that a mailbox's UIDs were reassigned after a message ID was issued.
+var errUIDValidity = errors.New("mailbox UIDVALIDITY changed; re-run the search")
+
+// messageRef locates a message on a folder-based server. It is encoded into message IDs as
+// "uidvalidity:uid:folder".
+type messageRef struct {
+	validity uint32
+	uid      uint32
+	folder   string
+}
+
+func (r messageRef) id() gmail.MessageID {
+	return gmail.MessageID(fmt.Sprintf("%d:%d:%s", r.validity, r.uid, r.folder))
+}
+
+func parseMessageRef(id gmail.MessageID) (messageRef, error) {
+	parts := strings.SplitN(string(id), ":", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return messageRef{}, fmt.Errorf("invalid imap message id %q", id)
+	}
+	validity, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return messageRef{}, fmt.Errorf("invalid imap message id %q", id)
+	}
+	uid, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return messageRef{}, fmt.Errorf("invalid imap message id %q", id)
+	}
+	return messageRef{validity: uint32(validity), uid: uint32(uid), folder: parts[2]}, nil
+}
+
+// selectChecked selects the message's folder and rejects IDs issued under a different UIDVALIDITY.
+func (c *Client) selectChecked(ctx context.Context, ref messageRef) error {
+	if err := c.selectMailbox(ctx, ref.folder); err != nil {
+		return err
+	}
+	if c.validity != ref.validity {
+		return fmt.Errorf("%q: %w", ref.folder, errUIDValidity)
+	}
+	return nil
+}
+
+// folderQuery is a Gmail query translated for a folder-based server.
+type folderQuery struct {
+	// folders are the label IDs a positive in:/label: term restricted the search to.
+	folders  []gmail.LabelID
+	excluded map[gmail.LabelID]bool
+	criteria []any
+	after    time.Time
+	before   time.Time
+	anywhere bool
+	// none is set when the query cannot match anything on this server (e.g. is:important).
+	none bool
+}
+
+// translateQuery maps the subset of Gmail search syntax chronosweep emits onto IMAP SEARCH. A message on a
+// folder server lives in exactly one mailbox, so in:/label: terms pick mailboxes; in:inbox combined with a
+// label: term selects the label's mailbox, because filing into it is how these servers label mail.
+func (c *Client) translateQuery(raw string) (folderQuery, error) {
+	q := folderQuery{excluded: map[gmail.LabelID]bool{}}
+	terms, err := splitTerms(raw)
+	if err != nil {
+		return folderQuery{}, err
+	}
+	for _, term := range terms {
+		if termErr := c.applyTerm(&q, term); termErr != nil {
+			return folderQuery{}, termErr
+		}
+	}
+	q.folders = distinctLabels(q.folders)
+	if len(q.folders) > 1 {
+		q.folders = withoutLabel(q.folders, "INBOX")
+	}
+	if len(q.folders) > 1 {
+		q.none = true
+	}
+	return q, nil
+}
+
+type queryTerm struct {
+	negated bool
+	op      string
+	value   string
+}
+
+func splitTerms(raw string) ([]queryTerm, error) {
+	var (
+		terms   []queryTerm
+		current strings.Builder
+		quoted  bool
+		escaped bool
+	)
+	flush := func() error {
+		tok := current.String()
+		current.Reset()
+		if tok == "" {
+			return nil
+		}
+		term, err := parseTerm(tok)
+		if err != nil {
+			return err
+		}
+		terms = append(terms, term)
+		return nil
+	}
+	for _, r := range raw {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		case !quoted && (r == '(' || r == ')' || r == '{' || r == '}'):
+			return nil, fmt.Errorf("grouping in %q: %w", raw, ErrUnsupported)
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted || escaped {
+		return nil, fmt.Errorf("unterminated quote in query %q", raw)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+func parseTerm(tok string) (queryTerm, error) {
+	if tok == "OR" || tok == "AND" {
+		return queryTerm{}, fmt.Errorf("operator %s: %w", tok, ErrUnsupported)
+	}
+	term := queryTerm{}
+	if strings.HasPrefix(tok, "-") {
+		term.negated = true
+		tok = tok[1:]
+	}
+	op, val, ok := strings.Cut(tok, ":")
+	if !ok || val == "" {
+		return queryTerm{}, fmt.Errorf("free-text term %q: %w", tok, ErrUnsupported)
+	}
+	term.op, term.value = strings.ToLower(op), val
+	return term, nil
+}
+
+func (c *Client) applyTerm(q *folderQuery, term queryTerm) error {
+	switch term.op {
+	case "in", "label":
+		c.applyFolderTerm(q, term)
+	case "is":
+		return applyFlagTerm(q, term)
+	case "from", "to", "subject":
+		q.criteria = append(q.criteria, negate(term.negated, strings.ToUpper(term.op), astring(term.value))...)
+	case "list":
+		q.criteria = append(q.criteria, negate(term.negated, "HEADER", "List-Id", astring(term.value))...)
+	case "before", "after", "older_than", "newer_than":
+		return c.applyDateTerm(q, term)
+	default:
+		return fmt.Errorf("operator %s: %w", term.op, ErrUnsupported)
+	}
+	return nil
+}
+
+func negate(negated bool, criteria ...any) []any {
+	if !negated {
+		return criteria
+	}
+	return append([]any{"NOT"}, criteria...)
+}
+
+func (c *Client) applyFolderTerm(q *folderQuery, term queryTerm) {
+	if term.op == "in" && strings.EqualFold(term.value, "anywhere") {
+		q.anywhere = !term.negated
+		return
+	}
+	id, found := c.resolveLabel(term.op, term.value)
+	switch {
+	case term.negated:
+		if found {
+			q.excluded[id] = true
+		}
+	case !found:
+		q.none = true
+	default:
+		q.folders = append(q.folders, id)
+	}
+}
+
+// resolveLabel finds the label a query term names, matching Gmail's normalization of label names.
+func (c *Client) resolveLabel(op, value string) (gmail.LabelID, bool) {
+	if op == "in" {
+		switch strings.ToLower(value) {
+		case "inbox":
+			return "INBOX", true
+		case "sent":
+			value = "SENT"
+		case "drafts", "draft":
+			value = "DRAFT"
+		case "trash":
+			value = "TRASH"
+		case "spam":
+			value = "SPAM"
+		}
+	}
+	want := gmail.NormalizeLabel(value)
+	byName, _ := c.labelTable()
+	for name, id := range byName {
+		if gmail.NormalizeLabel(name) == want {
+			if _, ok := c.folderForLabel(id); ok {
+				return id, true
+			}
+		}
+	}
+	return "", false
+}
+
+func applyFlagTerm(q *folderQuery, term queryTerm) error {
+	value := strings.ToLower(term.value)
+	if value == "read" {
+		value, term.negated = "unread", !term.negated
+	}
+	switch value {
+	case "unread":
+		if term.negated {
+			q.criteria = append(q.criteria, "SEEN")
+		} else {
+			q.criteria = append(q.criteria, "UNSEEN")
+		}
+	case "starred":
+		if term.negated {
+			q.criteria = append(q.criteria, "UNFLAGGED")
+		} else {
+			q.criteria = append(q.criteria, "FLAGGED")
+		}
+	case "important":
+		// Folder servers have no importance marker: nothing is important.
+		q.none = q.none || !term.negated
+	default:
+		return fmt.Errorf("is:%s: %w", term.value, ErrUnsupported)
+	}
+	return nil
+}
+
+func (c *Client) applyDateTerm(q *folderQuery, term queryTerm) error {
+	if term.negated {
+		return fmt.Errorf("negated %s: %w", term.op, ErrUnsupported)
+	}
+	var (
+		when time.Time
+		err  error
+	)
+	switch term.op {
+	case "before", "after":
+		when, err = parseQueryDate(term.value)
+	default:
+		var age time.Duration
+		age, err = parseQueryAge(term.value)
+		when = c.clock().Add(-age)
+	}
+	if err != nil {
+		return err
+	}
+	if term.op == "before" || term.op == "older_than" {
+		if q.before.IsZero() || when.Before(q.before) {
+			q.before = when
+		}
+		return nil
+	}
+	if when.After(q.after) {
+		q.after = when
+	}
+	return nil
+}
+
+func parseQueryDate(value string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	for _, layout := range []string{"2006/01/02", "2006/1/2", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", value)
+}
+
+func parseQueryAge(value string) (time.Duration, error) {
+	if len(value) < 2 {
+		return 0, fmt.Errorf("unrecognized age %q", value)
+	}
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("unrecognized age %q", value)
+	}
+	units := map[byte]time.Duration{'h': time.Hour, 'd': day, 'm': month, 'y': year}
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return 0, fmt.Errorf("unrecognized age %q", value)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// searchCriteria renders the translated query, widening date bounds to whole days.
+func (q folderQuery) searchCriteria() []any {
+	criteria := append([]any(nil), q.criteria...)
+	if !q.after.IsZero() {
+		criteria = append(criteria, "SINCE", q.after.Add(-searchSlack).Format(searchDateLayout))
+	}
+	if !q.before.IsZero() {
+		criteria = append(criteria, "BEFORE", q.before.Add(searchSlack).Format(searchDateLayout))
+	}
+	if len(criteria) == 0 {
+		criteria = []any{"ALL"}
+	}
+	return criteria
+}
+
+func (q folderQuery) admits(date time.Time) bool {
+	if !q.after.IsZero() && date.Before(q.after) {
+		return false
+	}
+	return q.before.IsZero() || date.Before(q.before)
+}
+
+// searchScope lists the mailboxes to search. Without a folder term every selectable mailbox is searched,
+// except trash and spam, which Gmail also hides unless asked for.
+func (c *Client) searchScope(q folderQuery) []string {
+	var scope []string
+	if len(q.folders) > 0 {
+		if name, ok := c.folderForLabel(q.folders[0]); ok && !q.excluded[q.folders[0]] {
+			scope = append(scope, name)
+		}
+		return scope
+	}
+	for _, f := range c.folders {
+		id := c.labelForFolder(f.name)
+		if id == "" || q.excluded[id] || (!q.anywhere && (id == "TRASH" || id == "SPAM")) {
+			continue
+		}
+		scope = append(scope, f.name)
+	}
+	return scope
+}
+
+// searchFolders runs the translated query in every mailbox in scope and merges hits newest first.
+func (c *Client) searchFolders(ctx context.Context, raw string) ([]gmail.MessageID, error) {
+	if err := c.refreshFolders(ctx); err != nil {
+		return nil, err
+	}
+	q, err := c.translateQuery(raw)
+	if err != nil {
+		return nil, err
+	}
+	if q.none {
+		return []gmail.MessageID{}, nil
+	}
+	type hit struct {
+		id   gmail.MessageID
+		date time.Time
+	}
+	var hits []hit
+	for _, name := range c.searchScope(q) {
+		if selErr := c.selectMailbox(ctx, name); selErr != nil {
+			return nil, selErr
+		}
+		uids, searchErr := c.searchUIDs(ctx, q.searchCriteria()...)
+		if searchErr != nil {
+			return nil, searchErr
+		}
+		fetched, fetchErr := c.fetch(ctx, uids, "(UID INTERNALDATE)")
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		for _, item := range fetched {
+			if q.admits(item.date) {
+				ref := messageRef{validity: c.validity, uid: item.uid, folder: name}
+				hits = append(hits, hit{id: ref.id(), date: item.date})
+			}
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if !hits[i].date.Equal(hits[j].date) {
+			return hits[i].date.After(hits[j].date)
+		}
+		return hits[i].id < hits[j].id
+	})
+	ids := make([]gmail.MessageID, len(hits))
+	for i, h := range hits {
+		ids[i] = h.id
+	}
+	return ids, nil
+}
+
+// modifyFolders applies ops folder by folder. Flags are stored first so they travel with moved messages.
+// Archiving takes a message out of its current mailbox: into the single added label's mailbox if there is
+// one, otherwise into the Archive mailbox. Adding a label without archiving copies the message.
+func (c *Client) modifyFolders(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	groups := map[string][]messageRef{}
+	for _, id := range ids {
+		ref, err := parseMessageRef(id)
+		if err != nil {
+			return err
+		}
+		groups[ref.folder] = append(groups[ref.folder], ref)
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := c.modifyFolder(ctx, name, groups[name], ops); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) modifyFolder(ctx context.Context, name string, refs []messageRef, ops gmail.ModifyOps) error {
+	uids := make([]uint32, 0, len(refs))
+	for _, ref := range refs {
+		if err := c.selectChecked(ctx, ref); err != nil {
+			return err
+		}
+		uids = append(uids, ref.uid)
+	}
+	target, leave, err := c.destination(ctx, name, ops)
+	if err != nil {
+		return err
+	}
+	if selErr := c.selectMailbox(ctx, name); selErr != nil {
+		return selErr
+	}
+	for _, chunk := range chunkUIDs(uids) {
+		set := uidSet(chunk)
+		for _, edit := range flagEdits(ops) {
+			if _, storeErr := c.conn.execute(ctx, "UID", "STORE", set, edit); storeErr != nil {
+				return fmt.Errorf("imap store %s: %w", edit, storeErr)
+			}
+		}
+		if target == "" {
+			continue
+		}
+		if leave {
+			err = c.move(ctx, set, target)
+		} else {
+			_, err = c.conn.execute(ctx, "UID", "COPY", set, astring(encodeMailbox(target)))
+		}
+		if err != nil {
+			return fmt.Errorf("imap file into %q: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// destination decides where messages in folder end up and whether they leave it.
+func (c *Client) destination(ctx context.Context, name string, ops gmail.ModifyOps) (string, bool, error) {
+	current := c.labelForFolder(name)
+	leave := ops.Archive
+	var targets []gmail.LabelID
+	for _, id := range ops.AddLabels {
+		if id != "UNREAD" && id != "STARRED" && id != current {
+			targets = append(targets, id)
+		}
+	}
+	for _, id := range ops.RemoveLabels {
+		if id == current {
+			leave = true
+		}
+	}
+	if len(targets) > 1 {
+		return "", false, fmt.Errorf("filing into %d mailboxes at once: %w", len(targets), ErrUnsupported)
+	}
+	if len(targets) == 1 {
+		target, ok := c.folderForLabel(targets[0])
+		if !ok {
+			return "", false, fmt.Errorf("label %s: %w", targets[0], ErrUnsupported)
+		}
+		return target, leave, nil
+	}
+	if !leave {
+		return "", false, nil
+	}
+	target, err := c.archiveMailbox(ctx)
+	return target, true, err
+}
+
+// archiveMailbox returns the \Archive special-use mailbox, creating an "Archive" mailbox if none exists.
+func (c *Client) archiveMailbox(ctx context.Context) (string, error) {
+	for _, f := range c.folders {
+		if f.attrs[`\ARCHIVE`] {
+			return f.name, nil
+		}
+	}
+	for _, f := range c.folders {
+		if strings.EqualFold(f.name, archiveFolder) {
+			return f.name, nil
+		}
+	}
+	if _, err := c.conn.execute(ctx, "CREATE", astring(archiveFolder)); err != nil {
+		return "", fmt.Errorf("imap create %q: %w", archiveFolder, err)
+	}
+	if err := c.refreshFolders(ctx); err != nil {
+		return "", err
+	}
+	return archiveFolder, nil
+}
+
+// move relocates messages with MOVE, or with COPY plus a UID EXPUNGE scoped to exactly those messages.
+// Servers offering neither are refused rather than risking an EXPUNGE of unrelated deleted mail.
+func (c *Client) move(ctx context.Context, set, target string) error {
+	mailbox := astring(encodeMailbox(target))
+	if c.conn.has("MOVE") {
+		_, err := c.conn.execute(ctx, "UID", "MOVE", set, mailbox)
+		return err
+	}
+	if !c.conn.has("UIDPLUS") {
+		return fmt.Errorf("moving messages without MOVE or UIDPLUS: %w", ErrUnsupported)
+	}
+	if _, err := c.conn.execute(ctx, "UID", "COPY", set, mailbox); err != nil {
+		return err
+	}
+	if _, err := c.conn.execute(ctx, "UID", "STORE", set, `+FLAGS.SILENT (\Deleted)`); err != nil {
+		return err
+	}
+	_, err := c.conn.execute(ctx, "UID", "EXPUNGE", set)
+	return err
+}
+
+func flagEdits(ops gmail.ModifyOps) []string {
+	var addFlags, removeFlags []string
+	for _, id := range ops.AddLabels {
+		switch id {
+		case "UNREAD":
+			removeFlags = append(removeFlags, `\Seen`)
+		case "STARRED":
+			addFlags = append(addFlags, `\Flagged`)
+		}
+	}
+	for _, id := range ops.RemoveLabels {
+		switch id {
+		case "UNREAD":
+			addFlags = append(addFlags, `\Seen`)
+		case "STARRED":
+			removeFlags = append(removeFlags, `\Flagged`)
+		}
+	}
+	if ops.MarkRead {
+		addFlags = append(addFlags, `\Seen`)
+	}
+	var edits []string
+	if len(addFlags) > 0 {
+		edits = append(edits, "+FLAGS.SILENT ("+strings.Join(dedupe(addFlags), " ")+")")
+	}
+	if len(removeFlags) > 0 {
+		edits = append(edits, "-FLAGS.SILENT ("+strings.Join(dedupe(removeFlags), " ")+")")
+	}
+	return edits
+}
+
+func withoutLabel(ids []gmail.LabelID, drop gmail.LabelID) []gmail.LabelID {
+	out := ids[:0:0]
+	for _, id := range ids {
+		if id != drop {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func distinctLabels(ids []gmail.LabelID) []gmail.LabelID {
+	seen := map[gmail.LabelID]struct{}{}
+	out := ids[:0:0]
+	for _, id := range ids {
+		if _, dup := seen[id]; !dup {
+			seen[id] = struct{}{}
+			out = append(out, id)
+		}
+	}
+	return out
+}
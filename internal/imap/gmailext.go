@@ -0,0 +1,180 @@
+package imap
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// gmailSystemLabels maps Gmail's X-GM-LABELS system names onto label IDs. UNREAD and STARRED are flags.
+func gmailSystemLabels() map[string]gmail.LabelID {
+	return map[string]gmail.LabelID{
+		`\INBOX`:     "INBOX",
+		`\IMPORTANT`: "IMPORTANT",
+		`\SENT`:      "SENT",
+		`\DRAFT`:     "DRAFT",
+		`\TRASH`:     "TRASH",
+		`\SPAM`:      "SPAM",
+	}
+}
+
+// specialUseLabels maps RFC 6154 mailbox attributes onto the system label they stand for.
+func specialUseLabels() map[string]gmail.LabelID {
+	return map[string]gmail.LabelID{
+		`\SENT`:      "SENT",
+		`\DRAFTS`:    "DRAFT",
+		`\TRASH`:     "TRASH",
+		`\JUNK`:      "SPAM",
+		`\IMPORTANT`: "IMPORTANT",
+	}
+}
+
+func isSystemLabel(id gmail.LabelID) bool {
+	switch id {
+	case "INBOX", "UNREAD", "STARRED", "IMPORTANT", "SENT", "DRAFT", "TRASH", "SPAM":
+		return true
+	}
+	return strings.HasPrefix(string(id), "CATEGORY_")
+}
+
+// gmailLabelID converts one X-GM-LABELS value to a label ID; user labels are identified by name.
+func gmailLabelID(raw string) gmail.LabelID {
+	if id, ok := gmailSystemLabels()[strings.ToUpper(raw)]; ok {
+		return id
+	}
+	return gmail.LabelID(decodeMailbox(raw))
+}
+
+// gmailLabelArg renders a label ID for STORE X-GM-LABELS.
+func gmailLabelArg(id gmail.LabelID) string {
+	for raw, system := range gmailSystemLabels() {
+		if system == id {
+			return `\` + raw[1:2] + strings.ToLower(raw[2:])
+		}
+	}
+	return quote(encodeMailbox(string(id)))
+}
+
+// searchGmail runs an X-GM-RAW search over All Mail and resolves each hit to its Gmail message ID.
+func (c *Client) searchGmail(ctx context.Context, raw string) ([]gmail.MessageID, error) {
+	if err := c.selectMailbox(ctx, c.allMail); err != nil {
+		return nil, err
+	}
+	criteria := []any{"ALL"}
+	if strings.TrimSpace(raw) != "" {
+		criteria = []any{"X-GM-RAW", astring(raw)}
+	}
+	uids, err := c.searchUIDs(ctx, criteria...)
+	if err != nil {
+		return nil, err
+	}
+	fetched, err := c.fetch(ctx, uids, "(UID X-GM-MSGID)")
+	if err != nil {
+		return nil, err
+	}
+	byUID := make(map[uint32]gmail.MessageID, len(fetched))
+	for _, item := range fetched {
+		if !item.hasMsgID {
+			continue
+		}
+		id := gmail.MessageID(strconv.FormatUint(item.msgID, 16))
+		byUID[item.uid] = id
+		c.uids[id] = item.uid
+	}
+	// UIDs grow with arrival in All Mail, so descending UID order is newest first.
+	ids := make([]gmail.MessageID, 0, len(uids))
+	for i := len(uids) - 1; i >= 0; i-- {
+		if id, ok := byUID[uids[i]]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// gmailUID resolves a Gmail message ID to its All Mail UID, searching by X-GM-MSGID when List did not see it.
+func (c *Client) gmailUID(ctx context.Context, id gmail.MessageID) (uint32, error) {
+	if err := c.selectMailbox(ctx, c.allMail); err != nil {
+		return 0, err
+	}
+	if uid, ok := c.uids[id]; ok {
+		return uid, nil
+	}
+	msgID, err := strconv.ParseUint(string(id), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid gmail message id %q", id)
+	}
+	uids, err := c.searchUIDs(ctx, "X-GM-MSGID", strconv.FormatUint(msgID, 10))
+	if err != nil {
+		return 0, err
+	}
+	if len(uids) == 0 {
+		return 0, fmt.Errorf("message %s: %w", id, gmail.ErrMessageNotFound)
+	}
+	c.uids[id] = uids[0]
+	return uids[0], nil
+}
+
+// modifyGmail applies ops with STORE. Labels are added before INBOX is removed so a message never ends up
+// without the labels a sweep meant to give it.
+func (c *Client) modifyGmail(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	uids := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		uid, err := c.gmailUID(ctx, id)
+		if err != nil {
+			return err
+		}
+		uids = append(uids, uid)
+	}
+	edits := planGmailEdits(ops)
+	for _, chunk := range chunkUIDs(uids) {
+		set := uidSet(chunk)
+		for _, edit := range edits {
+			if _, err := c.conn.execute(ctx, "UID", "STORE", set, edit); err != nil {
+				return fmt.Errorf("imap store %s: %w", edit, err)
+			}
+		}
+	}
+	return nil
+}
+
+func planGmailEdits(ops gmail.ModifyOps) []string {
+	var addLabels, removeLabels []string
+	for _, id := range ops.AddLabels {
+		if id != "UNREAD" && id != "STARRED" {
+			addLabels = append(addLabels, gmailLabelArg(id))
+		}
+	}
+	for _, id := range ops.RemoveLabels {
+		if id != "UNREAD" && id != "STARRED" {
+			removeLabels = append(removeLabels, gmailLabelArg(id))
+		}
+	}
+	if ops.Archive {
+		removeLabels = append(removeLabels, gmailLabelArg("INBOX"))
+	}
+	var edits []string
+	if len(addLabels) > 0 {
+		edits = append(edits, "+X-GM-LABELS ("+strings.Join(dedupe(addLabels), " ")+")")
+	}
+	edits = append(edits, flagEdits(ops)...)
+	if len(removeLabels) > 0 {
+		edits = append(edits, "-X-GM-LABELS ("+strings.Join(dedupe(removeLabels), " ")+")")
+	}
+	return edits
+}
+
+func dedupe(items []string) []string {
+	seen := map[string]struct{}{}
+	out := items[:0:0]
+	for _, item := range items {
+		if _, dup := seen[item]; dup {
+			continue
+		}
+		seen[item] = struct{}{}
+		out = append(out, item)
+	}
+	return out
+}
@@ -0,0 +1,406 @@
+package imap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// standIn is a minimal in-process IMAP server covering the commands the client issues.
+type standIn struct {
+	t        *testing.T
+	caps     string
+	user     string
+	password string
+	// raw answers X-GM-RAW searches in Gmail mode.
+	raw func(query string) []uint32
+
+	mu        sync.Mutex
+	mailboxes map[string]*testMailbox
+	order     []string
+	commands  []string
+	selected  string
+}
+
+type testMailbox struct {
+	attrs    string
+	validity uint32
+	nextUID  uint32
+	messages []*testMessage
+}
+
+type testMessage struct {
+	uid    uint32
+	msgID  uint64
+	flags  map[string]bool
+	labels []string
+	date   time.Time
+	header string
+}
+
+func newStandIn(t *testing.T, caps string) *standIn {
+	t.Helper()
+	return &standIn{
+		t:         t,
+		caps:      caps,
+		user:      "user@example.com",
+		password:  "secret",
+		mailboxes: map[string]*testMailbox{},
+	}
+}
+
+func (s *standIn) addMailbox(name, attrs string, validity uint32) *testMailbox {
+	mb := &testMailbox{attrs: attrs, validity: validity, nextUID: 1}
+	s.mailboxes[name] = mb
+	s.order = append(s.order, name)
+	return mb
+}
+
+func (mb *testMailbox) add(msg *testMessage) {
+	if msg.uid == 0 {
+		msg.uid = mb.nextUID
+	}
+	mb.nextUID = max(mb.nextUID, msg.uid+1)
+	if msg.flags == nil {
+		msg.flags = map[string]bool{}
+	}
+	mb.messages = append(mb.messages, msg)
+}
+
+// start listens on a loopback port and returns its address; the listener closes with the test.
+func (s *standIn) start() string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		s.t.Fatalf("listen: %v", err)
+	}
+	s.t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			nc, acceptErr := ln.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go s.serve(nc)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (s *standIn) log() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *standIn) serve(nc net.Conn) {
+	defer func() { _ = nc.Close() }()
+	r := bufio.NewReader(nc)
+	w := bufio.NewWriter(nc)
+	write := func(line string) {
+		_, _ = w.WriteString(line + "\r\n")
+		_ = w.Flush()
+	}
+	write("* OK stand-in ready")
+	for {
+		line, err := readCommand(r, write)
+		if err != nil {
+			return
+		}
+		tag, rest, _ := strings.Cut(line, " ")
+		s.mu.Lock()
+		s.commands = append(s.commands, rest)
+		untagged, status := s.handle(rest)
+		s.mu.Unlock()
+		for _, u := range untagged {
+			write("* " + u)
+		}
+		write(tag + " " + status)
+		if strings.HasPrefix(strings.ToUpper(rest), "LOGOUT") {
+			return
+		}
+	}
+}
+
+// readCommand reads one command, answering synchronizing literals with a continuation request.
+func readCommand(r *bufio.Reader, write func(string)) (string, error) {
+	var out strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("read command: %w", err)
+		}
+		size, ok := trailingLiteral(line)
+		if !ok {
+			out.WriteString(strings.TrimRight(line, "\r\n"))
+			return out.String(), nil
+		}
+		if !strings.HasSuffix(strings.TrimRight(line, "\r\n"), "+}") {
+			write("+ go ahead")
+		}
+		out.WriteString(line)
+		buf := make([]byte, size)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return "", fmt.Errorf("read literal: %w", err)
+		}
+		out.Write(buf)
+	}
+}
+
+func (s *standIn) handle(rest string) ([]string, string) {
+	vals, err := parseValues(rest)
+	if err != nil || len(vals) == 0 {
+		return nil, "BAD parse error"
+	}
+	args := vals[1:]
+	switch strings.ToUpper(vals[0].text) {
+	case "CAPABILITY":
+		return []string{"CAPABILITY IMAP4rev1 " + s.caps}, "OK done"
+	case "LOGIN":
+		if len(args) != 2 || args[0].text != s.user || args[1].text != s.password {
+			return nil, "NO [AUTHENTICATIONFAILED] bad credentials"
+		}
+		return nil, "OK logged in"
+	case "LIST":
+		return s.list(), "OK done"
+	case "SELECT":
+		return s.selectMailbox(decodeMailbox(args[0].text))
+	case "CREATE":
+		s.addMailbox(decodeMailbox(args[0].text), `\HasNoChildren`, uint32(len(s.order)+1))
+		return nil, "OK created"
+	case "UID":
+		return s.handleUID(args)
+	case "LOGOUT":
+		return []string{"BYE"}, "OK bye"
+	}
+	return nil, "BAD unknown command"
+}
+
+func (s *standIn) list() []string {
+	out := make([]string, 0, len(s.order))
+	for _, name := range s.order {
+		out = append(out, fmt.Sprintf(`LIST (%s) "/" %s`, s.mailboxes[name].attrs, quote(encodeMailbox(name))))
+	}
+	return out
+}
+
+func (s *standIn) selectMailbox(name string) ([]string, string) {
+	mb, ok := s.mailboxes[name]
+	if !ok {
+		return nil, "NO no such mailbox"
+	}
+	s.selected = name
+	return []string{
+		fmt.Sprintf("%d EXISTS", len(mb.messages)),
+		fmt.Sprintf("OK [UIDVALIDITY %d] ok", mb.validity),
+	}, "OK [READ-WRITE] selected"
+}
+
+func (s *standIn) handleUID(args []value) ([]string, string) {
+	mb := s.mailboxes[s.selected]
+	if mb == nil || len(args) == 0 {
+		return nil, "BAD no mailbox selected"
+	}
+	switch strings.ToUpper(args[0].text) {
+	case "SEARCH":
+		return []string{"SEARCH" + s.search(mb, args[1:])}, "OK done"
+	case "FETCH":
+		var out []string
+		for _, msg := range pick(mb, args[1].text) {
+			out = append(out, s.fetch(msg, args[2].list))
+		}
+		return out, "OK done"
+	case "STORE":
+		for _, msg := range pick(mb, args[1].text) {
+			store(msg, strings.ToUpper(args[2].text), texts(args[3].list))
+		}
+		return nil, "OK done"
+	case "MOVE", "COPY":
+		target, ok := s.mailboxes[decodeMailbox(args[2].text)]
+		if !ok {
+			return nil, "NO [TRYCREATE] no such mailbox"
+		}
+		moved := pick(mb, args[1].text)
+		for _, msg := range moved {
+			clone := *msg
+			clone.uid = 0
+			clone.flags = map[string]bool{}
+			for flag := range msg.flags {
+				clone.flags[flag] = true
+			}
+			target.add(&clone)
+		}
+		if strings.EqualFold(args[0].text, "MOVE") {
+			mb.messages = without(mb.messages, moved)
+		}
+		return nil, "OK done"
+	}
+	return nil, "BAD unknown UID command"
+}
+
+func (s *standIn) search(mb *testMailbox, criteria []value) string {
+	var uids []uint32
+	if len(criteria) >= 2 && strings.EqualFold(criteria[0].text, "X-GM-RAW") {
+		uids = s.raw(criteria[1].text)
+	} else {
+		for _, msg := range mb.messages {
+			if matches(msg, criteria) {
+				uids = append(uids, msg.uid)
+			}
+		}
+	}
+	var b strings.Builder
+	for _, uid := range uids {
+		b.WriteString(" " + strconv.FormatUint(uint64(uid), 10))
+	}
+	return b.String()
+}
+
+func matches(msg *testMessage, criteria []value) bool {
+	for i := 0; i < len(criteria); i++ {
+		negated := strings.EqualFold(criteria[i].text, "NOT")
+		if negated {
+			i++
+		}
+		ok := true
+		switch strings.ToUpper(criteria[i].text) {
+		case "UNSEEN":
+			ok = !msg.flags[`\Seen`]
+		case "SEEN":
+			ok = msg.flags[`\Seen`]
+		case "FLAGGED":
+			ok = msg.flags[`\Flagged`]
+		case "UNFLAGGED":
+			ok = !msg.flags[`\Flagged`]
+		case "SINCE", "BEFORE":
+			// Date criteria are day-granular on real servers; the client filters precisely afterwards.
+			i++
+		case "FROM", "TO", "SUBJECT":
+			i++
+			ok = strings.Contains(strings.ToLower(msg.header), strings.ToLower(criteria[i].text))
+		case "X-GM-MSGID":
+			i++
+			ok = strconv.FormatUint(msg.msgID, 10) == criteria[i].text
+		}
+		if ok == negated {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *standIn) fetch(msg *testMessage, items []value) string {
+	parts := []string{"UID", strconv.FormatUint(uint64(msg.uid), 10)}
+	for _, item := range items {
+		key := strings.ToUpper(item.text)
+		switch {
+		case key == "FLAGS":
+			var flags []string
+			for flag := range msg.flags {
+				flags = append(flags, flag)
+			}
+			parts = append(parts, "FLAGS ("+strings.Join(flags, " ")+")")
+		case key == "INTERNALDATE":
+			parts = append(parts, `INTERNALDATE "`+msg.date.Format(internalDateLayout)+`"`)
+		case key == "X-GM-MSGID":
+			parts = append(parts, "X-GM-MSGID "+strconv.FormatUint(msg.msgID, 10))
+		case key == "X-GM-LABELS":
+			quoted := make([]string, len(msg.labels))
+			for i, label := range msg.labels {
+				quoted[i] = quote(label)
+			}
+			parts = append(parts, "X-GM-LABELS ("+strings.Join(quoted, " ")+")")
+		case strings.HasPrefix(key, "BODY.PEEK["):
+			section := "BODY" + strings.TrimPrefix(item.text, "BODY.PEEK")
+			parts = append(parts, fmt.Sprintf("%s {%d}\r\n%s", section, len(msg.header), msg.header))
+		}
+	}
+	return "1 FETCH (" + strings.Join(parts, " ") + ")"
+}
+
+func store(msg *testMessage, op string, items []string) {
+	switch op {
+	case "+X-GM-LABELS":
+		for _, item := range items {
+			if !containsFold(msg.labels, item) {
+				msg.labels = append(msg.labels, item)
+			}
+		}
+	case "-X-GM-LABELS":
+		kept := msg.labels[:0]
+		for _, label := range msg.labels {
+			if !containsFold(items, label) {
+				kept = append(kept, label)
+			}
+		}
+		msg.labels = kept
+	case "+FLAGS.SILENT":
+		for _, item := range items {
+			msg.flags[item] = true
+		}
+	case "-FLAGS.SILENT":
+		for _, item := range items {
+			delete(msg.flags, item)
+		}
+	}
+}
+
+func pick(mb *testMailbox, set string) []*testMessage {
+	wanted := map[string]bool{}
+	for _, uid := range strings.Split(set, ",") {
+		wanted[uid] = true
+	}
+	var out []*testMessage
+	for _, msg := range mb.messages {
+		if wanted[strconv.FormatUint(uint64(msg.uid), 10)] {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+func without(all, drop []*testMessage) []*testMessage {
+	var kept []*testMessage
+	for _, msg := range all {
+		dropped := false
+		for _, d := range drop {
+			dropped = dropped || d == msg
+		}
+		if !dropped {
+			kept = append(kept, msg)
+		}
+	}
+	return kept
+}
+
+func containsFold(items []string, want string) bool {
+	for _, item := range items {
+		if strings.EqualFold(item, want) {
+			return true
+		}
+	}
+	return false
+}
+
+var errNoMessage = errors.New("message not found")
+
+func (s *standIn) message(mailbox, subject string) (*testMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mb, ok := s.mailboxes[mailbox]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", mailbox, errNoMessage)
+	}
+	for _, msg := range mb.messages {
+		if strings.Contains(msg.header, "Subject: "+subject) {
+			return msg, nil
+		}
+	}
+	return nil, fmt.Errorf("%s in %s: %w", subject, mailbox, errNoMessage)
+}
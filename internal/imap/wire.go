@@ -0,0 +1,238 @@
+package imap
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// value is one parsed element of an IMAP response: an atom, a string, or a parenthesized list.
+type value struct {
+	text   string
+	list   []value
+	isList bool
+	isNil  bool
+}
+
+// parseValues parses a sequence of IMAP data items (atoms, quoted strings, literals, lists).
+func parseValues(raw string) ([]value, error) {
+	p := &wireParser{raw: raw}
+	vals, err := p.parseSeq(0)
+	if err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+type wireParser struct {
+	raw string
+	pos int
+}
+
+func (p *wireParser) parseSeq(closer byte) ([]value, error) {
+	var out []value
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.raw) {
+			if closer != 0 {
+				return nil, errors.New("unterminated list in response")
+			}
+			return out, nil
+		}
+		ch := p.raw[p.pos]
+		if closer != 0 && ch == closer {
+			p.pos++
+			return out, nil
+		}
+		v, err := p.parseOne()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+}
+
+func (p *wireParser) parseOne() (value, error) {
+	switch p.raw[p.pos] {
+	case '(':
+		p.pos++
+		list, err := p.parseSeq(')')
+		if err != nil {
+			return value{}, err
+		}
+		return value{list: list, isList: true}, nil
+	case '"':
+		return p.parseQuoted()
+	case '{':
+		return p.parseLiteral()
+	default:
+		return p.parseAtom(), nil
+	}
+}
+
+func (p *wireParser) parseQuoted() (value, error) {
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.raw) {
+		ch := p.raw[p.pos]
+		p.pos++
+		switch ch {
+		case '\\':
+			if p.pos < len(p.raw) {
+				b.WriteByte(p.raw[p.pos])
+				p.pos++
+			}
+		case '"':
+			return value{text: b.String()}, nil
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return value{}, errors.New("unterminated quoted string in response")
+}
+
+func (p *wireParser) parseLiteral() (value, error) {
+	end := strings.IndexByte(p.raw[p.pos:], '}')
+	if end < 0 {
+		return value{}, errors.New("malformed literal in response")
+	}
+	size, err := strconv.Atoi(strings.TrimSuffix(p.raw[p.pos+1:p.pos+end], "+"))
+	if err != nil {
+		return value{}, fmt.Errorf("literal size: %w", err)
+	}
+	p.pos += end + 1
+	p.pos += len(crlfPrefix(p.raw[p.pos:]))
+	if p.pos+size > len(p.raw) {
+		return value{}, errors.New("literal exceeds response")
+	}
+	text := p.raw[p.pos : p.pos+size]
+	p.pos += size
+	return value{text: text}, nil
+}
+
+// parseAtom reads an atom; bracketed sections such as BODY[HEADER.FIELDS (FROM)] stay part of the atom.
+func (p *wireParser) parseAtom() value {
+	start := p.pos
+	depth := 0
+	for p.pos < len(p.raw) {
+		ch := p.raw[p.pos]
+		if depth == 0 && (ch == ' ' || ch == '(' || ch == ')') {
+			break
+		}
+		switch ch {
+		case '[':
+			depth++
+		case ']':
+			depth = max(depth-1, 0)
+		}
+		p.pos++
+	}
+	text := p.raw[start:p.pos]
+	if strings.EqualFold(text, "NIL") {
+		return value{isNil: true}
+	}
+	return value{text: text}
+}
+
+func (p *wireParser) skipSpace() {
+	for p.pos < len(p.raw) && (p.raw[p.pos] == ' ' || p.raw[p.pos] == '\r' || p.raw[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func crlfPrefix(s string) string {
+	if strings.HasPrefix(s, "\r\n") {
+		return "\r\n"
+	}
+	if strings.HasPrefix(s, "\n") {
+		return "\n"
+	}
+	return ""
+}
+
+// quote renders s as an IMAP quoted string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// needsLiteral reports whether s cannot be sent as a quoted string.
+func needsLiteral(s string) bool {
+	for i := range len(s) {
+		if s[i] >= 0x80 || s[i] == '\r' || s[i] == '\n' || s[i] == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeMailbox converts a UTF-8 mailbox name to IMAP's modified UTF-7 (RFC 3501 §5.1.3).
+func encodeMailbox(name string) string {
+	var (
+		out     strings.Builder
+		pending []rune
+	)
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		units := utf16.Encode(pending)
+		buf := make([]byte, 0, len(units)*2)
+		for _, u := range units {
+			buf = append(buf, byte(u>>8), byte(u))
+		}
+		out.WriteByte('&')
+		out.WriteString(strings.ReplaceAll(base64.RawStdEncoding.EncodeToString(buf), "/", ","))
+		out.WriteByte('-')
+		pending = pending[:0]
+	}
+	for _, r := range name {
+		switch {
+		case r == '&':
+			flush()
+			out.WriteString("&-")
+		case r >= 0x20 && r <= 0x7e:
+			flush()
+			out.WriteRune(r)
+		default:
+			pending = append(pending, r)
+		}
+	}
+	flush()
+	return out.String()
+}
+
+// decodeMailbox converts a modified UTF-7 mailbox name back to UTF-8.
+func decodeMailbox(name string) string {
+	var out strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '&' {
+			out.WriteByte(name[i])
+			continue
+		}
+		end := strings.IndexByte(name[i:], '-')
+		if end < 0 {
+			out.WriteString(name[i:])
+			break
+		}
+		chunk := name[i+1 : i+end]
+		i += end
+		if chunk == "" {
+			out.WriteByte('&')
+			continue
+		}
+		raw, err := base64.RawStdEncoding.DecodeString(strings.ReplaceAll(chunk, ",", "/"))
+		if err != nil || len(raw)%2 != 0 {
+			out.WriteString("&" + chunk + "-")
+			continue
+		}
+		units := make([]uint16, 0, len(raw)/2)
+		for j := 0; j+1 < len(raw); j += 2 {
+			units = append(units, uint16(raw[j])<<8|uint16(raw[j+1]))
+		}
+		out.WriteString(string(utf16.Decode(units)))
+	}
+	return out.String()
+}
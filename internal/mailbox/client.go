@@ -0,0 +1,85 @@
+package mailbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/snapshot"
+)
+
+// ErrReadOnly is returned by mutating calls; imported mailboxes are never modified.
+var ErrReadOnly = gmail.ErrReadOnly
+
+// Client implements the read-only half of gmail.Client over an imported mailbox.
+type Client struct {
+	reader   *snapshot.Client
+	unparsed int
+}
+
+// NewClient serves reads from an imported mailbox snapshot.
+func NewClient(snap snapshot.Snapshot) *Client {
+	return &Client{reader: snapshot.NewClient(snap)}
+}
+
+// Unparsed counts the messages the import left out because their headers could not be parsed.
+func (c *Client) Unparsed() int {
+	return c.unparsed
+}
+
+// List evaluates the query against the imported messages.
+func (c *Client) List(
+	ctx context.Context,
+	q gmail.Query,
+	pageToken string,
+	pageSize int,
+) (gmail.ListPage, error) {
+	page, err := c.reader.List(ctx, q, pageToken, pageSize)
+	if err != nil {
+		return gmail.ListPage{}, fmt.Errorf("mailbox list: %w", err)
+	}
+	return page, nil
+}
+
+// GetMetadata returns the imported headers for a message.
+func (c *Client) GetMetadata(
+	ctx context.Context,
+	id gmail.MessageID,
+	headers []string,
+) (gmail.MessageMeta, error) {
+	meta, err := c.reader.GetMetadata(ctx, id, headers)
+	if err != nil {
+		return gmail.MessageMeta{}, fmt.Errorf("mailbox get metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// ListLabels returns the labels discovered during import.
+func (c *Client) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	byName, byID, err := c.reader.ListLabels(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mailbox list labels: %w", err)
+	}
+	return byName, byID, nil
+}
+
+// BatchModify always fails with ErrReadOnly.
+func (c *Client) BatchModify(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	_ = ctx
+	_ = ops
+	return fmt.Errorf("batch modify %d messages: %w", len(ids), ErrReadOnly)
+}
+
+// EnsureLabel always fails with ErrReadOnly.
+func (c *Client) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
+	_ = ctx
+	return "", fmt.Errorf("ensure label %q: %w", name, ErrReadOnly)
+}
+
+// CapturedAt reports the date of the newest imported message, used as "now" for relative queries.
+func (c *Client) CapturedAt() time.Time {
+	return c.reader.CapturedAt()
+}
+
+var _ gmail.Client = (*Client)(nil)
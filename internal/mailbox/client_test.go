@@ -0,0 +1,178 @@
+package mailbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/snapshot"
+)
+
+const takeoutMbox = `From 1780000000000000001@xxx Mon Mar 04 10:00:00 +0000 2024
+X-GM-THRID: 1780000000000000001
+X-Gmail-Labels: Inbox,Unread,Category Promotions,"Finance, Bills"
+From: Bank <alerts@bank.example>
+Subject: =?UTF-8?Q?Statement_=E2=9C=93?=
+Date: Mon, 04 Mar 2024 10:00:00 +0000
+List-Id: <statements.bank.example>
+
+Body line
+>From the desk of someone, escaped as mboxrd requires
+
+From 1780000000000000002@xxx Tue Mar 05 10:00:00 +0000 2024
+X-Gmail-Labels: Archived,Opened,Sent
+From: me@example.com
+Subject: Reply
+Date: Tue, 05 Mar 2024 10:00:00 +0000
+
+Hello
+`
+
+func TestReadMboxMapsLabelsAndHeaders(t *testing.T) {
+	imported, err := ReadMbox(strings.NewReader(takeoutMbox), Options{})
+	if err != nil {
+		t.Fatalf("read mbox: %v", err)
+	}
+	client := imported.Client()
+	ctx := context.Background()
+
+	page, err := client.List(ctx, gmail.Query{Raw: `in:inbox label:"Finance, Bills"`}, "", 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(page.IDs) != 1 || page.IDs[0] != "18b3d491b4320001" {
+		t.Fatalf("unexpected ids: %v", page.IDs)
+	}
+	meta, err := client.GetMetadata(ctx, page.IDs[0], []string{"Subject", "List-Id"})
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if meta.Headers["Subject"] != "Statement ✓" || meta.Headers["List-Id"] != "<statements.bank.example>" {
+		t.Fatalf("unexpected headers: %+v", meta.Headers)
+	}
+	if !containsLabel(meta.LabelIDs, "CATEGORY_PROMOTIONS") || !containsLabel(meta.LabelIDs, "UNREAD") {
+		t.Fatalf("unexpected labels: %v", meta.LabelIDs)
+	}
+
+	sent, err := client.List(ctx, gmail.Query{Raw: "in:sent"}, "", 10)
+	if err != nil {
+		t.Fatalf("list sent: %v", err)
+	}
+	if len(sent.IDs) != 1 {
+		t.Fatalf("expected one sent message, got %v", sent.IDs)
+	}
+}
+
+func TestLoadMaildirUsesFoldersAndFlags(t *testing.T) {
+	root := t.TempDir()
+	writeMaildirFile(t, filepath.Join(root, "new", "1700000000.a.host"), "From: a@example.com\nSubject: new\n\nbody\n")
+	writeMaildirFile(t, filepath.Join(root, "cur", "1700000001.b.host:2,SF"), "From: b@example.com\nSubject: seen\n\nbody\n")
+	writeMaildirFile(
+		t,
+		filepath.Join(root, ".Work.Reports", "cur", "1700000002.c.host:2,"),
+		"From: c@example.com\nSubject: report\n\nbody\n",
+	)
+
+	writeMaildirFile(
+		t,
+		filepath.Join(root, "cur", "1700000003.d.host:2,S"),
+		"From: d@example.com\nnot a header\n\nbody\n",
+	)
+
+	imported, err := LoadMaildir(root, Options{Headers: []string{"From"}})
+	if err != nil {
+		t.Fatalf("load maildir: %v", err)
+	}
+	if imported.Unparsed != 1 {
+		t.Fatalf("expected the malformed file to be counted, got %d", imported.Unparsed)
+	}
+	client := imported.Client()
+	ctx := context.Background()
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{query: "in:inbox", want: 2},
+		{query: "is:unread", want: 2},
+		{query: "is:starred", want: 1},
+		{query: "label:work/reports", want: 1},
+	}
+	for _, tt := range tests {
+		page, listErr := client.List(ctx, gmail.Query{Raw: tt.query}, "", 10)
+		if listErr != nil {
+			t.Fatalf("list %q: %v", tt.query, listErr)
+		}
+		if len(page.IDs) != tt.want {
+			t.Fatalf("query %q: got %v want %d", tt.query, page.IDs, tt.want)
+		}
+	}
+	meta, err := client.GetMetadata(ctx, "1700000001.b.host", nil)
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if _, ok := meta.Headers["Subject"]; ok {
+		t.Fatalf("expected header filtering to drop Subject: %+v", meta.Headers)
+	}
+}
+
+func TestClientRejectsMutations(t *testing.T) {
+	client := NewClient(mustSnapshot(t))
+	err := client.BatchModify(context.Background(), []gmail.MessageID{"x"}, gmail.ModifyOps{Archive: true})
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected read-only error, got %v", err)
+	}
+	if _, err = client.EnsureLabel(context.Background(), "x"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected read-only error, got %v", err)
+	}
+}
+
+func TestReadMboxSkipsUnparseableHeaders(t *testing.T) {
+	broken := "From 1780000000000000003@xxx Wed Mar 06 10:00:00 +0000 2024\n" +
+		"From: spam@example.com\n" +
+		"this line has no colon\n\nbody\n\n"
+	imported, err := ReadMbox(strings.NewReader(broken+takeoutMbox), Options{})
+	if err != nil {
+		t.Fatalf("read mbox: %v", err)
+	}
+	if imported.Unparsed != 1 || len(imported.Snapshot.Messages) != 2 {
+		t.Fatalf("got %d unparsed and %d messages, want 1 and 2", imported.Unparsed, len(imported.Snapshot.Messages))
+	}
+	if got := imported.Client().Unparsed(); got != 1 {
+		t.Fatalf("client reports %d unparsed, want 1", got)
+	}
+}
+
+func mustSnapshot(t *testing.T) snapshot.Snapshot {
+	t.Helper()
+	imported, err := ReadMbox(strings.NewReader(takeoutMbox), Options{})
+	if err != nil {
+		t.Fatalf("read mbox: %v", err)
+	}
+	return imported.Snapshot
+}
+
+func writeMaildirFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(filepath.Dir(filepath.Dir(path)), "cur"), 0o700); err != nil {
+		t.Fatalf("mkdir cur: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func containsLabel(ids []gmail.LabelID, want gmail.LabelID) bool {
+	for _, id := range ids {
+		if id == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,2 @@
+// Package mailbox imports Google Takeout mbox exports and local Maildirs as a read-only gmail.Client.
+package mailbox
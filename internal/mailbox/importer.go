@@ -0,0 +1,121 @@
+package mailbox
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/snapshot"
+)
+
+// Import is a mailbox read into memory.
+type Import struct {
+	Snapshot snapshot.Snapshot
+	// Unparsed counts messages left out because net/mail could not parse their header block.
+	Unparsed int
+}
+
+// Client serves reads from the imported messages and reports how many were left out.
+func (imp Import) Client() *Client {
+	client := NewClient(imp.Snapshot)
+	client.unparsed = imp.Unparsed
+	return client
+}
+
+// importer turns parsed header blocks into snapshot messages.
+type importer struct {
+	wanted   map[string]struct{}
+	labels   *labelSet
+	messages []gmail.MessageMeta
+	seen     map[gmail.MessageID]struct{}
+	latest   time.Time
+	logger   *slog.Logger
+	unparsed int
+}
+
+func newImporter(opts Options) *importer {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	imp := &importer{labels: newLabelSet(), seen: map[gmail.MessageID]struct{}{}, logger: logger}
+	if len(opts.Headers) > 0 {
+		imp.wanted = make(map[string]struct{}, len(opts.Headers))
+		for _, h := range opts.Headers {
+			imp.wanted[strings.ToLower(strings.TrimSpace(h))] = struct{}{}
+		}
+	}
+	return imp
+}
+
+// add records one message. extraLabels are applied in addition to any label headers. A message whose
+// header block net/mail rejects is counted and logged rather than failing the whole import; where names
+// the message in the log, e.g. its mbox sequence number or Maildir path.
+func (imp *importer) add(
+	id gmail.MessageID,
+	where string,
+	header []byte,
+	fallbackDate time.Time,
+	extraLabels []string,
+) {
+	if _, dup := imp.seen[id]; dup {
+		return
+	}
+	parsed, err := parseHeaderBlock(header, imp.wanted)
+	if err != nil {
+		imp.unparsed++
+		imp.logger.WarnContext(context.Background(), "skip message with unparseable headers",
+			slog.String("message", where), slog.String("error", err.Error()))
+		return
+	}
+	date := parsed.date
+	if date.IsZero() {
+		date = fallbackDate
+	}
+	var ids []gmail.LabelID
+	present := map[gmail.LabelID]struct{}{}
+	for _, name := range append(parsed.labels, extraLabels...) {
+		lid, ok := imp.labels.resolve(name)
+		if !ok {
+			continue
+		}
+		if _, dup := present[lid]; dup {
+			continue
+		}
+		present[lid] = struct{}{}
+		ids = append(ids, lid)
+	}
+	imp.seen[id] = struct{}{}
+	imp.messages = append(imp.messages, gmail.MessageMeta{
+		ID:       id,
+		ThreadID: parsed.threadID,
+		LabelIDs: ids,
+		Headers:  parsed.headers,
+		Date:     date,
+	})
+	if date.After(imp.latest) {
+		imp.latest = date
+	}
+}
+
+// result returns the imported messages; the newest message date stands in for the capture time.
+func (imp *importer) result() Import {
+	labels := make(map[gmail.LabelID]string, len(imp.labels.byID))
+	for id, name := range imp.labels.byID {
+		labels[id] = name
+	}
+	captured := imp.latest
+	if captured.IsZero() {
+		captured = time.Now()
+	}
+	if imp.unparsed > 0 {
+		imp.logger.WarnContext(context.Background(), "skipped messages with unparseable headers",
+			slog.Int("count", imp.unparsed))
+	}
+	return Import{
+		Snapshot: snapshot.Snapshot{CapturedAt: captured, Labels: labels, Messages: imp.messages},
+		Unparsed: imp.unparsed,
+	}
+}
@@ -0,0 +1,133 @@
+package mailbox
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+const maildirInfoSeparator = ":2,"
+
+// LoadMaildir imports a Maildir (and any Maildir++ subfolders) by reading only the header block of each file.
+// Messages without label headers get labels from their location and flags: the root folder maps to INBOX,
+// subfolders such as .Work.Reports become the label Work/Reports, files in new/ or without the S flag are
+// UNREAD, and the F flag marks them STARRED. Files with unparseable headers are skipped and counted.
+func LoadMaildir(root string, opts Options) (Import, error) {
+	root = filepath.Clean(root)
+	folders, err := maildirFolders(root)
+	if err != nil {
+		return Import{}, err
+	}
+	imp := newImporter(opts)
+	for _, folder := range folders {
+		for _, sub := range []string{"new", "cur"} {
+			if loadErr := loadMaildirFiles(imp, filepath.Join(folder.path, sub), folder.label, sub == "new"); loadErr != nil {
+				return Import{}, loadErr
+			}
+		}
+	}
+	return imp.result(), nil
+}
+
+type maildirFolder struct {
+	path  string
+	label string
+}
+
+func maildirFolders(root string) ([]maildirFolder, error) {
+	if _, err := os.Stat(filepath.Join(root, "cur")); err != nil {
+		return nil, fmt.Errorf("%s is not a maildir: %w", root, err)
+	}
+	folders := []maildirFolder{{path: root, label: "INBOX"}}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("read maildir %s: %w", root, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(name, ".") || name == "." || name == ".." {
+			continue
+		}
+		label := strings.ReplaceAll(strings.TrimPrefix(name, "."), ".", "/")
+		folders = append(folders, maildirFolder{path: filepath.Join(root, name), label: label})
+	}
+	return folders, nil
+}
+
+func loadMaildirFiles(imp *importer, dir, folderLabel string, isNew bool) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", dir, err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		header, readErr := readHeaderBlock(path)
+		if readErr != nil {
+			return readErr
+		}
+		info, statErr := entry.Info()
+		if statErr != nil {
+			return fmt.Errorf("stat %s: %w", path, statErr)
+		}
+		base, flags, _ := strings.Cut(entry.Name(), maildirInfoSeparator)
+		labels := maildirLabels(header, folderLabel, flags, isNew)
+		imp.add(gmail.MessageID(base), path, header, info.ModTime(), labels)
+	}
+	return nil
+}
+
+// maildirLabels derives labels from the folder and flags unless the message already carries X-Gmail-Labels.
+func maildirLabels(header []byte, folderLabel, flags string, isNew bool) []string {
+	if bytes.Contains(bytes.ToLower(header), []byte("\nx-gmail-labels:")) ||
+		bytes.HasPrefix(bytes.ToLower(header), []byte("x-gmail-labels:")) {
+		return nil
+	}
+	labels := []string{folderLabel}
+	if isNew || !strings.Contains(flags, "S") {
+		labels = append(labels, "UNREAD")
+	}
+	if strings.Contains(flags, "F") {
+		labels = append(labels, "STARRED")
+	}
+	return labels
+}
+
+func readHeaderBlock(path string) ([]byte, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+	br := bufio.NewReader(f)
+	var header bytes.Buffer
+	for {
+		line, readErr := br.ReadBytes('\n')
+		if len(bytes.TrimRight(line, "\r\n")) == 0 && len(line) > 0 {
+			break
+		}
+		header.Write(line)
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("read %s: %w", path, readErr)
+		}
+	}
+	return header.Bytes(), nil
+}
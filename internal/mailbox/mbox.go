@@ -0,0 +1,89 @@
+package mailbox
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// Options controls how much of each message is retained during import.
+type Options struct {
+	// Headers limits the retained headers (case-insensitive). Empty keeps every header.
+	Headers []string
+	// Logger receives a warning for each message skipped because its headers could not be parsed.
+	// Nil uses slog.Default.
+	Logger *slog.Logger
+}
+
+// ReadMbox streams an mbox (such as a Google Takeout export) and keeps only message headers. Messages
+// with unparseable headers are skipped and counted; only read failures are returned as errors.
+func ReadMbox(r io.Reader, opts Options) (Import, error) {
+	imp := newImporter(opts)
+	br := bufio.NewReader(r)
+	var (
+		header    bytes.Buffer
+		fromLine  string
+		inHeaders bool
+		started   bool
+		seq       int
+	)
+	flush := func() {
+		if !started {
+			return
+		}
+		seq++
+		id := gmailIDFromFromLine(fromLine)
+		if id == "" {
+			id = gmail.MessageID(fmt.Sprintf("mbox-%d", seq))
+		}
+		imp.add(id, fmt.Sprintf("mbox message %d", seq), header.Bytes(), dateFromFromLine(fromLine), nil)
+		header.Reset()
+	}
+	for {
+		line, readErr := br.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case bytes.HasPrefix(line, []byte("From ")):
+				flush()
+				started = true
+				inHeaders = true
+				fromLine = strings.TrimRight(string(line), "\r\n")
+			case inHeaders && len(bytes.TrimRight(line, "\r\n")) == 0:
+				inHeaders = false
+			case inHeaders:
+				header.Write(line)
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return Import{}, fmt.Errorf("read mbox: %w", readErr)
+		}
+	}
+	flush()
+	return imp.result(), nil
+}
+
+// LoadMbox imports the mbox file at path.
+func LoadMbox(path string, opts Options) (Import, error) {
+	clean := filepath.Clean(path)
+	f, err := os.Open(clean)
+	if err != nil {
+		return Import{}, fmt.Errorf("open mbox %s: %w", clean, err)
+	}
+	defer func() { _ = f.Close() }()
+	imported, err := ReadMbox(f, opts)
+	if err != nil {
+		return Import{}, fmt.Errorf("import %s: %w", clean, err)
+	}
+	return imported, nil
+}
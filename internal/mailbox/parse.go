@@ -0,0 +1,180 @@
+package mailbox
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// systemLabels maps the names Takeout writes into X-Gmail-Labels onto Gmail's system label IDs.
+func systemLabels() map[string]gmail.LabelID {
+	return map[string]gmail.LabelID{
+		"inbox":               "INBOX",
+		"unread":              "UNREAD",
+		"starred":             "STARRED",
+		"important":           "IMPORTANT",
+		"sent":                "SENT",
+		"spam":                "SPAM",
+		"trash":               "TRASH",
+		"draft":               "DRAFT",
+		"drafts":              "DRAFT",
+		"chat":                "CHAT",
+		"category personal":   "CATEGORY_PERSONAL",
+		"category social":     "CATEGORY_SOCIAL",
+		"category promotions": "CATEGORY_PROMOTIONS",
+		"category updates":    "CATEGORY_UPDATES",
+		"category forums":     "CATEGORY_FORUMS",
+		"category_personal":   "CATEGORY_PERSONAL",
+		"category_social":     "CATEGORY_SOCIAL",
+		"category_promotions": "CATEGORY_PROMOTIONS",
+		"category_updates":    "CATEGORY_UPDATES",
+		"category_forums":     "CATEGORY_FORUMS",
+		"opened":              "",
+		"archived":            "",
+		"all mail":            "",
+	}
+}
+
+// labelSet accumulates label names and assigns stable synthetic IDs to user labels.
+type labelSet struct {
+	system map[string]gmail.LabelID
+	byID   map[gmail.LabelID]string
+}
+
+func newLabelSet() *labelSet {
+	ls := &labelSet{system: systemLabels(), byID: map[gmail.LabelID]string{}}
+	for _, id := range ls.system {
+		if id != "" {
+			ls.byID[id] = string(id)
+		}
+	}
+	return ls
+}
+
+// resolve returns the label ID for name, registering user labels on first sight.
+func (ls *labelSet) resolve(name string) (gmail.LabelID, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", false
+	}
+	// IMAP-style keywords such as \Important name the same system labels.
+	if id, ok := ls.system[strings.ToLower(strings.TrimLeft(name, "\\"))]; ok {
+		return id, id != ""
+	}
+	id := gmail.LabelID("Label_" + name)
+	ls.byID[id] = name
+	return id, true
+}
+
+// parsedMessage is the header block of a single message.
+type parsedMessage struct {
+	headers  map[string]string
+	threadID string
+	date     time.Time
+	labels   []string
+}
+
+// parseHeaderBlock decodes an RFC 5322 header block, keeping only wanted headers when the list is non-empty.
+func parseHeaderBlock(block []byte, wanted map[string]struct{}) (parsedMessage, error) {
+	if !bytes.HasSuffix(block, []byte("\n\n")) && !bytes.HasSuffix(block, []byte("\r\n\r\n")) {
+		block = append(append([]byte(nil), block...), '\n', '\n')
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(block))
+	if err != nil {
+		return parsedMessage{}, fmt.Errorf("parse headers: %w", err)
+	}
+	decoder := mime.WordDecoder{}
+	out := parsedMessage{headers: make(map[string]string, len(msg.Header))}
+	for key, values := range msg.Header {
+		if len(values) == 0 {
+			continue
+		}
+		if len(wanted) > 0 {
+			if _, keep := wanted[strings.ToLower(key)]; !keep {
+				continue
+			}
+		}
+		value := values[0]
+		if decoded, decodeErr := decoder.DecodeHeader(value); decodeErr == nil {
+			value = decoded
+		}
+		out.headers[key] = value
+	}
+	if raw := msg.Header.Get("X-Gmail-Labels"); raw != "" {
+		out.labels = splitLabels(raw)
+	}
+	if raw := msg.Header.Get("X-Keywords"); raw != "" {
+		out.labels = append(out.labels, splitLabels(raw)...)
+	}
+	// Takeout records the decimal X-GM-THRID; the Gmail API uses its hexadecimal form.
+	if n, parseErr := strconv.ParseUint(strings.TrimSpace(msg.Header.Get("X-GM-THRID")), 10, 64); parseErr == nil {
+		out.threadID = strconv.FormatUint(n, 16)
+	}
+	if when, dateErr := msg.Header.Date(); dateErr == nil {
+		out.date = when
+	}
+	return out, nil
+}
+
+// splitLabels splits a comma separated label header, honoring double-quoted names that contain commas.
+func splitLabels(raw string) []string {
+	var (
+		out     []string
+		current strings.Builder
+		quoted  bool
+	)
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			out = append(out, strings.TrimSpace(current.String()))
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	out = append(out, strings.TrimSpace(current.String()))
+	filtered := out[:0]
+	for _, name := range out {
+		if name != "" {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered
+}
+
+// gmailIDFromFromLine extracts the Gmail message ID Takeout encodes in the mbox "From " separator.
+// Takeout writes the decimal X-GM-MSGID; the Gmail API uses its hexadecimal form.
+func gmailIDFromFromLine(line string) gmail.MessageID {
+	fields := strings.Fields(strings.TrimPrefix(line, "From "))
+	if len(fields) == 0 {
+		return ""
+	}
+	local, _, _ := strings.Cut(fields[0], "@")
+	n, err := strconv.ParseUint(local, 10, 64)
+	if err != nil {
+		return ""
+	}
+	return gmail.MessageID(strconv.FormatUint(n, 16))
+}
+
+// dateFromFromLine parses the asctime timestamp of an mbox "From " separator.
+func dateFromFromLine(line string) time.Time {
+	fields := strings.Fields(strings.TrimPrefix(line, "From "))
+	const asctimeFields = 5
+	if len(fields) < 1+asctimeFields {
+		return time.Time{}
+	}
+	stamp := strings.Join(fields[len(fields)-asctimeFields:], " ")
+	if when, err := time.Parse(time.ANSIC, stamp); err == nil {
+		return when
+	}
+	return time.Time{}
+}
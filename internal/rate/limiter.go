@@ -3,6 +3,9 @@ package rate
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"math"
+	"sync"
 	"time"
 )
 
@@ -11,53 +14,135 @@ type Limiter interface {
 	Wait(ctx context.Context) error
 }
 
-// TokenBucket implements a simple fixed-rate token bucket limiter.
+// WaitStats summarizes how long callers were held back by a limiter.
+type WaitStats struct {
+	Acquired  int64
+	Delayed   int64
+	Canceled  int64
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// LogValue renders the statistics as a structured slog group.
+func (s WaitStats) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("acquired", s.Acquired),
+		slog.Int64("delayed", s.Delayed),
+		slog.Int64("canceled", s.Canceled),
+		slog.Duration("total", s.TotalWait),
+		slog.Duration("max", s.MaxWait),
+	)
+}
+
+// TokenBucket implements a token bucket limiter with a fractional refill rate and explicit burst size.
 type TokenBucket struct {
-	ticker   *time.Ticker
-	tokens   chan struct{}
-	stopDone chan struct{}
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	stats  WaitStats
+	now    func() time.Time
+	after  func(d time.Duration) <-chan time.Time
 }
 
-// NewTokenBucket returns a limiter that releases rps tokens per second.
-func NewTokenBucket(rps int) *TokenBucket {
-	if rps <= 0 {
-		rps = 1
+// NewTokenBucket returns a limiter that refills rps tokens per second and holds at most burst tokens.
+// A burst of zero or less derives the bucket size from the rate (at least one token).
+// The bucket starts full so an initial burst proceeds without delay.
+func NewTokenBucket(rps float64, burst int) (*TokenBucket, error) {
+	if math.IsNaN(rps) || math.IsInf(rps, 0) || rps <= 0 {
+		return nil, fmt.Errorf("rate must be a positive finite number, got %v", rps)
 	}
-	tb := &TokenBucket{
-		ticker:   time.NewTicker(time.Second / time.Duration(rps)),
-		tokens:   make(chan struct{}, rps),
-		stopDone: make(chan struct{}),
+	size := float64(burst)
+	if burst <= 0 {
+		size = math.Max(1, math.Floor(rps))
 	}
-	// allow the first call to proceed immediately
-	tb.tokens <- struct{}{}
-	go tb.run()
-	return tb
-}
-
-func (t *TokenBucket) run() {
-	defer close(t.stopDone)
-	for range t.ticker.C {
-		select {
-		case t.tokens <- struct{}{}:
-		default:
-		}
+	tb := &TokenBucket{
+		rate:   rps,
+		burst:  size,
+		tokens: size,
+		now:    time.Now,
+		after:  time.After,
 	}
+	tb.last = tb.now()
+	return tb, nil
 }
 
 // Wait blocks until a token is available or the context is canceled.
 func (t *TokenBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return t.cancel(0, err)
+	}
+	delay := t.reserve()
+	if delay <= 0 {
+		return nil
+	}
 	select {
 	case <-ctx.Done():
-		return fmt.Errorf("rate wait canceled: %w", ctx.Err())
-	case <-t.tokens:
+		return t.cancel(delay, ctx.Err())
+	case <-t.after(delay):
 		return nil
 	}
 }
 
-// Stop releases resources held by the limiter.
-func (t *TokenBucket) Stop() {
-	t.ticker.Stop()
-	<-t.stopDone
+// Stats returns a snapshot of the wait statistics gathered so far.
+func (t *TokenBucket) Stats() WaitStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// Rate reports the configured refill rate in tokens per second.
+func (t *TokenBucket) Rate() float64 {
+	return t.rate
+}
+
+// Burst reports the maximum number of tokens the bucket holds.
+func (t *TokenBucket) Burst() int {
+	return int(t.burst)
+}
+
+// reserve takes a token, possibly driving the balance negative, and returns how long the caller must wait.
+func (t *TokenBucket) reserve() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refill()
+	t.tokens--
+	t.stats.Acquired++
+	if t.tokens >= 0 {
+		return 0
+	}
+	delay := time.Duration(-t.tokens / t.rate * float64(time.Second))
+	t.stats.Delayed++
+	t.stats.TotalWait += delay
+	if delay > t.stats.MaxWait {
+		t.stats.MaxWait = delay
+	}
+	return delay
+}
+
+// cancel returns an unused reservation to the bucket and records the cancellation.
+func (t *TokenBucket) cancel(delay time.Duration, cause error) error {
+	t.mu.Lock()
+	t.stats.Canceled++
+	if delay > 0 {
+		t.tokens = math.Min(t.burst, t.tokens+1)
+		t.stats.Acquired--
+		t.stats.Delayed--
+		t.stats.TotalWait -= delay
+	}
+	t.mu.Unlock()
+	return fmt.Errorf("rate wait canceled: %w", cause)
+}
+
+func (t *TokenBucket) refill() {
+	now := t.now()
+	elapsed := now.Sub(t.last)
+	t.last = now
+	if elapsed <= 0 {
+		return
+	}
+	t.tokens = math.Min(t.burst, t.tokens+elapsed.Seconds()*t.rate)
 }
 
 var _ Limiter = (*TokenBucket)(nil)
@@ -0,0 +1,125 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now    time.Time
+	waited []time.Duration
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.waited = append(f.waited, d)
+	f.now = f.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- f.now
+	return ch
+}
+
+func newTestBucket(t *testing.T, rps float64, burst int) (*TokenBucket, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	tb, err := NewTokenBucket(rps, burst)
+	if err != nil {
+		t.Fatalf("new token bucket: %v", err)
+	}
+	tb.now = clock.Now
+	tb.after = clock.After
+	tb.last = clock.now
+	return tb, clock
+}
+
+func TestNewTokenBucketRejectsInvalidRate(t *testing.T) {
+	for _, rps := range []float64{0, -1} {
+		if _, err := NewTokenBucket(rps, 1); err == nil {
+			t.Fatalf("expected error for rps %v", rps)
+		}
+	}
+}
+
+func TestTokenBucketBurstThenSteadyRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		rps       float64
+		burst     int
+		calls     int
+		wantWaits []time.Duration
+	}{
+		{
+			name:      "fractional",
+			rps:       0.5,
+			burst:     1,
+			calls:     3,
+			wantWaits: []time.Duration{2 * time.Second, 2 * time.Second},
+		},
+		{
+			name:      "burst",
+			rps:       2,
+			burst:     3,
+			calls:     5,
+			wantWaits: []time.Duration{500 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{
+			name:      "derived-burst",
+			rps:       4,
+			burst:     0,
+			calls:     5,
+			wantWaits: []time.Duration{250 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb, clock := newTestBucket(t, tt.rps, tt.burst)
+			for range tt.calls {
+				if err := tb.Wait(context.Background()); err != nil {
+					t.Fatalf("wait: %v", err)
+				}
+			}
+			if len(clock.waited) != len(tt.wantWaits) {
+				t.Fatalf("waits mismatch: got %v want %v", clock.waited, tt.wantWaits)
+			}
+			for i, want := range tt.wantWaits {
+				if clock.waited[i] != want {
+					t.Fatalf("wait %d: got %v want %v", i, clock.waited[i], want)
+				}
+			}
+			stats := tb.Stats()
+			if stats.Acquired != int64(tt.calls) || stats.Delayed != int64(len(tt.wantWaits)) {
+				t.Fatalf("unexpected stats: %+v", stats)
+			}
+		})
+	}
+}
+
+func TestTokenBucketCanceledWaitReturnsToken(t *testing.T) {
+	tb, _ := newTestBucket(t, 1, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tb.after = func(time.Duration) <-chan time.Time {
+		cancel()
+		return make(chan time.Time)
+	}
+	if err := tb.Wait(ctx); err != nil {
+		t.Fatalf("first wait: %v", err)
+	}
+	err := tb.Wait(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled error, got %v", err)
+	}
+	stats := tb.Stats()
+	if stats.Canceled != 1 || stats.Acquired != 1 || stats.TotalWait != 0 {
+		t.Fatalf("unexpected stats after cancel: %+v", stats)
+	}
+	if tb.tokens != 0 {
+		t.Fatalf("expected reservation to be returned, tokens=%v", tb.tokens)
+	}
+}
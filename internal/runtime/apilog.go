@@ -0,0 +1,187 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// LoggingClient logs every call that reaches the wrapped mailbox client at debug level with its method,
+// latency and outcome class. Wrap it around the live client, beneath any cache, so only real calls show.
+type LoggingClient struct {
+	inner  gmail.Client
+	logger *slog.Logger
+}
+
+// NewLoggingClient wraps inner so each call is logged to logger.
+func NewLoggingClient(inner gmail.Client, logger *slog.Logger) *LoggingClient {
+	return &LoggingClient{inner: inner, logger: logger}
+}
+
+// List delegates to the inner client.
+func (l *LoggingClient) List(
+	ctx context.Context,
+	q gmail.Query,
+	pageToken string,
+	pageSize int,
+) (gmail.ListPage, error) {
+	start := time.Now()
+	page, err := l.inner.List(ctx, q, pageToken, pageSize)
+	l.log(ctx, "messages.list", start, err, slog.Int("ids", len(page.IDs)))
+	if err != nil {
+		return gmail.ListPage{}, fmt.Errorf("logged list: %w", err)
+	}
+	return page, nil
+}
+
+// GetMetadata delegates to the inner client.
+func (l *LoggingClient) GetMetadata(
+	ctx context.Context,
+	id gmail.MessageID,
+	headers []string,
+) (gmail.MessageMeta, error) {
+	start := time.Now()
+	meta, err := l.inner.GetMetadata(ctx, id, headers)
+	l.log(ctx, "messages.get", start, err, slog.String("id", string(id)))
+	if err != nil {
+		return gmail.MessageMeta{}, fmt.Errorf("logged get metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// GetLabels delegates to the inner client, falling back to GetMetadata when it has no cheaper call.
+func (l *LoggingClient) GetLabels(ctx context.Context, id gmail.MessageID) ([]gmail.LabelID, error) {
+	reader, ok := l.inner.(gmail.LabelReader)
+	if !ok {
+		meta, err := l.GetMetadata(ctx, id, nil)
+		if err != nil {
+			return nil, err
+		}
+		return meta.LabelIDs, nil
+	}
+	start := time.Now()
+	labels, err := reader.GetLabels(ctx, id)
+	l.log(ctx, "messages.get", start, err, slog.String("id", string(id)), slog.String("format", "minimal"))
+	if err != nil {
+		return nil, fmt.Errorf("logged get labels: %w", err)
+	}
+	return labels, nil
+}
+
+// PeekMetadata delegates to the inner client when it can answer locally; nothing is logged.
+func (l *LoggingClient) PeekMetadata(id gmail.MessageID, headers []string) (gmail.MessageMeta, bool) {
+	if peeker, ok := l.inner.(gmail.MetadataPeeker); ok {
+		return peeker.PeekMetadata(id, headers)
+	}
+	return gmail.MessageMeta{}, false
+}
+
+// BatchModify delegates to the inner client.
+func (l *LoggingClient) BatchModify(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	start := time.Now()
+	err := l.inner.BatchModify(ctx, ids, ops)
+	l.log(ctx, "messages.batchModify", start, err, slog.Int("messages", len(ids)))
+	if err != nil {
+		return fmt.Errorf("logged batch modify: %w", err)
+	}
+	return nil
+}
+
+// ListLabels delegates to the inner client.
+func (l *LoggingClient) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	start := time.Now()
+	byName, byID, err := l.inner.ListLabels(ctx)
+	l.log(ctx, "labels.list", start, err, slog.Int("labels", len(byName)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("logged list labels: %w", err)
+	}
+	return byName, byID, nil
+}
+
+// EnsureLabel delegates to the inner client.
+func (l *LoggingClient) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
+	start := time.Now()
+	id, err := l.inner.EnsureLabel(ctx, name)
+	l.log(ctx, "labels.ensure", start, err, slog.String("name", name))
+	if err != nil {
+		return "", fmt.Errorf("logged ensure label: %w", err)
+	}
+	return id, nil
+}
+
+// HistoryID delegates to the inner client, failing with gmail.ErrHistoryUnsupported when it keeps no history.
+func (l *LoggingClient) HistoryID(ctx context.Context) (uint64, error) {
+	history, ok := l.inner.(gmail.HistoryReader)
+	if !ok {
+		return 0, gmail.ErrHistoryUnsupported
+	}
+	start := time.Now()
+	id, err := history.HistoryID(ctx)
+	l.log(ctx, "profile.get", start, err)
+	if err != nil {
+		return 0, fmt.Errorf("logged history id: %w", err)
+	}
+	return id, nil
+}
+
+// LabelChanges delegates to the inner client, failing with gmail.ErrHistoryUnsupported when it keeps no
+// history.
+func (l *LoggingClient) LabelChanges(ctx context.Context, since uint64) ([]gmail.LabelChange, uint64, error) {
+	history, ok := l.inner.(gmail.HistoryReader)
+	if !ok {
+		return nil, 0, gmail.ErrHistoryUnsupported
+	}
+	start := time.Now()
+	changes, latest, err := history.LabelChanges(ctx, since)
+	l.log(ctx, "history.list", start, err, slog.Int("changes", len(changes)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("logged label changes: %w", err)
+	}
+	return changes, latest, nil
+}
+
+// CreateDraft delegates to the inner client, failing with gmail.ErrDraftsUnsupported when it cannot
+// save drafts.
+func (l *LoggingClient) CreateDraft(ctx context.Context, raw []byte) (string, error) {
+	creator, ok := l.inner.(gmail.DraftCreator)
+	if !ok {
+		return "", gmail.ErrDraftsUnsupported
+	}
+	start := time.Now()
+	id, err := creator.CreateDraft(ctx, raw)
+	l.log(ctx, "drafts.create", start, err, slog.Int("bytes", len(raw)))
+	if err != nil {
+		return "", fmt.Errorf("logged create draft: %w", err)
+	}
+	return id, nil
+}
+
+func (l *LoggingClient) log(ctx context.Context, method string, start time.Time, err error, attrs ...slog.Attr) {
+	status := statusOK
+	if err != nil {
+		status = ClassifyError(err).String()
+	}
+	attrs = append(
+		[]slog.Attr{
+			slog.String("method", method),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("status", status),
+		},
+		attrs...,
+	)
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.logger.LogAttrs(ctx, slog.LevelDebug, "mailbox call", attrs...)
+}
+
+var (
+	_ gmail.Client         = (*LoggingClient)(nil)
+	_ gmail.LabelReader    = (*LoggingClient)(nil)
+	_ gmail.HistoryReader  = (*LoggingClient)(nil)
+	_ gmail.MetadataPeeker = (*LoggingClient)(nil)
+	_ gmail.DraftCreator   = (*LoggingClient)(nil)
+)
@@ -2,14 +2,22 @@ package runtime
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
-	"os"
+	"net/http"
+	"net/url"
+	"strings"
 
-	"github.com/mbrt/gmailctl/cmd/gmailctl/localcred"
+	"golang.org/x/oauth2"
+	gmailapi "google.golang.org/api/gmail/v1"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
 )
 
-// Scope controls which Gmail OAuth scope is requested from gmailctl's local credentials store.
+// Scope controls which Gmail OAuth scope chronosweep requests and requires of the stored token.
 type Scope int
 
 const (
@@ -17,24 +25,232 @@ const (
 	ScopeReadonly Scope = iota
 	// ScopeModify grants access to modify labels and mark messages read.
 	ScopeModify
+	// ScopeMetadata grants access to labels and message headers only. Search queries are refused under
+	// it, so callers must list by label ID instead.
+	ScopeMetadata
+)
+
+const (
+	// tokenInfoURL reports the scopes granted to an access token.
+	tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+	// maxTokenInfoBytes bounds the tokeninfo response body.
+	maxTokenInfoBytes = 64 << 10
 )
 
-// NewGmailClient constructs a gmail.Client backed by the Google API Go client using gmailctl's credential store.
-func NewGmailClient(ctx context.Context, cfgDir string, scope Scope) (*ClientAdapter, error) {
+// ErrScopeNotGranted is returned when the stored token does not carry the scope a command requires.
+var ErrScopeNotGranted = errors.New("stored token lacks the required Gmail scope")
+
+// ErrScopeTooBroad is returned when a read-only command is handed a token that could also modify mail.
+var ErrScopeTooBroad = errors.New("stored token grants more than the read-only scope the command needs")
+
+// ClientOptions tunes the client NewGmailClient builds.
+type ClientOptions struct {
+	// Labels styles the labels a modify client creates.
+	Labels LabelStyle
+	// AllowBroaderScope lets a read-only or metadata client run on a token that also grants gmail.modify or
+	// mail.google.com. The broader grant is logged as a warning.
+	AllowBroaderScope bool
+	// Logger receives the broader-scope warning; nil uses slog.Default.
+	Logger *slog.Logger
+}
+
+// URL returns the OAuth scope URL Google expects.
+func (s Scope) URL() string {
+	switch s {
+	case ScopeModify:
+		return gmailapi.GmailModifyScope
+	case ScopeMetadata:
+		return gmailapi.GmailMetadataScope
+	default:
+		return gmailapi.GmailReadonlyScope
+	}
+}
+
+// String returns the short scope name, e.g. "gmail.readonly".
+func (s Scope) String() string {
+	return strings.TrimPrefix(s.URL(), "https://www.googleapis.com/auth/")
+}
+
+// SatisfiedBy reports whether the granted scopes include s or a scope that implies it.
+func (s Scope) SatisfiedBy(granted []string) bool {
+	accepted := map[string]bool{gmailapi.MailGoogleComScope: true, gmailapi.GmailModifyScope: true}
+	if s == ScopeReadonly || s == ScopeMetadata {
+		accepted[gmailapi.GmailReadonlyScope] = true
+	}
+	if s == ScopeMetadata {
+		accepted[gmailapi.GmailMetadataScope] = true
+	}
+	for _, g := range granted {
+		if accepted[g] {
+			return true
+		}
+	}
+	return false
+}
+
+// BroaderGranted lists the granted scopes that satisfy the read-only or metadata scope s only because they
+// also allow modifying mail. It is empty for ScopeModify and for tokens carrying exactly what s needs.
+func (s Scope) BroaderGranted(granted []string) []string {
+	if s == ScopeModify {
+		return nil
+	}
+	var out []string
+	for _, g := range granted {
+		if g == gmailapi.MailGoogleComScope || g == gmailapi.GmailModifyScope {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// ParseScope converts CLI input ("readonly", "modify", "metadata", or the gmail.* form) into a Scope.
+func ParseScope(input string) (Scope, error) {
+	name := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(input)), "gmail.")
+	switch name {
+	case "readonly":
+		return ScopeReadonly, nil
+	case "modify":
+		return ScopeModify, nil
+	case "metadata":
+		return ScopeMetadata, nil
+	default:
+		return ScopeReadonly, fmt.Errorf("unknown scope %q (want readonly, modify or metadata)", input)
+	}
+}
+
+// NewGmailClient constructs a gmail.Client backed by the Google API Go client, taking tokens from
+// provider. The token is requested for scope and checked against the scopes Google reports for it;
+// read-only and metadata clients refuse tokens that could also modify mail unless opts.AllowBroaderScope is
+// set, and are additionally wrapped so that mutating calls fail before reaching the API.
+// Labels a modify client creates are given opts.Labels.
+func NewGmailClient(
+	ctx context.Context,
+	provider TokenProvider,
+	scope Scope,
+	opts ClientOptions,
+) (gmail.Client, error) {
 	switch scope {
-	case ScopeReadonly, ScopeModify:
+	case ScopeReadonly, ScopeModify, ScopeMetadata:
 	default:
 		return nil, fmt.Errorf("unsupported scope %d", scope)
 	}
-	provider := localcred.Provider{}
-	svc, err := provider.Service(ctx, cfgDir)
+	ts, err := provider.TokenSource(ctx, scope)
 	if err != nil {
-		return nil, fmt.Errorf("create gmail service: %w", err)
+		return nil, fmt.Errorf("load %s token for %s: %w", scope, provider.Account(), err)
 	}
-	return NewGoogleAPIClient(svc), nil
+	broader, err := verifyScope(ctx, http.DefaultClient, tokenInfoURL, ts, scope, opts.AllowBroaderScope)
+	if err != nil {
+		return nil, err
+	}
+	if len(broader) > 0 {
+		logger := opts.Logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.WarnContext(
+			ctx,
+			"token grants a broader scope than requested; mutating calls are still blocked",
+			slog.String("requested", scope.String()),
+			slog.String("granted", strings.Join(broader, " ")),
+		)
+	}
+	client, err := NewTokenClient(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+	if scope != ScopeModify {
+		return gmail.NewReadOnly(client), nil
+	}
+	client.labels = NewLabelManager(client.svc, opts.Labels)
+	return client, nil
+}
+
+// GrantedScopes reports the scopes Google's tokeninfo endpoint lists for the access token ts returns.
+func GrantedScopes(ctx context.Context, ts oauth2.TokenSource) ([]string, error) {
+	return grantedScopes(ctx, http.DefaultClient, tokenInfoURL, ts)
 }
 
-// DefaultLogger returns a slog.Logger configured for structured CLI output.
-func DefaultLogger() *slog.Logger {
-	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+// verifyScope refuses tokens whose granted scopes do not cover scope, and tokens that cover a read-only
+// scope only through a write-capable one unless allowBroader is set. It returns the broader scopes it accepted.
+func verifyScope(
+	ctx context.Context,
+	client *http.Client,
+	endpoint string,
+	ts oauth2.TokenSource,
+	scope Scope,
+	allowBroader bool,
+) ([]string, error) {
+	granted, err := grantedScopes(ctx, client, endpoint, ts)
+	if err != nil {
+		return nil, err
+	}
+	if !scope.SatisfiedBy(granted) {
+		return nil, fmt.Errorf(
+			"%w: need %s, token grants [%s]; re-authorize with the %s scope",
+			ErrScopeNotGranted,
+			scope,
+			strings.Join(granted, " "),
+			scope.URL(),
+		)
+	}
+	broader := scope.BroaderGranted(granted)
+	if len(broader) == 0 || allowBroader {
+		return broader, nil
+	}
+	return nil, fmt.Errorf(
+		"%w: need %s, token grants [%s]; authorize a %s token or pass -allow-broader-scope",
+		ErrScopeTooBroad,
+		scope,
+		strings.Join(granted, " "),
+		scope,
+	)
+}
+
+// grantedScopes asks Google's tokeninfo endpoint which scopes the current access token carries.
+func grantedScopes(
+	ctx context.Context,
+	client *http.Client,
+	endpoint string,
+	ts oauth2.TokenSource,
+) ([]string, error) {
+	tok, err := ts.Token()
+	if err != nil {
+		return nil, fmt.Errorf("refresh gmail token: %w", err)
+	}
+	form := url.Values{"access_token": {tok.AccessToken}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("build tokeninfo request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query tokeninfo: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenInfoBytes))
+	if err != nil {
+		return nil, fmt.Errorf("read tokeninfo: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tokeninfo rejected the token: %s", resp.Status)
+	}
+	var info struct {
+		Scope string `json:"scope"`
+	}
+	if unmarshalErr := json.Unmarshal(body, &info); unmarshalErr != nil {
+		return nil, fmt.Errorf("parse tokeninfo: %w", unmarshalErr)
+	}
+	return strings.Fields(info.Scope), nil
+}
+
+// candidates lists the scopes whose tokens can serve s: s itself first, then broader ones, narrowest first.
+func (s Scope) candidates() []Scope {
+	out := []Scope{s}
+	for _, broader := range []Scope{ScopeMetadata, ScopeReadonly, ScopeModify} {
+		if broader != s && s.SatisfiedBy([]string{broader.URL()}) {
+			out = append(out, broader)
+		}
+	}
+	return out
 }
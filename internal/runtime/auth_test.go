@@ -0,0 +1,88 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestVerifyScope(t *testing.T) {
+	tests := []struct {
+		name        string
+		granted     string
+		scope       Scope
+		allow       bool
+		wantBroader bool
+		wantErr     error
+	}{
+		{name: "exact readonly", granted: "https://www.googleapis.com/auth/gmail.readonly", scope: ScopeReadonly},
+		{
+			name:    "modify refused for readonly",
+			granted: "https://www.googleapis.com/auth/gmail.modify",
+			scope:   ScopeReadonly,
+			wantErr: ErrScopeTooBroad,
+		},
+		{
+			name:        "modify allowed for readonly",
+			granted:     "https://www.googleapis.com/auth/gmail.modify",
+			scope:       ScopeReadonly,
+			allow:       true,
+			wantBroader: true,
+		},
+		{
+			name:    "full access refused for metadata",
+			granted: "https://www.googleapis.com/auth/gmail.readonly https://mail.google.com/",
+			scope:   ScopeMetadata,
+			wantErr: ErrScopeTooBroad,
+		},
+		{name: "full access", granted: "https://mail.google.com/", scope: ScopeModify},
+		{
+			name:    "readonly cannot modify",
+			granted: "https://www.googleapis.com/auth/gmail.readonly",
+			scope:   ScopeModify,
+			wantErr: ErrScopeNotGranted,
+		},
+		{name: "metadata", granted: "https://www.googleapis.com/auth/gmail.metadata", scope: ScopeMetadata},
+		{name: "readonly implies metadata", granted: "https://www.googleapis.com/auth/gmail.readonly", scope: ScopeMetadata},
+		{
+			name:    "metadata cannot read bodies",
+			granted: "https://www.googleapis.com/auth/gmail.metadata",
+			scope:   ScopeReadonly,
+			wantErr: ErrScopeNotGranted,
+		},
+		{
+			name:    "gmailctl default scopes",
+			granted: "https://www.googleapis.com/auth/gmail.labels https://www.googleapis.com/auth/gmail.settings.basic",
+			scope:   ScopeReadonly,
+			wantErr: ErrScopeNotGranted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil || r.PostForm.Get("access_token") != "access" {
+					http.Error(w, "bad token", http.StatusBadRequest)
+					return
+				}
+				_, _ = w.Write([]byte(`{"scope":"` + tt.granted + `","expires_in":"3599"}`))
+			}))
+			defer srv.Close()
+
+			ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+			broader, err := verifyScope(context.Background(), srv.Client(), srv.URL, ts, tt.scope, tt.allow)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := len(broader) > 0; got != tt.wantBroader {
+				t.Fatalf("broader scopes %v, want any=%v", broader, tt.wantBroader)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,2 +1,2 @@
-// Package runtime wires Gmail API clients and logging helpers for chronosweep.
+// Package runtime wires Gmail API, IMAP and local mailbox clients and logging helpers for chronosweep.
 package runtime
@@ -0,0 +1,176 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+
+	"github.com/joshsymonds/chronosweep/internal/auth"
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// Exit codes shared by the chronosweep commands. They are stable: systemd units list the non-retryable
+// ones in RestartPreventExitStatus and CI keys off them.
+const (
+	ExitOK              = 0
+	ExitFailure         = 1
+	ExitInvalidConfig   = 2
+	ExitPolicyViolation = 3
+	ExitSafetyAbort     = 4
+	ExitAuthExpired     = 5
+	ExitScopeMissing    = 6
+	ExitQuotaExhausted  = 7
+	ExitTransient       = 8
+)
+
+// statusOK is the status line value for a successful run.
+const statusOK = "ok"
+
+// Status is the machine-readable final line a command writes to stderr.
+type Status struct {
+	Command   string `json:"command"`
+	Status    string `json:"status"`
+	ExitCode  int    `json:"exit_code"`
+	Retryable bool   `json:"retryable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ClassifyError returns err's class. Explicitly classified errors win; otherwise known sentinels and
+// Google API, OAuth and network errors are recognized from the chain.
+func ClassifyError(err error) gmail.ErrorClass {
+	if class := gmail.ClassOf(err); class != gmail.ClassUnknown {
+		return class
+	}
+	switch {
+	case errors.Is(err, gmail.ErrReadOnly):
+		return gmail.ClassSafetyAbort
+	case errors.Is(err, ErrScopeNotGranted), errors.Is(err, ErrScopeTooBroad):
+		return gmail.ClassScopeMissing
+	case errors.Is(err, auth.ErrNoToken):
+		return gmail.ClassAuthExpired
+	case errors.Is(err, auth.ErrNoCredentials), errors.Is(err, auth.ErrLocked):
+		return gmail.ClassInvalidConfig
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return classifyRetrieve(retrieveErr)
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return classifyAPI(apiErr)
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return gmail.ClassTransient
+	}
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.As(err, &netErr) || errors.As(err, &urlErr) {
+		return gmail.ClassTransient
+	}
+	return gmail.ClassUnknown
+}
+
+// ExitCode maps an error class to its exit code.
+func ExitCode(class gmail.ErrorClass) int {
+	switch class {
+	case gmail.ClassUnknown:
+		return ExitFailure
+	case gmail.ClassAuthExpired:
+		return ExitAuthExpired
+	case gmail.ClassScopeMissing:
+		return ExitScopeMissing
+	case gmail.ClassQuotaExhausted:
+		return ExitQuotaExhausted
+	case gmail.ClassTransient:
+		return ExitTransient
+	case gmail.ClassInvalidConfig:
+		return ExitInvalidConfig
+	case gmail.ClassSafetyAbort:
+		return ExitSafetyAbort
+	case gmail.ClassPolicyViolation:
+		return ExitPolicyViolation
+	}
+	return ExitFailure
+}
+
+// StatusFor describes how command finished; a nil err is success.
+func StatusFor(command string, err error) Status {
+	if err == nil {
+		return Status{Command: command, Status: statusOK, ExitCode: ExitOK}
+	}
+	class := ClassifyError(err)
+	return Status{
+		Command:   command,
+		Status:    class.String(),
+		ExitCode:  ExitCode(class),
+		Retryable: class.Retryable(),
+		Error:     err.Error(),
+	}
+}
+
+// WriteStatus writes command's final status line to w as a single JSON object and returns the exit code
+// the process should use.
+func WriteStatus(w io.Writer, command string, err error) int {
+	status := StatusFor(command, err)
+	if encodeErr := json.NewEncoder(w).Encode(status); encodeErr != nil {
+		DefaultLogger().Warn("write status line", "error", encodeErr)
+	}
+	return status.ExitCode
+}
+
+// InvalidConfig marks err as a configuration problem the operator has to fix before rerunning.
+func InvalidConfig(err error) error {
+	return gmail.Classify(gmail.ClassInvalidConfig, err)
+}
+
+// InvalidConfigf is InvalidConfig over fmt.Errorf.
+func InvalidConfigf(format string, args ...any) error {
+	return InvalidConfig(fmt.Errorf(format, args...))
+}
+
+func classifyRetrieve(err *oauth2.RetrieveError) gmail.ErrorClass {
+	switch err.ErrorCode {
+	case "invalid_grant":
+		return gmail.ClassAuthExpired
+	case "invalid_client", "unauthorized_client":
+		return gmail.ClassInvalidConfig
+	}
+	if err.Response != nil && err.Response.StatusCode >= http.StatusInternalServerError {
+		return gmail.ClassTransient
+	}
+	return gmail.ClassAuthExpired
+}
+
+func classifyAPI(err *googleapi.Error) gmail.ErrorClass {
+	for _, item := range err.Errors {
+		switch item.Reason {
+		case "rateLimitExceeded", "userRateLimitExceeded", "quotaExceeded", "dailyLimitExceeded":
+			return gmail.ClassQuotaExhausted
+		case "insufficientPermissions":
+			return gmail.ClassScopeMissing
+		case "authError":
+			return gmail.ClassAuthExpired
+		case "accessNotConfigured":
+			return gmail.ClassInvalidConfig
+		}
+	}
+	switch {
+	case err.Code == http.StatusUnauthorized:
+		return gmail.ClassAuthExpired
+	case err.Code == http.StatusTooManyRequests:
+		return gmail.ClassQuotaExhausted
+	case err.Code == http.StatusForbidden:
+		return gmail.ClassScopeMissing
+	case err.Code >= http.StatusInternalServerError:
+		return gmail.ClassTransient
+	}
+	return gmail.ClassUnknown
+}
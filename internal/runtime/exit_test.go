@@ -0,0 +1,124 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+
+	"github.com/joshsymonds/chronosweep/internal/auth"
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want gmail.ErrorClass
+		code int
+	}{
+		{name: "plain", err: errors.New("boom"), want: gmail.ClassUnknown, code: ExitFailure},
+		{name: "explicit", err: InvalidConfigf("bad flag"), want: gmail.ClassInvalidConfig, code: ExitInvalidConfig},
+		{
+			name: "explicit wrapped",
+			err:  fmt.Errorf("run lint: %w", gmail.Classify(gmail.ClassPolicyViolation, errors.New("dead rules"))),
+			want: gmail.ClassPolicyViolation,
+			code: ExitPolicyViolation,
+		},
+		{
+			name: "read-only mutation",
+			err:  fmt.Errorf("batch modify: %w", gmail.ErrReadOnly),
+			want: gmail.ClassSafetyAbort,
+			code: ExitSafetyAbort,
+		},
+		{name: "scope", err: ErrScopeNotGranted, want: gmail.ClassScopeMissing, code: ExitScopeMissing},
+		{name: "scope too broad", err: ErrScopeTooBroad, want: gmail.ClassScopeMissing, code: ExitScopeMissing},
+		{name: "no token", err: auth.ErrNoToken, want: gmail.ClassAuthExpired, code: ExitAuthExpired},
+		{
+			name: "revoked refresh token",
+			err: &url.Error{
+				Op:  "Post",
+				URL: "https://oauth2.example",
+				Err: &oauth2.RetrieveError{ErrorCode: "invalid_grant"},
+			},
+			want: gmail.ClassAuthExpired,
+			code: ExitAuthExpired,
+		},
+		{
+			name: "rate limited",
+			err: fmt.Errorf("list messages: %w", &googleapi.Error{
+				Code:   http.StatusForbidden,
+				Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}},
+			}),
+			want: gmail.ClassQuotaExhausted,
+			code: ExitQuotaExhausted,
+		},
+		{
+			name: "insufficient scope",
+			err:  &googleapi.Error{Code: http.StatusForbidden},
+			want: gmail.ClassScopeMissing,
+			code: ExitScopeMissing,
+		},
+		{
+			name: "server error",
+			err:  &googleapi.Error{Code: http.StatusServiceUnavailable},
+			want: gmail.ClassTransient,
+			code: ExitTransient,
+		},
+		{
+			name: "not found",
+			err:  &googleapi.Error{Code: http.StatusNotFound},
+			want: gmail.ClassUnknown,
+			code: ExitFailure,
+		},
+		{
+			name: "network",
+			err:  &url.Error{Op: "Get", URL: "https://gmail.example", Err: errors.New("connection reset")},
+			want: gmail.ClassTransient,
+			code: ExitTransient,
+		},
+		{name: "deadline", err: context.DeadlineExceeded, want: gmail.ClassTransient, code: ExitTransient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyError(tt.err)
+			if got != tt.want {
+				t.Fatalf("class: got %v want %v", got, tt.want)
+			}
+			if code := ExitCode(got); code != tt.code {
+				t.Fatalf("exit code: got %d want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestWriteStatus(t *testing.T) {
+	var buf bytes.Buffer
+	code := WriteStatus(&buf, "chronosweep-sweep", &googleapi.Error{Code: http.StatusTooManyRequests})
+	if code != ExitQuotaExhausted {
+		t.Fatalf("exit code: got %d", code)
+	}
+	var status Status
+	if err := json.Unmarshal(buf.Bytes(), &status); err != nil {
+		t.Fatalf("decode status line %q: %v", buf.String(), err)
+	}
+	if status.Status != "quota_exhausted" || !status.Retryable || status.Command != "chronosweep-sweep" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	buf.Reset()
+	if code := WriteStatus(&buf, "chronosweep-lint", nil); code != ExitOK {
+		t.Fatalf("success exit code: got %d", code)
+	}
+	want := `{"command":"chronosweep-lint","status":"ok","exit_code":0,"retryable":false}` + "\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
@@ -2,22 +2,64 @@ package runtime
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
+	"golang.org/x/oauth2"
 	gmailapi "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
 
 	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/telemetry"
 )
 
+// historyPageSize is the most history records Gmail returns per history.list page.
+const historyPageSize = 500
+
 // ClientAdapter implements gmail.Client using the Google API client.
 type ClientAdapter struct {
-	svc *gmailapi.Service
+	svc    *gmailapi.Service
+	labels *LabelManager
+}
+
+// Profile identifies the mailbox behind a token.
+type Profile struct {
+	Email         string
+	MessagesTotal int64
+}
+
+// NewGoogleAPIClient wraps a gmail Service with the chronosweep gmail.Client interface; labels it creates
+// are given style.
+func NewGoogleAPIClient(svc *gmailapi.Service, style LabelStyle) *ClientAdapter {
+	return &ClientAdapter{svc: svc, labels: NewLabelManager(svc, style)}
+}
+
+// NewTokenClient builds a ClientAdapter on ts without checking its scopes; NewGmailClient is the checked
+// entry point for commands. Requests go through a telemetry.Transport so traced calls report their HTTP
+// attempts.
+func NewTokenClient(ctx context.Context, ts oauth2.TokenSource) (*ClientAdapter, error) {
+	httpClient := &http.Client{Transport: &oauth2.Transport{
+		Source: oauth2.ReuseTokenSource(nil, ts),
+		Base:   telemetry.NewTransport(http.DefaultTransport),
+	}}
+	svc, err := gmailapi.NewService(ctx, option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("create gmail service: %w", err)
+	}
+	return NewGoogleAPIClient(svc, LabelStyle{}), nil
 }
 
-// NewGoogleAPIClient wraps a gmail Service with the chronosweep gmail.Client interface.
-func NewGoogleAPIClient(svc *gmailapi.Service) *ClientAdapter {
-	return &ClientAdapter{svc: svc}
+// Profile fetches the account's address and message count; any Gmail scope may read it.
+func (g *ClientAdapter) Profile(ctx context.Context) (Profile, error) {
+	res, err := g.svc.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return Profile{}, fmt.Errorf("get gmail profile: %w", err)
+	}
+	return Profile{Email: res.EmailAddress, MessagesTotal: res.MessagesTotal}, nil
 }
 
 // List retrieves message identifiers matching the supplied query.
@@ -27,7 +69,18 @@ func (g *ClientAdapter) List(
 	pageToken string,
 	pageSize int,
 ) (gmail.ListPage, error) {
-	call := g.svc.Users.Messages.List("me").Q(q.Raw)
+	call := g.svc.Users.Messages.List("me")
+	// The q parameter is rejected outright under the gmail.metadata scope, so only send it when set.
+	if q.Raw != "" {
+		call = call.Q(q.Raw)
+	}
+	if len(q.LabelIDs) > 0 {
+		ids := make([]string, 0, len(q.LabelIDs))
+		for _, id := range q.LabelIDs {
+			ids = append(ids, string(id))
+		}
+		call = call.LabelIds(ids...)
+	}
 	if pageSize > 0 {
 		call = call.MaxResults(int64(pageSize))
 	}
@@ -56,7 +109,7 @@ func (g *ClientAdapter) GetMetadata(
 		MetadataHeaders(headers...)
 	msg, err := call.Context(ctx).Do()
 	if err != nil {
-		return gmail.MessageMeta{}, fmt.Errorf("get metadata %s: %w", id, err)
+		return gmail.MessageMeta{}, fmt.Errorf("get metadata %s: %w", id, notFound(err))
 	}
 	headersMap := make(map[string]string, len(msg.Payload.Headers))
 	for _, h := range msg.Payload.Headers {
@@ -64,6 +117,7 @@ func (g *ClientAdapter) GetMetadata(
 	}
 	meta := gmail.MessageMeta{
 		ID:       id,
+		ThreadID: msg.ThreadId,
 		LabelIDs: toLabelIDs(msg.LabelIds),
 		Headers:  headersMap,
 		Date:     time.UnixMilli(msg.InternalDate),
@@ -71,6 +125,19 @@ func (g *ClientAdapter) GetMetadata(
 	return meta, nil
 }
 
+// GetLabels fetches only the current label identifiers for a message.
+func (g *ClientAdapter) GetLabels(ctx context.Context, id gmail.MessageID) ([]gmail.LabelID, error) {
+	msg, err := g.svc.Users.Messages.Get("me", string(id)).
+		Format("minimal").
+		Fields("id", "labelIds").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("get labels %s: %w", id, notFound(err))
+	}
+	return toLabelIDs(msg.LabelIds), nil
+}
+
 // BatchModify applies label modifications to the provided message IDs.
 func (g *ClientAdapter) BatchModify(
 	ctx context.Context,
@@ -109,38 +176,93 @@ func (g *ClientAdapter) BatchModify(
 	return nil
 }
 
-// ListLabels returns Gmail labels keyed by both name and identifier.
+// ListLabels returns Gmail labels keyed by both name and identifier, fetched once per run.
 func (g *ClientAdapter) ListLabels(
 	ctx context.Context,
 ) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
-	res, err := g.svc.Users.Labels.List("me").Context(ctx).Do()
+	return g.labels.ListLabels(ctx)
+}
+
+// EnsureLabel guarantees that the requested label and its parents exist, creating them when necessary.
+func (g *ClientAdapter) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
+	return g.labels.EnsureLabel(ctx, name)
+}
+
+// CreateDraft saves raw, an RFC 5322 message, as a draft and returns its ID. It needs gmail.modify.
+func (g *ClientAdapter) CreateDraft(ctx context.Context, raw []byte) (string, error) {
+	draft := &gmailapi.Draft{Message: &gmailapi.Message{Raw: base64.URLEncoding.EncodeToString(raw)}}
+	created, err := g.svc.Users.Drafts.Create("me", draft).Context(ctx).Do()
 	if err != nil {
-		return nil, nil, fmt.Errorf("list labels: %w", err)
+		return "", fmt.Errorf("create draft: %w", err)
 	}
-	byName := make(map[string]gmail.LabelID, len(res.Labels))
-	byID := make(map[gmail.LabelID]string, len(res.Labels))
-	for _, lbl := range res.Labels {
-		id := gmail.LabelID(lbl.Id)
-		byName[lbl.Name] = id
-		byID[id] = lbl.Name
+	return created.Id, nil
+}
+
+// HistoryID returns the mailbox's current history position from its profile.
+func (g *ClientAdapter) HistoryID(ctx context.Context) (uint64, error) {
+	res, err := g.svc.Users.GetProfile("me").Fields("historyId").Context(ctx).Do()
+	if err != nil {
+		return 0, fmt.Errorf("get history id: %w", err)
 	}
-	return byName, byID, nil
+	return res.HistoryId, nil
 }
 
-// EnsureLabel guarantees that the requested label exists, creating it when necessary.
-func (g *ClientAdapter) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
-	byName, _, err := g.ListLabels(ctx)
+// LabelChanges pages through history.list from start, keeping label edits and deletions. Gmail answers
+// 404 once start is older than the history it keeps (about a week), reported as gmail.ErrHistoryExpired.
+func (g *ClientAdapter) LabelChanges(ctx context.Context, start uint64) ([]gmail.LabelChange, uint64, error) {
+	call := g.svc.Users.History.List("me").
+		StartHistoryId(start).
+		HistoryTypes("labelAdded", "labelRemoved", "messageDeleted").
+		MaxResults(historyPageSize)
+	var (
+		changes []gmail.LabelChange
+		latest  = start
+	)
+	err := call.Pages(ctx, func(res *gmailapi.ListHistoryResponse) error {
+		latest = max(latest, res.HistoryId)
+		for _, h := range res.History {
+			changes = append(changes, historyChanges(h)...)
+		}
+		return nil
+	})
 	if err != nil {
-		return "", err
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return nil, 0, fmt.Errorf("list history from %d: %w: %w", start, gmail.ErrHistoryExpired, err)
+		}
+		return nil, 0, fmt.Errorf("list history from %d: %w", start, err)
 	}
-	if id, ok := byName[name]; ok {
-		return id, nil
+	return changes, latest, nil
+}
+
+// historyChanges flattens one history record, keeping Gmail's order within it.
+func historyChanges(h *gmailapi.History) []gmail.LabelChange {
+	var changes []gmail.LabelChange
+	for _, added := range h.LabelsAdded {
+		changes = append(changes, gmail.LabelChange{
+			ID:    gmail.MessageID(added.Message.Id),
+			Added: toLabelIDs(added.LabelIds),
+		})
 	}
-	created, err := g.svc.Users.Labels.Create("me", &gmailapi.Label{Name: name}).Context(ctx).Do()
-	if err != nil {
-		return "", fmt.Errorf("create label %q: %w", name, err)
+	for _, removed := range h.LabelsRemoved {
+		changes = append(changes, gmail.LabelChange{
+			ID:      gmail.MessageID(removed.Message.Id),
+			Removed: toLabelIDs(removed.LabelIds),
+		})
+	}
+	for _, deleted := range h.MessagesDeleted {
+		changes = append(changes, gmail.LabelChange{ID: gmail.MessageID(deleted.Message.Id), Deleted: true})
 	}
-	return gmail.LabelID(created.Id), nil
+	return changes
+}
+
+// notFound marks a 404 from a message call with gmail.ErrMessageNotFound, keeping the API error.
+func notFound(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return fmt.Errorf("%w: %w", gmail.ErrMessageNotFound, err)
+	}
+	return err
 }
 
 func toStrings(ids []gmail.MessageID) []string {
@@ -159,4 +281,9 @@ func toLabelIDs(ids []string) []gmail.LabelID {
 	return out
 }
 
-var _ gmail.Client = (*ClientAdapter)(nil)
+var (
+	_ gmail.Client        = (*ClientAdapter)(nil)
+	_ gmail.LabelReader   = (*ClientAdapter)(nil)
+	_ gmail.HistoryReader = (*ClientAdapter)(nil)
+	_ gmail.DraftCreator  = (*ClientAdapter)(nil)
+)
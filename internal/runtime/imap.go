@@ -0,0 +1,85 @@
+package runtime
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joshsymonds/chronosweep/internal/imap"
+)
+
+// IMAPPasswordEnv names the environment variable consulted when no password file is given.
+const IMAPPasswordEnv = "CHRONOSWEEP_IMAP_PASSWORD"
+
+// IMAPConfig selects an IMAP account in place of the Gmail API.
+type IMAPConfig struct {
+	Addr         string
+	User         string
+	PasswordFile string
+	Plaintext    bool
+}
+
+// RegisterIMAPFlags adds the shared -imap-* flags to fs. Read the result after fs.Parse.
+func RegisterIMAPFlags(fs *flag.FlagSet) *IMAPConfig {
+	cfg := &IMAPConfig{}
+	fs.StringVar(&cfg.Addr, "imap-addr", "", "use an IMAP server (host:port, e.g. imap.gmail.com:993) instead of the Gmail API")
+	fs.StringVar(&cfg.User, "imap-user", "", "IMAP login name")
+	fs.StringVar(
+		&cfg.PasswordFile,
+		"imap-password-file",
+		"",
+		"file holding the IMAP (app) password; defaults to $"+IMAPPasswordEnv,
+	)
+	fs.BoolVar(&cfg.Plaintext, "imap-plaintext", false, "connect without TLS (local test servers only)")
+	return cfg
+}
+
+// Enabled reports whether an IMAP server was configured.
+func (c IMAPConfig) Enabled() bool {
+	return c.Addr != ""
+}
+
+// Account identifies the mailbox, e.g. for keying the metadata cache.
+func (c IMAPConfig) Account() string {
+	return "imap://" + c.User + "@" + c.Addr
+}
+
+// NewIMAPClient logs in to the configured IMAP server. The password comes from PasswordFile, or from
+// $CHRONOSWEEP_IMAP_PASSWORD so it never has to appear on a command line.
+func NewIMAPClient(ctx context.Context, cfg IMAPConfig) (*imap.Client, error) {
+	if cfg.User == "" {
+		return nil, InvalidConfigf("-imap-user is required with -imap-addr")
+	}
+	password, err := imapPassword(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := imap.Dial(ctx, imap.Options{
+		Addr:      cfg.Addr,
+		Username:  cfg.User,
+		Password:  password,
+		Plaintext: cfg.Plaintext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to imap server %s: %w", cfg.Addr, err)
+	}
+	return client, nil
+}
+
+func imapPassword(cfg IMAPConfig) (string, error) {
+	if cfg.PasswordFile == "" {
+		password := os.Getenv(IMAPPasswordEnv)
+		if password == "" {
+			return "", fmt.Errorf("set -imap-password-file or $%s", IMAPPasswordEnv)
+		}
+		return password, nil
+	}
+	raw, err := os.ReadFile(filepath.Clean(cfg.PasswordFile))
+	if err != nil {
+		return "", fmt.Errorf("read imap password file: %w", err)
+	}
+	return strings.TrimRight(string(raw), "\r\n"), nil
+}
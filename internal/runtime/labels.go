@@ -0,0 +1,229 @@
+package runtime
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+	"sync"
+
+	gmailapi "google.golang.org/api/gmail/v1"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+const (
+	// LabelListShow always lists the label in the sidebar.
+	LabelListShow = "labelShow"
+	// LabelListShowIfUnread lists the label only while it has unread mail.
+	LabelListShowIfUnread = "labelShowIfUnread"
+	// LabelListHide never lists the label in the sidebar.
+	LabelListHide = "labelHide"
+	// MessageListShow shows the label as a chip on messages.
+	MessageListShow = "show"
+	// MessageListHide hides the label chip on messages.
+	MessageListHide = "hide"
+
+	labelColorParts = 2
+)
+
+// LabelStyle is applied to labels chronosweep creates. Empty fields keep Gmail's defaults; colors must
+// come from Gmail's label palette and are set as a pair.
+type LabelStyle struct {
+	TextColor             string
+	BackgroundColor       string
+	LabelListVisibility   string
+	MessageListVisibility string
+}
+
+// RegisterLabelFlags registers the -label-* flags styling created labels on fs.
+func RegisterLabelFlags(fs *flag.FlagSet) *LabelStyle {
+	style := &LabelStyle{}
+	fs.Func("label-color", "color for created labels as text:background hex (e.g. #ffffff:#4a86e8)", style.setColor)
+	fs.StringVar(
+		&style.LabelListVisibility,
+		"label-list-visibility",
+		LabelListShowIfUnread,
+		"sidebar visibility for created labels (labelShow, labelShowIfUnread, labelHide)",
+	)
+	fs.StringVar(
+		&style.MessageListVisibility,
+		"message-list-visibility",
+		MessageListShow,
+		"label chip visibility on messages for created labels (show or hide)",
+	)
+	return style
+}
+
+// Validate rejects colors and visibilities Gmail would refuse, before any label is created.
+func (s LabelStyle) Validate() error {
+	hex := regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	if (s.TextColor == "") != (s.BackgroundColor == "") {
+		return InvalidConfigf("label color needs both text and background colors")
+	}
+	for _, color := range []string{s.TextColor, s.BackgroundColor} {
+		if color != "" && !hex.MatchString(color) {
+			return InvalidConfigf("invalid label color %q (want #rrggbb)", color)
+		}
+	}
+	switch s.LabelListVisibility {
+	case "", LabelListShow, LabelListShowIfUnread, LabelListHide:
+	default:
+		return InvalidConfigf(
+			"unknown label list visibility %q (want %s, %s or %s)",
+			s.LabelListVisibility, LabelListShow, LabelListShowIfUnread, LabelListHide,
+		)
+	}
+	switch s.MessageListVisibility {
+	case "", MessageListShow, MessageListHide:
+	default:
+		return InvalidConfigf(
+			"unknown message list visibility %q (want %s or %s)",
+			s.MessageListVisibility, MessageListShow, MessageListHide,
+		)
+	}
+	return nil
+}
+
+func (s *LabelStyle) setColor(value string) error {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != labelColorParts {
+		return fmt.Errorf("invalid label color %q (want text:background)", value)
+	}
+	s.TextColor = strings.ToLower(strings.TrimSpace(parts[0]))
+	s.BackgroundColor = strings.ToLower(strings.TrimSpace(parts[1]))
+	return nil
+}
+
+func (s LabelStyle) label(name string) *gmailapi.Label {
+	label := &gmailapi.Label{
+		Name:                  name,
+		LabelListVisibility:   s.LabelListVisibility,
+		MessageListVisibility: s.MessageListVisibility,
+	}
+	if s.TextColor != "" {
+		label.Color = &gmailapi.LabelColor{TextColor: s.TextColor, BackgroundColor: s.BackgroundColor}
+	}
+	return label
+}
+
+// LabelManager owns the mailbox's label list for a run. The list is fetched once and served from memory
+// until a label is created; EnsureLabel creates any missing parents of a nested name such as
+// "auto-archived/expired" first, styling every label it creates.
+type LabelManager struct {
+	svc   *gmailapi.Service
+	style LabelStyle
+
+	mu     sync.Mutex
+	byName map[string]gmail.LabelID
+	byID   map[gmail.LabelID]string
+}
+
+// NewLabelManager manages the labels of the mailbox svc is authorized for.
+func NewLabelManager(svc *gmailapi.Service, style LabelStyle) *LabelManager {
+	return &LabelManager{svc: svc, style: style}
+}
+
+// ListLabels returns the cached label list, fetching it on first use or after a create. The maps are
+// copies the caller may modify.
+func (m *LabelManager) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.load(ctx); err != nil {
+		return nil, nil, err
+	}
+	return maps.Clone(m.byName), maps.Clone(m.byID), nil
+}
+
+// EnsureLabel returns the ID of name, creating it and any missing parents when necessary. Names are
+// matched case-insensitively, as Gmail does.
+func (m *LabelManager) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.load(ctx); err != nil {
+		return "", err
+	}
+	if id, ok := m.lookup(name); ok {
+		return id, nil
+	}
+	created := false
+	defer func() {
+		if created {
+			m.invalidate()
+		}
+	}()
+	paths := labelPaths(name)
+	if len(paths) == 0 {
+		return "", InvalidConfigf("invalid label name %q", name)
+	}
+	var id gmail.LabelID
+	for _, path := range paths {
+		if existing, ok := m.lookup(path); ok {
+			id = existing
+			continue
+		}
+		label, err := m.svc.Users.Labels.Create("me", m.style.label(path)).Context(ctx).Do()
+		if err != nil {
+			return "", fmt.Errorf("create label %q: %w", path, err)
+		}
+		created = true
+		id = gmail.LabelID(label.Id)
+		m.byName[path] = id
+		m.byID[id] = path
+	}
+	return id, nil
+}
+
+func (m *LabelManager) load(ctx context.Context) error {
+	if m.byName != nil {
+		return nil
+	}
+	res, err := m.svc.Users.Labels.List("me").Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("list labels: %w", err)
+	}
+	m.byName = make(map[string]gmail.LabelID, len(res.Labels))
+	m.byID = make(map[gmail.LabelID]string, len(res.Labels))
+	for _, lbl := range res.Labels {
+		id := gmail.LabelID(lbl.Id)
+		m.byName[lbl.Name] = id
+		m.byID[id] = lbl.Name
+	}
+	return nil
+}
+
+func (m *LabelManager) lookup(name string) (gmail.LabelID, bool) {
+	if id, ok := m.byName[name]; ok {
+		return id, true
+	}
+	for existing, id := range m.byName {
+		if strings.EqualFold(existing, name) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// invalidate drops the cached list so the next call sees labels exactly as Gmail stored them.
+func (m *LabelManager) invalidate() {
+	m.byName = nil
+	m.byID = nil
+}
+
+// labelPaths expands "a/b/c" into "a", "a/b", "a/b/c", skipping empty segments.
+func labelPaths(name string) []string {
+	var (
+		paths    []string
+		segments []string
+	)
+	for _, segment := range strings.Split(name, "/") {
+		if segment = strings.TrimSpace(segment); segment == "" {
+			continue
+		}
+		segments = append(segments, segment)
+		paths = append(paths, strings.Join(segments, "/"))
+	}
+	return paths
+}
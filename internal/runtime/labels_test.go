@@ -0,0 +1,139 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gmailapi "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+type fakeLabelAPI struct {
+	labels  []*gmailapi.Label
+	lists   int
+	created []*gmailapi.Label
+}
+
+func (f *fakeLabelAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/users/me/labels") {
+		http.NotFound(w, r)
+		return
+	}
+	var body any
+	switch r.Method {
+	case http.MethodGet:
+		f.lists++
+		body = &gmailapi.ListLabelsResponse{Labels: f.labels}
+	case http.MethodPost:
+		var label gmailapi.Label
+		if err := json.NewDecoder(r.Body).Decode(&label); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		label.Id = "Label_" + label.Name
+		f.labels = append(f.labels, &label)
+		f.created = append(f.created, &label)
+		body = &label
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func newTestLabelManager(t *testing.T, api *fakeLabelAPI, style LabelStyle) *LabelManager {
+	t.Helper()
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	svc, err := gmailapi.NewService(
+		context.Background(),
+		option.WithEndpoint(srv.URL),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("create service: %v", err)
+	}
+	return NewLabelManager(svc, style)
+}
+
+func TestLabelManagerCreatesParentsWithStyle(t *testing.T) {
+	api := &fakeLabelAPI{labels: []*gmailapi.Label{{Id: "INBOX", Name: "INBOX"}}}
+	style := LabelStyle{
+		TextColor:             "#ffffff",
+		BackgroundColor:       "#4a86e8",
+		LabelListVisibility:   LabelListHide,
+		MessageListVisibility: MessageListShow,
+	}
+	manager := newTestLabelManager(t, api, style)
+	ctx := context.Background()
+
+	id, err := manager.EnsureLabel(ctx, "auto-archived/expired")
+	if err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	if id != "Label_auto-archived/expired" {
+		t.Fatalf("unexpected id %q", id)
+	}
+	if len(api.created) != 2 ||
+		api.created[0].Name != "auto-archived" ||
+		api.created[1].Name != "auto-archived/expired" {
+		t.Fatalf("expected parent then child to be created, got %+v", api.created)
+	}
+	for _, label := range api.created {
+		if label.LabelListVisibility != LabelListHide || label.Color == nil ||
+			label.Color.BackgroundColor != "#4a86e8" {
+			t.Fatalf("style not applied to %+v", label)
+		}
+	}
+
+	byName, _, err := manager.ListLabels(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if byName["auto-archived/expired"] != id || api.lists != 2 {
+		t.Fatalf("expected a fresh list after create, got %v after %d lists", byName, api.lists)
+	}
+	if _, err := manager.EnsureLabel(ctx, "Auto-Archived/Expired"); err != nil {
+		t.Fatalf("ensure existing: %v", err)
+	}
+	if _, _, err := manager.ListLabels(ctx); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(api.created) != 2 || api.lists != 2 {
+		t.Fatalf("expected cached lookups, got %d creates and %d lists", len(api.created), api.lists)
+	}
+}
+
+func TestLabelStyleValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		style LabelStyle
+		ok    bool
+	}{
+		{name: "defaults", style: LabelStyle{}, ok: true},
+		{
+			name:  "full",
+			style: LabelStyle{TextColor: "#000000", BackgroundColor: "#ffffff", LabelListVisibility: LabelListShow},
+			ok:    true,
+		},
+		{name: "half color", style: LabelStyle{TextColor: "#000000"}},
+		{name: "bad color", style: LabelStyle{TextColor: "black", BackgroundColor: "#ffffff"}},
+		{name: "bad visibility", style: LabelStyle{LabelListVisibility: "hidden"}},
+		{name: "bad message visibility", style: LabelStyle{MessageListVisibility: "never"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.style.Validate()
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && gmail.ClassOf(err) != gmail.ClassInvalidConfig {
+				t.Fatalf("expected invalid config, got %v", err)
+			}
+		})
+	}
+}
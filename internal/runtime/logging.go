@@ -0,0 +1,225 @@
+package runtime
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// LogFormatText renders records as logfmt-style key=value lines.
+	LogFormatText = "text"
+	// LogFormatJSON renders one JSON object per record.
+	LogFormatJSON = "json"
+
+	runIDBytes    = 8
+	attrPairParts = 2
+	redacted      = "[redacted]"
+)
+
+// LogConfig collects the logging flags shared by audit, lint and sweep.
+type LogConfig struct {
+	Format   string
+	Level    string
+	Journald bool
+	Attrs    string
+	DebugAPI bool
+	AllowPII bool
+}
+
+// RegisterLogFlags registers the -log-* flags on fs.
+func RegisterLogFlags(fs *flag.FlagSet) *LogConfig {
+	cfg := &LogConfig{}
+	fs.StringVar(&cfg.Format, "log-format", LogFormatText, "log format (text or json)")
+	fs.StringVar(&cfg.Level, "log-level", "info", "minimum log level (debug, info, warn, error)")
+	fs.BoolVar(&cfg.Journald, "log-journald", false, "omit timestamps; journald records its own")
+	fs.StringVar(&cfg.Attrs, "log-attrs", "", "comma separated key=value attributes added to every record")
+	fs.BoolVar(&cfg.DebugAPI, "log-api", false, "log every mailbox call with method, latency and status at debug")
+	fs.BoolVar(
+		&cfg.AllowPII,
+		"log-allow-pii",
+		false,
+		"log email addresses and subjects verbatim instead of redacting them",
+	)
+	return cfg
+}
+
+// Logger builds the logger cfg describes for command, writing to w. Every record carries the command
+// name, a random run ID and the -log-attrs pairs. On a configuration error the default logger is
+// returned alongside the error so the failure itself can still be logged.
+func (cfg LogConfig) Logger(w io.Writer, command string) (*slog.Logger, error) {
+	level, err := cfg.level()
+	if err != nil {
+		return DefaultLogger(), err
+	}
+	attrs, err := parseLogAttrs(cfg.Attrs)
+	if err != nil {
+		return DefaultLogger(), err
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	if cfg.Journald {
+		opts.ReplaceAttr = dropTime
+	}
+	var handler slog.Handler
+	switch strings.ToLower(strings.TrimSpace(cfg.Format)) {
+	case LogFormatText, "":
+		handler = slog.NewTextHandler(w, opts)
+	case LogFormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return DefaultLogger(), InvalidConfigf("unknown log format %q (want text or json)", cfg.Format)
+	}
+	if !cfg.AllowPII {
+		handler = NewRedactingHandler(handler)
+	}
+	attrs = append([]slog.Attr{slog.String("command", command), slog.String("run_id", newRunID())}, attrs...)
+	return slog.New(handler.WithAttrs(attrs)), nil
+}
+
+// DefaultLogger returns a slog.Logger configured for structured CLI output.
+func DefaultLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+}
+
+func (cfg LogConfig) level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(cfg.Level))); err != nil {
+		return 0, InvalidConfigf("parse log level %q: %w", cfg.Level, err)
+	}
+	// -log-api records are debug records; asking for them implies the level that shows them.
+	if cfg.DebugAPI && level > slog.LevelDebug {
+		level = slog.LevelDebug
+	}
+	return level, nil
+}
+
+func parseLogAttrs(raw string) ([]slog.Attr, error) {
+	var attrs []slog.Attr
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", attrPairParts)
+		if len(parts) != attrPairParts || strings.TrimSpace(parts[0]) == "" {
+			return nil, InvalidConfigf("invalid -log-attrs entry %q (want key=value)", pair)
+		}
+		attrs = append(attrs, slog.String(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])))
+	}
+	return attrs, nil
+}
+
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
+
+func newRunID() string {
+	buf := make([]byte, runIDBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// RedactingHandler masks personal data before records reach the wrapped handler: the local part of
+// every email address in messages, string attributes and errors, and the whole value of subject
+// attributes. Domains are kept because they are what audits aggregate on.
+type RedactingHandler struct {
+	inner   slog.Handler
+	address *regexp.Regexp
+}
+
+// NewRedactingHandler wraps inner with redaction.
+func NewRedactingHandler(inner slog.Handler) *RedactingHandler {
+	return &RedactingHandler{
+		inner:   inner,
+		address: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@([A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)+)`),
+	}
+}
+
+// Enabled delegates to the wrapped handler.
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle redacts the record and passes it on.
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	clean := slog.NewRecord(record.Time, record.Level, h.text(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		clean.AddAttrs(h.attr(a))
+		return true
+	})
+	if err := h.inner.Handle(ctx, clean); err != nil {
+		return fmt.Errorf("redacting handler: %w", err)
+	}
+	return nil
+}
+
+// WithAttrs redacts attrs once, up front.
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		clean = append(clean, h.attr(a))
+	}
+	return &RedactingHandler{inner: h.inner.WithAttrs(clean), address: h.address}
+}
+
+// WithGroup delegates to the wrapped handler.
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{inner: h.inner.WithGroup(name), address: h.address}
+}
+
+func (h *RedactingHandler) attr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if isSubjectKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.text(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		clean := make([]any, 0, len(group))
+		for _, member := range group {
+			clean = append(clean, h.attr(member))
+		}
+		return slog.Group(a.Key, clean...)
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, h.text(v.Error()))
+		case []string:
+			clean := make([]string, 0, len(v))
+			for _, s := range v {
+				clean = append(clean, h.text(s))
+			}
+			return slog.Any(a.Key, clean)
+		}
+	default:
+	}
+	return a
+}
+
+func (h *RedactingHandler) text(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	return h.address.ReplaceAllString(s, redacted+"@$1")
+}
+
+func isSubjectKey(key string) bool {
+	key = strings.ToLower(key)
+	return key == "subject" || key == "subjects"
+}
+
+var _ slog.Handler = (*RedactingHandler)(nil)
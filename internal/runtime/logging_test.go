@@ -0,0 +1,120 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+func TestLogConfigLogger(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := LogConfig{Format: "json", Level: "warn", Journald: true, Attrs: "policy=nightly, team=ops"}
+	logger, err := cfg.Logger(&buf, "chronosweep-sweep")
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	logger.Info("dropped")
+	logger.Warn("kept", slog.String("sender", "Alice <alice@example.com>"), slog.String("subject", "Payslip"))
+
+	var record map[string]any
+	if decodeErr := json.Unmarshal(buf.Bytes(), &record); decodeErr != nil {
+		t.Fatalf("expected exactly one JSON record, got %q: %v", buf.String(), decodeErr)
+	}
+	if _, ok := record[slog.TimeKey]; ok {
+		t.Fatalf("journald output must not carry a timestamp: %v", record)
+	}
+	want := map[string]any{
+		"msg":     "kept",
+		"command": "chronosweep-sweep",
+		"policy":  "nightly",
+		"team":    "ops",
+		"sender":  "Alice <[redacted]@example.com>",
+		"subject": "[redacted]",
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Fatalf("%s: got %v want %v", key, record[key], value)
+		}
+	}
+	if id, _ := record["run_id"].(string); len(id) != 2*runIDBytes {
+		t.Fatalf("unexpected run id %v", record["run_id"])
+	}
+}
+
+func TestLogConfigAllowPII(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := LogConfig{Format: "text", Level: "info", AllowPII: true}.Logger(&buf, "chronosweep-audit")
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	logger.Info("sender", slog.String("from", "alice@example.com"), slog.String("subject", "Payslip"))
+	if out := buf.String(); !strings.Contains(out, "alice@example.com") || !strings.Contains(out, "Payslip") {
+		t.Fatalf("expected verbatim output, got %q", out)
+	}
+}
+
+func TestLogConfigRejectsBadInput(t *testing.T) {
+	tests := []LogConfig{
+		{Format: "xml", Level: "info"},
+		{Format: "text", Level: "loud"},
+		{Format: "text", Level: "info", Attrs: "policy"},
+	}
+	for _, cfg := range tests {
+		logger, err := cfg.Logger(&bytes.Buffer{}, "chronosweep-lint")
+		if gmail.ClassOf(err) != gmail.ClassInvalidConfig {
+			t.Fatalf("%+v: expected invalid config, got %v", cfg, err)
+		}
+		if logger == nil {
+			t.Fatalf("%+v: expected a fallback logger", cfg)
+		}
+	}
+}
+
+func TestRedactingHandlerErrorsAndGroups(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewRedactingHandler(slog.NewTextHandler(&buf, nil)))
+	logger.With(slog.String("account", "me@example.org")).Info(
+		"mail from bob@example.net",
+		slog.Any("error", errors.New("get bob@example.net: not found")),
+		slog.Group("msg", slog.String("subject", "secret"), slog.Any("to", []string{"carol@example.com"})),
+	)
+	out := buf.String()
+	for _, leak := range []string{"me@", "bob@", "carol@", "secret"} {
+		if strings.Contains(out, leak) {
+			t.Fatalf("output leaked %q: %s", leak, out)
+		}
+	}
+	if !strings.Contains(out, "[redacted]@example.net") {
+		t.Fatalf("expected domain to be kept: %s", out)
+	}
+}
+
+type erroringClient struct {
+	gmail.Client
+}
+
+func (erroringClient) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	_ = ctx
+	return nil, nil, ErrScopeNotGranted
+}
+
+func TestLoggingClientLogsCalls(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := NewLoggingClient(erroringClient{}, logger)
+	if _, _, err := client.ListLabels(context.Background()); !errors.Is(err, ErrScopeNotGranted) {
+		t.Fatalf("expected the inner error, got %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"level=DEBUG", "method=labels.list", "status=scope_missing", "latency="} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in %q", want, out)
+		}
+	}
+}
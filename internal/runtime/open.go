@@ -0,0 +1,180 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/cache"
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/mailbox"
+	"github.com/joshsymonds/chronosweep/internal/snapshot"
+	"github.com/joshsymonds/chronosweep/internal/telemetry"
+)
+
+// OpenOptions selects the mailbox a command runs against. At most one of Snapshot, Mbox, Maildir and
+// IMAP.Addr may be set; with none, the Gmail API is used.
+type OpenOptions struct {
+	Snapshot string
+	Mbox     string
+	Maildir  string
+	IMAP     IMAPConfig
+	// ConfigDir and Auth pick the Gmail API credentials.
+	ConfigDir string
+	Auth      AuthConfig
+	// Scope is what the command needs. Live clients for any scope but ScopeModify refuse mutating calls.
+	Scope Scope
+	// Labels styles the labels a modify client creates.
+	Labels LabelStyle
+	// Cache layers the on-disk metadata cache over live clients; nil disables it.
+	Cache *CacheOptions
+	// Log and Trace decide whether live calls are logged (-log-api) and traced (-trace).
+	Log    LogConfig
+	Trace  telemetry.Config
+	Logger *slog.Logger
+}
+
+// CacheOptions configures the metadata cache OpenClient puts in front of a live client.
+type CacheOptions struct {
+	Dir    string
+	MaxAge time.Duration
+	// Labels is the -cache-labels value, parsed with cache.ParseLabelMode.
+	Labels string
+}
+
+// OpenClient returns the mailbox client, the clock the run should use, and a cleanup hook. Local sources
+// (snapshot, mbox, maildir) use their capture time as "now" so relative queries replay identically.
+func OpenClient(ctx context.Context, opts OpenOptions) (gmail.Client, func() time.Time, func(), error) {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if set := sourceFlags(opts); len(set) > 1 {
+		return nil, nil, nil, InvalidConfigf("%s are mutually exclusive", strings.Join(set, " and "))
+	}
+	switch {
+	case opts.Snapshot != "":
+		snap, err := snapshot.Load(opts.Snapshot)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("load snapshot: %w", err)
+		}
+		offline := snapshot.NewClient(snap)
+		return offline, offline.CapturedAt, func() {}, nil
+	case opts.Mbox != "":
+		imported, err := mailbox.LoadMbox(opts.Mbox, mailbox.Options{Logger: opts.Logger})
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("import mbox: %w", err)
+		}
+		local := imported.Client()
+		return local, local.CapturedAt, func() {}, nil
+	case opts.Maildir != "":
+		imported, err := mailbox.LoadMaildir(opts.Maildir, mailbox.Options{Logger: opts.Logger})
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("import maildir: %w", err)
+		}
+		local := imported.Client()
+		return local, local.CapturedAt, func() {}, nil
+	}
+	live, account, closeLive, err := openLive(ctx, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if opts.Cache == nil {
+		return live, time.Now, closeLive, nil
+	}
+	cached, err := openCache(*opts.Cache, live, account)
+	if err != nil {
+		closeLive()
+		return nil, nil, nil, err
+	}
+	closeCache := func() {
+		if closeErr := cached.Close(); closeErr != nil {
+			opts.Logger.WarnContext(ctx, "save metadata cache", slog.String("error", closeErr.Error()))
+		}
+		opts.Logger.InfoContext(ctx, "metadata cache stats", slog.Any("cache", cached.Stats()))
+		closeLive()
+	}
+	return cached, time.Now, closeCache, nil
+}
+
+// sourceFlags names the flags behind each mailbox source opts selects.
+func sourceFlags(opts OpenOptions) []string {
+	var set []string
+	for _, source := range []struct{ flag, value string }{
+		{"-snapshot", opts.Snapshot},
+		{"-mbox", opts.Mbox},
+		{"-maildir", opts.Maildir},
+		{"-imap-addr", opts.IMAP.Addr},
+	} {
+		if source.value != "" {
+			set = append(set, source.flag)
+		}
+	}
+	return set
+}
+
+// openLive connects to the mailbox over IMAP or the Gmail API and names the account for cache keying.
+func openLive(ctx context.Context, opts OpenOptions) (gmail.Client, string, func(), error) {
+	if opts.IMAP.Enabled() {
+		imapClient, err := NewIMAPClient(ctx, opts.IMAP)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("create imap client: %w", err)
+		}
+		closeIMAP := func() {
+			if closeErr := imapClient.Close(); closeErr != nil {
+				opts.Logger.WarnContext(ctx, "close imap connection", slog.String("error", closeErr.Error()))
+			}
+		}
+		return guard(instrument(imapClient, opts), opts.Scope), opts.IMAP.Account(), closeIMAP, nil
+	}
+	if err := opts.Labels.Validate(); err != nil {
+		return nil, "", nil, err
+	}
+	provider := opts.Auth.Provider(opts.ConfigDir)
+	apiClient, err := NewGmailClient(ctx, provider, opts.Scope, ClientOptions{
+		Labels:            opts.Labels,
+		AllowBroaderScope: opts.Auth.AllowBroaderScope,
+		Logger:            opts.Logger,
+	})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("create gmail client: %w", err)
+	}
+	return guard(instrument(apiClient, opts), opts.Scope), provider.Account(), func() {}, nil
+}
+
+// instrument decorates the live mailbox client so each call is logged under -log-api and traced under
+// -trace.
+func instrument(client gmail.Client, opts OpenOptions) gmail.Client {
+	if opts.Log.DebugAPI {
+		client = NewLoggingClient(client, opts.Logger)
+	}
+	if opts.Trace.Enabled() {
+		client = telemetry.NewClient(client)
+	}
+	return client
+}
+
+// guard refuses mutating calls on the outermost client unless the command asked for ScopeModify.
+func guard(client gmail.Client, scope Scope) gmail.Client {
+	if scope == ScopeModify {
+		return client
+	}
+	return gmail.NewReadOnly(client)
+}
+
+func openCache(opts CacheOptions, client gmail.Client, account string) (*cache.Client, error) {
+	mode, err := cache.ParseLabelMode(opts.Labels)
+	if err != nil {
+		return nil, InvalidConfigf("parse cache labels: %w", err)
+	}
+	cached, err := cache.Open(client, cache.Options{
+		Path:   cache.PathFor(opts.Dir, account),
+		MaxAge: opts.MaxAge,
+		Labels: mode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open metadata cache: %w", err)
+	}
+	return cached, nil
+}
@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+const openMbox = `From 1780000000000000001@xxx Mon Mar 04 10:00:00 +0000 2024
+X-Gmail-Labels: Inbox
+From: Bank <alerts@bank.example>
+Subject: Statement
+Date: Mon, 04 Mar 2024 10:00:00 +0000
+
+Body
+`
+
+func TestOpenClientRejectsSeveralSources(t *testing.T) {
+	_, _, _, err := OpenClient(context.Background(), OpenOptions{
+		Snapshot: "snap.json",
+		IMAP:     IMAPConfig{Addr: "imap.example.com:993"},
+	})
+	if err == nil || err.Error() != "-snapshot and -imap-addr are mutually exclusive" {
+		t.Fatalf("err = %v, want the two flags named", err)
+	}
+	if class := ClassifyError(err); class != gmail.ClassInvalidConfig {
+		t.Fatalf("class = %v, want invalid config", class)
+	}
+}
+
+func TestOpenClientUsesCaptureTimeForLocalSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "takeout.mbox")
+	if err := os.WriteFile(path, []byte(openMbox), 0o600); err != nil {
+		t.Fatalf("write mbox: %v", err)
+	}
+	// A cache is ignored for local sources; they are already on disk.
+	client, clock, closeClient, err := OpenClient(context.Background(), OpenOptions{
+		Mbox:  path,
+		Cache: &CacheOptions{Dir: t.TempDir(), Labels: "bogus"},
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer closeClient()
+
+	want := time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+	if got := clock(); !got.Equal(want) {
+		t.Fatalf("clock = %v, want %v", got, want)
+	}
+	page, err := client.List(context.Background(), gmail.Query{Raw: "in:inbox"}, "", 0)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(page.IDs) != 1 {
+		t.Fatalf("listed %d messages, want 1", len(page.IDs))
+	}
+}
@@ -0,0 +1,243 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/joshsymonds/chronosweep/internal/auth"
+)
+
+// TokenPassphraseEnv names the environment variable whose value encrypts tokens in chronosweep's store.
+const TokenPassphraseEnv = "CHRONOSWEEP_TOKEN_PASSPHRASE"
+
+const (
+	credentialsFile = "credentials.json"
+	tokenFile       = "token.json"
+)
+
+// TokenProvider supplies OAuth tokens for the Gmail API.
+type TokenProvider interface {
+	// TokenSource returns tokens granting scope or a broader one.
+	TokenSource(ctx context.Context, scope Scope) (oauth2.TokenSource, error)
+	// Account names whose tokens these are, e.g. for keying the metadata cache.
+	Account() string
+}
+
+// CredentialLocator is implemented by providers that keep their secrets on disk, so chronosweep-doctor
+// can inspect them without refreshing anything.
+type CredentialLocator interface {
+	// CredentialDir is the directory holding the provider's secrets.
+	CredentialDir() string
+	// CredentialsPath is the OAuth client credentials file.
+	CredentialsPath() string
+	// StoredToken loads the token TokenSource would start from for scope and names its file.
+	StoredToken(scope Scope) (*oauth2.Token, string, error)
+	// LoginHint tells the user how to authorize scope again.
+	LoginHint(scope Scope) string
+}
+
+// GmailctlProvider reads the credentials.json and token.json gmailctl keeps in its config directory.
+type GmailctlProvider struct {
+	Dir string
+}
+
+// StoreProvider serves the tokens chronosweep-auth login saved for one Google account.
+type StoreProvider struct {
+	Store *auth.Store
+	Email string
+}
+
+// AuthConfig selects the token provider for a command.
+type AuthConfig struct {
+	Account  string
+	TokenDir string
+	// AllowBroaderScope lets read-only commands run on a token that could also modify mail.
+	AllowBroaderScope bool
+}
+
+// RegisterAuthFlags adds the shared -account and -token-dir flags to fs. Read the result after fs.Parse.
+func RegisterAuthFlags(fs *flag.FlagSet) *AuthConfig {
+	cfg := &AuthConfig{}
+	fs.StringVar(
+		&cfg.Account,
+		"account",
+		"",
+		"use the tokens chronosweep-auth login stored for this Google account instead of gmailctl's -config",
+	)
+	fs.StringVar(&cfg.TokenDir, "token-dir", auth.DefaultDir(), "directory holding chronosweep's OAuth token store")
+	return cfg
+}
+
+// RegisterScopeFlag adds -allow-broader-scope to fs for commands that only read mail.
+func (c *AuthConfig) RegisterScopeFlag(fs *flag.FlagSet) {
+	fs.BoolVar(
+		&c.AllowBroaderScope,
+		"allow-broader-scope",
+		false,
+		"accept a gmail.modify or mail.google.com token for read-only access (logged as a warning)",
+	)
+}
+
+// Store opens the token store, encrypting with $CHRONOSWEEP_TOKEN_PASSPHRASE when it is set.
+func (c AuthConfig) Store() *auth.Store {
+	return auth.NewStore(c.TokenDir, []byte(os.Getenv(TokenPassphraseEnv)))
+}
+
+// Provider returns the token store provider when -account is set, and gmailctl's credentials otherwise.
+func (c AuthConfig) Provider(gmailctlDir string) TokenProvider {
+	if c.Account == "" {
+		return GmailctlProvider{Dir: gmailctlDir}
+	}
+	return StoreProvider{Store: c.Store(), Email: c.Account}
+}
+
+// TokenSource configures the OAuth client for scope alone so any re-authorization asks for exactly that scope.
+func (p GmailctlProvider) TokenSource(ctx context.Context, scope Scope) (oauth2.TokenSource, error) {
+	credBytes, err := os.ReadFile(p.CredentialsPath())
+	if err != nil {
+		return nil, fmt.Errorf("read gmailctl credentials: %w", err)
+	}
+	cfg, err := google.ConfigFromJSON(credBytes, scope.URL())
+	if err != nil {
+		return nil, fmt.Errorf("parse gmailctl credentials: %w", err)
+	}
+	tok, _, err := p.StoredToken(scope)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.TokenSource(ctx, tok), nil
+}
+
+// Account returns the gmailctl config directory, which stands for one account.
+func (p GmailctlProvider) Account() string {
+	return p.Dir
+}
+
+// CredentialDir returns the gmailctl config directory.
+func (p GmailctlProvider) CredentialDir() string {
+	return p.Dir
+}
+
+// CredentialsPath returns gmailctl's credentials.json.
+func (p GmailctlProvider) CredentialsPath() string {
+	return filepath.Join(p.Dir, credentialsFile)
+}
+
+// StoredToken reads gmailctl's token.json; gmailctl keeps a single token whatever the scope.
+func (p GmailctlProvider) StoredToken(scope Scope) (*oauth2.Token, string, error) {
+	_ = scope
+	path := filepath.Join(p.Dir, tokenFile)
+	tokenBytes, err := os.ReadFile(path) // #nosec G304 -- the user names the gmailctl directory
+	if err != nil {
+		return nil, path, fmt.Errorf("read gmailctl token: %w", err)
+	}
+	tok := &oauth2.Token{}
+	if unmarshalErr := json.Unmarshal(tokenBytes, tok); unmarshalErr != nil {
+		return nil, path, fmt.Errorf("parse gmailctl token: %w", unmarshalErr)
+	}
+	return tok, path, nil
+}
+
+// LoginHint points at gmailctl's login for scope.
+func (p GmailctlProvider) LoginHint(scope Scope) string {
+	return fmt.Sprintf("gmailctl auth login --config %s --scope %s", p.Dir, scope)
+}
+
+// TokenSource prefers a token stored for exactly scope and falls back to the narrowest broader one.
+// Refreshed tokens are written back to the store.
+func (p StoreProvider) TokenSource(ctx context.Context, scope Scope) (oauth2.TokenSource, error) {
+	tok, granted, err := p.storedToken(scope)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := p.Store.Config(granted.URL())
+	if err != nil {
+		return nil, fmt.Errorf("load oauth client: %w", err)
+	}
+	base := cfg.TokenSource(ctx, tok)
+	return auth.NewPersistingSource(base, p.Store, p.Email, granted.String(), tok), nil
+}
+
+// Account returns the Google account's email address.
+func (p StoreProvider) Account() string {
+	return p.Email
+}
+
+// CredentialDir returns the token store's root directory.
+func (p StoreProvider) CredentialDir() string {
+	return p.Store.Dir()
+}
+
+// CredentialsPath returns the OAuth client credentials copied into the store.
+func (p StoreProvider) CredentialsPath() string {
+	return p.Store.CredentialsPath()
+}
+
+// StoredToken loads the token TokenSource would pick for scope.
+func (p StoreProvider) StoredToken(scope Scope) (*oauth2.Token, string, error) {
+	tok, granted, err := p.storedToken(scope)
+	if err != nil {
+		return nil, "", err
+	}
+	path, err := p.Store.TokenPath(p.Email, granted.String())
+	if err != nil {
+		return nil, "", fmt.Errorf("locate stored token: %w", err)
+	}
+	return tok, path, nil
+}
+
+// LoginHint points at chronosweep-auth login for this account and scope.
+func (p StoreProvider) LoginHint(scope Scope) string {
+	scopeName := strings.TrimPrefix(scope.String(), "gmail.")
+	return fmt.Sprintf("chronosweep-auth login -token-dir %s -account %s -scope %s", p.Store.Dir(), p.Email, scopeName)
+}
+
+// storedToken returns the stored token for scope, or for the narrowest broader scope, and the scope it carries.
+func (p StoreProvider) storedToken(scope Scope) (*oauth2.Token, Scope, error) {
+	for _, candidate := range scope.candidates() {
+		tok, err := p.Store.Load(p.Email, candidate.String())
+		if errors.Is(err, auth.ErrNoToken) {
+			continue
+		}
+		if err != nil {
+			return nil, candidate, fmt.Errorf("load stored token: %w", err)
+		}
+		return tok, candidate, nil
+	}
+	return nil, scope, fmt.Errorf(
+		"%w for %s with %s or broader; run %s",
+		auth.ErrNoToken,
+		p.Email,
+		scope,
+		p.LoginHint(scope),
+	)
+}
+
+// AccountEmail asks Gmail which account ts belongs to.
+func AccountEmail(ctx context.Context, ts oauth2.TokenSource) (string, error) {
+	client, err := NewTokenClient(ctx, ts)
+	if err != nil {
+		return "", err
+	}
+	profile, err := client.Profile(ctx)
+	if err != nil {
+		return "", err
+	}
+	return profile.Email, nil
+}
+
+var (
+	_ TokenProvider     = GmailctlProvider{}
+	_ TokenProvider     = StoreProvider{}
+	_ CredentialLocator = GmailctlProvider{}
+	_ CredentialLocator = StoreProvider{}
+)
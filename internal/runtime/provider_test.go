@@ -0,0 +1,57 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/joshsymonds/chronosweep/internal/auth"
+)
+
+func TestStoreProviderFallsBackToBroaderScope(t *testing.T) {
+	store := auth.NewStore(t.TempDir(), nil)
+	creds := `{"installed":{"client_id":"id","client_secret":"secret",` +
+		`"auth_uri":"https://accounts.google.com/o/oauth2/auth","token_uri":"https://oauth2.googleapis.com/token",` +
+		`"redirect_uris":["http://localhost"]}}`
+	if err := store.SaveCredentials([]byte(creds)); err != nil {
+		t.Fatalf("save credentials: %v", err)
+	}
+	expiry := time.Now().Add(time.Hour)
+	for scope, access := range map[Scope]string{ScopeReadonly: "readonly", ScopeModify: "modify"} {
+		tok := &oauth2.Token{AccessToken: access, RefreshToken: "r", Expiry: expiry}
+		if err := store.Save("me@example.com", scope.String(), tok); err != nil {
+			t.Fatalf("save token: %v", err)
+		}
+	}
+	provider := StoreProvider{Store: store, Email: "me@example.com"}
+
+	tests := []struct {
+		scope Scope
+		want  string
+	}{
+		{scope: ScopeMetadata, want: "readonly"},
+		{scope: ScopeReadonly, want: "readonly"},
+		{scope: ScopeModify, want: "modify"},
+	}
+	for _, tt := range tests {
+		ts, err := provider.TokenSource(context.Background(), tt.scope)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.scope, err)
+		}
+		tok, err := ts.Token()
+		if err != nil {
+			t.Fatalf("%s token: %v", tt.scope, err)
+		}
+		if tok.AccessToken != tt.want {
+			t.Fatalf("%s: got the %s token, want %s", tt.scope, tok.AccessToken, tt.want)
+		}
+	}
+
+	other := StoreProvider{Store: store, Email: "someone@example.com"}
+	if _, err := other.TokenSource(context.Background(), ScopeReadonly); !errors.Is(err, auth.ErrNoToken) {
+		t.Fatalf("expected ErrNoToken, got %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+const defaultPageSize = 100
+
+// Client serves gmail.Client reads from a Snapshot so commands can run offline.
+// Mutations are applied to the in-memory copy only and never leave the process.
+type Client struct {
+	mu       sync.Mutex
+	now      time.Time
+	labels   map[gmail.LabelID]string
+	messages map[gmail.MessageID]gmail.MessageMeta
+	order    []gmail.MessageID
+	created  int
+}
+
+// NewClient builds an offline client. Relative queries such as newer_than: are evaluated
+// against the snapshot's capture time so results stay stable as the file ages.
+func NewClient(snap Snapshot) *Client {
+	c := &Client{
+		now:      snap.CapturedAt,
+		labels:   make(map[gmail.LabelID]string, len(snap.Labels)),
+		messages: make(map[gmail.MessageID]gmail.MessageMeta, len(snap.Messages)),
+	}
+	if c.now.IsZero() {
+		c.now = time.Now()
+	}
+	for id, name := range snap.Labels {
+		c.labels[id] = name
+	}
+	for _, meta := range snap.Messages {
+		c.messages[meta.ID] = meta
+		c.order = append(c.order, meta.ID)
+	}
+	// Gmail lists newest first; ties fall back to ID for determinism.
+	sort.Slice(c.order, func(i, j int) bool {
+		a, b := c.messages[c.order[i]], c.messages[c.order[j]]
+		if a.Date.Equal(b.Date) {
+			return a.ID < b.ID
+		}
+		return a.Date.After(b.Date)
+	})
+	return c
+}
+
+// List evaluates the query and label filter client-side and paginates with numeric offsets.
+func (c *Client) List(
+	ctx context.Context,
+	q gmail.Query,
+	pageToken string,
+	pageSize int,
+) (gmail.ListPage, error) {
+	if err := ctx.Err(); err != nil {
+		return gmail.ListPage{}, fmt.Errorf("snapshot list: %w", err)
+	}
+	compiled, err := compileQuery(q.Raw)
+	if err != nil {
+		return gmail.ListPage{}, fmt.Errorf("snapshot query: %w", err)
+	}
+	offset := 0
+	if pageToken != "" {
+		offset, err = strconv.Atoi(pageToken)
+		if err != nil || offset < 0 {
+			return gmail.ListPage{}, fmt.Errorf("invalid snapshot page token %q", pageToken)
+		}
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	env := matchEnv{labels: c.labels, now: c.now}
+	var matched []gmail.MessageID
+	for _, id := range c.order {
+		meta := c.messages[id]
+		if hasAllLabels(meta.LabelIDs, q.LabelIDs) && compiled.match(meta, env) {
+			matched = append(matched, id)
+		}
+	}
+	if offset >= len(matched) {
+		return gmail.ListPage{}, nil
+	}
+	end := offset + pageSize
+	page := gmail.ListPage{}
+	if end < len(matched) {
+		page.NextPageToken = strconv.Itoa(end)
+	} else {
+		end = len(matched)
+	}
+	page.IDs = append([]gmail.MessageID(nil), matched[offset:end]...)
+	return page, nil
+}
+
+// GetMetadata returns the recorded message restricted to the requested headers.
+func (c *Client) GetMetadata(
+	ctx context.Context,
+	id gmail.MessageID,
+	headers []string,
+) (gmail.MessageMeta, error) {
+	if err := ctx.Err(); err != nil {
+		return gmail.MessageMeta{}, fmt.Errorf("snapshot get metadata: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, ok := c.messages[id]
+	if !ok {
+		return gmail.MessageMeta{}, fmt.Errorf("message %s not in snapshot: %w", id, gmail.ErrMessageNotFound)
+	}
+	return gmail.MessageMeta{
+		ID:       meta.ID,
+		ThreadID: meta.ThreadID,
+		LabelIDs: append([]gmail.LabelID(nil), meta.LabelIDs...),
+		Headers:  filterHeaders(meta.Headers, headers),
+		Date:     meta.Date,
+	}, nil
+}
+
+// GetLabels returns the recorded labels for a message.
+func (c *Client) GetLabels(ctx context.Context, id gmail.MessageID) ([]gmail.LabelID, error) {
+	meta, err := c.GetMetadata(ctx, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	return meta.LabelIDs, nil
+}
+
+// BatchModify applies label changes to the in-memory snapshot.
+func (c *Client) BatchModify(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("snapshot batch modify: %w", err)
+	}
+	remove := append([]gmail.LabelID(nil), ops.RemoveLabels...)
+	if ops.MarkRead {
+		remove = append(remove, "UNREAD")
+	}
+	if ops.Archive {
+		remove = append(remove, "INBOX")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		meta, ok := c.messages[id]
+		if !ok {
+			continue
+		}
+		meta.LabelIDs = modifyLabels(meta.LabelIDs, ops.AddLabels, remove)
+		c.messages[id] = meta
+	}
+	return nil
+}
+
+// ListLabels returns the recorded label map.
+func (c *Client) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, fmt.Errorf("snapshot list labels: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	byName := make(map[string]gmail.LabelID, len(c.labels))
+	byID := make(map[gmail.LabelID]string, len(c.labels))
+	for id, name := range c.labels {
+		byName[name] = id
+		byID[id] = name
+	}
+	return byName, byID, nil
+}
+
+// EnsureLabel returns an existing label or adds one to the in-memory snapshot.
+func (c *Client) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("snapshot ensure label: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, existing := range c.labels {
+		if existing == name {
+			return id, nil
+		}
+	}
+	c.created++
+	id := gmail.LabelID(fmt.Sprintf("Label_snapshot_%d", c.created))
+	c.labels[id] = name
+	return id, nil
+}
+
+// CapturedAt reports the moment the snapshot was taken.
+func (c *Client) CapturedAt() time.Time {
+	return c.now
+}
+
+func filterHeaders(all map[string]string, want []string) map[string]string {
+	if len(want) == 0 {
+		out := make(map[string]string, len(all))
+		for k, v := range all {
+			out[k] = v
+		}
+		return out
+	}
+	out := make(map[string]string, len(want))
+	for _, name := range want {
+		for k, v := range all {
+			if strings.EqualFold(k, name) {
+				out[k] = v
+			}
+		}
+	}
+	return out
+}
+
+// hasAllLabels mirrors the Gmail API's labelIds filter: a message must carry every requested label.
+func hasAllLabels(labels, want []gmail.LabelID) bool {
+	for _, w := range want {
+		found := false
+		for _, id := range labels {
+			if id == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func modifyLabels(labels, add, remove []gmail.LabelID) []gmail.LabelID {
+	drop := make(map[gmail.LabelID]struct{}, len(remove))
+	for _, id := range remove {
+		drop[id] = struct{}{}
+	}
+	out := make([]gmail.LabelID, 0, len(labels)+len(add))
+	seen := make(map[gmail.LabelID]struct{}, len(labels)+len(add))
+	for _, id := range append(append([]gmail.LabelID(nil), labels...), add...) {
+		if _, skip := drop[id]; skip {
+			continue
+		}
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	return out
+}
+
+var (
+	_ gmail.Client      = (*Client)(nil)
+	_ gmail.LabelReader = (*Client)(nil)
+)
@@ -0,0 +1,191 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+func testSnapshot() Snapshot {
+	now := time.Unix(1700000000, 0).UTC()
+	return Snapshot{
+		CapturedAt: now,
+		Labels: map[gmail.LabelID]string{
+			"INBOX":       "INBOX",
+			"Label_fin":   "Finance/Bills",
+			"Label_alert": "monitoring alerts",
+		},
+		Messages: []gmail.MessageMeta{
+			{
+				ID:       "old",
+				LabelIDs: []gmail.LabelID{"INBOX", "UNREAD", "Label_fin"},
+				Headers:  map[string]string{"From": "billing@bank.example", "Subject": "Statement"},
+				Date:     now.Add(-72 * time.Hour),
+			},
+			{
+				ID:       "starred",
+				ThreadID: "18c0ffee",
+				LabelIDs: []gmail.LabelID{"INBOX", "UNREAD", "STARRED"},
+				Headers:  map[string]string{"From": "friend@example.com", "Subject": "Hi"},
+				Date:     now.Add(-96 * time.Hour),
+			},
+			{
+				ID:       "fresh",
+				LabelIDs: []gmail.LabelID{"INBOX", "UNREAD", "Label_alert"},
+				Headers:  map[string]string{"From": "alerts@example.com", "List-Id": "<alerts.example.com>"},
+				Date:     now.Add(-time.Hour),
+			},
+			{
+				ID:       "spam",
+				LabelIDs: []gmail.LabelID{"SPAM"},
+				Headers:  map[string]string{"From": "spam@example.net"},
+				Date:     now.Add(-2 * time.Hour),
+			},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testSnapshot()); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !got.CapturedAt.Equal(testSnapshot().CapturedAt) {
+		t.Fatalf("captured at mismatch: %v", got.CapturedAt)
+	}
+	if len(got.Messages) != 4 || got.Labels["Label_fin"] != "Finance/Bills" {
+		t.Fatalf("unexpected snapshot: %+v", got)
+	}
+	for _, meta := range got.Messages {
+		if meta.ID == "starred" && meta.ThreadID != "18c0ffee" {
+			t.Fatalf("thread id lost in round trip: %+v", meta)
+		}
+	}
+}
+
+func TestListEvaluatesQueries(t *testing.T) {
+	before := time.Unix(1700000000, 0).Add(-48 * time.Hour).Unix()
+	tests := []struct {
+		name   string
+		query  string
+		labels []gmail.LabelID
+		want   []gmail.MessageID
+	}{
+		{name: "all", query: "", want: []gmail.MessageID{"fresh", "old", "starred"}},
+		{name: "newer-than", query: "newer_than:1d", want: []gmail.MessageID{"fresh"}},
+		{
+			name:  "sweep",
+			query: "in:inbox is:unread before:" + strconv.FormatInt(before, 10) + " -is:starred -is:important",
+			want:  []gmail.MessageID{"old"},
+		},
+		{name: "label-normalized", query: `label:finance-bills`, want: []gmail.MessageID{"old"}},
+		{name: "label-quoted", query: `label:"monitoring alerts"`, want: []gmail.MessageID{"fresh"}},
+		{name: "exclude-label", query: `in:inbox -label:"Finance/Bills"`, want: []gmail.MessageID{"fresh", "starred"}},
+		{name: "or-group", query: `(from:bank.example OR from:friend)`, want: []gmail.MessageID{"old", "starred"}},
+		{name: "anywhere", query: "in:anywhere newer_than:1d", want: []gmail.MessageID{"fresh", "spam"}},
+		{name: "label-ids", labels: []gmail.LabelID{"INBOX", "Label_fin"}, want: []gmail.MessageID{"old"}},
+		{
+			name:   "label-ids-and-query",
+			query:  "is:unread",
+			labels: []gmail.LabelID{"STARRED"},
+			want:   []gmail.MessageID{"starred"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(testSnapshot())
+			page, err := client.List(context.Background(), gmail.Query{Raw: tt.query, LabelIDs: tt.labels}, "", 10)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if len(page.IDs) != len(tt.want) {
+				t.Fatalf("ids mismatch: got %v want %v", page.IDs, tt.want)
+			}
+			for i := range tt.want {
+				if page.IDs[i] != tt.want[i] {
+					t.Fatalf("ids mismatch: got %v want %v", page.IDs, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestListMapsLocations(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	client := NewClient(Snapshot{
+		CapturedAt: now,
+		Labels:     map[gmail.LabelID]string{"Label_fin": "Finance/Bills"},
+		Messages: []gmail.MessageMeta{
+			{ID: "draft", LabelIDs: []gmail.LabelID{"DRAFT"}, Date: now.Add(-time.Hour)},
+			{ID: "chat", LabelIDs: []gmail.LabelID{"CHAT"}, Date: now.Add(-time.Hour)},
+			{ID: "bill", LabelIDs: []gmail.LabelID{"Label_fin", "IMPORTANT"}, Date: now.Add(-time.Hour)},
+		},
+	})
+	tests := map[string]gmail.MessageID{
+		"in:drafts":        "draft",
+		"in:chats":         "chat",
+		"in:finance-bills": "bill",
+		"in:important":     "bill",
+	}
+	for query, want := range tests {
+		page, err := client.List(context.Background(), gmail.Query{Raw: query}, "", 10)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if len(page.IDs) != 1 || page.IDs[0] != want {
+			t.Fatalf("%s: got %v want [%s]", query, page.IDs, want)
+		}
+	}
+}
+
+func TestListRejectsUnsupportedOperators(t *testing.T) {
+	client := NewClient(testSnapshot())
+	if _, err := client.List(context.Background(), gmail.Query{Raw: "has:attachment"}, "", 10); err == nil {
+		t.Fatalf("expected unsupported operator error")
+	}
+}
+
+func TestListPaginates(t *testing.T) {
+	client := NewClient(testSnapshot())
+	first, err := client.List(context.Background(), gmail.Query{Raw: "in:inbox"}, "", 2)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(first.IDs) != 2 || first.NextPageToken == "" {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+	second, err := client.List(context.Background(), gmail.Query{Raw: "in:inbox"}, first.NextPageToken, 2)
+	if err != nil {
+		t.Fatalf("list second: %v", err)
+	}
+	if len(second.IDs) != 1 || second.NextPageToken != "" {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+}
+
+func TestRecorderCapturesReads(t *testing.T) {
+	rec := NewRecorder(NewClient(testSnapshot()))
+	ctx := context.Background()
+	if _, _, err := rec.ListLabels(ctx); err != nil {
+		t.Fatalf("list labels: %v", err)
+	}
+	if _, err := rec.GetMetadata(ctx, "fresh", []string{"From"}); err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	snap := rec.Snapshot(time.Unix(1700000000, 0))
+	if len(snap.Messages) != 1 || snap.Messages[0].Headers["From"] != "alerts@example.com" {
+		t.Fatalf("unexpected recorded messages: %+v", snap.Messages)
+	}
+	if len(snap.Labels) != 3 {
+		t.Fatalf("unexpected recorded labels: %+v", snap.Labels)
+	}
+}
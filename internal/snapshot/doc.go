@@ -0,0 +1,2 @@
+// Package snapshot records Gmail metadata to JSONL files and serves them back as an offline gmail.Client.
+package snapshot
@@ -0,0 +1,383 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+const (
+	day   = 24 * time.Hour
+	month = 30 * day
+	year  = 365 * day
+)
+
+// matchEnv carries the context a predicate needs to evaluate a message.
+type matchEnv struct {
+	labels map[gmail.LabelID]string
+	now    time.Time
+}
+
+// predicate is a compiled piece of a Gmail search query.
+type predicate interface {
+	match(meta gmail.MessageMeta, env matchEnv) bool
+}
+
+type andPred []predicate
+
+func (a andPred) match(meta gmail.MessageMeta, env matchEnv) bool {
+	for _, p := range a {
+		if !p.match(meta, env) {
+			return false
+		}
+	}
+	return true
+}
+
+type orPred []predicate
+
+func (o orPred) match(meta gmail.MessageMeta, env matchEnv) bool {
+	for _, p := range o {
+		if p.match(meta, env) {
+			return true
+		}
+	}
+	return false
+}
+
+type notPred struct{ inner predicate }
+
+func (n notPred) match(meta gmail.MessageMeta, env matchEnv) bool {
+	return !n.inner.match(meta, env)
+}
+
+type termPred struct {
+	op    string
+	value string
+	when  time.Time
+	dur   time.Duration
+}
+
+// compiledQuery is the evaluator for the subset of Gmail search syntax chronosweep emits.
+type compiledQuery struct {
+	root predicate
+	// includeSpamTrash mirrors Gmail, which hides SPAM and TRASH unless the query names them.
+	includeSpamTrash bool
+}
+
+func (q compiledQuery) match(meta gmail.MessageMeta, env matchEnv) bool {
+	if !q.includeSpamTrash && (hasLabel(meta, "SPAM") || hasLabel(meta, "TRASH")) {
+		return false
+	}
+	if q.root == nil {
+		return true
+	}
+	return q.root.match(meta, env)
+}
+
+// compileQuery parses raw into an evaluator, rejecting operators it cannot simulate faithfully.
+func compileQuery(raw string) (compiledQuery, error) {
+	tokens, err := tokenize(raw)
+	if err != nil {
+		return compiledQuery{}, err
+	}
+	p := &parser{tokens: tokens}
+	if len(tokens) == 0 {
+		return compiledQuery{}, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return compiledQuery{}, err
+	}
+	if p.pos != len(p.tokens) {
+		return compiledQuery{}, fmt.Errorf("unexpected %q in query %q", p.tokens[p.pos], raw)
+	}
+	return compiledQuery{root: root, includeSpamTrash: p.spamTrash}, nil
+}
+
+func tokenize(raw string) ([]string, error) {
+	var (
+		tokens  []string
+		current strings.Builder
+		quoted  bool
+		escaped bool
+	)
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range raw {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case quoted:
+			current.WriteRune(r)
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted || escaped {
+		return nil, fmt.Errorf("unterminated quote in query %q", raw)
+	}
+	flush()
+	return tokens, nil
+}
+
+type parser struct {
+	tokens    []string
+	pos       int
+	spamTrash bool
+}
+
+func (p *parser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) parseOr() (predicate, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	alts := orPred{first}
+	for p.peek() == "OR" {
+		p.pos++
+		next, nextErr := p.parseAnd()
+		if nextErr != nil {
+			return nil, nextErr
+		}
+		alts = append(alts, next)
+	}
+	if len(alts) == 1 {
+		return first, nil
+	}
+	return alts, nil
+}
+
+func (p *parser) parseAnd() (predicate, error) {
+	var terms andPred
+	for {
+		tok := p.peek()
+		if tok == "" || tok == ")" || tok == "OR" {
+			break
+		}
+		term, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 0 {
+		return nil, errors.New("empty query group")
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *parser) parseUnary() (predicate, error) {
+	tok := p.peek()
+	switch {
+	case tok == "-":
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notPred{inner: inner}, nil
+	case tok == "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("unbalanced parentheses in query")
+		}
+		p.pos++
+		return inner, nil
+	case strings.HasPrefix(tok, "-") && len(tok) > 1:
+		p.tokens[p.pos] = tok[1:]
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notPred{inner: inner}, nil
+	default:
+		p.pos++
+		return p.parseTerm(tok)
+	}
+}
+
+func (p *parser) parseTerm(tok string) (predicate, error) {
+	op, value, ok := strings.Cut(tok, ":")
+	if !ok {
+		return nil, fmt.Errorf("unsupported free-text query term %q", tok)
+	}
+	op = strings.ToLower(op)
+	value = unquote(value)
+	term := termPred{op: op, value: strings.ToLower(value)}
+	switch op {
+	case "in":
+		if term.value == "spam" || term.value == "trash" || term.value == "anywhere" {
+			p.spamTrash = true
+		}
+	case "is", "label", "category", "from", "to", "subject", "list":
+	case "before", "after":
+		when, err := parseDate(value)
+		if err != nil {
+			return nil, err
+		}
+		term.when = when
+	case "newer_than", "older_than":
+		dur, err := parseRelative(value)
+		if err != nil {
+			return nil, err
+		}
+		term.dur = dur
+	default:
+		return nil, fmt.Errorf("unsupported query operator %q", op)
+	}
+	if term.value == "" {
+		return nil, fmt.Errorf("empty value for %s:", op)
+	}
+	return term, nil
+}
+
+func (t termPred) match(meta gmail.MessageMeta, env matchEnv) bool {
+	switch t.op {
+	case "in":
+		if t.value == "anywhere" {
+			return true
+		}
+		if id, ok := gmail.LocationLabel(t.value); ok {
+			return hasLabel(meta, id)
+		}
+		// Gmail also accepts in: with a label name.
+		return matchLabelName(meta, env.labels, t.value)
+	case "is":
+		return matchIs(meta, t.value)
+	case "label":
+		return matchLabelName(meta, env.labels, t.value)
+	case "category":
+		return hasLabel(meta, gmail.LabelID("CATEGORY_"+strings.ToUpper(t.value)))
+	case "from":
+		return strings.Contains(strings.ToLower(meta.Headers["From"]), t.value)
+	case "to":
+		return strings.Contains(strings.ToLower(meta.Headers["To"]), t.value)
+	case "subject":
+		return strings.Contains(strings.ToLower(meta.Headers["Subject"]), t.value)
+	case "list":
+		return strings.Contains(strings.ToLower(meta.Headers["List-Id"]), t.value)
+	case "before":
+		return meta.Date.Before(t.when)
+	case "after":
+		return meta.Date.After(t.when)
+	case "newer_than":
+		return meta.Date.After(env.now.Add(-t.dur))
+	case "older_than":
+		return meta.Date.Before(env.now.Add(-t.dur))
+	default:
+		return false
+	}
+}
+
+func matchIs(meta gmail.MessageMeta, value string) bool {
+	switch value {
+	case "unread":
+		return hasLabel(meta, "UNREAD")
+	case "read":
+		return !hasLabel(meta, "UNREAD")
+	case "starred":
+		return hasLabel(meta, "STARRED")
+	case "important":
+		return hasLabel(meta, "IMPORTANT")
+	default:
+		return hasLabel(meta, gmail.LabelID(strings.ToUpper(value)))
+	}
+}
+
+func matchLabelName(meta gmail.MessageMeta, labels map[gmail.LabelID]string, want string) bool {
+	want = gmail.NormalizeLabel(want)
+	for _, id := range meta.LabelIDs {
+		if gmail.NormalizeLabel(string(id)) == want {
+			return true
+		}
+		if name, ok := labels[id]; ok && gmail.NormalizeLabel(name) == want {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLabel(meta gmail.MessageMeta, want gmail.LabelID) bool {
+	for _, id := range meta.LabelIDs {
+		if id == want {
+			return true
+		}
+	}
+	return false
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func parseDate(value string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	for _, layout := range []string{"2006/01/02", "2006/1/2", "2006-01-02"} {
+		if when, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return when, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported date %q", value)
+}
+
+func parseRelative(value string) (time.Duration, error) {
+	if len(value) < 2 {
+		return 0, fmt.Errorf("invalid relative age %q", value)
+	}
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid relative age %q", value)
+	}
+	var unit time.Duration
+	switch value[len(value)-1] {
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = day
+	case 'm':
+		unit = month
+	case 'y':
+		unit = year
+	default:
+		return 0, fmt.Errorf("invalid relative age unit in %q", value)
+	}
+	return time.Duration(n) * unit, nil
+}
@@ -0,0 +1,126 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// Recorder decorates a gmail.Client and remembers every message and label map it returns.
+type Recorder struct {
+	inner    gmail.Client
+	mu       sync.Mutex
+	labels   map[gmail.LabelID]string
+	messages map[gmail.MessageID]gmail.MessageMeta
+}
+
+// NewRecorder wraps inner so its reads can later be dumped as a Snapshot.
+func NewRecorder(inner gmail.Client) *Recorder {
+	return &Recorder{
+		inner:    inner,
+		labels:   map[gmail.LabelID]string{},
+		messages: map[gmail.MessageID]gmail.MessageMeta{},
+	}
+}
+
+// List delegates to the wrapped client.
+func (r *Recorder) List(
+	ctx context.Context,
+	q gmail.Query,
+	pageToken string,
+	pageSize int,
+) (gmail.ListPage, error) {
+	page, err := r.inner.List(ctx, q, pageToken, pageSize)
+	if err != nil {
+		return gmail.ListPage{}, fmt.Errorf("recorder list: %w", err)
+	}
+	return page, nil
+}
+
+// GetMetadata delegates to the wrapped client and records the result.
+func (r *Recorder) GetMetadata(
+	ctx context.Context,
+	id gmail.MessageID,
+	headers []string,
+) (gmail.MessageMeta, error) {
+	meta, err := r.inner.GetMetadata(ctx, id, headers)
+	if err != nil {
+		return gmail.MessageMeta{}, fmt.Errorf("recorder get metadata: %w", err)
+	}
+	r.record(meta)
+	return meta, nil
+}
+
+// PeekMetadata forwards to the wrapped client when it can serve requests locally.
+func (r *Recorder) PeekMetadata(id gmail.MessageID, headers []string) (gmail.MessageMeta, bool) {
+	peeker, ok := r.inner.(gmail.MetadataPeeker)
+	if !ok {
+		return gmail.MessageMeta{}, false
+	}
+	meta, hit := peeker.PeekMetadata(id, headers)
+	if hit {
+		r.record(meta)
+	}
+	return meta, hit
+}
+
+// BatchModify delegates to the wrapped client.
+func (r *Recorder) BatchModify(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	if err := r.inner.BatchModify(ctx, ids, ops); err != nil {
+		return fmt.Errorf("recorder batch modify: %w", err)
+	}
+	return nil
+}
+
+// ListLabels delegates to the wrapped client and records the label map.
+func (r *Recorder) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	byName, byID, err := r.inner.ListLabels(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("recorder list labels: %w", err)
+	}
+	r.mu.Lock()
+	for id, name := range byID {
+		r.labels[id] = name
+	}
+	r.mu.Unlock()
+	return byName, byID, nil
+}
+
+// EnsureLabel delegates to the wrapped client.
+func (r *Recorder) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
+	id, err := r.inner.EnsureLabel(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("recorder ensure label: %w", err)
+	}
+	return id, nil
+}
+
+// Snapshot returns everything recorded so far, stamped with capturedAt.
+func (r *Recorder) Snapshot(capturedAt time.Time) Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	labels := make(map[gmail.LabelID]string, len(r.labels))
+	for id, name := range r.labels {
+		labels[id] = name
+	}
+	msgs := make([]gmail.MessageMeta, 0, len(r.messages))
+	for _, meta := range r.messages {
+		msgs = append(msgs, meta)
+	}
+	return Snapshot{CapturedAt: capturedAt, Labels: labels, Messages: msgs}
+}
+
+func (r *Recorder) record(meta gmail.MessageMeta) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	meta.LabelsStale = false
+	r.messages[meta.ID] = meta
+}
+
+var (
+	_ gmail.Client         = (*Recorder)(nil)
+	_ gmail.MetadataPeeker = (*Recorder)(nil)
+)
@@ -0,0 +1,154 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+const (
+	kindHeader  = "header"
+	kindMessage = "message"
+	maxLineSize = 4 << 20
+)
+
+// Snapshot is a frozen copy of mailbox metadata.
+type Snapshot struct {
+	CapturedAt time.Time
+	Labels     map[gmail.LabelID]string
+	Messages   []gmail.MessageMeta
+}
+
+// record is one JSONL line: a single header record followed by one record per message.
+type record struct {
+	Kind       string                   `json:"kind"`
+	CapturedAt time.Time                `json:"captured_at,omitzero"`
+	Labels     map[gmail.LabelID]string `json:"labels,omitempty"`
+	ID         gmail.MessageID          `json:"id,omitempty"`
+	ThreadID   string                   `json:"thread_id,omitempty"`
+	LabelIDs   []gmail.LabelID          `json:"label_ids,omitempty"`
+	Headers    map[string]string        `json:"headers,omitempty"`
+	Date       time.Time                `json:"date,omitzero"`
+}
+
+// Write encodes the snapshot as JSONL with messages ordered by ID.
+func Write(w io.Writer, snap Snapshot) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	header := record{Kind: kindHeader, CapturedAt: snap.CapturedAt.UTC(), Labels: snap.Labels}
+	if err := enc.Encode(header); err != nil {
+		return fmt.Errorf("encode snapshot header: %w", err)
+	}
+	msgs := append([]gmail.MessageMeta(nil), snap.Messages...)
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+	for _, meta := range msgs {
+		rec := record{
+			Kind:     kindMessage,
+			ID:       meta.ID,
+			ThreadID: meta.ThreadID,
+			LabelIDs: meta.LabelIDs,
+			Headers:  meta.Headers,
+			Date:     meta.Date.UTC(),
+		}
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("encode snapshot message %s: %w", meta.ID, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("flush snapshot: %w", err)
+	}
+	return nil
+}
+
+// WriteFile writes the snapshot to path with owner-only permissions.
+func WriteFile(path string, snap Snapshot) error {
+	clean := filepath.Clean(path)
+	f, err := os.OpenFile(clean, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("create snapshot %s: %w", clean, err)
+	}
+	if writeErr := Write(f, snap); writeErr != nil {
+		_ = f.Close()
+		return writeErr
+	}
+	if closeErr := f.Close(); closeErr != nil {
+		return fmt.Errorf("close snapshot %s: %w", clean, closeErr)
+	}
+	return nil
+}
+
+// Read decodes a JSONL snapshot.
+func Read(r io.Reader) (Snapshot, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+	var (
+		snap       Snapshot
+		sawHeader  bool
+		lineNumber int
+	)
+	for scanner.Scan() {
+		lineNumber++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return Snapshot{}, fmt.Errorf("decode snapshot line %d: %w", lineNumber, err)
+		}
+		switch rec.Kind {
+		case kindHeader:
+			if sawHeader {
+				return Snapshot{}, fmt.Errorf("snapshot line %d: duplicate header", lineNumber)
+			}
+			sawHeader = true
+			snap.CapturedAt = rec.CapturedAt
+			snap.Labels = rec.Labels
+		case kindMessage:
+			if rec.ID == "" {
+				return Snapshot{}, fmt.Errorf("snapshot line %d: message without id", lineNumber)
+			}
+			snap.Messages = append(snap.Messages, gmail.MessageMeta{
+				ID:       rec.ID,
+				ThreadID: rec.ThreadID,
+				LabelIDs: rec.LabelIDs,
+				Headers:  rec.Headers,
+				Date:     rec.Date,
+			})
+		default:
+			return Snapshot{}, fmt.Errorf("snapshot line %d: unknown record kind %q", lineNumber, rec.Kind)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Snapshot{}, fmt.Errorf("read snapshot: %w", err)
+	}
+	if !sawHeader {
+		return Snapshot{}, errors.New("snapshot is missing its header record")
+	}
+	if snap.Labels == nil {
+		snap.Labels = map[gmail.LabelID]string{}
+	}
+	return snap, nil
+}
+
+// Load reads a snapshot file from disk.
+func Load(path string) (Snapshot, error) {
+	clean := filepath.Clean(path)
+	f, err := os.Open(clean)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("open snapshot %s: %w", clean, err)
+	}
+	defer func() { _ = f.Close() }()
+	snap, err := Read(f)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("load %s: %w", clean, err)
+	}
+	return snap, nil
+}
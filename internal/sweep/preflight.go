@@ -0,0 +1,150 @@
+package sweep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+const (
+	maxSuggestions = 3
+	// suggestDivisor scales the edit distance tolerated for a suggestion with the length of the name.
+	suggestDivisor = 3
+	minSuggestDist = 2
+)
+
+// ErrUnknownLabels is returned when the spec names labels the mailbox does not have. A misspelled
+// exclusion would otherwise match nothing and let the mail it was meant to protect be swept.
+var ErrUnknownLabels = errors.New("unknown labels")
+
+// preflight resolves every label the spec names (the swept label, exclusions and grace overrides)
+// before anything is listed or modified, and returns the mailbox's labels by name for later phases.
+func (s *Service) preflight(ctx context.Context, spec Spec) (map[string]gmail.LabelID, error) {
+	if err := s.wait(ctx, "rate limit list labels"); err != nil {
+		return nil, err
+	}
+	byName, _, err := s.Client.ListLabels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list labels for preflight: %w", err)
+	}
+	known := make(map[string]struct{}, len(byName))
+	for name := range byName {
+		known[gmail.NormalizeLabel(name)] = struct{}{}
+	}
+	allowed := make(map[string]struct{}, len(spec.AllowMissingLabels))
+	for _, name := range spec.AllowMissingLabels {
+		allowed[gmail.NormalizeLabel(name)] = struct{}{}
+	}
+
+	var problems []string
+	for _, name := range referencedLabels(spec) {
+		want := gmail.NormalizeLabel(name)
+		if _, ok := known[want]; ok {
+			continue
+		}
+		if _, ok := allowed[want]; ok {
+			s.Logger.InfoContext(ctx, "label not found; allowed missing", slog.String("label", name))
+			continue
+		}
+		problem := fmt.Sprintf("%q", name)
+		if hints := suggestLabels(name, byName); len(hints) > 0 {
+			problem += " (did you mean " + strings.Join(hints, ", ") + "?)"
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) > 0 {
+		return nil, gmail.Classify(gmail.ClassInvalidConfig, fmt.Errorf(
+			"%w: %s; fix the names or list labels that do not exist yet in -allow-missing-labels",
+			ErrUnknownLabels,
+			strings.Join(problems, ", "),
+		))
+	}
+	return byName, nil
+}
+
+// referencedLabels lists the distinct label names spec refers to, in a stable order.
+func referencedLabels(spec Spec) []string {
+	seen := map[string]struct{}{}
+	var names []string
+	add := func(name string) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return
+		}
+		if _, ok := seen[name]; ok {
+			return
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	add(spec.Label)
+	for _, name := range spec.ExcludeLabels {
+		add(name)
+	}
+	overrides := make([]string, 0, len(spec.GraceOverrides))
+	for name := range spec.GraceOverrides {
+		overrides = append(overrides, name)
+	}
+	sort.Strings(overrides)
+	for _, name := range overrides {
+		add(name)
+	}
+	return names
+}
+
+// suggestLabels returns up to maxSuggestions existing label names closest to name by edit distance.
+func suggestLabels(name string, byName map[string]gmail.LabelID) []string {
+	want := gmail.NormalizeLabel(name)
+	limit := max(len(want)/suggestDivisor, minSuggestDist)
+	type candidate struct {
+		name string
+		dist int
+	}
+	var candidates []candidate
+	for existing := range byName {
+		if dist := editDistance(want, gmail.NormalizeLabel(existing)); dist <= limit {
+			candidates = append(candidates, candidate{name: existing, dist: dist})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].dist != candidates[j].dist {
+			return candidates[i].dist < candidates[j].dist
+		}
+		return candidates[i].name < candidates[j].name
+	})
+	hints := make([]string, 0, maxSuggestions)
+	for _, c := range candidates {
+		if len(hints) == maxSuggestions {
+			break
+		}
+		hints = append(hints, fmt.Sprintf("%q", c.name))
+	}
+	return hints
+}
+
+// editDistance is the Levenshtein distance between a and b, counted in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -2,13 +2,19 @@ package sweep
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sort"
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/telemetry"
 )
 
 // Limiter is the minimal rate limiter interface the service needs.
@@ -33,6 +39,11 @@ type Spec struct {
 	ExcludeLabels  []string
 	ExpiredLabel   string
 	PageSize       int
+	// Workers is how many candidates have their labels re-read at once before a batch is modified.
+	Workers int
+	// AllowMissingLabels names labels that may be absent from the mailbox (e.g. not created yet);
+	// any other unknown label fails preflight with ErrUnknownLabels.
+	AllowMissingLabels []string
 }
 
 // Service sweeps stale messages out of the inbox while labeling them for safety.
@@ -41,20 +52,33 @@ type Service struct {
 	Limiter Limiter
 	Logger  *slog.Logger
 	Clock   func() time.Time
+	Tracer  trace.Tracer
 }
 
-// NewService constructs a sweeper with injected dependencies.
+// NewService constructs a sweeper with injected dependencies. Spans go to the global tracer provider.
 func NewService(client gmail.Client, limiter Limiter, logger *slog.Logger) *Service {
 	return &Service{
 		Client:  client,
 		Limiter: limiter,
 		Logger:  logger,
 		Clock:   time.Now,
+		Tracer:  otel.Tracer(telemetry.TracerName),
 	}
 }
 
-// Run executes the sweep according to spec.
+// Run executes the sweep according to spec, recorded as one "sweep.policy" span.
 func (s *Service) Run(ctx context.Context, spec Spec) error {
+	ctx, span := s.Tracer.Start(ctx, "sweep.policy", trace.WithAttributes(
+		attribute.String("label", spec.Label),
+		attribute.Bool("dry_run", spec.DryRun),
+	))
+	defer span.End()
+	err := s.run(ctx, spec)
+	telemetry.RecordError(span, err)
+	return err
+}
+
+func (s *Service) run(ctx context.Context, spec Spec) error {
 	if err := validateSpec(spec); err != nil {
 		return err
 	}
@@ -78,19 +102,22 @@ func (s *Service) Run(ctx context.Context, spec Spec) error {
 		return nil
 	}
 
+	labelsByName, err := s.preflight(ctx, spec)
+	if err != nil {
+		return err
+	}
+
 	grace := s.effectiveGrace(spec)
 	pageSize := normalizePageSize(spec.PageSize)
-	query := gmail.Query{
-		Raw: strings.Join(
-			buildQueryParts(spec.Label, spec.ExcludeLabels, s.Clock().Add(-grace).Unix()),
-			" ",
-		),
-	}
+	query := buildQuery(spec.Label, spec.ExcludeLabels, s.Clock().Add(-grace), labelsByName)
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("grace", grace.String()))
 
 	ids, err := s.collectMessageIDs(ctx, query, pageSize)
 	if err != nil {
 		return err
 	}
+	span.SetAttributes(attribute.Int("candidates", len(ids)))
 	if len(ids) == 0 {
 		logger.InfoContext(
 			ctx,
@@ -101,15 +128,9 @@ func (s *Service) Run(ctx context.Context, spec Spec) error {
 		return nil
 	}
 
+	verify := s.newVerifier(spec.ExcludeLabels, labelsByName, spec.Workers)
 	if spec.DryRun {
-		logger.InfoContext(
-			ctx,
-			"dry-run sweep",
-			slog.String("label", spec.Label),
-			slog.Int("count", len(ids)),
-			slog.Duration("grace", grace),
-		)
-		return nil
+		return s.dryRun(ctx, spec, ids, verify, grace)
 	}
 
 	expiredLabel := spec.ExpiredLabel
@@ -126,7 +147,10 @@ func (s *Service) Run(ctx context.Context, spec Spec) error {
 		MarkRead:  true,
 		Archive:   true,
 	}
-	if applyErr := s.applyBatches(ctx, ids, ops); applyErr != nil {
+	swept, applyErr := s.applyBatches(ctx, ids, ops, verify)
+	s.logRejected(ctx, spec, verify.rejected)
+	span.SetAttributes(attribute.Int("swept", swept), attribute.Int("rejected", verify.rejected))
+	if applyErr != nil {
 		return applyErr
 	}
 
@@ -134,7 +158,34 @@ func (s *Service) Run(ctx context.Context, spec Spec) error {
 		ctx,
 		"sweep complete",
 		slog.String("label", spec.Label),
-		slog.Int("count", len(ids)),
+		slog.Int("count", swept),
+		slog.Duration("grace", grace),
+	)
+	return nil
+}
+
+// dryRun verifies the candidates and reports how many a real run would sweep, without modifying anything.
+func (s *Service) dryRun(
+	ctx context.Context,
+	spec Spec,
+	ids []gmail.MessageID,
+	verify *verifier,
+	grace time.Duration,
+) error {
+	kept, err := verify.filter(ctx, ids)
+	if err != nil {
+		return err
+	}
+	s.logRejected(ctx, spec, verify.rejected)
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("would_sweep", len(kept)),
+		attribute.Int("rejected", verify.rejected),
+	)
+	s.Logger.InfoContext(
+		ctx,
+		"dry-run sweep",
+		slog.String("label", spec.Label),
+		slog.Int("count", len(kept)),
 		slog.Duration("grace", grace),
 	)
 	return nil
@@ -148,16 +199,11 @@ func (s *Service) collectMessageIDs(
 	var (
 		ids   []gmail.MessageID
 		token string
-		page  int
 	)
-	for {
-		page++
-		if err := s.wait(ctx, "rate limit list messages"); err != nil {
-			return nil, err
-		}
-		resp, err := s.Client.List(ctx, query, token, pageSize)
+	for page := 1; ; page++ {
+		resp, err := s.listPage(ctx, query, token, pageSize, page)
 		if err != nil {
-			return nil, fmt.Errorf("list page %d: %w", page, err)
+			return nil, err
 		}
 		ids = append(ids, resp.IDs...)
 		if resp.NextPageToken == "" {
@@ -168,24 +214,97 @@ func (s *Service) collectMessageIDs(
 	return ids, nil
 }
 
+func (s *Service) listPage(
+	ctx context.Context,
+	query gmail.Query,
+	token string,
+	pageSize int,
+	page int,
+) (gmail.ListPage, error) {
+	ctx, span := s.Tracer.Start(ctx, "sweep.list_page", trace.WithAttributes(attribute.Int("page", page)))
+	defer span.End()
+	if err := s.wait(ctx, "rate limit list messages"); err != nil {
+		telemetry.RecordError(span, err)
+		return gmail.ListPage{}, err
+	}
+	resp, err := s.Client.List(ctx, query, token, pageSize)
+	if err != nil {
+		err = fmt.Errorf("list page %d: %w", page, err)
+		telemetry.RecordError(span, err)
+		return gmail.ListPage{}, err
+	}
+	span.SetAttributes(attribute.Int("messages", len(resp.IDs)))
+	return resp, nil
+}
+
+// applyBatches verifies each batch immediately before modifying it, keeping the window between the label
+// check and the mutation small, and returns how many messages were modified.
 func (s *Service) applyBatches(
 	ctx context.Context,
 	ids []gmail.MessageID,
 	ops gmail.ModifyOps,
-) error {
+	verify *verifier,
+) (int, error) {
+	swept := 0
 	for start := 0; start < len(ids); start += batchSize {
 		end := start + batchSize
 		if end > len(ids) {
 			end = len(ids)
 		}
-		if err := s.wait(ctx, "rate limit batch modify"); err != nil {
-			return err
-		}
-		if err := s.Client.BatchModify(ctx, ids[start:end], ops); err != nil {
-			return fmt.Errorf("batch modify %d-%d: %w", start, end, err)
+		n, err := s.applyBatch(ctx, ids[start:end], ops, verify, start/batchSize+1)
+		swept += n
+		if err != nil {
+			return swept, fmt.Errorf("batch %d-%d: %w", start, end, err)
 		}
 	}
-	return nil
+	return swept, nil
+}
+
+func (s *Service) applyBatch(
+	ctx context.Context,
+	ids []gmail.MessageID,
+	ops gmail.ModifyOps,
+	verify *verifier,
+	number int,
+) (int, error) {
+	ctx, span := s.Tracer.Start(ctx, "sweep.batch", trace.WithAttributes(
+		attribute.Int("batch", number),
+		attribute.Int("candidates", len(ids)),
+	))
+	defer span.End()
+	batch, err := verify.filter(ctx, ids)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int("messages", len(batch)))
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := s.wait(ctx, "rate limit batch modify"); err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	if err := s.Client.BatchModify(ctx, batch, ops); err != nil {
+		err = fmt.Errorf("batch modify: %w", err)
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	return len(batch), nil
+}
+
+// logRejected reports messages the search returned but verification refused to sweep. A non-zero count
+// means the query and the mailbox disagree: a query bug, or the user acting on mail mid-run.
+func (s *Service) logRejected(ctx context.Context, spec Spec, rejected int) {
+	if rejected == 0 {
+		return
+	}
+	s.Logger.WarnContext(
+		ctx,
+		"verification rejected messages the query matched",
+		slog.String("label", spec.Label),
+		slog.Int("rejected", rejected),
+	)
 }
 
 // ParseGraceMap converts CLI input into per-label durations.
@@ -222,16 +341,24 @@ func ParseGraceMap(input string) (map[string]time.Duration, error) {
 	return result, nil
 }
 
-func buildQueryParts(label string, exclude []string, before int64) []string {
-	parts := []string{
-		"in:inbox",
-		"is:unread",
-		fmt.Sprintf("before:%d", before),
-		"-is:starred",
-		"-is:important",
+// buildQuery selects the swept label by the ID preflight resolved, so Gmail never has to match its name;
+// a label allowed to be missing has no ID and falls back to a label: term. Exclusions stay label: terms
+// because messages.list can only require labels, not exclude them; the verifier re-checks them by ID.
+func buildQuery(label string, exclude []string, before time.Time, byName map[string]gmail.LabelID) gmail.Query {
+	terms := []gmail.Term{
+		gmail.In("inbox"),
+		gmail.Is("unread"),
+		gmail.Before(before),
+		gmail.Not(gmail.Is("starred")),
+		gmail.Not(gmail.Is("important")),
 	}
+	var labelIDs []gmail.LabelID
 	if label != "" {
-		parts = append([]string{fmt.Sprintf(`label:"%s"`, label)}, parts...)
+		if id, ok := lookupLabel(label, byName); ok {
+			labelIDs = []gmail.LabelID{id}
+		} else {
+			terms = append([]gmail.Term{gmail.Label(label)}, terms...)
+		}
 	}
 	sorted := append([]string(nil), exclude...)
 	sort.Strings(sorted)
@@ -240,18 +367,43 @@ func buildQueryParts(label string, exclude []string, before int64) []string {
 		if ex == "" {
 			continue
 		}
-		parts = append(parts, fmt.Sprintf(`-label:"%s"`, ex))
+		terms = append(terms, gmail.Not(gmail.Label(ex)))
+	}
+	query := gmail.Search(terms...)
+	query.LabelIDs = labelIDs
+	return query
+}
+
+// lookupLabel finds the ID of the label name refers to: the label with exactly that name, or else the
+// only one whose name normalizes the same way. Ambiguous or unknown names are not resolved.
+func lookupLabel(name string, byName map[string]gmail.LabelID) (gmail.LabelID, bool) {
+	name = strings.TrimSpace(name)
+	if id, ok := byName[name]; ok {
+		return id, true
+	}
+	want := gmail.NormalizeLabel(name)
+	var (
+		found gmail.LabelID
+		count int
+	)
+	for existing, id := range byName {
+		if gmail.NormalizeLabel(existing) == want {
+			found = id
+			count++
+		}
 	}
-	return parts
+	return found, count == 1
 }
 
 func (s *Service) wait(ctx context.Context, operation string) error {
 	if s.Limiter == nil {
 		return nil
 	}
+	start := time.Now()
 	if err := s.Limiter.Wait(ctx); err != nil {
 		return fmt.Errorf("%s: %w", operation, err)
 	}
+	telemetry.RecordWait(ctx, operation, time.Since(start))
 	return nil
 }
 
@@ -272,7 +424,7 @@ func (s *Service) effectiveGrace(spec Spec) time.Duration {
 
 func validateSpec(spec Spec) error {
 	if spec.Grace <= 0 {
-		return fmt.Errorf("grace must be positive")
+		return gmail.Classify(gmail.ClassInvalidConfig, errors.New("grace must be positive"))
 	}
 	return nil
 }
@@ -2,24 +2,32 @@ package sweep
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
 	"github.com/joshsymonds/chronosweep/internal/gmail"
 )
 
 type fakeClient struct {
 	listPages        []gmail.ListPage
 	listQueries      []string
+	listLabelIDs     [][]gmail.LabelID
 	batchBatches     [][]gmail.MessageID
 	ensuredLabel     string
 	ensureLabelErr   error
 	listLabelsByName map[string]gmail.LabelID
 	listLabelsByID   map[gmail.LabelID]string
+	labels           map[gmail.MessageID][]gmail.LabelID
+	deleted          map[gmail.MessageID]bool
 }
 
 func (f *fakeClient) List(ctx context.Context, q gmail.Query, pageToken string, pageSize int) (gmail.ListPage, error) {
@@ -28,6 +36,7 @@ func (f *fakeClient) List(ctx context.Context, q gmail.Query, pageToken string,
 	_ = pageToken
 	_ = pageSize
 	f.listQueries = append(f.listQueries, q.Raw)
+	f.listLabelIDs = append(f.listLabelIDs, q.LabelIDs)
 	if len(f.listPages) == 0 {
 		return gmail.ListPage{}, nil
 	}
@@ -38,9 +47,15 @@ func (f *fakeClient) List(ctx context.Context, q gmail.Query, pageToken string,
 
 func (f *fakeClient) GetMetadata(ctx context.Context, id gmail.MessageID, headers []string) (gmail.MessageMeta, error) {
 	_ = ctx
-	_ = id
 	_ = headers
-	return gmail.MessageMeta{}, nil
+	if f.deleted[id] {
+		return gmail.MessageMeta{}, fmt.Errorf("get %s: %w", id, gmail.ErrMessageNotFound)
+	}
+	labels, ok := f.labels[id]
+	if !ok {
+		labels = []gmail.LabelID{"INBOX", "UNREAD"}
+	}
+	return gmail.MessageMeta{ID: id, LabelIDs: labels}, nil
 }
 
 func (f *fakeClient) BatchModify(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
@@ -135,7 +150,11 @@ func TestParseGraceMap(t *testing.T) {
 }
 
 func TestRunBuildsQuery(t *testing.T) {
-	fake := &fakeClient{}
+	fake := &fakeClient{listLabelsByName: map[string]gmail.LabelID{
+		"News":         "Label_1",
+		"protected":    "Label_2",
+		`Finance/"Q4"`: "Label_3",
+	}}
 	svc := NewService(fake, noLimiter{}, slogDiscard())
 	svc.Clock = func() time.Time { return time.Unix(1700000000, 0) }
 
@@ -143,7 +162,7 @@ func TestRunBuildsQuery(t *testing.T) {
 		Label:         "news",
 		Grace:         48 * time.Hour,
 		DryRun:        true,
-		ExcludeLabels: []string{"protected", "finance"},
+		ExcludeLabels: []string{"protected", `Finance/"Q4"`},
 		ExpiredLabel:  "auto-archived/expired",
 	}
 
@@ -154,10 +173,15 @@ func TestRunBuildsQuery(t *testing.T) {
 		t.Fatalf("expected 1 list call, got %d", len(fake.listQueries))
 	}
 	query := fake.listQueries[0]
+	if ids := fake.listLabelIDs[0]; len(ids) != 1 || ids[0] != "Label_1" {
+		t.Fatalf("expected the swept label to be selected by ID, got %v", ids)
+	}
+	if strings.Contains(query, "label:news") {
+		t.Fatalf("query %q should not match the swept label by name", query)
+	}
 	wantParts := []string{
-		"label:\"news\"",
-		"-label:\"finance\"",
-		"-label:\"protected\"",
+		"-label:finance--q4-",
+		"-label:protected",
 		"in:inbox",
 		"before:",
 	}
@@ -168,6 +192,48 @@ func TestRunBuildsQuery(t *testing.T) {
 	}
 }
 
+func TestBuildQueryLabelFallback(t *testing.T) {
+	before := time.Unix(1700000000, 0)
+	tests := []struct {
+		name    string
+		label   string
+		byName  map[string]gmail.LabelID
+		wantIDs []gmail.LabelID
+		wantRaw string
+	}{
+		{
+			name:    "normalized match",
+			label:   "ops on call",
+			byName:  map[string]gmail.LabelID{"Ops/On Call": "Label_1"},
+			wantIDs: []gmail.LabelID{"Label_1"},
+		},
+		{
+			name:    "exact name wins over normalized twin",
+			label:   "a b",
+			byName:  map[string]gmail.LabelID{"a b": "Label_1", "a-b": "Label_2"},
+			wantIDs: []gmail.LabelID{"Label_1"},
+		},
+		{
+			name:    "ambiguous name",
+			label:   "A B",
+			byName:  map[string]gmail.LabelID{"a b": "Label_1", "a-b": "Label_2"},
+			wantRaw: "label:a-b ",
+		},
+		{name: "allowed missing", label: "later", wantRaw: "label:later "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := buildQuery(tt.label, nil, before, tt.byName)
+			if !slices.Equal(query.LabelIDs, tt.wantIDs) {
+				t.Fatalf("label IDs = %v, want %v", query.LabelIDs, tt.wantIDs)
+			}
+			if tt.wantRaw != "" && !strings.HasPrefix(query.Raw, tt.wantRaw) {
+				t.Fatalf("query %q does not start with %q", query.Raw, tt.wantRaw)
+			}
+		})
+	}
+}
+
 func TestRunChunking(t *testing.T) {
 	fake := &fakeClient{}
 	ids := make([]gmail.MessageID, 1200)
@@ -211,6 +277,158 @@ func TestRunDryRunSkipsMutations(t *testing.T) {
 	}
 }
 
+func TestRunVerifiesLabelsBeforeModify(t *testing.T) {
+	fake := &fakeClient{
+		listPages: []gmail.ListPage{{IDs: []gmail.MessageID{"keep", "starred", "important", "archived", "protected"}}},
+		labels: map[gmail.MessageID][]gmail.LabelID{
+			"starred":   {"INBOX", "STARRED"},
+			"important": {"INBOX", "IMPORTANT"},
+			"archived":  {"UNREAD"},
+			"protected": {"INBOX", "Label_9"},
+		},
+		listLabelsByName: map[string]gmail.LabelID{"Finance/Bills": "Label_9"},
+	}
+	svc := NewService(fake, noLimiter{}, slogDiscard())
+	svc.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+
+	spec := Spec{Grace: 24 * time.Hour, ExcludeLabels: []string{"finance-bills"}}
+	if err := svc.Run(context.Background(), spec); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if len(fake.batchBatches) != 1 {
+		t.Fatalf("expected 1 batch call, got %d", len(fake.batchBatches))
+	}
+	if got := fake.batchBatches[0]; len(got) != 1 || got[0] != "keep" {
+		t.Fatalf("expected only the verified message to be swept, got %v", got)
+	}
+}
+
+func TestRunSkipsMessagesDeletedBeforeVerify(t *testing.T) {
+	fake := &fakeClient{
+		listPages: []gmail.ListPage{{IDs: []gmail.MessageID{"a", "gone", "b", "c"}}},
+		deleted:   map[gmail.MessageID]bool{"gone": true},
+	}
+	svc := NewService(fake, noLimiter{}, slogDiscard())
+	svc.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+
+	if err := svc.Run(context.Background(), Spec{Grace: 24 * time.Hour, Workers: 2}); err != nil {
+		t.Fatalf("a message deleted mid-run must not abort the sweep: %v", err)
+	}
+	if len(fake.batchBatches) != 1 {
+		t.Fatalf("expected 1 batch call, got %d", len(fake.batchBatches))
+	}
+	got := fake.batchBatches[0]
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Fatalf("expected the rest of the batch in listing order, got %v", got)
+	}
+}
+
+func TestRunRecordsSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	fake := &fakeClient{listPages: []gmail.ListPage{{IDs: []gmail.MessageID{"a", "b"}}}}
+	svc := NewService(fake, noLimiter{}, slogDiscard())
+	svc.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+	svc.Tracer = provider.Tracer("test")
+
+	spec := Spec{Grace: 24 * time.Hour, ExpiredLabel: "auto-archived/expired"}
+	if err := svc.Run(context.Background(), spec); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	for _, name := range []string{"sweep.policy", "sweep.list_page", "sweep.batch"} {
+		if _, ok := spans[name]; !ok {
+			t.Fatalf("missing %s span, got %v", name, spans)
+		}
+	}
+	policy := spans["sweep.policy"]
+	if got := attrInt(policy, "swept"); got != 2 {
+		t.Fatalf("expected swept=2 on the policy span, got %d", got)
+	}
+	if spans["sweep.batch"].Parent().SpanID() != policy.SpanContext().SpanID() {
+		t.Fatalf("expected batch span to be a child of the policy span")
+	}
+	var waits int
+	for _, event := range spans["sweep.batch"].Events() {
+		if event.Name == "rate limiter wait" {
+			waits++
+		}
+	}
+	if waits == 0 {
+		t.Fatalf("expected limiter waits recorded on the batch span")
+	}
+}
+
+func attrInt(span sdktrace.ReadOnlySpan, key string) int64 {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.AsInt64()
+		}
+	}
+	return -1
+}
+
+func TestRunPreflightRejectsUnknownLabels(t *testing.T) {
+	labels := map[string]gmail.LabelID{"Finance": "Label_1", "Newsletters": "Label_2", "INBOX": "INBOX"}
+	tests := []struct {
+		name    string
+		spec    Spec
+		wantErr string
+	}{
+		{
+			name:    "misspelled exclusion",
+			spec:    Spec{Grace: time.Hour, ExcludeLabels: []string{"finanse"}},
+			wantErr: `"finanse" (did you mean "Finance"?)`,
+		},
+		{
+			name:    "unknown grace override",
+			spec:    Spec{Grace: time.Hour, GraceOverrides: map[string]time.Duration{"newsleters": time.Hour}},
+			wantErr: `"newsleters" (did you mean "Newsletters"?)`,
+		},
+		{
+			name:    "no close match",
+			spec:    Spec{Grace: time.Hour, Label: "zzz"},
+			wantErr: `"zzz";`,
+		},
+		{
+			name: "allowed missing",
+			spec: Spec{
+				Grace:              time.Hour,
+				Label:              "inbox",
+				ExcludeLabels:      []string{"later"},
+				AllowMissingLabels: []string{"Later"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeClient{
+				listPages:        []gmail.ListPage{{IDs: []gmail.MessageID{"a"}}},
+				listLabelsByName: labels,
+			}
+			svc := NewService(fake, noLimiter{}, slogDiscard())
+			svc.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+			err := svc.Run(context.Background(), tt.spec)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrUnknownLabels) || !strings.Contains(err.Error(), tt.wantErr) ||
+				gmail.ClassOf(err) != gmail.ClassInvalidConfig {
+				t.Fatalf("got %v, want ErrUnknownLabels containing %s", err, tt.wantErr)
+			}
+			if len(fake.listQueries) != 0 || len(fake.batchBatches) != 0 {
+				t.Fatalf("expected no listing or mutation after failed preflight")
+			}
+		})
+	}
+}
+
 func TestRunPauseWeekends(t *testing.T) {
 	fake := &fakeClient{}
 	svc := NewService(fake, noLimiter{}, slogDiscard())
@@ -0,0 +1,161 @@
+package sweep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+const (
+	labelInbox     gmail.LabelID = "INBOX"
+	labelStarred   gmail.LabelID = "STARRED"
+	labelImportant gmail.LabelID = "IMPORTANT"
+
+	// defaultVerifyWorkers is how many label reads run at once when the spec does not say.
+	defaultVerifyWorkers = 4
+)
+
+// verifier re-reads each candidate's labels right before it is modified, so a message the search let
+// through by mistake (an escaping bug, or the user starring or deleting it mid-run) is never swept.
+// That costs one label read per candidate, against the same limiter as every other call; workers only
+// overlap their round trips.
+type verifier struct {
+	svc       *Service
+	protected map[gmail.LabelID]struct{}
+	workers   int
+	rejected  int
+}
+
+// newVerifier resolves the excluded label names to IDs. Names are compared the way Gmail's label:
+// operator compares them; an unknown name is still kept as a literal ID so system labels work.
+func (s *Service) newVerifier(exclude []string, byName map[string]gmail.LabelID, workers int) *verifier {
+	protected := map[gmail.LabelID]struct{}{
+		labelStarred:   {},
+		labelImportant: {},
+	}
+	for _, ex := range exclude {
+		ex = strings.TrimSpace(ex)
+		if ex == "" {
+			continue
+		}
+		protected[gmail.LabelID(ex)] = struct{}{}
+		want := gmail.NormalizeLabel(ex)
+		for name, id := range byName {
+			if gmail.NormalizeLabel(name) == want {
+				protected[id] = struct{}{}
+			}
+		}
+	}
+	if workers <= 0 {
+		workers = defaultVerifyWorkers
+	}
+	return &verifier{svc: s, protected: protected, workers: workers}
+}
+
+// filter returns the ids that are still in the inbox and carry no protected label, counting the rest
+// as rejected. A message deleted since it was listed is rejected too; any other read failure stops the
+// remaining reads and is returned.
+func (v *verifier) filter(ctx context.Context, ids []gmail.MessageID) ([]gmail.MessageID, error) {
+	keep, err := v.verdicts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	kept := make([]gmail.MessageID, 0, len(ids))
+	for i, id := range ids {
+		if keep[i] {
+			kept = append(kept, id)
+			continue
+		}
+		v.rejected++
+	}
+	return kept, nil
+}
+
+// verdicts reads labels for ids with up to v.workers concurrent calls. Each worker writes only the
+// slots it was handed, so keep needs no locking.
+func (v *verifier) verdicts(ctx context.Context, ids []gmail.MessageID) ([]bool, error) {
+	keep := make([]bool, len(ids))
+	if len(ids) == 0 {
+		return keep, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	jobs := make(chan int)
+	for range max(1, min(v.workers, len(ids))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				labels, err := v.labels(ctx, ids[i])
+				switch {
+				case err == nil:
+					keep[i] = v.sweepable(labels)
+				case errors.Is(err, gmail.ErrMessageNotFound):
+				default:
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for i := range ids {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("verify labels: %w", err)
+	}
+	return keep, nil
+}
+
+func (v *verifier) sweepable(labels []gmail.LabelID) bool {
+	inInbox := false
+	for _, id := range labels {
+		if _, ok := v.protected[id]; ok {
+			return false
+		}
+		if id == labelInbox {
+			inInbox = true
+		}
+	}
+	return inInbox
+}
+
+// labels fetches a message's current labels, using the headerless LabelReader call when available.
+func (v *verifier) labels(ctx context.Context, id gmail.MessageID) ([]gmail.LabelID, error) {
+	if err := v.svc.wait(ctx, "rate limit verify labels"); err != nil {
+		return nil, err
+	}
+	if reader, ok := v.svc.Client.(gmail.LabelReader); ok {
+		labels, err := reader.GetLabels(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("verify labels %s: %w", id, err)
+		}
+		return labels, nil
+	}
+	meta, err := v.svc.Client.GetMetadata(ctx, id, nil)
+	if err != nil {
+		return nil, fmt.Errorf("verify labels %s: %w", id, err)
+	}
+	return meta.LabelIDs, nil
+}
@@ -0,0 +1,208 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+// TracerName scopes the spans chronosweep creates.
+const TracerName = "github.com/joshsymonds/chronosweep"
+
+// Client wraps a gmail.Client and records a span for every call that reaches it. Wrap it around the
+// live client, beneath any cache, so spans correspond to real mailbox round trips. When the client
+// sends its requests through a Transport, each span also carries the HTTP attempts and retries made.
+type Client struct {
+	inner  gmail.Client
+	tracer trace.Tracer
+}
+
+// NewClient traces calls to inner using the global tracer provider.
+func NewClient(inner gmail.Client) *Client {
+	return &Client{inner: inner, tracer: otel.Tracer(TracerName)}
+}
+
+// List records a span per page.
+func (c *Client) List(ctx context.Context, q gmail.Query, pageToken string, pageSize int) (gmail.ListPage, error) {
+	ctx, span := c.tracer.Start(ctx, "gmail.messages.list", trace.WithAttributes(
+		attribute.Int("page_size", pageSize),
+		attribute.Bool("continuation", pageToken != ""),
+		attribute.Int("label_filters", len(q.LabelIDs)),
+	))
+	defer span.End()
+	ctx, counter := withAttempts(ctx)
+	defer counter.record(span)
+	page, err := c.inner.List(ctx, q, pageToken, pageSize)
+	if err != nil {
+		RecordError(span, err)
+		return gmail.ListPage{}, fmt.Errorf("traced list: %w", err)
+	}
+	span.SetAttributes(attribute.Int("messages", len(page.IDs)), attribute.Bool("more", page.NextPageToken != ""))
+	return page, nil
+}
+
+// GetMetadata records a span per message.
+func (c *Client) GetMetadata(ctx context.Context, id gmail.MessageID, headers []string) (gmail.MessageMeta, error) {
+	ctx, span := c.tracer.Start(ctx, "gmail.messages.get", trace.WithAttributes(
+		attribute.String("message_id", string(id)),
+		attribute.Int("headers", len(headers)),
+	))
+	defer span.End()
+	ctx, counter := withAttempts(ctx)
+	defer counter.record(span)
+	meta, err := c.inner.GetMetadata(ctx, id, headers)
+	if err != nil {
+		RecordError(span, err)
+		return gmail.MessageMeta{}, fmt.Errorf("traced get metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// GetLabels records a span per message, falling back to GetMetadata when inner has no cheaper call.
+func (c *Client) GetLabels(ctx context.Context, id gmail.MessageID) ([]gmail.LabelID, error) {
+	reader, ok := c.inner.(gmail.LabelReader)
+	if !ok {
+		meta, err := c.GetMetadata(ctx, id, nil)
+		if err != nil {
+			return nil, err
+		}
+		return meta.LabelIDs, nil
+	}
+	ctx, span := c.tracer.Start(ctx, "gmail.messages.get", trace.WithAttributes(
+		attribute.String("message_id", string(id)),
+		attribute.String("format", "minimal"),
+	))
+	defer span.End()
+	ctx, counter := withAttempts(ctx)
+	defer counter.record(span)
+	labels, err := reader.GetLabels(ctx, id)
+	if err != nil {
+		RecordError(span, err)
+		return nil, fmt.Errorf("traced get labels: %w", err)
+	}
+	return labels, nil
+}
+
+// PeekMetadata delegates to the inner client when it can answer locally; no span is recorded.
+func (c *Client) PeekMetadata(id gmail.MessageID, headers []string) (gmail.MessageMeta, bool) {
+	if peeker, ok := c.inner.(gmail.MetadataPeeker); ok {
+		return peeker.PeekMetadata(id, headers)
+	}
+	return gmail.MessageMeta{}, false
+}
+
+// BatchModify records a span per batch with its size and label changes.
+func (c *Client) BatchModify(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	ctx, span := c.tracer.Start(ctx, "gmail.messages.batchModify", trace.WithAttributes(
+		attribute.Int("messages", len(ids)),
+		attribute.Int("add_labels", len(ops.AddLabels)),
+		attribute.Int("remove_labels", len(ops.RemoveLabels)),
+		attribute.Bool("mark_read", ops.MarkRead),
+		attribute.Bool("archive", ops.Archive),
+	))
+	defer span.End()
+	ctx, counter := withAttempts(ctx)
+	defer counter.record(span)
+	if err := c.inner.BatchModify(ctx, ids, ops); err != nil {
+		RecordError(span, err)
+		return fmt.Errorf("traced batch modify: %w", err)
+	}
+	return nil
+}
+
+// ListLabels records a span.
+func (c *Client) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	ctx, span := c.tracer.Start(ctx, "gmail.labels.list")
+	defer span.End()
+	ctx, counter := withAttempts(ctx)
+	defer counter.record(span)
+	byName, byID, err := c.inner.ListLabels(ctx)
+	if err != nil {
+		RecordError(span, err)
+		return nil, nil, fmt.Errorf("traced list labels: %w", err)
+	}
+	span.SetAttributes(attribute.Int("labels", len(byName)))
+	return byName, byID, nil
+}
+
+// EnsureLabel records a span.
+func (c *Client) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
+	ctx, span := c.tracer.Start(ctx, "gmail.labels.ensure")
+	defer span.End()
+	ctx, counter := withAttempts(ctx)
+	defer counter.record(span)
+	id, err := c.inner.EnsureLabel(ctx, name)
+	if err != nil {
+		RecordError(span, err)
+		return "", fmt.Errorf("traced ensure label: %w", err)
+	}
+	return id, nil
+}
+
+// HistoryID records a span, failing with gmail.ErrHistoryUnsupported when inner keeps no history.
+func (c *Client) HistoryID(ctx context.Context) (uint64, error) {
+	history, ok := c.inner.(gmail.HistoryReader)
+	if !ok {
+		return 0, gmail.ErrHistoryUnsupported
+	}
+	ctx, span := c.tracer.Start(ctx, "gmail.profile.get")
+	defer span.End()
+	ctx, counter := withAttempts(ctx)
+	defer counter.record(span)
+	id, err := history.HistoryID(ctx)
+	if err != nil {
+		RecordError(span, err)
+		return 0, fmt.Errorf("traced history id: %w", err)
+	}
+	return id, nil
+}
+
+// LabelChanges records a span, failing with gmail.ErrHistoryUnsupported when inner keeps no history.
+func (c *Client) LabelChanges(ctx context.Context, start uint64) ([]gmail.LabelChange, uint64, error) {
+	history, ok := c.inner.(gmail.HistoryReader)
+	if !ok {
+		return nil, 0, gmail.ErrHistoryUnsupported
+	}
+	ctx, span := c.tracer.Start(ctx, "gmail.history.list")
+	defer span.End()
+	ctx, counter := withAttempts(ctx)
+	defer counter.record(span)
+	changes, latest, err := history.LabelChanges(ctx, start)
+	if err != nil {
+		RecordError(span, err)
+		return nil, 0, fmt.Errorf("traced label changes: %w", err)
+	}
+	span.SetAttributes(attribute.Int("changes", len(changes)))
+	return changes, latest, nil
+}
+
+// CreateDraft records a span, failing with gmail.ErrDraftsUnsupported when inner cannot save drafts.
+func (c *Client) CreateDraft(ctx context.Context, raw []byte) (string, error) {
+	creator, ok := c.inner.(gmail.DraftCreator)
+	if !ok {
+		return "", gmail.ErrDraftsUnsupported
+	}
+	ctx, span := c.tracer.Start(ctx, "gmail.drafts.create", trace.WithAttributes(attribute.Int("bytes", len(raw))))
+	defer span.End()
+	ctx, counter := withAttempts(ctx)
+	defer counter.record(span)
+	id, err := creator.CreateDraft(ctx, raw)
+	if err != nil {
+		RecordError(span, err)
+		return "", fmt.Errorf("traced create draft: %w", err)
+	}
+	return id, nil
+}
+
+var (
+	_ gmail.Client         = (*Client)(nil)
+	_ gmail.LabelReader    = (*Client)(nil)
+	_ gmail.HistoryReader  = (*Client)(nil)
+	_ gmail.MetadataPeeker = (*Client)(nil)
+	_ gmail.DraftCreator   = (*Client)(nil)
+)
@@ -0,0 +1,139 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+var errQuota = errors.New("quota exhausted")
+
+type stubClient struct {
+	gmail.Client
+}
+
+func (stubClient) List(ctx context.Context, q gmail.Query, pageToken string, pageSize int) (gmail.ListPage, error) {
+	_ = ctx
+	_ = q
+	_ = pageToken
+	_ = pageSize
+	return gmail.ListPage{IDs: []gmail.MessageID{"a", "b"}, NextPageToken: "next"}, nil
+}
+
+func (stubClient) BatchModify(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	_ = ctx
+	_ = ids
+	_ = ops
+	return errQuota
+}
+
+func TestClientRecordsSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client := &Client{inner: stubClient{}, tracer: provider.Tracer(TracerName)}
+
+	if _, err := client.List(context.Background(), gmail.Search(gmail.Label("news")), "", 50); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	err := client.BatchModify(context.Background(), []gmail.MessageID{"a"}, gmail.ModifyOps{Archive: true})
+	if !errors.Is(err, errQuota) {
+		t.Fatalf("expected the inner error, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	list, modify := spans[0], spans[1]
+	if list.Name() != "gmail.messages.list" || modify.Name() != "gmail.messages.batchModify" {
+		t.Fatalf("unexpected span names %q, %q", list.Name(), modify.Name())
+	}
+	attrs := map[string]string{}
+	for _, kv := range list.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["page_size"] != "50" || attrs["messages"] != "2" || attrs["more"] != "true" {
+		t.Fatalf("unexpected list attributes %v", attrs)
+	}
+	if modify.Status().Code != codes.Error {
+		t.Fatalf("expected error status on batch modify, got %v", modify.Status())
+	}
+}
+
+// retryingClient fetches a label listing over HTTP, retrying once when the server is unavailable.
+type retryingClient struct {
+	gmail.Client
+	http *http.Client
+	url  string
+}
+
+func (r retryingClient) ListLabels(ctx context.Context) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	for range 2 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp, err := r.http.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return map[string]gmail.LabelID{"news": "Label_1"}, map[gmail.LabelID]string{"Label_1": "news"}, nil
+		}
+	}
+	return nil, nil, errQuota
+}
+
+func TestClientRecordsHTTPAttempts(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	inner := retryingClient{http: &http.Client{Transport: NewTransport(nil)}, url: server.URL}
+	client := &Client{inner: inner, tracer: provider.Tracer(TracerName)}
+
+	if _, _, err := client.ListLabels(context.Background()); err != nil {
+		t.Fatalf("list labels: %v", err)
+	}
+	// Requests outside a traced call are sent but not counted.
+	if _, _, err := inner.ListLabels(context.Background()); err != nil {
+		t.Fatalf("untraced list labels: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	attrs := map[string]string{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["http.attempts"] != "2" || attrs["http.retries"] != "1" {
+		t.Fatalf("unexpected attempt attributes %v", attrs)
+	}
+	if events := spans[0].Events(); len(events) != 2 || events[0].Name != "http attempt" {
+		t.Fatalf("expected an event per attempt, got %v", events)
+	}
+}
+
+func TestConfigSetupRejectsUnknownExporter(t *testing.T) {
+	_, err := Config{Exporter: "zipkin"}.Setup(context.Background(), "chronosweep-sweep", nil)
+	if gmail.ClassOf(err) != gmail.ClassInvalidConfig {
+		t.Fatalf("expected invalid config, got %v", err)
+	}
+}
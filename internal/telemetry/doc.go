@@ -0,0 +1,2 @@
+// Package telemetry sets up OpenTelemetry tracing and traces calls to a gmail.Client.
+package telemetry
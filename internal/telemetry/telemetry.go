@@ -0,0 +1,145 @@
+package telemetry
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+const (
+	// ExporterNone disables tracing; spans go to the global no-op provider.
+	ExporterNone = "none"
+	// ExporterOTLP sends spans over OTLP/HTTP.
+	ExporterOTLP = "otlp"
+	// ExporterStdout pretty-prints spans, for local debugging.
+	ExporterStdout = "stdout"
+
+	// flushTimeout bounds the final export when the command exits.
+	flushTimeout = 5 * time.Second
+)
+
+// Config collects the tracing flags shared by audit, lint and sweep.
+type Config struct {
+	Exporter string
+	Endpoint string
+	Insecure bool
+}
+
+// RegisterFlags registers the -trace* flags on fs.
+func RegisterFlags(fs *flag.FlagSet) *Config {
+	cfg := &Config{}
+	fs.StringVar(&cfg.Exporter, "trace", ExporterNone, "trace exporter (none, otlp or stdout; stdout writes to stderr)")
+	fs.StringVar(
+		&cfg.Endpoint,
+		"trace-endpoint",
+		"",
+		"OTLP/HTTP endpoint as host:port or URL (default: $OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318)",
+	)
+	fs.BoolVar(&cfg.Insecure, "trace-insecure", false, "send OTLP spans over plain HTTP")
+	return cfg
+}
+
+// Enabled reports whether spans are exported.
+func (cfg Config) Enabled() bool {
+	exporter := strings.ToLower(strings.TrimSpace(cfg.Exporter))
+	return exporter != "" && exporter != ExporterNone
+}
+
+// Setup installs a global tracer provider exporting to the configured backend and returns the function
+// that flushes and shuts it down. The stdout exporter writes to debugOut. When tracing is disabled the
+// global no-op provider stays in place and the returned function does nothing.
+func (cfg Config) Setup(ctx context.Context, service string, debugOut io.Writer) (func() error, error) {
+	if !cfg.Enabled() {
+		return func() error { return nil }, nil
+	}
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch strings.ToLower(strings.TrimSpace(cfg.Exporter)) {
+	case ExporterStdout:
+		exporter, err = stdoutExporter(debugOut)
+	case ExporterOTLP:
+		exporter, err = cfg.otlpExporter(ctx)
+	default:
+		err = gmail.Classify(
+			gmail.ClassInvalidConfig,
+			fmt.Errorf("unknown trace exporter %q (want none, otlp or stdout)", cfg.Exporter),
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", service)))
+	if err != nil {
+		return nil, fmt.Errorf("build trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return func() error {
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+		defer cancel()
+		if shutdownErr := provider.Shutdown(flushCtx); shutdownErr != nil {
+			return fmt.Errorf("flush traces: %w", shutdownErr)
+		}
+		return nil
+	}, nil
+}
+
+// RecordError marks span as failed with err; a nil err leaves it untouched.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// RecordWait adds a rate limiter wait to the span in ctx as an event.
+func RecordWait(ctx context.Context, operation string, waited time.Duration) {
+	trace.SpanFromContext(ctx).AddEvent("rate limiter wait", trace.WithAttributes(
+		attribute.String("operation", operation),
+		attribute.Int64("wait_ms", waited.Milliseconds()),
+	))
+}
+
+func stdoutExporter(w io.Writer) (*stdouttrace.Exporter, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w), stdouttrace.WithPrettyPrint())
+	if err != nil {
+		return nil, fmt.Errorf("create stdout trace exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+func (cfg Config) otlpExporter(ctx context.Context) (*otlptrace.Exporter, error) {
+	var opts []otlptracehttp.Option
+	switch endpoint := strings.TrimSpace(cfg.Endpoint); {
+	case strings.Contains(endpoint, "://"):
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	case endpoint != "":
+		opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create otlp trace exporter: %w", err)
+	}
+	return exporter, nil
+}
@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// attemptsKey carries the counter a Client opens for each call down to the Transport.
+type attemptsKey struct{}
+
+// attempts counts the HTTP requests made on behalf of one traced call.
+type attempts struct {
+	n atomic.Int64
+}
+
+// withAttempts returns a context whose HTTP requests are counted by a Transport.
+func withAttempts(ctx context.Context) (context.Context, *attempts) {
+	counter := &attempts{}
+	return context.WithValue(ctx, attemptsKey{}, counter), counter
+}
+
+// record sets the attempt and retry counts on span. Calls that never reached HTTP, such as IMAP or
+// local mailbox reads, are left without them.
+func (a *attempts) record(span trace.Span) {
+	n := int(a.n.Load())
+	if n == 0 {
+		return
+	}
+	span.SetAttributes(attribute.Int("http.attempts", n), attribute.Int("http.retries", n-1))
+}
+
+// Transport counts every request it sends against the call a Client is tracing and adds an event
+// per attempt to that call's span. Requests made outside a traced call pass straight through, so it
+// is safe to install whether or not tracing is enabled.
+type Transport struct {
+	base http.RoundTripper
+}
+
+// NewTransport counts requests sent through base; a nil base uses http.DefaultTransport.
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{base: base}
+}
+
+// RoundTrip sends req through the base transport.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	counter, ok := ctx.Value(attemptsKey{}).(*attempts)
+	if !ok {
+		return t.round(req)
+	}
+	attempt := counter.n.Add(1)
+	resp, err := t.round(req)
+	attrs := []attribute.KeyValue{attribute.Int64("attempt", attempt)}
+	if err != nil {
+		attrs = append(attrs, attribute.String("error", err.Error()))
+	} else {
+		attrs = append(attrs, attribute.Int("status", resp.StatusCode))
+	}
+	trace.SpanFromContext(ctx).AddEvent("http attempt", trace.WithAttributes(attrs...))
+	return resp, err
+}
+
+func (t *Transport) round(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("send %s %s: %w", req.Method, req.URL.Path, err)
+	}
+	return resp, nil
+}
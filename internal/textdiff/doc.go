@@ -0,0 +1,2 @@
+// Package textdiff renders line-based unified diffs that git apply and patch accept.
+package textdiff
@@ -0,0 +1,165 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is how many unchanged lines surround each hunk, as in diff -u.
+const contextLines = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns the unified diff turning before into after, labelled oldName and newName. An empty
+// oldName or newName stands for a file being created or deleted and is written as /dev/null. Identical
+// inputs produce an empty string. The diff is computed from a longest common subsequence, which is
+// quadratic in the line counts and meant for configuration files, not large documents.
+func Unified(oldName, newName, before, after string) string {
+	if before == after {
+		return ""
+	}
+	ops := diffLines(splitLines(before), splitLines(after))
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", orDevNull(oldName), orDevNull(newName))
+	for _, h := range hunks(ops) {
+		writeHunk(&b, ops, h)
+	}
+	return b.String()
+}
+
+func orDevNull(name string) string {
+	if name == "" {
+		return "/dev/null"
+	}
+	return name
+}
+
+// splitLines splits s into lines that keep their newline, so a missing final newline survives.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines walks a longest-common-subsequence table to produce the edit script.
+func diffLines(a, b []string) []op {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	ops := make([]op, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{kind: opEqual, line: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{kind: opDelete, line: a[i]})
+			i++
+		default:
+			ops = append(ops, op{kind: opInsert, line: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{kind: opDelete, line: a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{kind: opInsert, line: b[j]})
+	}
+	return ops
+}
+
+// hunk is a half-open range of ops.
+type hunk struct {
+	start, end int
+}
+
+// hunks groups changes that are within two contexts of each other, padded with context on both sides.
+func hunks(ops []op) []hunk {
+	var out []hunk
+	for i, o := range ops {
+		if o.kind == opEqual {
+			continue
+		}
+		start := max(0, i-contextLines)
+		end := min(len(ops), i+1+contextLines)
+		if n := len(out); n > 0 && start <= out[n-1].end {
+			out[n-1].end = end
+			continue
+		}
+		out = append(out, hunk{start: start, end: end})
+	}
+	return out
+}
+
+func writeHunk(b *strings.Builder, ops []op, h hunk) {
+	oldStart, newStart := 1, 1
+	for _, o := range ops[:h.start] {
+		if o.kind != opInsert {
+			oldStart++
+		}
+		if o.kind != opDelete {
+			newStart++
+		}
+	}
+	var oldCount, newCount int
+	var body strings.Builder
+	for _, o := range ops[h.start:h.end] {
+		prefix := " "
+		switch o.kind {
+		case opEqual:
+			oldCount++
+			newCount++
+		case opDelete:
+			prefix = "-"
+			oldCount++
+		case opInsert:
+			prefix = "+"
+			newCount++
+		}
+		body.WriteString(prefix + o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			body.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	fmt.Fprintf(b, "@@ -%s +%s @@\n%s", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount), body.String())
+}
+
+// hunkRange formats a hunk header range; an empty range names the line before it, as diff -u does.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
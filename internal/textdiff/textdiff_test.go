@@ -0,0 +1,50 @@
+package textdiff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		oldName       string
+		want          string
+	}{
+		{name: "identical", before: "a\nb\n", after: "a\nb\n", oldName: "a/f", want: ""},
+		{
+			name:    "create",
+			before:  "",
+			after:   "x\ny\n",
+			oldName: "",
+			want:    "--- /dev/null\n+++ b/f\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name:    "replace line",
+			before:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			after:   "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			oldName: "a/f",
+			want:    "--- a/f\n+++ b/f\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:    "separate hunks",
+			before:  "a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n",
+			after:   "A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n",
+			oldName: "a/f",
+			want: "--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n" +
+				"@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-b\n+B\n",
+		},
+		{
+			name:    "missing final newline",
+			before:  "a\nb",
+			after:   "a\nc",
+			oldName: "a/f",
+			want:    "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified(tt.oldName, "b/f", tt.before, tt.after); got != tt.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package unsubscribe parses List-Unsubscribe headers, performs RFC 8058 one-click unsubscribes and
+// mailto drafts, and keeps a ledger of attempts so a later run can check whether the mail stopped.
+package unsubscribe
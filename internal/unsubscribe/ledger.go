@@ -0,0 +1,141 @@
+package unsubscribe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	fileMode = 0o600
+	dirMode  = 0o700
+)
+
+// Status records what an attempt did.
+type Status string
+
+// Attempt statuses.
+const (
+	// StatusSent means a one-click POST was accepted.
+	StatusSent Status = "sent"
+	// StatusDrafted means a mailto unsubscribe was saved as a draft for the user to send.
+	StatusDrafted Status = "drafted"
+	// StatusManual means only a web page was offered; the user has to visit it.
+	StatusManual Status = "manual"
+	// StatusUnavailable means no recent message advertised a usable mechanism.
+	StatusUnavailable Status = "unavailable"
+	// StatusFailed means the POST or draft failed; Error says why.
+	StatusFailed Status = "failed"
+	// StatusDryRun means the attempt was only planned.
+	StatusDryRun Status = "dry-run"
+)
+
+// Outcome is what verification concluded about an attempt.
+type Outcome string
+
+// Verification outcomes.
+const (
+	// OutcomePending means the grace period has not passed yet.
+	OutcomePending Outcome = "pending"
+	// OutcomeUnsent means the unsubscribe draft has not been sent, so the sender was never asked to stop.
+	OutcomeUnsent Outcome = "unsent"
+	// OutcomeStopped means no mail arrived after the grace period.
+	OutcomeStopped Outcome = "stopped"
+	// OutcomeStillSending means mail kept arriving after the grace period.
+	OutcomeStillSending Outcome = "still-sending"
+)
+
+// Attempt is one ledger entry.
+type Attempt struct {
+	Account   string        `json:"account,omitempty"`
+	Target    Target        `json:"target"`
+	Mechanism Mechanism     `json:"mechanism,omitempty"`
+	URI       string        `json:"uri,omitempty"`
+	MessageID string        `json:"message_id,omitempty"`
+	DraftID   string        `json:"draft_id,omitempty"`
+	Status    Status        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	At        time.Time     `json:"at"`
+	Verified  *Verification `json:"verified,omitempty"`
+}
+
+// Verification records the latest check of an attempt.
+type Verification struct {
+	At        time.Time `json:"at"`
+	Outcome   Outcome   `json:"outcome"`
+	MessageID string    `json:"message_id,omitempty"`
+}
+
+// Verifiable reports whether the attempt asked, or prepared to ask, the sender to stop, so there is
+// something to verify. A drafted attempt only counts once its draft was sent; Verify checks that first.
+func (a Attempt) Verifiable() bool {
+	return a.Status == StatusSent || a.Status == StatusDrafted
+}
+
+// Ledger is the persisted list of attempts, oldest first.
+type Ledger struct {
+	Attempts []Attempt `json:"attempts"`
+}
+
+// DefaultLedgerPath returns the per-user ledger location. The ledger is state, not cache, so it lives
+// in the config directory where cache cleaners leave it alone.
+func DefaultLedgerPath() string {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "chronosweep", "unsubscribe-ledger.json")
+}
+
+// LoadLedger reads the ledger at path; a missing file is an empty ledger.
+func LoadLedger(path string) (*Ledger, error) {
+	clean := filepath.Clean(path)
+	data, err := os.ReadFile(clean)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Ledger{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read ledger %s: %w", clean, err)
+	}
+	var ledger Ledger
+	if err := json.Unmarshal(data, &ledger); err != nil {
+		return nil, fmt.Errorf("decode ledger %s: %w", clean, err)
+	}
+	return &ledger, nil
+}
+
+// Save replaces the ledger at path atomically.
+func (l *Ledger) Save(path string) error {
+	clean := filepath.Clean(path)
+	if err := os.MkdirAll(filepath.Dir(clean), dirMode); err != nil {
+		return fmt.Errorf("create ledger dir: %w", err)
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode ledger: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(clean), filepath.Base(clean)+".*")
+	if err != nil {
+		return fmt.Errorf("create ledger file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if chmodErr := tmp.Chmod(fileMode); chmodErr != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("chmod ledger file: %w", chmodErr)
+	}
+	if _, writeErr := tmp.Write(append(data, '\n')); writeErr != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write ledger: %w", writeErr)
+	}
+	if closeErr := tmp.Close(); closeErr != nil {
+		return fmt.Errorf("close ledger: %w", closeErr)
+	}
+	if renameErr := os.Rename(tmp.Name(), clean); renameErr != nil {
+		return fmt.Errorf("replace ledger %s: %w", clean, renameErr)
+	}
+	return nil
+}
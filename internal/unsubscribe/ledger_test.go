@@ -0,0 +1,57 @@
+package unsubscribe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedgerRoundTrip(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "state", "ledger.json")
+
+	empty, err := LoadLedger(path)
+	if err != nil {
+		t.Fatalf("LoadLedger missing: %v", err)
+	}
+	if len(empty.Attempts) != 0 {
+		t.Fatalf("missing ledger has %d attempts", len(empty.Attempts))
+	}
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ledger := &Ledger{Attempts: []Attempt{{
+		Account:   "me@example.com",
+		Target:    Target{Kind: KindList, Value: "news.example.com"},
+		Mechanism: MechanismOneClick,
+		URI:       "https://example.com/u/1",
+		Status:    StatusSent,
+		At:        at,
+		Verified:  &Verification{At: at.Add(time.Hour), Outcome: OutcomePending},
+	}}}
+	if err := ledger.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat ledger: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != fileMode {
+		t.Fatalf("ledger mode = %o, want %o", perm, fileMode)
+	}
+
+	loaded, err := LoadLedger(path)
+	if err != nil {
+		t.Fatalf("LoadLedger: %v", err)
+	}
+	if len(loaded.Attempts) != 1 {
+		t.Fatalf("loaded %d attempts, want 1", len(loaded.Attempts))
+	}
+	got := loaded.Attempts[0]
+	if got.Target != ledger.Attempts[0].Target || !got.At.Equal(at) || got.Status != StatusSent {
+		t.Fatalf("loaded attempt = %+v", got)
+	}
+	if got.Verified == nil || got.Verified.Outcome != OutcomePending {
+		t.Fatalf("loaded verification = %+v", got.Verified)
+	}
+}
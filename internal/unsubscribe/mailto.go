@@ -0,0 +1,54 @@
+package unsubscribe
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// defaultMailtoSubject is used when the mailto URI names no subject.
+const defaultMailtoSubject = "unsubscribe"
+
+var errBadMailto = errors.New("invalid mailto unsubscribe URI")
+
+// Mailto is a parsed RFC 6068 mailto URI.
+type Mailto struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// ParseMailto reads the recipient and the subject and body fields of raw.
+func ParseMailto(raw string) (Mailto, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || !strings.EqualFold(parsed.Scheme, "mailto") {
+		return Mailto{}, fmt.Errorf("%w: %q", errBadMailto, raw)
+	}
+	to, err := url.PathUnescape(parsed.Opaque)
+	if err != nil {
+		return Mailto{}, fmt.Errorf("%w: %q: %w", errBadMailto, raw, err)
+	}
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		return Mailto{}, fmt.Errorf("%w: %q: %w", errBadMailto, raw, err)
+	}
+	query := parsed.Query()
+	m := Mailto{To: addr.Address, Subject: query.Get("subject"), Body: query.Get("body")}
+	if strings.TrimSpace(m.Subject) == "" {
+		m.Subject = defaultMailtoSubject
+	}
+	return m, nil
+}
+
+// Message renders m as an RFC 5322 message ready to be saved as a draft. Gmail fills in From.
+func (m Mailto) Message() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
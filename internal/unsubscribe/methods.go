@@ -0,0 +1,99 @@
+package unsubscribe
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Mechanism names one way a sender lets recipients unsubscribe.
+type Mechanism string
+
+// Mechanisms, in the order they are preferred.
+const (
+	// MechanismOneClick is an RFC 8058 POST to the https URI, needing no page visit or confirmation.
+	MechanismOneClick Mechanism = "one-click"
+	// MechanismMailto is a message sent to the mailto URI.
+	MechanismMailto Mechanism = "mailto"
+	// MechanismHTTPS is a web page the user has to visit.
+	MechanismHTTPS Mechanism = "https"
+)
+
+// HeaderUnsubscribe and HeaderUnsubscribePost are the headers Parse reads.
+const (
+	HeaderUnsubscribe     = "List-Unsubscribe"
+	HeaderUnsubscribePost = "List-Unsubscribe-Post"
+)
+
+// oneClickPost is the only List-Unsubscribe-Post value RFC 8058 defines.
+const oneClickPost = "List-Unsubscribe=One-Click"
+
+// Methods are the unsubscribe options a message advertises. HTTPS and Mailto hold the first URI of
+// each kind; plain http URIs are ignored.
+type Methods struct {
+	OneClick bool   `json:"one_click,omitempty"`
+	HTTPS    string `json:"https,omitempty"`
+	Mailto   string `json:"mailto,omitempty"`
+}
+
+// Parse reads a List-Unsubscribe header (RFC 2369: comma separated URIs in angle brackets) and its
+// List-Unsubscribe-Post companion. One-click is only reported when the POST header is exactly the
+// RFC 8058 value and an https URI is present to post to.
+func Parse(listUnsubscribe, post string) Methods {
+	var m Methods
+	for _, part := range strings.Split(listUnsubscribe, ",") {
+		raw := strings.TrimSpace(part)
+		if !strings.HasPrefix(raw, "<") || !strings.HasSuffix(raw, ">") {
+			continue
+		}
+		raw = strings.TrimSpace(raw[1 : len(raw)-1])
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(parsed.Scheme) {
+		case "https":
+			if m.HTTPS == "" && parsed.Host != "" {
+				m.HTTPS = raw
+			}
+		case "mailto":
+			if m.Mailto == "" && parsed.Opaque != "" {
+				m.Mailto = raw
+			}
+		}
+	}
+	m.OneClick = m.HTTPS != "" && strings.EqualFold(strings.TrimSpace(post), oneClickPost)
+	return m
+}
+
+// Empty reports whether no usable mechanism was advertised.
+func (m Methods) Empty() bool {
+	return m.HTTPS == "" && m.Mailto == ""
+}
+
+// Mechanisms lists what m offers in order of preference.
+func (m Methods) Mechanisms() []Mechanism {
+	var out []Mechanism
+	if m.OneClick {
+		out = append(out, MechanismOneClick)
+	}
+	if m.Mailto != "" {
+		out = append(out, MechanismMailto)
+	}
+	if m.HTTPS != "" {
+		out = append(out, MechanismHTTPS)
+	}
+	return out
+}
+
+// String joins the mechanisms for reports, or "none".
+func (m Methods) String() string {
+	mechanisms := m.Mechanisms()
+	if len(mechanisms) == 0 {
+		return "none"
+	}
+	parts := make([]string, 0, len(mechanisms))
+	for _, mechanism := range mechanisms {
+		parts = append(parts, string(mechanism))
+	}
+	return strings.Join(parts, ", ")
+}
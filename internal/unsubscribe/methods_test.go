@@ -0,0 +1,94 @@
+package unsubscribe
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		header string
+		post   string
+		want   Methods
+		str    string
+	}{
+		{
+			name:   "one-click with mailto",
+			header: "<mailto:leave@example.com?subject=stop>, <https://example.com/u/1>",
+			post:   "List-Unsubscribe=One-Click",
+			want: Methods{
+				OneClick: true,
+				HTTPS:    "https://example.com/u/1",
+				Mailto:   "mailto:leave@example.com?subject=stop",
+			},
+			str: "one-click, mailto, https",
+		},
+		{
+			name:   "https without post header",
+			header: "<https://example.com/u/1>",
+			want:   Methods{HTTPS: "https://example.com/u/1"},
+			str:    "https",
+		},
+		{
+			name:   "post header without https uri",
+			header: "<mailto:leave@example.com>",
+			post:   "List-Unsubscribe=One-Click",
+			want:   Methods{Mailto: "mailto:leave@example.com"},
+			str:    "mailto",
+		},
+		{
+			name:   "plain http is ignored",
+			header: "<http://example.com/u/1>",
+			post:   "List-Unsubscribe=One-Click",
+			want:   Methods{},
+			str:    "none",
+		},
+		{
+			name: "missing header",
+			want: Methods{},
+			str:  "none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := Parse(tt.header, tt.post)
+			if got != tt.want {
+				t.Fatalf("Parse = %+v, want %+v", got, tt.want)
+			}
+			if got.String() != tt.str {
+				t.Fatalf("String = %q, want %q", got.String(), tt.str)
+			}
+		})
+	}
+}
+
+func TestParseMailto(t *testing.T) {
+	t.Parallel()
+	m, err := ParseMailto("mailto:leave@example.com?subject=Remove%20me&body=list%2042")
+	if err != nil {
+		t.Fatalf("ParseMailto: %v", err)
+	}
+	if m.To != "leave@example.com" || m.Subject != "Remove me" || m.Body != "list 42" {
+		t.Fatalf("ParseMailto = %+v", m)
+	}
+	msg := string(m.Message())
+	for _, want := range []string{"To: leave@example.com\r\n", "Subject: Remove me\r\n", "\r\n\r\nlist 42"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("message missing %q:\n%s", want, msg)
+		}
+	}
+
+	def, err := ParseMailto("mailto:leave@example.com")
+	if err != nil {
+		t.Fatalf("ParseMailto default: %v", err)
+	}
+	if def.Subject != "unsubscribe" {
+		t.Fatalf("default subject = %q", def.Subject)
+	}
+	if _, err := ParseMailto("https://example.com"); err == nil {
+		t.Fatalf("expected error for non-mailto URI")
+	}
+}
@@ -0,0 +1,69 @@
+package unsubscribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds a one-click POST when the caller supplies no client.
+const DefaultTimeout = 30 * time.Second
+
+// maxDrainBytes caps how much of a response body is read before closing it.
+const maxDrainBytes = 64 << 10
+
+var (
+	// ErrNotHTTPS is returned for one-click URIs that are not https.
+	ErrNotHTTPS = errors.New("one-click unsubscribe requires an https URI")
+	// ErrRejected is returned when the sender answers a one-click POST with anything but 2xx.
+	ErrRejected = errors.New("one-click unsubscribe rejected")
+)
+
+// Poster performs RFC 8058 one-click unsubscribes.
+type Poster struct {
+	client *http.Client
+}
+
+// NewPoster posts through client, or a client with DefaultTimeout when nil. Redirects are never
+// followed: RFC 8058 expects the POST itself to unsubscribe, and following one would turn it into a GET
+// against an arbitrary location.
+func NewPoster(client *http.Client) *Poster {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	clone := *client
+	clone.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return &Poster{client: &clone}
+}
+
+// OneClick POSTs List-Unsubscribe=One-Click to target as RFC 8058 prescribes: form encoded, without
+// cookies or credentials.
+func (p *Poster) OneClick(ctx context.Context, target string) error {
+	parsed, err := url.Parse(target)
+	if err != nil || !strings.EqualFold(parsed.Scheme, "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: %q", ErrNotHTTPS, target)
+	}
+	body := strings.NewReader(url.Values{"List-Unsubscribe": {"One-Click"}}.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, parsed.String(), body)
+	if err != nil {
+		return fmt.Errorf("build one-click request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post one-click unsubscribe to %s: %w", parsed.Host, err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s answered %s", ErrRejected, parsed.Host, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package unsubscribe
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPosterOneClick(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr error
+	}{
+		{
+			name: "accepted",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Errorf("method = %s, want POST", r.Method)
+				}
+				if got := r.Header.Get("Content-Type"); got != "application/x-www-form-urlencoded" {
+					t.Errorf("content type = %q", got)
+				}
+				if got := r.PostFormValue("List-Unsubscribe"); got != "One-Click" {
+					t.Errorf("List-Unsubscribe = %q, want One-Click", got)
+				}
+				w.WriteHeader(http.StatusAccepted)
+			},
+		},
+		{
+			name: "rejected",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantErr: ErrRejected,
+		},
+		{
+			name: "redirect is not followed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/confirm", http.StatusFound)
+			},
+			wantErr: ErrRejected,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewTLSServer(tt.handler)
+			defer server.Close()
+			err := NewPoster(server.Client()).OneClick(context.Background(), server.URL+"/u/1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OneClick err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPosterRejectsPlainHTTP(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Errorf("plain http target must not be contacted")
+	}))
+	defer server.Close()
+	err := NewPoster(server.Client()).OneClick(context.Background(), server.URL+"/u/1")
+	if !errors.Is(err, ErrNotHTTPS) {
+		t.Fatalf("OneClick err = %v, want ErrNotHTTPS", err)
+	}
+}
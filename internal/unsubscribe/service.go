@@ -0,0 +1,285 @@
+package unsubscribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+	"github.com/joshsymonds/chronosweep/internal/rate"
+)
+
+// Target kinds match the audit report's: a sender domain or a List-Id.
+const (
+	KindSender = "sender"
+	KindList   = "list"
+)
+
+// searchPageSize is how many recent messages are inspected for an unsubscribe header.
+const searchPageSize = 10
+
+// Target selects the mail to unsubscribe from.
+type Target struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Term returns the search term matching the target's mail: list:<id> or from:@<domain>.
+func (t Target) Term() gmail.Term {
+	if t.Kind == KindList {
+		return gmail.List(t.Value)
+	}
+	return gmail.From("@" + strings.TrimPrefix(t.Value, "@"))
+}
+
+func (t Target) String() string {
+	return t.Kind + " " + t.Value
+}
+
+// Options controls an unsubscribe run.
+type Options struct {
+	// Window is how far back to look for a message carrying List-Unsubscribe.
+	Window time.Duration
+	// DryRun plans every attempt without posting or drafting anything.
+	DryRun bool
+	// Account is recorded on each attempt.
+	Account string
+}
+
+// Service unsubscribes from targets and verifies earlier attempts.
+type Service struct {
+	Client  gmail.Client
+	Poster  *Poster
+	Limiter rate.Limiter
+	Logger  *slog.Logger
+	Clock   func() time.Time
+}
+
+// NewService constructs a Service with sane defaults.
+func NewService(client gmail.Client, poster *Poster, limiter rate.Limiter, logger *slog.Logger) *Service {
+	if poster == nil {
+		poster = NewPoster(nil)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Service{Client: client, Poster: poster, Limiter: limiter, Logger: logger, Clock: time.Now}
+}
+
+// Unsubscribe makes one attempt per target, preferring one-click, then a mailto draft, and falling back
+// to reporting the web page. A failed POST or draft is recorded on its attempt; only mailbox errors
+// abort the run.
+func (s *Service) Unsubscribe(ctx context.Context, targets []Target, opts Options) ([]Attempt, error) {
+	attempts := make([]Attempt, 0, len(targets))
+	for _, target := range targets {
+		attempt, err := s.attempt(ctx, target, opts)
+		if err != nil {
+			return attempts, err
+		}
+		s.Logger.InfoContext(ctx, "unsubscribe attempt",
+			slog.String("target", target.String()),
+			slog.String("mechanism", string(attempt.Mechanism)),
+			slog.String("status", string(attempt.Status)),
+		)
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
+
+func (s *Service) attempt(ctx context.Context, target Target, opts Options) (Attempt, error) {
+	attempt := Attempt{Account: opts.Account, Target: target, At: s.Clock()}
+	meta, methods, err := s.latestMethods(ctx, target, opts.Window)
+	if err != nil {
+		return Attempt{}, err
+	}
+	attempt.MessageID = string(meta.ID)
+	switch {
+	case methods.OneClick:
+		attempt.Mechanism, attempt.URI = MechanismOneClick, methods.HTTPS
+		if !opts.DryRun {
+			err = s.Poster.OneClick(ctx, methods.HTTPS)
+		}
+		attempt.Status = StatusSent
+	case methods.Mailto != "":
+		attempt.Mechanism, attempt.URI = MechanismMailto, methods.Mailto
+		if !opts.DryRun {
+			attempt.DraftID, err = s.draft(ctx, methods.Mailto)
+		}
+		attempt.Status = StatusDrafted
+	case methods.HTTPS != "":
+		attempt.Mechanism, attempt.URI, attempt.Status = MechanismHTTPS, methods.HTTPS, StatusManual
+	default:
+		attempt.Status = StatusUnavailable
+		attempt.Error = fmt.Sprintf("no message in the last %s advertises List-Unsubscribe", opts.Window)
+		return attempt, nil
+	}
+	switch {
+	case err != nil:
+		attempt.Status, attempt.Error = StatusFailed, err.Error()
+	case opts.DryRun && attempt.Status != StatusManual:
+		attempt.Status = StatusDryRun
+	}
+	return attempt, nil
+}
+
+// latestMethods returns the newest message from target within window that advertises an unsubscribe
+// mechanism, or empty methods when none does.
+func (s *Service) latestMethods(
+	ctx context.Context,
+	target Target,
+	window time.Duration,
+) (gmail.MessageMeta, Methods, error) {
+	page, err := s.list(ctx, gmail.Search(target.Term(), gmail.NewerThan(window)), searchPageSize)
+	if err != nil {
+		return gmail.MessageMeta{}, Methods{}, fmt.Errorf("search %s: %w", target, err)
+	}
+	headers := []string{"From", "List-Id", HeaderUnsubscribe, HeaderUnsubscribePost}
+	for _, id := range page.IDs {
+		if err := s.wait(ctx); err != nil {
+			return gmail.MessageMeta{}, Methods{}, err
+		}
+		meta, err := s.Client.GetMetadata(ctx, id, headers)
+		if errors.Is(err, gmail.ErrMessageNotFound) {
+			continue
+		}
+		if err != nil {
+			return gmail.MessageMeta{}, Methods{}, fmt.Errorf("get metadata %s: %w", id, err)
+		}
+		methods := Parse(meta.Headers[HeaderUnsubscribe], meta.Headers[HeaderUnsubscribePost])
+		if !methods.Empty() {
+			return meta, methods, nil
+		}
+	}
+	return gmail.MessageMeta{}, Methods{}, nil
+}
+
+func (s *Service) draft(ctx context.Context, uri string) (string, error) {
+	mailto, err := ParseMailto(uri)
+	if err != nil {
+		return "", err
+	}
+	creator, ok := s.Client.(gmail.DraftCreator)
+	if !ok {
+		return "", gmail.ErrDraftsUnsupported
+	}
+	if err := s.wait(ctx); err != nil {
+		return "", err
+	}
+	id, err := creator.CreateDraft(ctx, mailto.Message())
+	if err != nil {
+		return "", fmt.Errorf("draft unsubscribe to %s: %w", mailto.To, err)
+	}
+	return id, nil
+}
+
+// Verify checks each verifiable attempt of account older than grace for mail from its target received
+// after the grace period, recording the outcome on the attempt. Attempts already found stopped are
+// checked again, since a sender may resume. A drafted attempt is unsent until the mailbox holds sent
+// mail to the draft's recipient dated after the attempt, and its grace period runs from that send.
+func (s *Service) Verify(ctx context.Context, ledger *Ledger, account string, grace time.Duration) error {
+	now := s.Clock()
+	for i := range ledger.Attempts {
+		attempt := &ledger.Attempts[i]
+		if !attempt.Verifiable() || attempt.Account != account {
+			continue
+		}
+		check, err := s.verify(ctx, *attempt, now, grace)
+		if err != nil {
+			return fmt.Errorf("verify %s: %w", attempt.Target, err)
+		}
+		attempt.Verified = &check
+		s.Logger.InfoContext(ctx, "unsubscribe verified",
+			slog.String("target", attempt.Target.String()),
+			slog.String("outcome", string(check.Outcome)),
+		)
+	}
+	return nil
+}
+
+func (s *Service) verify(
+	ctx context.Context,
+	attempt Attempt,
+	now time.Time,
+	grace time.Duration,
+) (Verification, error) {
+	check := Verification{At: now, Outcome: OutcomePending}
+	asked := attempt.At
+	if attempt.Status == StatusDrafted {
+		sentAt, sent, err := s.draftSent(ctx, attempt)
+		if err != nil {
+			return Verification{}, err
+		}
+		if !sent {
+			check.Outcome = OutcomeUnsent
+			return check, nil
+		}
+		asked = sentAt
+	}
+	deadline := asked.Add(grace)
+	if now.Before(deadline) {
+		return check, nil
+	}
+	page, err := s.list(ctx, gmail.Search(attempt.Target.Term(), gmail.After(deadline)), 1)
+	if err != nil {
+		return Verification{}, err
+	}
+	check.Outcome = OutcomeStopped
+	if len(page.IDs) > 0 {
+		check.Outcome, check.MessageID = OutcomeStillSending, string(page.IDs[0])
+	}
+	return check, nil
+}
+
+// draftSent looks for the user's sent mail to the drafted attempt's mailto recipient since the attempt,
+// and returns when the newest such message was sent.
+func (s *Service) draftSent(ctx context.Context, attempt Attempt) (time.Time, bool, error) {
+	mailto, err := ParseMailto(attempt.URI)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	page, err := s.list(ctx, gmail.Search(gmail.In("sent"), gmail.To(mailto.To), gmail.After(attempt.At)), 1)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if len(page.IDs) == 0 {
+		return time.Time{}, false, nil
+	}
+	if err := s.wait(ctx); err != nil {
+		return time.Time{}, false, err
+	}
+	meta, err := s.Client.GetMetadata(ctx, page.IDs[0], []string{"To"})
+	if errors.Is(err, gmail.ErrMessageNotFound) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("get sent message %s: %w", page.IDs[0], err)
+	}
+	if meta.Date.IsZero() {
+		return attempt.At, true, nil
+	}
+	return meta.Date, true, nil
+}
+
+func (s *Service) list(ctx context.Context, q gmail.Query, pageSize int) (gmail.ListPage, error) {
+	if err := s.wait(ctx); err != nil {
+		return gmail.ListPage{}, err
+	}
+	page, err := s.Client.List(ctx, q, "", pageSize)
+	if err != nil {
+		return gmail.ListPage{}, fmt.Errorf("list messages: %w", err)
+	}
+	return page, nil
+}
+
+func (s *Service) wait(ctx context.Context) error {
+	if s.Limiter == nil {
+		return nil
+	}
+	if err := s.Limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limiter: %w", err)
+	}
+	return nil
+}
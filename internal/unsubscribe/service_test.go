@@ -0,0 +1,284 @@
+package unsubscribe
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joshsymonds/chronosweep/internal/gmail"
+)
+
+type fakeUnsubscribeClient struct {
+	results map[string][]gmail.MessageID
+	metas   map[gmail.MessageID]gmail.MessageMeta
+	drafts  [][]byte
+	queries []string
+}
+
+func (f *fakeUnsubscribeClient) List(
+	ctx context.Context,
+	q gmail.Query,
+	pageToken string,
+	pageSize int,
+) (gmail.ListPage, error) {
+	_ = ctx
+	_ = pageToken
+	_ = pageSize
+	f.queries = append(f.queries, q.Raw)
+	for prefix, ids := range f.results {
+		if strings.HasPrefix(q.Raw, prefix) {
+			return gmail.ListPage{IDs: ids}, nil
+		}
+	}
+	return gmail.ListPage{}, nil
+}
+
+func (f *fakeUnsubscribeClient) GetMetadata(
+	ctx context.Context,
+	id gmail.MessageID,
+	headers []string,
+) (gmail.MessageMeta, error) {
+	_ = ctx
+	_ = headers
+	meta, ok := f.metas[id]
+	if !ok {
+		return gmail.MessageMeta{}, gmail.ErrMessageNotFound
+	}
+	return meta, nil
+}
+
+func (f *fakeUnsubscribeClient) BatchModify(ctx context.Context, ids []gmail.MessageID, ops gmail.ModifyOps) error {
+	_ = ctx
+	_ = ids
+	_ = ops
+	return nil
+}
+
+func (f *fakeUnsubscribeClient) ListLabels(
+	ctx context.Context,
+) (map[string]gmail.LabelID, map[gmail.LabelID]string, error) {
+	_ = ctx
+	return nil, nil, nil
+}
+
+func (f *fakeUnsubscribeClient) EnsureLabel(ctx context.Context, name string) (gmail.LabelID, error) {
+	_ = ctx
+	return gmail.LabelID(name), nil
+}
+
+func (f *fakeUnsubscribeClient) CreateDraft(ctx context.Context, raw []byte) (string, error) {
+	_ = ctx
+	f.drafts = append(f.drafts, raw)
+	return "draft-1", nil
+}
+
+func unsubscribeMeta(id, header, post string) gmail.MessageMeta {
+	return gmail.MessageMeta{
+		ID:      gmail.MessageID(id),
+		Headers: map[string]string{HeaderUnsubscribe: header, HeaderUnsubscribePost: post},
+	}
+}
+
+func TestServiceUnsubscribe(t *testing.T) {
+	t.Parallel()
+	var posts atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	client := &fakeUnsubscribeClient{
+		results: map[string][]gmail.MessageID{
+			"list:news.example.com": {"n1"},
+			"from:@shop.example":    {"s-plain", "s1"},
+			"from:@web.example":     {"w1"},
+			"list:broken.example":   {"b1"},
+			"from:@silent.example":  {"x1"},
+		},
+		metas: map[gmail.MessageID]gmail.MessageMeta{
+			"n1": unsubscribeMeta("n1", "<"+server.URL+"/u/news>", "List-Unsubscribe=One-Click"),
+			// The newest shop message lacks the header; an older one carries it.
+			"s-plain": unsubscribeMeta("s-plain", "", ""),
+			"s1":      unsubscribeMeta("s1", "<mailto:leave@shop.example?subject=stop>", ""),
+			"w1":      unsubscribeMeta("w1", "<https://web.example/prefs>", ""),
+			"b1":      unsubscribeMeta("b1", "<"+server.URL+"/broken>", "List-Unsubscribe=One-Click"),
+			"x1":      unsubscribeMeta("x1", "", ""),
+		},
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewService(client, NewPoster(server.Client()), nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	svc.Clock = func() time.Time { return now }
+
+	targets := []Target{
+		{Kind: KindList, Value: "news.example.com"},
+		{Kind: KindSender, Value: "shop.example"},
+		{Kind: KindSender, Value: "web.example"},
+		{Kind: KindList, Value: "broken.example"},
+		{Kind: KindSender, Value: "silent.example"},
+	}
+	attempts, err := svc.Unsubscribe(context.Background(), targets, Options{Window: 30 * 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	want := []struct {
+		mechanism Mechanism
+		status    Status
+		message   string
+	}{
+		{MechanismOneClick, StatusSent, "n1"},
+		{MechanismMailto, StatusDrafted, "s1"},
+		{MechanismHTTPS, StatusManual, "w1"},
+		{MechanismOneClick, StatusFailed, "b1"},
+		{"", StatusUnavailable, ""},
+	}
+	if len(attempts) != len(want) {
+		t.Fatalf("got %d attempts, want %d", len(attempts), len(want))
+	}
+	for i, w := range want {
+		got := attempts[i]
+		if got.Mechanism != w.mechanism || got.Status != w.status || got.MessageID != w.message {
+			t.Fatalf("attempt %d = %+v, want %+v", i, got, w)
+		}
+		if !got.At.Equal(now) {
+			t.Fatalf("attempt %d at %s, want %s", i, got.At, now)
+		}
+	}
+	if attempts[1].DraftID != "draft-1" || len(client.drafts) != 1 {
+		t.Fatalf("draft id %q, %d drafts", attempts[1].DraftID, len(client.drafts))
+	}
+	if !strings.Contains(string(client.drafts[0]), "To: leave@shop.example\r\n") {
+		t.Fatalf("draft = %q", client.drafts[0])
+	}
+	if !strings.Contains(attempts[3].Error, "rejected") {
+		t.Fatalf("failed attempt error = %q", attempts[3].Error)
+	}
+	if got := posts.Load(); got != 2 {
+		t.Fatalf("posts = %d, want 2", got)
+	}
+}
+
+func TestServiceUnsubscribeDryRun(t *testing.T) {
+	t.Parallel()
+	client := &fakeUnsubscribeClient{
+		results: map[string][]gmail.MessageID{"list:news.example.com": {"n1"}},
+		metas: map[gmail.MessageID]gmail.MessageMeta{
+			"n1": unsubscribeMeta("n1", "<https://127.0.0.1:1/u>", "List-Unsubscribe=One-Click"),
+		},
+	}
+	svc := NewService(client, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	attempts, err := svc.Unsubscribe(
+		context.Background(),
+		[]Target{{Kind: KindList, Value: "news.example.com"}},
+		Options{Window: time.Hour, DryRun: true},
+	)
+	if err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	if len(attempts) != 1 || attempts[0].Status != StatusDryRun || attempts[0].Mechanism != MechanismOneClick {
+		t.Fatalf("attempts = %+v", attempts)
+	}
+	if attempts[0].Verifiable() {
+		t.Fatalf("dry-run attempt must not be verifiable")
+	}
+}
+
+func TestServiceVerify(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	grace := 72 * time.Hour
+	old := now.AddDate(0, 0, -5)
+	client := &fakeUnsubscribeClient{
+		results: map[string][]gmail.MessageID{
+			"from:@loud.example":             {"l9"},
+			"in:sent to:unsub@loud.example":  {"s1"},
+			"in:sent to:unsub@late.example":  {"s2"},
+			"in:sent to:unsub@draft.example": nil,
+		},
+		metas: map[gmail.MessageID]gmail.MessageMeta{
+			"s1": {ID: "s1", Date: old.Add(time.Hour)},
+			"s2": {ID: "s2", Date: now.Add(-time.Hour)},
+		},
+	}
+	svc := NewService(client, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	svc.Clock = func() time.Time { return now }
+	attempt := func(account, kind, value string, status Status, at time.Time) Attempt {
+		a := Attempt{Account: account, Target: Target{Kind: kind, Value: value}, Status: status, At: at}
+		if status == StatusDrafted {
+			a.Mechanism, a.URI = MechanismMailto, "mailto:unsub@"+value
+		}
+		return a
+	}
+	ledger := &Ledger{Attempts: []Attempt{
+		attempt("me", KindList, "quiet.example", StatusSent, old),
+		attempt("me", KindSender, "loud.example", StatusDrafted, old),
+		attempt("me", KindList, "recent.example", StatusSent, now.Add(-time.Hour)),
+		attempt("me", KindList, "manual.example", StatusManual, old),
+		attempt("other", KindList, "quiet.example", StatusSent, old),
+		attempt("me", KindSender, "draft.example", StatusDrafted, old),
+		attempt("me", KindSender, "late.example", StatusDrafted, old),
+	}}
+	if err := svc.Verify(context.Background(), ledger, "me", grace); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	outcomes := []Outcome{
+		OutcomeStopped, OutcomeStillSending, OutcomePending, "", "",
+		// A draft never sent asked nothing; one sent an hour ago is still in its grace period.
+		OutcomeUnsent, OutcomePending,
+	}
+	for i, want := range outcomes {
+		got := ledger.Attempts[i].Verified
+		if want == "" {
+			if got != nil {
+				t.Fatalf("attempt %d verified = %+v, want none", i, got)
+			}
+			continue
+		}
+		if got == nil || got.Outcome != want {
+			t.Fatalf("attempt %d verified = %+v, want %s", i, got, want)
+		}
+	}
+	if ledger.Attempts[1].Verified.MessageID != "l9" {
+		t.Fatalf("still-sending evidence = %q", ledger.Attempts[1].Verified.MessageID)
+	}
+	deadline := ledger.Attempts[0].At.Add(grace).Unix()
+	wantQuery := "list:quiet.example after:" + strconv.FormatInt(deadline, 10)
+	if client.queries[0] != wantQuery {
+		t.Fatalf("verify query = %q, want %q", client.queries[0], wantQuery)
+	}
+	if len(client.queries) != 5 {
+		t.Fatalf("queries = %v, want 5", client.queries)
+	}
+}
+
+func TestServiceMissingDraftSupport(t *testing.T) {
+	t.Parallel()
+	// Hiding the fake behind gmail.Client drops its CreateDraft.
+	client := struct{ gmail.Client }{&fakeUnsubscribeClient{
+		results: map[string][]gmail.MessageID{"from:@shop.example": {"s1"}},
+		metas: map[gmail.MessageID]gmail.MessageMeta{
+			"s1": unsubscribeMeta("s1", "<mailto:leave@shop.example>", ""),
+		},
+	}}
+	svc := NewService(client, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	attempts, err := svc.Unsubscribe(
+		context.Background(),
+		[]Target{{Kind: KindSender, Value: "shop.example"}},
+		Options{Window: time.Hour},
+	)
+	if err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	if attempts[0].Status != StatusFailed || !strings.Contains(attempts[0].Error, gmail.ErrDraftsUnsupported.Error()) {
+		t.Fatalf("attempt = %+v", attempts[0])
+	}
+}